@@ -0,0 +1,342 @@
+package logf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultAsyncQueueSize = 1024
+	defaultAsyncWorkers   = 1
+)
+
+// OverflowPolicy tells AsyncHandler what to do when its queue is full.
+type OverflowPolicy int8
+
+// Overflow policies.
+const (
+	// OverflowBlock makes Handle wait until a worker frees a slot. No
+	// entry is lost, but a stalled downstream stalls the callers.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest discards the entry being handled.
+	OverflowDropNewest
+	// OverflowDropOldest discards the oldest queued entry to make room.
+	OverflowDropOldest
+)
+
+// NewAsyncHandler returns a Handler middleware that moves the downstream
+// Handler — encoding included — off the caller's goroutine. SlabWriter
+// already isolates I/O, but encoding still runs in the caller; with
+// expensive ObjectEncoder or Any fields that shows up in tail latency.
+// AsyncHandler copies each entry into a bounded lock-free queue and a
+// pool of worker goroutines runs the downstream Handler (typically a
+// Router):
+//
+//	async := logf.NewAsyncHandler(router,
+//	    logf.AsyncQueueSize(4096),
+//	    logf.AsyncOverflow(logf.OverflowDropOldest),
+//	)
+//	defer async.Close()
+//	logger := logf.New(logf.NewContextHandler(async))
+//
+// Place it after ContextHandler: the context is handed to the workers
+// as is, but by then the entry already carries its Bag. Entry fields
+// are deep-copied, so the caller is free to reuse the memory behind
+// ByteString, Bytes, Strings, Ints64 and friends as soon as the log call
+// returns. Values behind interfaces (Any, Object, Array, Error) are
+// copied by reference and must not be mutated after logging.
+//
+// With a single worker (the default) entries reach the downstream
+// Handler in order. More workers encode in parallel and the order is no
+// longer guaranteed. Always Close the handler to drain the queue.
+func NewAsyncHandler(next Handler, opts ...AsyncOption) *AsyncHandler {
+	h := &AsyncHandler{
+		next:      next,
+		queueSize: defaultAsyncQueueSize,
+		workers:   defaultAsyncWorkers,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.q = newAsyncQueue(h.queueSize)
+	h.notify = make(chan struct{}, 1)
+	h.space = make(chan struct{}, 1)
+	h.stop = make(chan struct{})
+	h.wg.Add(h.workers)
+	for i := 0; i < h.workers; i++ {
+		go h.worker()
+	}
+	return h
+}
+
+// AsyncHandler is the Handler built by NewAsyncHandler.
+type AsyncHandler struct {
+	next      Handler
+	queueSize int
+	workers   int
+	overflow  OverflowPolicy
+
+	q      *asyncQueue
+	notify chan struct{} // wakes an idle worker
+	space  chan struct{} // wakes a producer blocked on a full queue
+	stop   chan struct{} // closed by Close
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	// Handle calls hold closeMu for reading, so that Close can wait for
+	// them to finish enqueueing.
+	closeMu sync.RWMutex
+	closed  bool
+
+	enqueued atomic.Int64
+	handled  atomic.Int64
+	dropped  atomic.Int64
+	errors   atomic.Int64
+}
+
+// AsyncStats is a snapshot of AsyncHandler counters.
+type AsyncStats struct {
+	Queued   int   // entries waiting in the queue
+	Enqueued int64 // entries accepted by Handle
+	Handled  int64 // entries processed by the downstream Handler
+	Dropped  int64 // entries discarded by the overflow policy
+	Errors   int64 // errors returned by the downstream Handler
+}
+
+// AsyncOption configures an AsyncHandler at creation time.
+type AsyncOption func(*AsyncHandler)
+
+// AsyncQueueSize sets the queue capacity in entries (default 1024). It
+// is rounded up to a power of two, at least 2.
+func AsyncQueueSize(n int) AsyncOption {
+	return func(h *AsyncHandler) {
+		if n > 0 {
+			h.queueSize = n
+		}
+	}
+}
+
+// AsyncWorkers sets the number of worker goroutines (default 1).
+func AsyncWorkers(n int) AsyncOption {
+	return func(h *AsyncHandler) {
+		if n > 0 {
+			h.workers = n
+		}
+	}
+}
+
+// AsyncOverflow sets what happens when the queue is full (default
+// OverflowBlock).
+func AsyncOverflow(p OverflowPolicy) AsyncOption {
+	return func(h *AsyncHandler) {
+		h.overflow = p
+	}
+}
+
+// Enabled delegates to the downstream Handler.
+func (h *AsyncHandler) Enabled(ctx context.Context, lvl Level) bool {
+	return h.next.Enabled(ctx, lvl)
+}
+
+// EnabledName forwards the logger name to the downstream Handler.
+func (h *AsyncHandler) EnabledName(ctx context.Context, name string, lvl Level) bool {
+	return enabledName(h.next, ctx, name, lvl)
+}
+
+// Handle copies the entry into the queue and returns without waiting for
+// the downstream Handler. Errors returned by the downstream Handler are
+// only counted in Stats. Handle returns an error after Close.
+func (h *AsyncHandler) Handle(ctx context.Context, e Entry) error {
+	h.closeMu.RLock()
+	defer h.closeMu.RUnlock()
+	if h.closed {
+		return errors.New("logf: async handler is closed")
+	}
+
+	e.Fields = cloneFields(e.Fields)
+	it := asyncItem{ctx: ctx, e: e}
+
+	for !h.q.push(it) {
+		switch h.overflow {
+		case OverflowDropNewest:
+			h.dropped.Add(1)
+			return nil
+		case OverflowDropOldest:
+			if _, ok := h.q.pop(); ok {
+				h.dropped.Add(1)
+			}
+		default:
+			// Workers keep draining until Close, which waits for us.
+			<-h.space
+		}
+	}
+	h.enqueued.Add(1)
+	if h.overflow == OverflowBlock && h.q.len() < h.q.cap() {
+		// Pass the wake-up on to the next blocked producer, if any.
+		wake(h.space)
+	}
+	wake(h.notify)
+
+	return nil
+}
+
+// Stats returns a snapshot of the handler counters.
+func (h *AsyncHandler) Stats() AsyncStats {
+	return AsyncStats{
+		Queued:   h.q.len(),
+		Enqueued: h.enqueued.Load(),
+		Handled:  h.handled.Load(),
+		Dropped:  h.dropped.Load(),
+		Errors:   h.errors.Load(),
+	}
+}
+
+// Close stops accepting entries, waits for the workers to drain the
+// queue and stops them. It does not close the downstream Handler. Safe
+// to call multiple times.
+func (h *AsyncHandler) Close() error {
+	h.once.Do(func() {
+		// Wait for in-progress Handle calls to finish enqueueing.
+		h.closeMu.Lock()
+		h.closed = true
+		h.closeMu.Unlock()
+		close(h.stop)
+		h.wg.Wait()
+	})
+	return nil
+}
+
+func (h *AsyncHandler) worker() {
+	defer h.wg.Done()
+	for {
+		if h.process() {
+			continue
+		}
+		select {
+		case <-h.notify:
+		case <-h.stop:
+			for h.process() {
+			}
+			return
+		}
+	}
+}
+
+// process handles one queued entry and reports whether there was one.
+func (h *AsyncHandler) process() bool {
+	it, ok := h.q.pop()
+	if !ok {
+		return false
+	}
+	wake(h.space)
+	if h.q.len() > 0 {
+		// Keep the other workers busy.
+		wake(h.notify)
+	}
+	if err := h.next.Handle(it.ctx, it.e); err != nil {
+		h.errors.Add(1)
+	}
+	h.handled.Add(1)
+	return true
+}
+
+// wake does a non-blocking send on a wake-up channel.
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+type asyncItem struct {
+	ctx context.Context
+	e   Entry
+}
+
+// asyncQueue is a bounded lock-free multi-producer multi-consumer queue
+// (Dmitry Vyukov's design). Every cell carries a sequence number that
+// tells producers and consumers whether it is free or filled for their
+// current lap, so a single CAS on the position claims a cell.
+type asyncQueue struct {
+	cells []asyncCell
+	mask  uint64
+	_     [56]byte // keep enq and deq on separate cache lines
+	enq   atomic.Uint64
+	_     [56]byte
+	deq   atomic.Uint64
+}
+
+type asyncCell struct {
+	seq atomic.Uint64
+	it  asyncItem
+}
+
+func newAsyncQueue(size int) *asyncQueue {
+	// With a single cell "filled" and "free for the next lap" would share
+	// a sequence number, so the queue holds at least two.
+	n := 2
+	for n < size {
+		n <<= 1
+	}
+	q := &asyncQueue{cells: make([]asyncCell, n), mask: uint64(n - 1)}
+	for i := range q.cells {
+		q.cells[i].seq.Store(uint64(i))
+	}
+	return q
+}
+
+// push appends it to the queue. Returns false if the queue is full.
+func (q *asyncQueue) push(it asyncItem) bool {
+	pos := q.enq.Load()
+	for {
+		c := &q.cells[pos&q.mask]
+		seq := c.seq.Load()
+		switch dif := int64(seq - pos); {
+		case dif == 0:
+			if q.enq.CompareAndSwap(pos, pos+1) {
+				c.it = it
+				c.seq.Store(pos + 1)
+				return true
+			}
+		case dif < 0:
+			return false
+		}
+		pos = q.enq.Load()
+	}
+}
+
+// pop removes the oldest item. Returns false if the queue is empty.
+func (q *asyncQueue) pop() (asyncItem, bool) {
+	pos := q.deq.Load()
+	for {
+		c := &q.cells[pos&q.mask]
+		seq := c.seq.Load()
+		switch dif := int64(seq - (pos + 1)); {
+		case dif == 0:
+			if q.deq.CompareAndSwap(pos, pos+1) {
+				it := c.it
+				c.it = asyncItem{}
+				c.seq.Store(pos + q.mask + 1)
+				return it, true
+			}
+		case dif < 0:
+			return asyncItem{}, false
+		}
+		pos = q.deq.Load()
+	}
+}
+
+// len returns the approximate number of queued items.
+func (q *asyncQueue) len() int {
+	n := int64(q.enq.Load() - q.deq.Load())
+	if n < 0 {
+		return 0
+	}
+	return int(n)
+}
+
+func (q *asyncQueue) cap() int {
+	return len(q.cells)
+}
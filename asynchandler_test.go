@@ -0,0 +1,232 @@
+package logf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingHandler blocks every Handle call until release is closed.
+type blockingHandler struct {
+	syncTestHandler
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, e Entry) error {
+	wake(h.started)
+	<-h.release
+	return h.syncTestHandler.Handle(ctx, e)
+}
+
+func entryTexts(entries []Entry) []string {
+	texts := make([]string, len(entries))
+	for i, e := range entries {
+		texts[i] = e.Text
+	}
+	return texts
+}
+
+func TestAsyncHandlerPassesInOrder(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewAsyncHandler(sink)
+
+	for _, text := range []string{"a", "b", "c"} {
+		require.NoError(t, h.Handle(context.Background(), Entry{Level: LevelInfo, Text: text}))
+	}
+	require.NoError(t, h.Close())
+
+	assert.Equal(t, []string{"a", "b", "c"}, entryTexts(sink.Entries()))
+	assert.Equal(t, AsyncStats{Enqueued: 3, Handled: 3}, h.Stats())
+}
+
+func TestAsyncHandlerCopiesFields(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewAsyncHandler(sink)
+
+	b := []byte("hello")
+	ss := []string{"x", "y"}
+	require.NoError(t, h.Handle(context.Background(), Entry{
+		Level:  LevelInfo,
+		Fields: []Field{ByteString("b", b), Strings("ss", ss)},
+	}))
+	copy(b, "XXXXX")
+	ss[0] = "changed"
+	require.NoError(t, h.Close())
+
+	entries := sink.Entries()
+	require.Len(t, entries, 1)
+	enc := NewJSONEncoder(JSONEncoderConfig{DisableFieldTime: true, DisableFieldLevel: true, DisableFieldMsg: true})
+	buf, err := enc.Encode(entries[0])
+	require.NoError(t, err)
+	assert.Equal(t, `{"b":"hello","ss":["x","y"]}`+"\n", buf.String())
+}
+
+func TestAsyncHandlerOverflowDropNewest(t *testing.T) {
+	sink := newBlockingHandler()
+	h := NewAsyncHandler(sink, AsyncQueueSize(2), AsyncOverflow(OverflowDropNewest))
+
+	require.NoError(t, h.Handle(context.Background(), Entry{Text: "0"}))
+	<-sink.started // the worker holds "0"; the queue is empty
+	for _, text := range []string{"1", "2", "3", "4"} {
+		require.NoError(t, h.Handle(context.Background(), Entry{Text: text}))
+	}
+	close(sink.release)
+	require.NoError(t, h.Close())
+
+	assert.Equal(t, []string{"0", "1", "2"}, entryTexts(sink.Entries()))
+	assert.EqualValues(t, 2, h.Stats().Dropped)
+}
+
+func TestAsyncHandlerOverflowDropOldest(t *testing.T) {
+	sink := newBlockingHandler()
+	h := NewAsyncHandler(sink, AsyncQueueSize(2), AsyncOverflow(OverflowDropOldest))
+
+	require.NoError(t, h.Handle(context.Background(), Entry{Text: "0"}))
+	<-sink.started
+	for _, text := range []string{"1", "2", "3", "4"} {
+		require.NoError(t, h.Handle(context.Background(), Entry{Text: text}))
+	}
+	close(sink.release)
+	require.NoError(t, h.Close())
+
+	assert.Equal(t, []string{"0", "3", "4"}, entryTexts(sink.Entries()))
+	assert.EqualValues(t, 2, h.Stats().Dropped)
+}
+
+func TestAsyncHandlerOverflowBlock(t *testing.T) {
+	sink := newBlockingHandler()
+	h := NewAsyncHandler(sink, AsyncQueueSize(2))
+
+	require.NoError(t, h.Handle(context.Background(), Entry{Text: "0"}))
+	<-sink.started
+	require.NoError(t, h.Handle(context.Background(), Entry{Text: "1"}))
+	require.NoError(t, h.Handle(context.Background(), Entry{Text: "2"}))
+
+	done := make(chan struct{})
+	go func() {
+		_ = h.Handle(context.Background(), Entry{Text: "3"})
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Handle must block while the queue is full")
+	default:
+	}
+
+	close(sink.release)
+	<-done
+	require.NoError(t, h.Close())
+
+	assert.Equal(t, []string{"0", "1", "2", "3"}, entryTexts(sink.Entries()))
+	assert.Zero(t, h.Stats().Dropped)
+}
+
+func TestAsyncHandlerCloseWaitsForBlockedProducer(t *testing.T) {
+	sink := newBlockingHandler()
+	h := NewAsyncHandler(sink, AsyncQueueSize(2))
+
+	require.NoError(t, h.Handle(context.Background(), Entry{Text: "0"}))
+	<-sink.started
+	require.NoError(t, h.Handle(context.Background(), Entry{Text: "1"}))
+	require.NoError(t, h.Handle(context.Background(), Entry{Text: "2"}))
+
+	blocked := make(chan error)
+	go func() {
+		blocked <- h.Handle(context.Background(), Entry{Text: "3"})
+	}()
+	// The producer holds closeMu while it waits for space.
+	require.Eventually(t, func() bool {
+		if h.closeMu.TryLock() {
+			h.closeMu.Unlock()
+			return false
+		}
+		return true
+	}, time.Second, time.Millisecond)
+	closed := make(chan struct{})
+	go func() {
+		_ = h.Close()
+		close(closed)
+	}()
+
+	close(sink.release)
+	require.NoError(t, <-blocked)
+	<-closed
+	assert.Equal(t, []string{"0", "1", "2", "3"}, entryTexts(sink.Entries()))
+}
+
+func TestAsyncHandlerCountsErrors(t *testing.T) {
+	h := NewAsyncHandler(handlerFunc(func(context.Context, Entry) error {
+		return errors.New("boom")
+	}))
+	require.NoError(t, h.Handle(context.Background(), Entry{}))
+	require.NoError(t, h.Close())
+
+	assert.EqualValues(t, 1, h.Stats().Errors)
+}
+
+func TestAsyncHandlerClosed(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewAsyncHandler(sink)
+	require.NoError(t, h.Close())
+	require.NoError(t, h.Close())
+
+	assert.Error(t, h.Handle(context.Background(), Entry{}))
+	assert.Empty(t, sink.Entries())
+}
+
+func TestAsyncHandlerConcurrent(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewAsyncHandler(sink, AsyncQueueSize(16), AsyncWorkers(4))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Fields: []Field{Int("i", i)}})
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, h.Close())
+
+	assert.Len(t, sink.Entries(), 8*500)
+	assert.Equal(t, AsyncStats{Enqueued: 8 * 500, Handled: 8 * 500}, h.Stats())
+}
+
+func TestAsyncQueue(t *testing.T) {
+	require.Equal(t, 2, newAsyncQueue(1).cap())
+
+	q := newAsyncQueue(3)
+	require.Equal(t, 4, q.cap())
+
+	for i := 0; i < 4; i++ {
+		require.True(t, q.push(asyncItem{e: Entry{CallerPC: uintptr(i)}}))
+	}
+	assert.False(t, q.push(asyncItem{}))
+	assert.Equal(t, 4, q.len())
+
+	for lap := 0; lap < 3; lap++ {
+		it, ok := q.pop()
+		require.True(t, ok)
+		require.True(t, q.push(it))
+	}
+	for _, want := range []uintptr{3, 0, 1, 2} {
+		it, ok := q.pop()
+		require.True(t, ok)
+		assert.Equal(t, want, it.e.CallerPC)
+	}
+	_, ok := q.pop()
+	assert.False(t, ok)
+}
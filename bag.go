@@ -47,6 +47,9 @@ type Bag struct {
 	parent *Bag
 	group  string // group name; empty = no group
 	cache  atomic.Pointer[bagCache]
+
+	// redacted memoizes the mirror of this node built by a RedactHandler.
+	redacted atomic.Pointer[bagRedaction]
 }
 
 // NewBag creates a root Bag node with the given fields. Most of the time
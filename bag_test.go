@@ -134,3 +134,32 @@ func TestBagGroupNil(t *testing.T) {
 	var bag *Bag
 	assert.Equal(t, "", bag.Group())
 }
+
+// withCacheSlot gives enc a Bag cache slot even when the few the package
+// hands out are taken by encoders built in other tests. Bags encoded with
+// it must not be encoded by another encoder.
+func withCacheSlot(enc Encoder) Encoder {
+	switch e := enc.(type) {
+	case *jsonEncoder:
+		e.slot = maxCacheSlots
+	case *textEncoder:
+		e.slot = maxCacheSlots
+	case *logfmtEncoder:
+		e.slot = maxCacheSlots
+	case *gelfEncoder:
+		e.slot = maxCacheSlots
+	case *syslogEncoder:
+		e.slot = maxCacheSlots
+	case *cborEncoder:
+		e.slot = maxCacheSlots
+	case *msgpackEncoder:
+		e.slot = maxCacheSlots
+	case *patternEncoder:
+		// One slot per format of %fields.
+		e.json.slot = 1
+		if e.logfmt != nil {
+			e.logfmt.slot = 2
+		}
+	}
+	return enc
+}
@@ -2,8 +2,10 @@ package logf
 
 import (
 	"context"
+	"errors"
 	"io"
 	"testing"
+	"time"
 )
 
 func benchLogger(lvl Level, addCaller bool) (*Logger, func() error) {
@@ -150,3 +152,174 @@ func BenchmarkRealisticPipeline(b *testing.B) {
 		l.Info(ctx, "request handled", String("status", "ok"), Int("code", 200))
 	}
 }
+
+// --- NamedLevels ---
+
+func BenchmarkNamedLevelsDisabled(b *testing.B) {
+	n := NewNamedLevels(NewSyncHandler(LevelDebug, io.Discard, JSON().Build()), LevelInfo)
+	n.Set("db", LevelDebug)
+	logger := New(NewContextHandler(n)).WithName("http").WithName("server")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debug(benchCtx, "request handled")
+	}
+}
+
+// --- WithLevel ---
+
+func BenchmarkWithLevelDisabled(b *testing.B) {
+	h := NewSyncHandler(LevelInfo, io.Discard, JSON().Build())
+	logger := New(NewContextHandler(h))
+	ctx := WithLevel(benchCtx, LevelWarn)
+
+	b.Run("NoOverride", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			logger.Debug(benchCtx, "request handled")
+		}
+	})
+	b.Run("Override", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			logger.Info(ctx, "request handled")
+		}
+	})
+}
+
+// --- RedactHandler ---
+
+func BenchmarkRedactHandler(b *testing.B) {
+	h := NewRedactHandler(handlerFunc(func(context.Context, Entry) error { return nil }),
+		RedactKeys("password", "http.headers.*"),
+		RedactDetect(DetectEmails, DetectCardNumbers, DetectBearerTokens),
+	)
+	e := Entry{
+		Level:     LevelInfo,
+		Text:      "request handled",
+		LoggerBag: NewBag(String("service", "api")).WithGroup("http"),
+		Fields:    []Field{String("path", "/api/v1/users"), Int("status", 200)},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = h.Handle(benchCtx, e)
+	}
+}
+
+// --- Encoders ---
+
+// benchEntry returns an entry with a logger Bag group and the fields of
+// benchFields, as most encoder benchmarks see it.
+func benchEntry() Entry {
+	return Entry{
+		Level:      LevelInfo,
+		Time:       time.Now(),
+		LoggerName: "http",
+		Text:       "request handled",
+		LoggerBag:  NewBag(String("service", "api")).WithGroup("http"),
+		Fields:     benchFields(),
+	}
+}
+
+func benchEncode(b *testing.B, enc Encoder, e Entry) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, err := enc.Encode(e)
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf.Free()
+	}
+}
+
+func BenchmarkLogfmtEncoder(b *testing.B) {
+	e := benchEntry()
+	e.Fields = append(e.Fields, Strings("tags", []string{"a", "b"}))
+	benchEncode(b, Logfmt().Build(), e)
+}
+
+func BenchmarkGELFEncoder(b *testing.B) {
+	benchEncode(b, GELF().Host("h").Build(), benchEntry())
+}
+
+func BenchmarkSyslogEncoder(b *testing.B) {
+	b.Run("Message", func(b *testing.B) {
+		enc := Syslog().Hostname("h").AppName("a").ProcID("1").Build()
+		benchEncode(b, enc, benchEntry())
+	})
+	b.Run("StructuredData", func(b *testing.B) {
+		enc := Syslog().Hostname("h").AppName("a").ProcID("1").StructuredDataID("app@12345").Build()
+		benchEncode(b, enc, benchEntry())
+	})
+}
+
+func BenchmarkJSONEncoderECS(b *testing.B) {
+	e := benchEntry()
+	e.Level = LevelError
+	e.Fields = append(e.Fields, Error(errors.New("boom")))
+	benchEncode(b, JSON().ECS().Build(), e)
+}
+
+func BenchmarkPatternEncoder(b *testing.B) {
+	enc := Pattern("%time{15:04:05.000} %level{upper,5} [%name{>8.8}] %msg %fields").Build()
+	benchEncode(b, enc, benchEntry())
+}
+
+func BenchmarkMsgPackEncoder(b *testing.B) {
+	e := benchEntry()
+	e.Fields = append(e.Fields, Group("req", Int("size", 10)), Strings("tags", []string{"a"}))
+	benchEncode(b, MsgPack().Build(), e)
+}
+
+func BenchmarkCBOREncoder(b *testing.B) {
+	e := benchEntry()
+	e.Fields = append(e.Fields, Group("req", Int("size", 10)), Ints64("ids", []int64{1, 2}))
+	benchEncode(b, CBOR().Build(), e)
+}
+
+func BenchmarkTextEncoderEscaping(b *testing.B) {
+	e := benchEntry()
+	e.Text = "user input: \x1b[2J\nforged"
+	e.Fields = append(e.Fields, String("name", "bob\u009b"), String("note", "a b\n"))
+	benchEncode(b, Text().Build(), e)
+}
+
+// --- Duplicate keys ---
+
+func BenchmarkJSONEncoderDuplicateKeys(b *testing.B) {
+	e := Entry{
+		Level:     LevelInfo,
+		Text:      "request handled",
+		Bag:       NewBag(String("request_id", "abc-123")),
+		LoggerBag: NewBag(String("service", "api")).WithGroup("http"),
+		Fields:    []Field{String("method", "GET"), Int("status", 200), Int("status", 500)},
+	}
+	policies := []struct {
+		name   string
+		policy DuplicateKeyPolicy
+	}{
+		{"Keep", KeepDuplicateKeys},
+		{"LastWins", LastKeyWins},
+		{"Rename", RenameDuplicateKeys},
+	}
+	for _, p := range policies {
+		enc := JSON().DuplicateKeys(p.policy).Build()
+		b.Run(p.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				buf, _ := enc.Encode(e)
+				buf.Free()
+			}
+		})
+	}
+}
+
+// --- Size limits ---
+
+func BenchmarkJSONEncoderLimits(b *testing.B) {
+	enc := JSON().Limits(SizeLimits{MaxStringLength: 8, MaxArrayLength: 2, MaxEntrySize: 128}).Build()
+	e := Entry{
+		Level:  LevelInfo,
+		Text:   "request served",
+		Fields: []Field{String("path", "/a/very/long/path"), Array("a", testCountArray(10)), Ints64("i", []int64{1, 2, 3})},
+	}
+	benchEncode(b, enc, e)
+}
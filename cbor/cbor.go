@@ -0,0 +1,483 @@
+// Package cbor renders CBOR logs, as written by the logf CBOR encoder,
+// back to JSON for humans and JSON tooling:
+//
+//	err := cbor.Convert(os.Stdout, f) // one JSON object per line
+//
+// It understands the subset of CBOR (RFC 8949) that loggers produce:
+// integers, floats, byte and text strings, arrays and maps of definite
+// and indefinite length, and simple values. Tags are rendered as their
+// content, except epoch-based date/time (tag 1), which becomes an RFC 3339
+// string, and typed arrays (RFC 8746), which become JSON arrays. Byte
+// strings become base64 strings, the JSON encoder's form of Bytes.
+package cbor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// maxDepth limits the nesting of arrays, maps and tags.
+const maxDepth = 1000
+
+// errBreak is returned by item for the break stop code of
+// indefinite-length items.
+var errBreak = errors.New("cbor: unexpected break")
+
+// AppendJSON decodes the first CBOR data item of src, appends its JSON
+// form to dst and returns the extended buffer and the rest of src. It
+// returns io.EOF for an empty src and io.ErrUnexpectedEOF for a
+// truncated one.
+func AppendJSON(dst, src []byte) ([]byte, []byte, error) {
+	r := bytes.NewReader(src)
+	d := decoder{r: r}
+	dst, err := d.item(dst, 0)
+	if err != nil {
+		return dst, src, err
+	}
+	return dst, src[len(src)-r.Len():], nil
+}
+
+// Decoder reads a sequence of CBOR data items (RFC 8742) from a stream.
+type Decoder struct {
+	d decoder
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Decoder{decoder{r: br}}
+}
+
+// Decode reads the next data item and appends its JSON form to dst. It
+// returns io.EOF when the stream ends between items, and
+// io.ErrUnexpectedEOF when it ends inside one.
+func (d *Decoder) Decode(dst []byte) ([]byte, error) {
+	if _, err := d.d.r.ReadByte(); err != nil {
+		return dst, err
+	}
+	_ = d.d.r.UnreadByte()
+
+	dst, err := d.d.item(dst, 0)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return dst, err
+}
+
+// Convert renders every data item read from r as one line of JSON
+// written to w.
+func Convert(w io.Writer, r io.Reader) error {
+	d := NewDecoder(r)
+	var line []byte
+	for {
+		var err error
+		line, err = d.Decode(line[:0])
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteScanner
+}
+
+type decoder struct {
+	r byteReader
+}
+
+// head reads the initial byte of a data item and its argument. For
+// indefinite lengths indefinite is true.
+func (d *decoder) head() (major byte, info byte, arg uint64, indefinite bool, err error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, false, err
+	}
+	major, info = b>>5, b&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), false, nil
+	case info <= 27:
+		var p [8]byte
+		n := 1 << (info - 24)
+		if _, err = io.ReadFull(d.r, p[8-n:]); err != nil {
+			return 0, 0, 0, false, noEOF(err)
+		}
+		return major, info, binary.BigEndian.Uint64(p[:]), false, nil
+	case info == 31 && major >= 2 && major != 6:
+		return major, info, 0, true, nil
+	default:
+		return 0, 0, 0, false, fmt.Errorf("cbor: invalid initial byte 0x%02x", b)
+	}
+}
+
+// item appends the JSON form of the next data item.
+func (d *decoder) item(dst []byte, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return dst, errors.New("cbor: nesting too deep")
+	}
+	major, info, arg, indefinite, err := d.head()
+	if err != nil {
+		return dst, err
+	}
+
+	switch major {
+	case 0:
+		return strconv.AppendUint(dst, arg, 10), nil
+	case 1:
+		if arg == math.MaxUint64 {
+			return append(dst, "-18446744073709551616"...), nil
+		}
+		dst = append(dst, '-')
+		return strconv.AppendUint(dst, arg+1, 10), nil
+	case 2:
+		b, err := d.str(2, arg, indefinite)
+		if err != nil {
+			return dst, err
+		}
+		n := len(dst) + 1
+		dst = append(dst, make([]byte, base64.StdEncoding.EncodedLen(len(b))+2)...)
+		dst[n-1] = '"'
+		base64.StdEncoding.Encode(dst[n:], b)
+		dst[len(dst)-1] = '"'
+		return dst, nil
+	case 3:
+		s, err := d.str(3, arg, indefinite)
+		if err != nil {
+			return dst, err
+		}
+		return appendString(dst, s), nil
+	case 4:
+		dst = append(dst, '[')
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if i != 0 {
+				dst = append(dst, ',')
+			}
+			dst, err = d.item(dst, depth+1)
+			if err == errBreak && indefinite {
+				if i != 0 {
+					dst = dst[:len(dst)-1]
+				}
+				break
+			}
+			if err != nil {
+				return dst, noEOF(err)
+			}
+		}
+		return append(dst, ']'), nil
+	case 5:
+		dst = append(dst, '{')
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if i != 0 {
+				dst = append(dst, ',')
+			}
+			dst, err = d.key(dst, depth+1)
+			if err == errBreak && indefinite {
+				if i != 0 {
+					dst = dst[:len(dst)-1]
+				}
+				break
+			}
+			if err != nil {
+				return dst, noEOF(err)
+			}
+			dst = append(dst, ':')
+			if dst, err = d.item(dst, depth+1); err != nil {
+				return dst, noEOF(err)
+			}
+		}
+		return append(dst, '}'), nil
+	case 6:
+		dst, err = d.tag(dst, arg, depth+1)
+		return dst, noEOF(err)
+	default:
+		return d.simple(dst, info, arg)
+	}
+}
+
+// key appends a map key. Keys other than text strings are rendered as
+// JSON and quoted.
+func (d *decoder) key(dst []byte, depth int) ([]byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return dst, err
+	}
+	_ = d.r.UnreadByte()
+	if b>>5 == 3 {
+		return d.item(dst, depth)
+	}
+
+	start := len(dst)
+	dst, err = d.item(dst, depth)
+	if err != nil {
+		return dst, err
+	}
+	key := string(dst[start:])
+	return appendString(dst[:start], []byte(key)), nil
+}
+
+// tag appends the content of a tagged data item.
+func (d *decoder) tag(dst []byte, tag uint64, depth int) ([]byte, error) {
+	switch {
+	case tag == 1:
+		return d.epoch(dst, depth)
+	case tag >= 64 && tag <= 87:
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return dst, err
+		}
+		_ = d.r.UnreadByte()
+		if b>>5 != 2 {
+			break
+		}
+		major, _, arg, indefinite, err := d.head()
+		if err != nil {
+			return dst, err
+		}
+		data, err := d.str(major, arg, indefinite)
+		if err != nil {
+			return dst, err
+		}
+		return appendTypedArray(dst, byte(tag-64), data)
+	}
+	return d.item(dst, depth)
+}
+
+// epoch appends epoch-based date/time as an RFC 3339 string.
+func (d *decoder) epoch(dst []byte, depth int) ([]byte, error) {
+	start := len(dst)
+	dst, err := d.item(dst, depth)
+	if err != nil {
+		return dst, err
+	}
+	v, err := strconv.ParseFloat(string(dst[start:]), 64)
+	if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+		// Not a number: keep the content.
+		return dst, nil
+	}
+
+	var t time.Time
+	if sec, err := strconv.ParseInt(string(dst[start:]), 10, 64); err == nil {
+		t = time.Unix(sec, 0)
+	} else {
+		// Floats carry about microsecond precision for current dates.
+		sec, frac := math.Modf(v)
+		t = time.Unix(int64(sec), int64(math.Round(frac*1e6))*1e3)
+	}
+	dst = append(dst[:start], '"')
+	dst = t.UTC().AppendFormat(dst, time.RFC3339Nano)
+	return append(dst, '"'), nil
+}
+
+// simple appends a simple value or a float.
+func (d *decoder) simple(dst []byte, info byte, arg uint64) ([]byte, error) {
+	switch info {
+	case 20:
+		return append(dst, "false"...), nil
+	case 21:
+		return append(dst, "true"...), nil
+	case 22, 23:
+		return append(dst, "null"...), nil
+	case 25:
+		return appendFloat(dst, float16(uint16(arg)), 32), nil
+	case 26:
+		return appendFloat(dst, float64(math.Float32frombits(uint32(arg))), 32), nil
+	case 27:
+		return appendFloat(dst, math.Float64frombits(arg), 64), nil
+	case 31:
+		return dst, errBreak
+	default:
+		// Unassigned simple values.
+		dst = strconv.AppendUint(append(dst, `"simple(`...), arg, 10)
+		return append(dst, `)"`...), nil
+	}
+}
+
+// str reads the content of a byte or text string, joining the chunks of
+// an indefinite-length string.
+func (d *decoder) str(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		var b bytes.Buffer
+		if _, err := io.CopyN(&b, d.r, int64(n)); err != nil {
+			return nil, noEOF(err)
+		}
+		return b.Bytes(), nil
+	}
+
+	var s []byte
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		if b == 0xff {
+			return s, nil
+		}
+		_ = d.r.UnreadByte()
+		chunkMajor, _, arg, chunkIndefinite, err := d.head()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		if chunkMajor != major || chunkIndefinite {
+			return nil, errors.New("cbor: invalid chunk of indefinite-length string")
+		}
+		chunk, err := d.str(major, arg, false)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, chunk...)
+	}
+}
+
+// appendTypedArray appends a typed array (RFC 8746) as a JSON array. t is
+// the tag minus 64: bits 0b_f_s_e_ll for float, signed, little endian
+// and length.
+func appendTypedArray(dst []byte, t byte, data []byte) ([]byte, error) {
+	float, signed, little := t&0x10 != 0, t&0x08 != 0, t&0x04 != 0
+	size := 1 << (t & 3)
+	if float {
+		size = 2 << (t & 3)
+		if size == 16 {
+			return dst, errors.New("cbor: 128-bit float arrays are not supported")
+		}
+	}
+	if len(data)%size != 0 {
+		return dst, fmt.Errorf("cbor: typed array length %d is not a multiple of %d", len(data), size)
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if little {
+		order = binary.LittleEndian
+	}
+	dst = append(dst, '[')
+	for i := 0; i < len(data); i += size {
+		if i != 0 {
+			dst = append(dst, ',')
+		}
+		var v uint64
+		switch size {
+		case 1:
+			v = uint64(data[i])
+		case 2:
+			v = uint64(order.Uint16(data[i:]))
+		case 4:
+			v = uint64(order.Uint32(data[i:]))
+		default:
+			v = order.Uint64(data[i:])
+		}
+		switch {
+		case float && size == 2:
+			dst = appendFloat(dst, float16(uint16(v)), 32)
+		case float && size == 4:
+			dst = appendFloat(dst, float64(math.Float32frombits(uint32(v))), 32)
+		case float:
+			dst = appendFloat(dst, math.Float64frombits(v), 64)
+		case signed:
+			shift := 64 - 8*size
+			dst = strconv.AppendInt(dst, int64(v<<shift)>>shift, 10)
+		default:
+			dst = strconv.AppendUint(dst, v, 10)
+		}
+	}
+	return append(dst, ']'), nil
+}
+
+// float16 converts an IEEE 754 half-precision float.
+func float16(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		v = -v
+	}
+	return v
+}
+
+// appendFloat appends a float as a JSON number, or as a string for NaN
+// and infinities, which JSON cannot represent.
+func appendFloat(dst []byte, v float64, bits int) []byte {
+	switch {
+	case math.IsNaN(v):
+		return append(dst, `"NaN"`...)
+	case math.IsInf(v, 1):
+		return append(dst, `"+Inf"`...)
+	case math.IsInf(v, -1):
+		return append(dst, `"-Inf"`...)
+	}
+	return strconv.AppendFloat(dst, v, 'g', -1, bits)
+}
+
+// appendString appends s as a JSON string. Invalid UTF-8 is replaced
+// with U+FFFD.
+func appendString(dst []byte, s []byte) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c == '\n':
+				dst = append(dst, '\\', 'n')
+			case c == '\r':
+				dst = append(dst, '\\', 'r')
+			case c == '\t':
+				dst = append(dst, '\\', 't')
+			case c < 0x20:
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			default:
+				dst = append(dst, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, `�`...)
+		} else {
+			dst = append(dst, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '"')
+}
+
+// noEOF turns io.EOF inside a data item into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendJSON(t *testing.T) {
+	// Examples from RFC 8949, Appendix A, and typed arrays from RFC 8746.
+	testCases := []struct {
+		name   string
+		hex    string
+		golden string
+	}{
+		{"Zero", "00", `0`},
+		{"Uint8", "1818", `24`},
+		{"Uint16", "1903e8", `1000`},
+		{"Uint64", "1bffffffffffffffff", `18446744073709551615`},
+		{"Negative", "3863", `-100`},
+		{"NegativeMin", "3bffffffffffffffff", `-18446744073709551616`},
+		{"Float16", "f93e00", `1.5`},
+		{"Float16Subnormal", "f90001", `5.9604645e-08`},
+		{"Float32", "fa47c35000", `100000`},
+		{"Float64", "fb3ff199999999999a", `1.1`},
+		{"Infinity", "f97c00", `"+Inf"`},
+		{"NaN", "f97e00", `"NaN"`},
+		{"Simple", "f4f5f6f7", `false`},
+		{"Unassigned", "f0", `"simple(16)"`},
+		{"Bytes", "4401020304", `"AQIDBA=="`},
+		{"Text", "6449455446", `"IETF"`},
+		{"TextEscapes", "65225c0a01ff", `"\"\\\n\u0001�"`},
+		{"IndefiniteBytes", "5f42010243030405ff", `"AQIDBAU="`},
+		{"IndefiniteText", "7f657374726561646d696e67ff", `"streaming"`},
+		{"Array", "8301820203820405", `[1,[2,3],[4,5]]`},
+		{"IndefiniteArray", "9f018202039f0405ffff", `[1,[2,3],[4,5]]`},
+		{"EmptyIndefiniteArray", "9fff", `[]`},
+		{"Map", "a26161016162820203", `{"a":1,"b":[2,3]}`},
+		{"IndefiniteMap", "bf61610161629f0203ffff", `{"a":1,"b":[2,3]}`},
+		{"EmptyIndefiniteMap", "bfff", `{}`},
+		{"NonTextKeys", "a201020304", `{"1":2,"3":4}`},
+		{"EpochInt", "c11a514b67b0", `"2013-03-21T20:04:00Z"`},
+		{"EpochFloat", "c1fb41d452d9ec200000", `"2013-03-21T20:04:00.5Z"`},
+		{"EpochText", "c16161", `"a"`},
+		{"DateTimeString", "c074323031332d30332d32315432303a30343a30305a", `"2013-03-21T20:04:00Z"`},
+		{"OtherTag", "d82076687474703a2f2f7777772e6578616d706c652e636f6d", `"http://www.example.com"`},
+		{"Uint8Array", "d84043010203", `[1,2,3]`},
+		{"Sint64BE", "d84b50ffffffffffffffff0000000000000002", `[-1,2]`},
+		{"Sint16LE", "d84d44feff0200", `[-2,2]`},
+		{"Float64BE", "d852483ff8000000000000", `[1.5]`},
+		{"Float32LE", "d8554400000000", `[0]`},
+		{"Float16BE", "d850423e00", `[1.5]`},
+		{"TypedArrayOfText", "d84b6161", `"a"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := hex.DecodeString(tc.hex)
+			require.NoError(t, err)
+			out, rest, err := AppendJSON([]byte("x"), src)
+			require.NoError(t, err)
+			assert.Equal(t, "x"+tc.golden, string(out))
+			if tc.name != "Simple" {
+				assert.Empty(t, rest)
+			}
+		})
+	}
+}
+
+func TestAppendJSONRest(t *testing.T) {
+	out, rest, err := AppendJSON(nil, []byte{0x01, 0x02})
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(out))
+	assert.Equal(t, []byte{0x02}, rest)
+}
+
+func TestAppendJSONErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		hex  string
+		err  error
+	}{
+		{"Empty", "", io.EOF},
+		{"TruncatedHead", "19", io.ErrUnexpectedEOF},
+		{"TruncatedText", "6449", io.ErrUnexpectedEOF},
+		{"TruncatedArray", "8301", io.ErrUnexpectedEOF},
+		{"TruncatedMap", "a16161", io.ErrUnexpectedEOF},
+		{"TruncatedIndefiniteMap", "bf616101", io.ErrUnexpectedEOF},
+		{"TruncatedTag", "c1", io.ErrUnexpectedEOF},
+		{"Break", "ff", nil},
+		{"BreakInDefiniteArray", "81ff", nil},
+		{"ReservedInfo", "1c", nil},
+		{"IndefiniteInt", "1f", nil},
+		{"MixedChunks", "5f6161ff", nil},
+		{"OddTypedArray", "d84b4101", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := hex.DecodeString(tc.hex)
+			require.NoError(t, err)
+			_, _, err = AppendJSON(nil, src)
+			require.Error(t, err)
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err), "got %v", err)
+			}
+		})
+	}
+
+	t.Run("TooDeep", func(t *testing.T) {
+		src := bytes.Repeat([]byte{0x81}, maxDepth+2)
+		_, _, err := AppendJSON(nil, append(src, 0x00))
+		assert.ErrorContains(t, err, "too deep")
+	})
+}
+
+func TestConvert(t *testing.T) {
+	src, err := hex.DecodeString("bf616101ff" + "a1616282f5f6" + "00")
+	require.NoError(t, err)
+
+	var out strings.Builder
+	require.NoError(t, Convert(&out, bytes.NewReader(src)))
+	assert.Equal(t, "{\"a\":1}\n{\"b\":[true,null]}\n0\n", out.String())
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		assert.True(t, json.Valid([]byte(line)), line)
+	}
+
+	// A stream cut inside an item.
+	err = Convert(io.Discard, io.MultiReader(bytes.NewReader(src), strings.NewReader("\x82\x01")))
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "got %v", err)
+}
+
+func TestDecoder(t *testing.T) {
+	// Readers without UnreadByte are buffered.
+	d := NewDecoder(io.MultiReader(strings.NewReader("\x01\x61"), strings.NewReader("a")))
+	out, err := d.Decode(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(out))
+	out, err = d.Decode(out[:0])
+	require.NoError(t, err)
+	assert.Equal(t, `"a"`, string(out))
+	_, err = d.Decode(nil)
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,44 @@
+// Command cbor2json renders CBOR logs written by the logf CBOR encoder as
+// JSON lines. It reads the files given as arguments, or stdin:
+//
+//	cbor2json app.cbor | jq .
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ssgreg/logf/v2/cbor"
+)
+
+func main() {
+	w := bufio.NewWriter(os.Stdout)
+	err := run(w, os.Args[1:])
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cbor2json:", err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer, files []string) error {
+	if len(files) == 0 {
+		return cbor.Convert(w, os.Stdin)
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = cbor.Convert(w, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,636 @@
+package logf
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// CBOREncoderConfig controls how the CBOR encoder formats log entries.
+// For a friendlier builder-style API, use CBOR() instead.
+type CBOREncoderConfig struct {
+	FieldKeyMsg    string
+	FieldKeyTime   string
+	FieldKeyLevel  string
+	FieldKeyName   string
+	FieldKeyCaller string
+
+	DisableFieldMsg    bool
+	DisableFieldTime   bool
+	DisableFieldLevel  bool
+	DisableFieldName   bool
+	DisableFieldCaller bool
+
+	// EncodeTime formats times. Nil, the default, writes them natively
+	// as epoch-based date/time (tag 1).
+	EncodeTime     TimeEncoder
+	EncodeDuration DurationEncoder
+	EncodeError    ErrorEncoder
+	EncodeLevel    LevelEncoder
+	EncodeCaller   CallerEncoder
+}
+
+// WithDefaults returns a copy of the config with all zero-value fields
+// replaced by sensible defaults (the JSON field keys, string durations,
+// short caller format, etc.). EncodeTime stays nil for native times.
+func (c CBOREncoderConfig) WithDefaults() CBOREncoderConfig {
+	if c.FieldKeyMsg == "" {
+		c.FieldKeyMsg = DefaultFieldKeyMsg
+	}
+	if c.FieldKeyTime == "" {
+		c.FieldKeyTime = DefaultFieldKeyTime
+	}
+	if c.FieldKeyLevel == "" {
+		c.FieldKeyLevel = DefaultFieldKeyLevel
+	}
+	if c.FieldKeyName == "" {
+		c.FieldKeyName = DefaultFieldKeyName
+	}
+	if c.FieldKeyCaller == "" {
+		c.FieldKeyCaller = DefaultFieldKeyCaller
+	}
+
+	if c.EncodeDuration == nil {
+		c.EncodeDuration = StringDurationEncoder
+	}
+	if c.EncodeError == nil {
+		c.EncodeError = DefaultErrorEncoder
+	}
+	if c.EncodeLevel == nil {
+		c.EncodeLevel = DefaultLevelEncoder
+	}
+	if c.EncodeCaller == nil {
+		c.EncodeCaller = ShortCallerEncoder
+	}
+	return c
+}
+
+// CBOREncoderBuilder configures and builds a CBOR Encoder using a clean
+// builder-style API. Create one with CBOR(), chain methods to customize,
+// then call Build() or pass directly to LoggerBuilder.EncoderFrom().
+type CBOREncoderBuilder struct {
+	cfg CBOREncoderConfig
+}
+
+// CBOR returns a new CBOREncoderBuilder with default settings. The
+// encoder writes each entry as a CBOR (RFC 8949) map with the same
+// layout as the JSON encoder, so a log file is a CBOR sequence (RFC
+// 8742). Values keep their types, without escaping or float formatting:
+//
+//   - integers and float64 as CBOR numbers;
+//   - times as epoch-based date/time (tag 1): an integer for whole
+//     seconds, a float64 with microsecond precision otherwise;
+//   - Bytes as byte strings;
+//   - Ints64 and Floats64 as big-endian typed arrays (RFC 8746, tags 75
+//     and 82);
+//   - groups, objects and Bag groups as indefinite-length maps, arrays
+//     of ArrayEncoder as indefinite-length arrays;
+//   - Any values as JSON text strings.
+//
+// The logf/v2/cbor package renders these logs back to JSON.
+func CBOR() *CBOREncoderBuilder {
+	return &CBOREncoderBuilder{}
+}
+
+// TimeKey sets the key for the timestamp field (default "ts").
+func (b *CBOREncoderBuilder) TimeKey(k string) *CBOREncoderBuilder {
+	b.cfg.FieldKeyTime = k
+	return b
+}
+
+// LevelKey sets the key for the severity level field (default "level").
+func (b *CBOREncoderBuilder) LevelKey(k string) *CBOREncoderBuilder {
+	b.cfg.FieldKeyLevel = k
+	return b
+}
+
+// MsgKey sets the key for the log message field (default "msg").
+func (b *CBOREncoderBuilder) MsgKey(k string) *CBOREncoderBuilder {
+	b.cfg.FieldKeyMsg = k
+	return b
+}
+
+// NameKey sets the key for the logger name field (default "logger").
+func (b *CBOREncoderBuilder) NameKey(k string) *CBOREncoderBuilder {
+	b.cfg.FieldKeyName = k
+	return b
+}
+
+// CallerKey sets the key for the caller location field (default "caller").
+func (b *CBOREncoderBuilder) CallerKey(k string) *CBOREncoderBuilder {
+	b.cfg.FieldKeyCaller = k
+	return b
+}
+
+// DisableTime omits the timestamp field.
+func (b *CBOREncoderBuilder) DisableTime() *CBOREncoderBuilder {
+	b.cfg.DisableFieldTime = true
+	return b
+}
+
+// DisableLevel omits the severity level field.
+func (b *CBOREncoderBuilder) DisableLevel() *CBOREncoderBuilder {
+	b.cfg.DisableFieldLevel = true
+	return b
+}
+
+// DisableMsg omits the message text field.
+func (b *CBOREncoderBuilder) DisableMsg() *CBOREncoderBuilder {
+	b.cfg.DisableFieldMsg = true
+	return b
+}
+
+// DisableName omits the logger name field.
+func (b *CBOREncoderBuilder) DisableName() *CBOREncoderBuilder {
+	b.cfg.DisableFieldName = true
+	return b
+}
+
+// DisableCaller omits the caller location field.
+func (b *CBOREncoderBuilder) DisableCaller() *CBOREncoderBuilder {
+	b.cfg.DisableFieldCaller = true
+	return b
+}
+
+// EncodeTime sets a custom TimeEncoder for formatting times (default
+// epoch-based date/time).
+func (b *CBOREncoderBuilder) EncodeTime(e TimeEncoder) *CBOREncoderBuilder {
+	b.cfg.EncodeTime = e
+	return b
+}
+
+// EncodeDuration sets a custom DurationEncoder for formatting durations (default string representation).
+func (b *CBOREncoderBuilder) EncodeDuration(e DurationEncoder) *CBOREncoderBuilder {
+	b.cfg.EncodeDuration = e
+	return b
+}
+
+// EncodeLevel sets a custom LevelEncoder for formatting severity levels.
+func (b *CBOREncoderBuilder) EncodeLevel(e LevelEncoder) *CBOREncoderBuilder {
+	b.cfg.EncodeLevel = e
+	return b
+}
+
+// EncodeCaller sets a custom CallerEncoder for formatting caller locations (default short format).
+func (b *CBOREncoderBuilder) EncodeCaller(e CallerEncoder) *CBOREncoderBuilder {
+	b.cfg.EncodeCaller = e
+	return b
+}
+
+// EncodeError sets a custom ErrorEncoder for formatting error values.
+func (b *CBOREncoderBuilder) EncodeError(e ErrorEncoder) *CBOREncoderBuilder {
+	b.cfg.EncodeError = e
+	return b
+}
+
+// Build finalizes the configuration and returns a ready-to-use CBOR
+// Encoder.
+func (b *CBOREncoderBuilder) Build() Encoder {
+	return buildCBOREncoder(b.cfg)
+}
+
+// NewCBOREncoder creates a CBOR Encoder from a CBOREncoderConfig struct.
+// For a friendlier builder-style API, use CBOR() instead.
+func NewCBOREncoder(cfg CBOREncoderConfig) Encoder {
+	return buildCBOREncoder(cfg)
+}
+
+func buildCBOREncoder(cfg CBOREncoderConfig) Encoder {
+	cfg = cfg.WithDefaults()
+	jsonEncodeTime := cfg.EncodeTime
+	if jsonEncodeTime == nil {
+		jsonEncodeTime = RFC3339NanoTimeEncoder
+	}
+	enc := &cborEncoder{
+		CBOREncoderConfig: cfg,
+		slot:              AllocEncoderSlot(),
+		json: &jsonEncoder{JSONEncoderConfig: JSONEncoderConfig{
+			EncodeTime:     jsonEncodeTime,
+			EncodeDuration: cfg.EncodeDuration,
+			EncodeError:    cfg.EncodeError,
+		}.WithDefaults()},
+	}
+	enc.pool = &sync.Pool{New: func() any {
+		return enc.Clone()
+	}}
+	return enc
+}
+
+// CBOR major types.
+const (
+	cborUint   = 0 << 5
+	cborNegInt = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+)
+
+// CBOR simple values, indefinite-length headers and tags.
+const (
+	cborFalse        = 0xf4
+	cborTrue         = 0xf5
+	cborNull         = 0xf6
+	cborFloat64      = 0xfb
+	cborBreak        = 0xff
+	cborMapStart     = 0xbf
+	cborArrayStart   = 0x9f
+	cborTagEpoch     = 1
+	cborTagSint64BE  = 75
+	cborTagFloat64BE = 82
+)
+
+type cborEncoder struct {
+	CBOREncoderConfig
+	pool *sync.Pool
+	slot int
+	json *jsonEncoder // Any values
+
+	// Internal state.
+	buf    *Buffer
+	groups int // maps opened by Bag groups
+}
+
+func (f *cborEncoder) Clone() Encoder {
+	return &cborEncoder{
+		CBOREncoderConfig: f.CBOREncoderConfig,
+		pool:              f.pool,
+		slot:              f.slot,
+		json:              &jsonEncoder{JSONEncoderConfig: f.json.JSONEncoderConfig},
+	}
+}
+
+func (f *cborEncoder) Encode(e Entry) (*Buffer, error) {
+	clone := f.pool.Get().(*cborEncoder)
+
+	buf := GetBuffer()
+	err := clone.encode(buf, e)
+
+	clone.buf = nil
+	f.pool.Put(clone)
+
+	if err != nil {
+		buf.Free()
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (f *cborEncoder) encode(buf *Buffer, e Entry) error {
+	f.buf = buf
+	f.groups = 0
+
+	buf.AppendByte(cborMapStart)
+
+	// Level.
+	if !f.DisableFieldLevel {
+		appendCBORText(buf, f.FieldKeyLevel)
+		cur := buf.Len()
+		f.EncodeLevel(e.Level, f)
+		if buf.Len() == cur {
+			f.EncodeTypeString(e.Level.String())
+		}
+	}
+
+	// Time.
+	if !f.DisableFieldTime && !e.Time.IsZero() {
+		appendCBORText(buf, f.FieldKeyTime)
+		f.EncodeTypeTime(e.Time)
+	}
+
+	// Logger name.
+	if !f.DisableFieldName && e.LoggerName != "" {
+		appendCBORText(buf, f.FieldKeyName)
+		f.EncodeTypeString(e.LoggerName)
+	}
+
+	// Message.
+	if !f.DisableFieldMsg {
+		appendCBORText(buf, f.FieldKeyMsg)
+		f.EncodeTypeString(e.Text)
+	}
+
+	// Caller.
+	if !f.DisableFieldCaller && e.CallerPC != 0 {
+		appendCBORText(buf, f.FieldKeyCaller)
+		cur := buf.Len()
+		f.EncodeCaller(e.CallerPC, f)
+		if buf.Len() == cur {
+			f.EncodeTypeString("unknown")
+		}
+	}
+
+	// Skip trailing groups that would produce empty maps.
+	loggerBag := e.LoggerBag
+	ctxBag := e.Bag
+	if len(e.Fields) == 0 {
+		loggerBag = skipTrailingGroups(loggerBag)
+		if !bagHasFields(loggerBag) {
+			ctxBag = skipTrailingGroups(ctxBag)
+		}
+	}
+
+	// Context fields (request-scoped).
+	f.encodeBag(ctxBag)
+
+	// Logger's fields (service-scoped).
+	f.encodeBag(loggerBag)
+
+	// Entry's fields.
+	for i := range e.Fields {
+		e.Fields[i].Accept(f)
+	}
+
+	// Close the maps of Bag groups and the record.
+	for ; f.groups != 0; f.groups-- {
+		buf.AppendByte(cborBreak)
+	}
+	buf.AppendByte(cborBreak)
+	return nil
+}
+
+// encodeBag writes the fields of a Bag chain. Group nodes open maps that
+// stay open until the end of the record, so a cache entry holds the number
+// of maps the chain opened before the encoded bytes.
+func (f *cborEncoder) encodeBag(bag *Bag) {
+	if bag == nil {
+		return
+	}
+
+	// Group node: open a nested map, no caching needed.
+	if bag.group != "" {
+		f.encodeBag(bag.parent)
+		appendCBORText(f.buf, bag.group)
+		f.buf.AppendByte(cborMapStart)
+		f.groups++
+		return
+	}
+
+	// Field node: use cache.
+	if data := bag.LoadCache(f.slot); data != nil {
+		groups, n := binary.Uvarint(data)
+		f.buf.AppendBytes(data[n:])
+		f.groups += int(groups)
+		return
+	}
+
+	start := f.buf.Len()
+	groups := f.groups
+
+	// Walk parent first to preserve field order (parent before child).
+	f.encodeBag(bag.parent)
+
+	for _, field := range bag.fields {
+		field.Accept(f)
+	}
+
+	if f.slot != 0 {
+		var meta [binary.MaxVarintLen64]byte
+		encoded := binary.AppendUvarint(meta[:0], uint64(f.groups-groups))
+		encoded = append(encoded[:len(encoded):len(encoded)], f.buf.Data[start:]...)
+		bag.StoreCache(f.slot, encoded)
+	}
+}
+
+// --- FieldEncoder ---
+
+func (f *cborEncoder) EncodeFieldAny(k string, v interface{}) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeAny(v)
+}
+
+func (f *cborEncoder) EncodeFieldBool(k string, v bool) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeBool(v)
+}
+
+func (f *cborEncoder) EncodeFieldInt64(k string, v int64) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeInt64(v)
+}
+
+func (f *cborEncoder) EncodeFieldUint64(k string, v uint64) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeUint64(v)
+}
+
+func (f *cborEncoder) EncodeFieldFloat64(k string, v float64) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeFloat64(v)
+}
+
+func (f *cborEncoder) EncodeFieldDuration(k string, v time.Duration) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeDuration(v)
+}
+
+func (f *cborEncoder) EncodeFieldError(k string, v error) {
+	f.EncodeError(k, v, f)
+}
+
+func (f *cborEncoder) EncodeFieldTime(k string, v time.Time) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeTime(v)
+}
+
+func (f *cborEncoder) EncodeFieldString(k string, v string) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeString(v)
+}
+
+func (f *cborEncoder) EncodeFieldBytes(k string, v []byte) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeBytes(v)
+}
+
+func (f *cborEncoder) EncodeFieldStrings(k string, v []string) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeStrings(v)
+}
+
+func (f *cborEncoder) EncodeFieldInts64(k string, v []int64) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeInts64(v)
+}
+
+func (f *cborEncoder) EncodeFieldFloats64(k string, v []float64) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeFloats64(v)
+}
+
+func (f *cborEncoder) EncodeFieldDurations(k string, v []time.Duration) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeDurations(v)
+}
+
+func (f *cborEncoder) EncodeFieldArray(k string, v ArrayEncoder) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeArray(v)
+}
+
+func (f *cborEncoder) EncodeFieldObject(k string, v ObjectEncoder) {
+	appendCBORText(f.buf, k)
+	f.EncodeTypeObject(v)
+}
+
+func (f *cborEncoder) EncodeFieldGroup(k string, fs []Field) {
+	if k == "" {
+		// Inline group: emit fields at current level.
+		for _, field := range fs {
+			field.Accept(f)
+		}
+		return
+	}
+	appendCBORText(f.buf, k)
+	f.buf.AppendByte(cborMapStart)
+	for _, field := range fs {
+		field.Accept(f)
+	}
+	f.buf.AppendByte(cborBreak)
+}
+
+// --- TypeEncoder ---
+
+func (f *cborEncoder) EncodeTypeAny(v interface{}) {
+	if v == nil {
+		f.buf.AppendByte(cborNull)
+		return
+	}
+	tmp := GetBuffer()
+	f.json.TypeEncoder(tmp).EncodeTypeAny(v)
+	appendCBORText(f.buf, tmp.Data)
+	tmp.Free()
+	f.json.buf = nil
+}
+
+func (f *cborEncoder) EncodeTypeBool(v bool) {
+	if v {
+		f.buf.AppendByte(cborTrue)
+	} else {
+		f.buf.AppendByte(cborFalse)
+	}
+}
+
+func (f *cborEncoder) EncodeTypeInt64(v int64) {
+	appendCBORInt(f.buf, v)
+}
+
+func (f *cborEncoder) EncodeTypeUint64(v uint64) {
+	appendCBORHead(f.buf, cborUint, v)
+}
+
+func (f *cborEncoder) EncodeTypeFloat64(v float64) {
+	f.buf.AppendByte(cborFloat64)
+	f.buf.Data = binary.BigEndian.AppendUint64(f.buf.Data, math.Float64bits(v))
+}
+
+func (f *cborEncoder) EncodeTypeDuration(v time.Duration) {
+	f.EncodeDuration(v, f)
+}
+
+func (f *cborEncoder) EncodeTypeTime(v time.Time) {
+	if f.EncodeTime != nil {
+		f.EncodeTime(v, f)
+		return
+	}
+	appendCBORHead(f.buf, cborTag, cborTagEpoch)
+	if v.Nanosecond() == 0 {
+		appendCBORInt(f.buf, v.Unix())
+		return
+	}
+	f.EncodeTypeFloat64(float64(v.Unix()) + float64(v.Nanosecond())/1e9)
+}
+
+func (f *cborEncoder) EncodeTypeString(v string) {
+	appendCBORText(f.buf, v)
+}
+
+func (f *cborEncoder) EncodeTypeUnsafeBytes(v unsafe.Pointer) {
+	appendCBORText(f.buf, *(*[]byte)(v))
+}
+
+func (f *cborEncoder) EncodeTypeBytes(v []byte) {
+	appendCBORHead(f.buf, cborBytes, uint64(len(v)))
+	f.buf.AppendBytes(v)
+}
+
+func (f *cborEncoder) EncodeTypeStrings(v []string) {
+	appendCBORHead(f.buf, cborArray, uint64(len(v)))
+	for i := range v {
+		appendCBORText(f.buf, v[i])
+	}
+}
+
+func (f *cborEncoder) EncodeTypeInts64(v []int64) {
+	appendCBORHead(f.buf, cborTag, cborTagSint64BE)
+	appendCBORHead(f.buf, cborBytes, uint64(len(v)*8))
+	for i := range v {
+		f.buf.Data = binary.BigEndian.AppendUint64(f.buf.Data, uint64(v[i]))
+	}
+}
+
+func (f *cborEncoder) EncodeTypeFloats64(v []float64) {
+	appendCBORHead(f.buf, cborTag, cborTagFloat64BE)
+	appendCBORHead(f.buf, cborBytes, uint64(len(v)*8))
+	for i := range v {
+		f.buf.Data = binary.BigEndian.AppendUint64(f.buf.Data, math.Float64bits(v[i]))
+	}
+}
+
+func (f *cborEncoder) EncodeTypeDurations(v []time.Duration) {
+	appendCBORHead(f.buf, cborArray, uint64(len(v)))
+	for i := range v {
+		f.EncodeTypeDuration(v[i])
+	}
+}
+
+func (f *cborEncoder) EncodeTypeArray(v ArrayEncoder) {
+	f.buf.AppendByte(cborArrayStart)
+	_ = v.EncodeLogfArray(f)
+	f.buf.AppendByte(cborBreak)
+}
+
+func (f *cborEncoder) EncodeTypeObject(v ObjectEncoder) {
+	f.buf.AppendByte(cborMapStart)
+	_ = v.EncodeLogfObject(f)
+	f.buf.AppendByte(cborBreak)
+}
+
+// --- helpers ---
+
+// appendCBORHead appends the initial bytes of a data item of the given
+// major type with the argument n in its shortest form.
+func appendCBORHead(buf *Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.AppendByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.AppendByte(major | 24)
+		buf.AppendByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.AppendByte(major | 25)
+		buf.Data = binary.BigEndian.AppendUint16(buf.Data, uint16(n))
+	case n <= math.MaxUint32:
+		buf.AppendByte(major | 26)
+		buf.Data = binary.BigEndian.AppendUint32(buf.Data, uint32(n))
+	default:
+		buf.AppendByte(major | 27)
+		buf.Data = binary.BigEndian.AppendUint64(buf.Data, n)
+	}
+}
+
+func appendCBORInt(buf *Buffer, v int64) {
+	if v < 0 {
+		appendCBORHead(buf, cborNegInt, uint64(-1-v))
+		return
+	}
+	appendCBORHead(buf, cborUint, uint64(v))
+}
+
+func appendCBORText[S string | []byte](buf *Buffer, s S) {
+	appendCBORHead(buf, cborText, uint64(len(s)))
+	appendBuf(buf, s)
+}
@@ -0,0 +1,209 @@
+package logf
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ssgreg/logf/v2/cbor"
+)
+
+// cborJSON renders one CBOR data item as JSON, failing on trailing bytes.
+func cborJSON(t *testing.T, b []byte) string {
+	t.Helper()
+	out, rest, err := cbor.AppendJSON(nil, b)
+	require.NoError(t, err)
+	require.Empty(t, rest, "trailing bytes")
+	require.True(t, json.Valid(out), string(out))
+	return string(out)
+}
+
+func TestCBOREncoder(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC)
+	testCases := []encoderTestCase{
+		{
+			"Header",
+			Entry{Level: LevelInfo, Time: ts, LoggerName: "http", Text: "request served"},
+			`{"level":"info","ts":"2024-05-01T10:00:00.123456Z","logger":"http","msg":"request served"}`,
+		},
+		{
+			"NoTime",
+			Entry{Level: LevelDebug, Text: "m"},
+			`{"level":"debug","msg":"m"}`,
+		},
+		{
+			"Types",
+			Entry{Level: LevelError, Time: ts.Truncate(time.Second), Text: "m", Fields: []Field{
+				String("s", "a\"b"), Bool("ok", true), Bool("no", false), Float64("f", 0.5),
+				Duration("d", time.Second), Time("t", ts), Bytes("b", []byte("hi")),
+				ByteString("bs", []byte("raw")), NamedError("err", errors.New("boom")),
+				Any("map", map[string]int{"a": 1}), Any("nil", nil),
+			}},
+			`{"level":"error","ts":"2024-05-01T10:00:00Z","msg":"m","s":"a\"b","ok":true,"no":false,"f":0.5,` +
+				`"d":"1s","t":"2024-05-01T10:00:00.123456Z","b":"aGk=","bs":"raw","err":"boom",` +
+				`"map":"{\"a\":1}","nil":null}`,
+		},
+		{
+			"Ints",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Int("a", 23), Int("b", 24), Int("c", -24), Int("d", -25), Int("e", 1000),
+				Int64("f", -1e10), Uint64("g", math.MaxUint64), Int64("h", math.MinInt64),
+			}},
+			`{"level":"info","msg":"m","a":23,"b":24,"c":-24,"d":-25,"e":1000,"f":-10000000000,` +
+				`"g":18446744073709551615,"h":-9223372036854775808}`,
+		},
+		{
+			"Containers",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Group("http", String("method", "GET"), Group("req", Int("size", 10))),
+				Group("", String("inline", "x")),
+				Group("empty"),
+				Object("user", testObjectEncoder{}),
+				Strings("tags", []string{"a", "b"}),
+				Ints64("ids", []int64{1, -2}),
+				Floats64("fs", []float64{1.5, -0.25}),
+				Durations("ds", []time.Duration{time.Second, time.Millisecond}),
+				Array("arr", testArrayEncoder{}),
+			}},
+			`{"level":"info","msg":"m","http":{"method":"GET","req":{"size":10}},"inline":"x","empty":{},` +
+				`"user":{"username":"username","code":42},"tags":["a","b"],"ids":[1,-2],"fs":[1.5,-0.25],` +
+				`"ds":["1s","1ms"],"arr":[42]}`,
+		},
+		{
+			"Bags",
+			Entry{
+				Level:     LevelInfo,
+				Text:      "m",
+				Bag:       NewBag(String("request_id", "r1")),
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http").With(String("method", "GET")),
+				Fields:    []Field{Int("status", 200)},
+			},
+			`{"level":"info","msg":"m","request_id":"r1","service":"api","http":{"method":"GET","status":200}}`,
+		},
+		{
+			"BagGroups",
+			Entry{
+				Level:     LevelInfo,
+				Text:      "m",
+				Bag:       NewBag(String("request_id", "r1")).WithGroup("ctx").With(Int("n", 1)),
+				LoggerBag: NewBag().WithGroup("a").With(Int("x", 1)).WithGroup("b").With(Int("y", 2)),
+				Fields:    []Field{Int("z", 3)},
+			},
+			`{"level":"info","msg":"m","request_id":"r1","ctx":{"n":1,"a":{"x":1,"b":{"y":2,"z":3}}}}`,
+		},
+		{
+			"TrailingGroups",
+			Entry{
+				Level:     LevelInfo,
+				Text:      "m",
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http"),
+			},
+			`{"level":"info","msg":"m","service":"api"}`,
+		},
+	}
+
+	enc := CBOR().Build()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden, cborJSON(t, b.Bytes()))
+			b.Free()
+		})
+	}
+}
+
+func TestCBOREncoderBagCache(t *testing.T) {
+	enc := withCacheSlot(CBOR().DisableLevel().DisableMsg().Build())
+	logger := NewBag(String("service", "api")).WithGroup("http").With(String("method", "GET")).WithGroup("req").With(Int("size", 10))
+
+	// The cached logger Bag leaves two maps open, and the record has to
+	// close them after the entry fields.
+	cases := []struct {
+		entry Entry
+		want  string
+	}{
+		{Entry{LoggerBag: logger}, `{"service":"api","http":{"method":"GET","req":{"size":10}}}`},
+		{Entry{LoggerBag: logger, Fields: []Field{Int("status", 200)}}, `{"service":"api","http":{"method":"GET","req":{"size":10,"status":200}}}`},
+		{
+			Entry{Bag: NewBag(String("request_id", "r1")).WithGroup("ctx"), LoggerBag: logger},
+			`{"request_id":"r1","ctx":{"service":"api","http":{"method":"GET","req":{"size":10}}}}`,
+		},
+	}
+	for _, c := range cases {
+		b, err := enc.Encode(c.entry)
+		require.NoError(t, err)
+		assert.Equal(t, c.want, cborJSON(t, b.Bytes()))
+		b.Free()
+	}
+}
+
+func TestCBOREncoderWireFormat(t *testing.T) {
+	enc := CBOR().DisableLevel().DisableMsg().Build()
+	ts := time.Unix(1714557600, 0)
+
+	b, err := enc.Encode(Entry{Time: ts, Fields: []Field{
+		Bytes("b", []byte{1}),
+		Ints64("i", []int64{-1}),
+		Floats64("f", []float64{1}),
+		Group("g"),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0xbf,                                               // indefinite-length map
+		0x62, 't', 's', 0xc1, 0x1a, 0x66, 0x32, 0x12, 0xa0, // tag 1, uint32 seconds
+		0x61, 'b', 0x41, 0x01, // byte string
+		0x61, 'i', 0xd8, 0x4b, 0x48, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // tag 75, sint64 BE
+		0x61, 'f', 0xd8, 0x52, 0x48, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0, // tag 82, float64 BE
+		0x61, 'g', 0xbf, 0xff, // group
+		0xff, // break
+	}, b.Bytes())
+	b.Free()
+}
+
+func TestCBOREncoderHooks(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	enc := CBOR().
+		TimeKey("time").LevelKey("severity").MsgKey("message").NameKey("name").
+		EncodeTime(RFC3339TimeEncoder).
+		EncodeDuration(NanoDurationEncoder).
+		EncodeLevel(UpperCaseLevelEncoder).
+		Build()
+
+	b, err := enc.Encode(Entry{Level: LevelInfo, Time: ts, LoggerName: "l", Text: "m", Fields: []Field{
+		Durations("ds", []time.Duration{1, 2}), Time("t", ts),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"severity":"INFO","time":"2024-05-01T10:00:00Z","name":"l","message":"m","ds":[1,2],`+
+		`"t":"2024-05-01T10:00:00Z"}`, cborJSON(t, b.Bytes()))
+	b.Free()
+}
+
+func TestCBOREncoderCaller(t *testing.T) {
+	enc := CBOR().Build()
+	b, err := enc.Encode(Entry{Level: LevelInfo, Text: "m", CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	assert.Regexp(t, `"caller":"[^"]+/cborencoder_test.go:\d+"}$`, cborJSON(t, b.Bytes()))
+	b.Free()
+
+	// Object callers become nested maps.
+	enc = CBOR().EncodeCaller(ECSCallerEncoder).Build()
+	b, err = enc.Encode(Entry{Level: LevelInfo, Text: "m", CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	assert.Regexp(t, `"caller":\{"file.name":"[^"]+/cborencoder_test.go","file.line":\d+,"function":"[^"]+"\}}$`,
+		cborJSON(t, b.Bytes()))
+	b.Free()
+}
+
+func TestNewCBOREncoder(t *testing.T) {
+	enc := NewCBOREncoder(CBOREncoderConfig{DisableFieldLevel: true, FieldKeyMsg: "message"})
+	b, err := enc.Encode(Entry{Text: "m"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"message":"m"}`, cborJSON(t, b.Bytes()))
+	b.Free()
+}
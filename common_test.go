@@ -32,6 +32,17 @@ func (w *testHandler) Enabled(_ context.Context, lvl Level) bool {
 	return true
 }
 
+// handlerFunc adapts a function to the Handler interface for tests.
+type handlerFunc func(context.Context, Entry) error
+
+func (f handlerFunc) Handle(ctx context.Context, e Entry) error {
+	return f(ctx, e)
+}
+
+func (f handlerFunc) Enabled(context.Context, Level) bool {
+	return true
+}
+
 // testTypeEncoder implements TypeEncoder storing the last encoding value.
 type testTypeEncoder struct {
 	result interface{}
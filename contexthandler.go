@@ -1,6 +1,9 @@
 package logf
 
-import "context"
+import (
+	"context"
+	"errors"
+)
 
 // With returns a new context carrying the given fields. If the context
 // already has a Bag, the fields are appended to it. This is the primary
@@ -39,6 +42,7 @@ type FieldSource func(ctx context.Context) []Field
 // pipeline, context fields are silently ignored.
 type ContextHandler struct {
 	next    Handler
+	named   NameEnabler // next, if it implements NameEnabler
 	sources []FieldSource
 }
 
@@ -47,18 +51,46 @@ type ContextHandler struct {
 // additional fields from the context (prepended to Entry.Fields so they
 // appear before per-call fields).
 func NewContextHandler(next Handler, sources ...FieldSource) *ContextHandler {
-	return &ContextHandler{next: next, sources: sources}
+	named, _ := next.(NameEnabler)
+	return &ContextHandler{next: next, named: named, sources: sources}
 }
 
 // Enabled delegates to the downstream Handler to check whether the given
-// level is active.
+// level is active, unless the context overrides the level (see
+// WithLevel). Levels held by a RequestBuffer in the context (see
+// BufferRequest) are enabled too.
 func (w *ContextHandler) Enabled(ctx context.Context, lvl Level) bool {
+	if override, ok := LevelFromContext(ctx); ok {
+		return override.Enabled(lvl) || buffered(ctx, lvl)
+	}
+	return w.next.Enabled(ctx, lvl) || buffered(ctx, lvl)
+}
+
+// EnabledName is like Enabled but forwards the logger name downstream.
+func (w *ContextHandler) EnabledName(ctx context.Context, name string, lvl Level) bool {
+	return w.nextEnabled(ctx, name, lvl) || buffered(ctx, lvl)
+}
+
+func (w *ContextHandler) nextEnabled(ctx context.Context, name string, lvl Level) bool {
+	if override, ok := LevelFromContext(ctx); ok {
+		return override.Enabled(lvl)
+	}
+	if w.named != nil {
+		return w.named.EnabledName(ctx, name, lvl)
+	}
 	return w.next.Enabled(ctx, lvl)
 }
 
+// buffered reports whether a RequestBuffer in ctx holds entries of lvl.
+func buffered(ctx context.Context, lvl Level) bool {
+	rb := requestBufferFromContext(ctx)
+	return rb != nil && rb.holds(lvl)
+}
+
 // Handle extracts the Bag from the context, collects fields from any
 // registered FieldSource functions, attaches everything to the Entry,
-// and hands it off to the downstream Handler.
+// and hands it off to the downstream Handler — or to the RequestBuffer
+// in the context, if there is one holding entries of this level.
 func (w *ContextHandler) Handle(ctx context.Context, e Entry) error {
 	if bag := BagFromContext(ctx); bag != nil {
 		e.Bag = bag
@@ -74,5 +106,17 @@ func (w *ContextHandler) Handle(ctx context.Context, e Entry) error {
 		}
 	}
 
+	if rb := requestBufferFromContext(ctx); rb != nil {
+		held, err := rb.handle(ctx, w.next, e)
+		if held {
+			return err
+		}
+		// Enabled may have let the entry through only for the buffer.
+		if !w.nextEnabled(ctx, e.LoggerName, e.Level) {
+			return err
+		}
+		return errors.Join(err, w.next.Handle(ctx, e))
+	}
+
 	return w.next.Handle(ctx, e)
 }
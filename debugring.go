@@ -0,0 +1,301 @@
+package logf
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"unsafe"
+)
+
+// DefaultFieldKeyDumped is the key of the flag field added to entries
+// replayed by DebugRingHandler.
+const DefaultFieldKeyDumped = "dumped"
+
+// NewDebugRingHandler returns a Handler middleware for post-mortem
+// debugging. Entries the downstream Handler would filter out (typically
+// Debug while production runs at Info) are not thrown away but recorded
+// into an in-memory ring of at most maxBytes. When something goes wrong,
+// the ring is replayed downstream, so the error comes with the debug
+// trail that led to it.
+//
+// The ring is dumped when:
+//   - an Error entry is logged (before the error itself; see DumpOnError),
+//   - Dump is called,
+//   - one of the signals registered with DumpOnSignal arrives.
+//
+// Replayed entries keep their original level, time and caller, and carry
+// an extra Bool(DefaultFieldKeyDumped, true) field so they can be told
+// apart. They are handled with a LevelDebug override (see WithLevel), so
+// a Router writes them despite its output levels; use DumpTo to send
+// them somewhere else.
+//
+//	h := logf.NewDebugRingHandler(
+//	    logf.NewSyncHandler(logf.LevelInfo, os.Stderr, enc),
+//	    1<<20, // 1 MB
+//	    logf.DumpOnSignal(syscall.SIGUSR1),
+//	)
+//	defer h.Close()
+//
+// Memory is accounted per entry: the Entry itself, its text and logger
+// name, and every field including the data behind ByteString, Strings,
+// Ints64 and friends, which are copied into the ring. When a new entry
+// does not fit, the oldest ones are evicted. Bags are shared and
+// immutable, so they are referenced rather than copied and not counted.
+//
+// DebugRingHandler is safe for concurrent use.
+func NewDebugRingHandler(next Handler, maxBytes int, opts ...DebugRingOption) *DebugRingHandler {
+	h := &DebugRingHandler{
+		next:        next,
+		dumpTo:      next,
+		maxBytes:    maxBytes,
+		recordLevel: LevelDebug,
+		dumpOnError: true,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if len(h.signals) > 0 {
+		h.sigCh = make(chan os.Signal, 1)
+		h.done = make(chan struct{})
+		signal.Notify(h.sigCh, h.signals...)
+		go h.signalLoop()
+	}
+	return h
+}
+
+// DebugRingHandler is the Handler built by NewDebugRingHandler.
+type DebugRingHandler struct {
+	next        Handler
+	dumpTo      Handler
+	maxBytes    int
+	recordLevel Level
+	dumpOnError bool
+	signals     []os.Signal
+
+	sigCh     chan os.Signal
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	ring    []ringItem // circular buffer, grown on demand
+	head    int        // index of the oldest item
+	n       int        // number of items
+	bytes   int        // accounted bytes of all items
+	evicted int64
+	dumped  int64
+}
+
+// DebugRingStats is a snapshot of DebugRingHandler counters.
+type DebugRingStats struct {
+	Entries int   // entries currently held in the ring
+	Bytes   int   // accounted memory of the held entries
+	Evicted int64 // entries evicted to stay within the memory bound
+	Dumped  int64 // entries replayed downstream
+}
+
+// DebugRingOption configures a DebugRingHandler at creation time.
+type DebugRingOption func(*DebugRingHandler)
+
+// RecordLevel sets the most verbose level recorded into the ring
+// (default LevelDebug). Only entries the downstream Handler rejects are
+// recorded; entries it accepts are passed through as usual.
+func RecordLevel(lvl Level) DebugRingOption {
+	return func(h *DebugRingHandler) {
+		h.recordLevel = lvl
+	}
+}
+
+// DumpOnError controls whether an Error entry triggers a dump (default
+// true).
+func DumpOnError(enabled bool) DebugRingOption {
+	return func(h *DebugRingHandler) {
+		h.dumpOnError = enabled
+	}
+}
+
+// DumpOnSignal dumps the ring whenever one of the given signals arrives,
+// e.g. syscall.SIGUSR1. Call Close to stop listening.
+func DumpOnSignal(sigs ...os.Signal) DebugRingOption {
+	return func(h *DebugRingHandler) {
+		h.signals = append(h.signals, sigs...)
+	}
+}
+
+// DumpTo sends dumped entries to h instead of the downstream Handler.
+func DumpTo(h Handler) DebugRingOption {
+	return func(r *DebugRingHandler) {
+		r.dumpTo = h
+	}
+}
+
+// Enabled reports true for levels the ring records in addition to the
+// levels the downstream Handler accepts.
+func (h *DebugRingHandler) Enabled(ctx context.Context, lvl Level) bool {
+	return h.recordLevel.Enabled(lvl) || h.next.Enabled(ctx, lvl)
+}
+
+// EnabledName is like Enabled but forwards the logger name downstream.
+func (h *DebugRingHandler) EnabledName(ctx context.Context, name string, lvl Level) bool {
+	return h.recordLevel.Enabled(lvl) || enabledName(h.next, ctx, name, lvl)
+}
+
+// Handle passes the entry downstream if the downstream Handler accepts
+// its level, and records it into the ring otherwise. An Error entry
+// dumps the ring first when DumpOnError is on.
+func (h *DebugRingHandler) Handle(ctx context.Context, e Entry) error {
+	if !enabledName(h.next, ctx, e.LoggerName, e.Level) {
+		if h.recordLevel.Enabled(e.Level) {
+			h.record(e)
+		}
+		return nil
+	}
+
+	var err error
+	if h.dumpOnError && e.Level == LevelError {
+		err = h.dump(ctx)
+	}
+	return errors.Join(err, h.next.Handle(ctx, e))
+}
+
+// Dump replays all recorded entries downstream, oldest first, and
+// empties the ring.
+func (h *DebugRingHandler) Dump() error {
+	return h.dump(context.Background())
+}
+
+// Stats returns a snapshot of the ring counters.
+func (h *DebugRingHandler) Stats() DebugRingStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return DebugRingStats{
+		Entries: h.n,
+		Bytes:   h.bytes,
+		Evicted: h.evicted,
+		Dumped:  h.dumped,
+	}
+}
+
+// Close stops listening for signals. The ring content is kept; call
+// Dump before Close if you want it. Safe to call multiple times.
+func (h *DebugRingHandler) Close() error {
+	h.closeOnce.Do(func() {
+		if h.sigCh != nil {
+			signal.Stop(h.sigCh)
+			close(h.done)
+		}
+	})
+	return nil
+}
+
+func (h *DebugRingHandler) signalLoop() {
+	for {
+		select {
+		case <-h.sigCh:
+			_ = h.Dump()
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// ringItem is a recorded entry with its accounted size.
+type ringItem struct {
+	e    Entry
+	size int
+}
+
+func (h *DebugRingHandler) record(e Entry) {
+	size := entrySize(e)
+	if size > h.maxBytes {
+		return
+	}
+	e.Fields = cloneFields(e.Fields)
+
+	h.mu.Lock()
+	for h.n > 0 && h.bytes+size > h.maxBytes {
+		h.pop()
+		h.evicted++
+	}
+	if h.n == len(h.ring) {
+		h.grow()
+	}
+	h.ring[(h.head+h.n)%len(h.ring)] = ringItem{e: e, size: size}
+	h.n++
+	h.bytes += size
+	h.mu.Unlock()
+}
+
+func (h *DebugRingHandler) dump(ctx context.Context) error {
+	h.mu.Lock()
+	if h.n == 0 {
+		h.mu.Unlock()
+		return nil
+	}
+	entries := make([]Entry, 0, h.n)
+	for h.n > 0 {
+		entries = append(entries, h.pop())
+	}
+	h.dumped += int64(len(entries))
+	h.mu.Unlock()
+
+	ctx = WithLevel(ctx, LevelDebug)
+	var err error
+	for _, e := range entries {
+		e.Fields = append(e.Fields, Bool(DefaultFieldKeyDumped, true))
+		err = errors.Join(err, h.dumpTo.Handle(ctx, e))
+	}
+	return err
+}
+
+// pop removes and returns the oldest item. Must be called with mu held.
+func (h *DebugRingHandler) pop() Entry {
+	it := h.ring[h.head]
+	h.ring[h.head] = ringItem{}
+	h.head = (h.head + 1) % len(h.ring)
+	h.n--
+	h.bytes -= it.size
+	return it.e
+}
+
+// grow doubles the ring capacity, keeping items in order. Must be called
+// with mu held.
+func (h *DebugRingHandler) grow() {
+	ring := make([]ringItem, max(2*len(h.ring), 16))
+	for i := 0; i < h.n; i++ {
+		ring[i] = h.ring[(h.head+i)%len(h.ring)]
+	}
+	h.ring = ring
+	h.head = 0
+}
+
+// entrySize estimates the memory held by a recorded copy of e.
+func entrySize(e Entry) int {
+	return int(unsafe.Sizeof(e)) + len(e.Text) + len(e.LoggerName) + fieldsSize(e.Fields)
+}
+
+// fieldsSize estimates the memory held by cloned fields, including the
+// data behind Ptr-based fields.
+func fieldsSize(fs []Field) int {
+	n := len(fs) * int(unsafe.Sizeof(Field{}))
+	for i := range fs {
+		f := &fs[i]
+		switch f.Type {
+		case FieldTypeBytes, FieldTypeBytesToString:
+			n += int(f.Val)
+		case FieldTypeBytesToInts64, FieldTypeBytesToFloats64, FieldTypeBytesToDurations:
+			n += int(f.Val) * 8
+		case FieldTypeBytesToStrings:
+			n += int(f.Val) * int(unsafe.Sizeof(""))
+			for _, s := range unsafe.Slice((*string)(f.Ptr), int(f.Val)) {
+				n += len(s)
+			}
+		case FieldTypeGroup:
+			if sub, ok := f.Any.([]Field); ok {
+				n += fieldsSize(sub)
+			}
+		}
+	}
+	return n
+}
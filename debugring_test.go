@@ -0,0 +1,180 @@
+package logf
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugRingHandlerRecordsFilteredEntries(t *testing.T) {
+	sink := newLeveledTestHandler(LevelInfo)
+	h := NewDebugRingHandler(sink, 1<<20)
+
+	assert.True(t, h.Enabled(context.Background(), LevelDebug))
+
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Text: "d1"})
+	_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Text: "i1"})
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Text: "d2"})
+
+	require.Len(t, sink.Entries, 1)
+	assert.Equal(t, "i1", sink.Entries[0].Text)
+	assert.Equal(t, 2, h.Stats().Entries)
+}
+
+func TestDebugRingHandlerDumpOnError(t *testing.T) {
+	sink := newLeveledTestHandler(LevelInfo)
+	h := NewDebugRingHandler(sink, 1<<20)
+
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Text: "d1"})
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Text: "d2"})
+	_ = h.Handle(context.Background(), Entry{Level: LevelError, Text: "boom"})
+
+	var texts []string
+	for _, e := range sink.Entries {
+		texts = append(texts, e.Text)
+	}
+	assert.Equal(t, []string{"d1", "d2", "boom"}, texts)
+	assert.Equal(t, []Field{Bool(DefaultFieldKeyDumped, true)}, sink.Entries[0].Fields)
+	assert.Empty(t, sink.Entries[2].Fields)
+	assert.Equal(t, DebugRingStats{Dumped: 2}, h.Stats())
+}
+
+func TestDebugRingHandlerDumpOnErrorDisabled(t *testing.T) {
+	sink := newLeveledTestHandler(LevelInfo)
+	h := NewDebugRingHandler(sink, 1<<20, DumpOnError(false))
+
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Text: "d1"})
+	_ = h.Handle(context.Background(), Entry{Level: LevelError, Text: "boom"})
+
+	assert.Len(t, sink.Entries, 1)
+	assert.Equal(t, 1, h.Stats().Entries)
+}
+
+func TestDebugRingHandlerDumpTo(t *testing.T) {
+	var buf bytes.Buffer
+	router, closeFn, err := NewRouter().
+		Route(JSON().DisableTime().Build(), Output(LevelInfo, &buf)).
+		Build()
+	require.NoError(t, err)
+	defer closeFn()
+
+	dumpSink := &testHandler{}
+	h := NewDebugRingHandler(router, 1<<20, DumpTo(dumpSink))
+
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Text: "d1"})
+	require.NoError(t, h.Dump())
+
+	require.Len(t, dumpSink.Entries, 1)
+	assert.Equal(t, "d1", dumpSink.Entries[0].Text)
+	assert.Empty(t, buf.String())
+}
+
+func TestDebugRingHandlerMemoryBound(t *testing.T) {
+	sink := newLeveledTestHandler(LevelInfo)
+	one := entrySize(Entry{Text: "0123456789"})
+	h := NewDebugRingHandler(sink, 3*one)
+
+	for i := 0; i < 10; i++ {
+		_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Text: strings.Repeat(string(rune('0'+i)), 10)})
+	}
+
+	stats := h.Stats()
+	assert.Equal(t, 3, stats.Entries)
+	assert.Equal(t, 3*one, stats.Bytes)
+	assert.Equal(t, int64(7), stats.Evicted)
+
+	// Entries larger than the whole ring are not recorded.
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Text: strings.Repeat("x", 4*one)})
+	assert.Equal(t, 3, h.Stats().Entries)
+
+	require.NoError(t, h.Dump())
+	require.Len(t, sink.Entries, 3)
+	assert.Equal(t, "7777777777", sink.Entries[0].Text)
+	assert.Equal(t, "9999999999", sink.Entries[2].Text)
+	assert.Equal(t, DebugRingStats{Evicted: 7, Dumped: 3}, h.Stats())
+}
+
+func TestDebugRingHandlerCopiesFields(t *testing.T) {
+	sink := newLeveledTestHandler(LevelInfo)
+	h := NewDebugRingHandler(sink, 1<<20)
+
+	buf := []byte("original")
+	strs := []string{"a", "b"}
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Fields: []Field{
+		ByteString("bs", buf),
+		Strings("strs", strs),
+	}})
+	copy(buf, "mutated!")
+	strs[0] = "z"
+
+	require.NoError(t, h.Dump())
+	require.Len(t, sink.Entries, 1)
+
+	enc := JSON().DisableTime().DisableLevel().DisableMsg().Build()
+	out, err := enc.Encode(sink.Entries[0])
+	require.NoError(t, err)
+	assert.Equal(t, `{"bs":"original","strs":["a","b"],"dumped":true}`+"\n", out.String())
+	out.Free()
+}
+
+func TestDebugRingHandlerDumpOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be sent to self on windows")
+	}
+	sink := &syncTestHandler{}
+	h := NewDebugRingHandler(NewSyncHandler(LevelInfo, &bytes.Buffer{}, JSON().Build()), 1<<20,
+		DumpTo(sink),
+		DumpOnSignal(os.Interrupt),
+	)
+	defer h.Close()
+
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Text: "d1"})
+
+	p, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, p.Signal(os.Interrupt))
+
+	assert.Eventually(t, func() bool {
+		return len(sink.Entries()) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestDebugRingHandlerWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewDebugRingHandler(NewSyncHandler(LevelInfo, &buf, JSON().DisableTime().Build()), 1<<20)
+	logger := New(h).WithCaller(false)
+
+	logger.Debug(context.Background(), "step", Int("n", 1))
+	logger.Info(context.Background(), "working")
+	assert.NotContains(t, buf.String(), "step")
+
+	logger.Error(context.Background(), "failed")
+	assert.Equal(t,
+		`{"level":"info","msg":"working"}`+"\n"+
+			`{"level":"debug","msg":"step","n":1,"dumped":true}`+"\n"+
+			`{"level":"error","msg":"failed"}`+"\n",
+		buf.String())
+}
+
+func TestDebugRingHandlerDumpThroughRouter(t *testing.T) {
+	var buf bytes.Buffer
+	router, closeFn, err := NewRouter().
+		Route(JSON().DisableTime().Build(), Output(LevelInfo, &buf)).
+		Build()
+	require.NoError(t, err)
+	defer closeFn()
+
+	h := NewDebugRingHandler(router, 1<<20)
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Text: "d1"})
+	assert.Empty(t, buf.String())
+	require.NoError(t, h.Dump())
+
+	assert.Equal(t, `{"level":"debug","msg":"d1","dumped":true}`+"\n", buf.String())
+}
@@ -0,0 +1,286 @@
+package logf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// NewDedupHandler returns a Handler middleware that collapses floods of
+// identical entries — the kind a flapping dependency produces. The first
+// entry of a kind is passed downstream immediately. Repeats arriving
+// within the window are held back, and when the window closes a single
+// entry is emitted in their place, carrying the number of repeats and
+// the timestamps of the first and last one:
+//
+//	{"level":"error","msg":"connection refused","repeated":4211,
+//	 "first":"2024-05-01T10:00:00Z","last":"2024-05-01T10:00:09Z"}
+//
+// If the entries have fields of their own with these keys, rename the
+// summary fields with DedupSummaryKeys.
+//
+// Entries are considered identical when their level, text, logger name
+// and caller match. Use DedupKeys to also compare the values of selected
+// fields, so that e.g. errors for different hosts are kept apart.
+//
+// A background timer emits the summary when the window closes. Call
+// Close on shutdown to flush pending summaries. DedupHandler is safe for
+// concurrent use.
+func NewDedupHandler(next Handler, window time.Duration, opts ...DedupOption) *DedupHandler {
+	h := &DedupHandler{
+		next:   next,
+		window: window,
+		seen:   make(map[string]*dedupState),
+		fpEnc:  &jsonEncoder{JSONEncoderConfig: JSONEncoderConfig{}.WithDefaults()},
+
+		keyRepeated: "repeated",
+		keyFirst:    "first",
+		keyLast:     "last",
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// DedupHandler is the Handler built by NewDedupHandler.
+type DedupHandler struct {
+	next   Handler
+	window time.Duration
+	keys   []string
+
+	keyRepeated string
+	keyFirst    string
+	keyLast     string
+
+	mu        sync.Mutex
+	seen      map[string]*dedupState // fingerprint → state
+	sweepAt   int                    // map size that triggers the next sweep
+	fp        Buffer                 // fingerprint scratch buffer
+	fpEnc     *jsonEncoder           // renders DedupKeys values into fp
+	collapsed int64
+	closed    bool
+}
+
+// DedupOption configures a DedupHandler at creation time.
+type DedupOption func(*DedupHandler)
+
+// DedupKeys adds the values of the given field keys to the fingerprint.
+// Fields are looked up in per-call fields first, then in the context
+// Bag and the logger Bag. Missing fields compare as equal.
+func DedupKeys(keys ...string) DedupOption {
+	return func(h *DedupHandler) {
+		h.keys = append(h.keys, keys...)
+	}
+}
+
+// DedupSummaryKeys sets the keys of the summary fields holding the number
+// of repeats and the timestamps of the first and last one (default
+// "repeated", "first" and "last"). Empty keys keep their default.
+func DedupSummaryKeys(repeated, first, last string) DedupOption {
+	return func(h *DedupHandler) {
+		if repeated != "" {
+			h.keyRepeated = repeated
+		}
+		if first != "" {
+			h.keyFirst = first
+		}
+		if last != "" {
+			h.keyLast = last
+		}
+	}
+}
+
+// dedupState tracks one fingerprint within its window.
+type dedupState struct {
+	windowEnd int64     // unix nanoseconds
+	held      Entry     // copy of the first held-back repeat
+	count     int64     // number of held-back repeats
+	first     time.Time // timestamp of the first repeat
+	last      time.Time // timestamp of the last repeat
+	timer     *time.Timer
+}
+
+// Enabled delegates to the downstream Handler.
+func (h *DedupHandler) Enabled(ctx context.Context, lvl Level) bool {
+	return h.next.Enabled(ctx, lvl)
+}
+
+// EnabledName forwards the logger name to the downstream Handler.
+func (h *DedupHandler) EnabledName(ctx context.Context, name string, lvl Level) bool {
+	return enabledName(h.next, ctx, name, lvl)
+}
+
+// Handle passes the first entry of a kind downstream and holds back its
+// repeats until the window closes.
+func (h *DedupHandler) Handle(ctx context.Context, e Entry) error {
+	if h.window <= 0 {
+		return h.next.Handle(ctx, e)
+	}
+	now := entryUnixNano(e)
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return h.next.Handle(ctx, e)
+	}
+	h.fingerprint(e)
+	st := h.seen[string(h.fp.Data)]
+	if st == nil || now >= st.windowEnd {
+		var pending Entry
+		var hasPending bool
+		if st != nil && st.count > 0 {
+			// The timer has not fired yet; emit its summary ourselves
+			// to keep the output in order.
+			st.timer.Stop()
+			pending, hasPending = h.summary(st), true
+		}
+		h.sweep(now)
+		h.seen[string(h.fp.Data)] = &dedupState{windowEnd: now + int64(h.window)}
+		h.mu.Unlock()
+
+		if hasPending {
+			if err := h.next.Handle(ctx, pending); err != nil {
+				return err
+			}
+		}
+		return h.next.Handle(ctx, e)
+	}
+
+	// A repeat within the window: hold it back.
+	t := e.Time
+	if t.IsZero() {
+		t = time.Unix(0, now)
+	}
+	if st.count == 0 {
+		st.held = e
+		st.held.Fields = cloneFields(e.Fields)
+		st.first = t
+		fp := string(h.fp.Data)
+		st.timer = time.AfterFunc(time.Duration(st.windowEnd-now), func() {
+			h.expire(fp, st)
+		})
+	}
+	st.count++
+	st.last = t
+	h.collapsed++
+	h.mu.Unlock()
+
+	return nil
+}
+
+// Collapsed returns the total number of entries held back and replaced
+// by summaries.
+func (h *DedupHandler) Collapsed() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.collapsed
+}
+
+// Close emits all pending summaries without waiting for their windows
+// to close. Entries handled after Close are passed through unchanged.
+func (h *DedupHandler) Close() error {
+	h.mu.Lock()
+	h.closed = true
+	var pending []Entry
+	for fp, st := range h.seen {
+		if st.count > 0 {
+			st.timer.Stop()
+			pending = append(pending, h.summary(st))
+		}
+		delete(h.seen, fp)
+	}
+	h.mu.Unlock()
+
+	var err error
+	for _, e := range pending {
+		err = errors.Join(err, h.next.Handle(context.Background(), e))
+	}
+	return err
+}
+
+// expire is called by the window timer of st.
+func (h *DedupHandler) expire(fp string, st *dedupState) {
+	h.mu.Lock()
+	if h.seen[fp] != st || st.count == 0 {
+		// Already flushed by Handle or Close.
+		h.mu.Unlock()
+		return
+	}
+	delete(h.seen, fp)
+	summary := h.summary(st)
+	h.mu.Unlock()
+
+	_ = h.next.Handle(context.Background(), summary)
+}
+
+// sweep drops expired states that never saw a repeat (and so have no
+// timer to clean them up). It runs whenever the map has doubled since
+// the last sweep, which keeps the amortized cost constant. Must be
+// called with mu held.
+func (h *DedupHandler) sweep(now int64) {
+	if len(h.seen) < h.sweepAt {
+		return
+	}
+	for fp, st := range h.seen {
+		if st.count == 0 && now >= st.windowEnd {
+			delete(h.seen, fp)
+		}
+	}
+	h.sweepAt = max(2*len(h.seen), 64)
+}
+
+// fingerprint renders the identity of e into h.fp. Must be called with
+// mu held.
+func (h *DedupHandler) fingerprint(e Entry) {
+	h.fp.Reset()
+	h.fp.AppendByte(byte(e.Level))
+	h.fp.AppendUint(uint64(e.CallerPC))
+	h.fp.AppendByte(0)
+	h.fp.AppendString(e.LoggerName)
+	h.fp.AppendByte(0)
+	h.fp.AppendString(e.Text)
+	for _, k := range h.keys {
+		h.fp.AppendByte(0)
+		if f, ok := lookupField(e, k); ok {
+			h.fpEnc.TypeEncoder(&h.fp)
+			f.Accept(h.fpEnc)
+		}
+	}
+}
+
+// summary builds the entry that replaces the held-back repeats.
+func (h *DedupHandler) summary(st *dedupState) Entry {
+	e := st.held
+	e.Time = st.last
+	fs := make([]Field, 0, len(e.Fields)+3)
+	fs = append(fs, e.Fields...)
+	fs = append(fs,
+		Int64(h.keyRepeated, st.count),
+		Time(h.keyFirst, st.first),
+		Time(h.keyLast, st.last),
+	)
+	e.Fields = fs
+	return e
+}
+
+// lookupField finds a field by key: per-call fields first, then the
+// context Bag, then the logger Bag (child nodes before parents).
+func lookupField(e Entry, key string) (Field, bool) {
+	for i := len(e.Fields) - 1; i >= 0; i-- {
+		if e.Fields[i].Key == key {
+			return e.Fields[i], true
+		}
+	}
+	for _, bag := range [2]*Bag{e.Bag, e.LoggerBag} {
+		for b := bag; b != nil; b = b.parent {
+			for i := len(b.fields) - 1; i >= 0; i-- {
+				if b.fields[i].Key == key {
+					return b.fields[i], true
+				}
+			}
+		}
+	}
+	return Field{}, false
+}
@@ -0,0 +1,199 @@
+package logf
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncTestHandler is a testHandler safe for use from timer goroutines.
+type syncTestHandler struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func (h *syncTestHandler) Handle(_ context.Context, e Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, e)
+	return nil
+}
+
+func (h *syncTestHandler) Enabled(context.Context, Level) bool {
+	return true
+}
+
+func (h *syncTestHandler) Entries() []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Entry(nil), h.entries...)
+}
+
+func TestDedupHandlerCollapsesRepeats(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewDedupHandler(sink, time.Hour)
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		_ = h.Handle(context.Background(), Entry{Level: LevelError, Text: "refused", Time: now.Add(time.Duration(i) * time.Second)})
+	}
+	require.Len(t, sink.Entries(), 1)
+	assert.Equal(t, int64(4), h.Collapsed())
+
+	require.NoError(t, h.Close())
+	entries := sink.Entries()
+	require.Len(t, entries, 2)
+
+	summary := entries[1]
+	assert.Equal(t, "refused", summary.Text)
+	assert.Equal(t, LevelError, summary.Level)
+	assert.Equal(t, []Field{
+		Int64("repeated", 4),
+		Time("first", now.Add(time.Second)),
+		Time("last", now.Add(4*time.Second)),
+	}, summary.Fields)
+}
+
+func TestDedupHandlerFingerprint(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewDedupHandler(sink, time.Hour)
+
+	now := time.Now()
+	entries := []Entry{
+		{Level: LevelError, Text: "a", Time: now},
+		{Level: LevelWarn, Text: "a", Time: now},
+		{Level: LevelError, Text: "b", Time: now},
+		{Level: LevelError, Text: "a", LoggerName: "db", Time: now},
+		{Level: LevelError, Text: "a", CallerPC: 42, Time: now},
+		{Level: LevelError, Text: "a", Time: now, Fields: []Field{String("host", "x")}},
+	}
+	for _, e := range entries {
+		_ = h.Handle(context.Background(), e)
+	}
+
+	// All but the last differ; fields are not part of the default fingerprint.
+	assert.Len(t, sink.Entries(), 5)
+}
+
+func TestDedupHandlerKeys(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewDedupHandler(sink, time.Hour, DedupKeys("host"))
+
+	now := time.Now()
+	ctx := With(context.Background(), String("host", "c"))
+	_ = h.Handle(context.Background(), Entry{Text: "down", Time: now, Fields: []Field{String("host", "a")}})
+	_ = h.Handle(context.Background(), Entry{Text: "down", Time: now, Fields: []Field{String("host", "b")}})
+	_ = h.Handle(context.Background(), Entry{Text: "down", Time: now, Fields: []Field{String("host", "a")}})
+	_ = h.Handle(ctx, Entry{Text: "down", Time: now, Bag: BagFromContext(ctx)})
+	_ = h.Handle(ctx, Entry{Text: "down", Time: now, Bag: BagFromContext(ctx)})
+
+	assert.Len(t, sink.Entries(), 3)
+}
+
+func TestDedupHandlerWindowTimer(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewDedupHandler(sink, 20*time.Millisecond)
+
+	_ = h.Handle(context.Background(), Entry{Text: "flap"})
+	_ = h.Handle(context.Background(), Entry{Text: "flap"})
+	_ = h.Handle(context.Background(), Entry{Text: "flap"})
+
+	assert.Eventually(t, func() bool {
+		return len(sink.Entries()) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	summary := sink.Entries()[1]
+	assert.Equal(t, Int64("repeated", 2), summary.Fields[0])
+
+	// The window is closed: the next entry passes immediately.
+	_ = h.Handle(context.Background(), Entry{Text: "flap"})
+	assert.Len(t, sink.Entries(), 3)
+}
+
+func TestDedupHandlerNextWindowFlushesInOrder(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewDedupHandler(sink, time.Hour)
+
+	now := time.Now()
+	_ = h.Handle(context.Background(), Entry{Text: "x", Time: now})
+	_ = h.Handle(context.Background(), Entry{Text: "x", Time: now})
+	_ = h.Handle(context.Background(), Entry{Text: "x", Time: now.Add(time.Hour)})
+
+	entries := sink.Entries()
+	require.Len(t, entries, 3)
+	assert.Equal(t, Int64("repeated", 1), entries[1].Fields[0])
+	assert.Empty(t, entries[2].Fields)
+	require.NoError(t, h.Close())
+	assert.Len(t, sink.Entries(), 3)
+}
+
+func TestDedupHandlerCopiesHeldFields(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewDedupHandler(sink, time.Hour)
+
+	now := time.Now()
+	buf := []byte("original")
+	ints := []int64{1, 2, 3}
+	for i := 0; i < 2; i++ {
+		_ = h.Handle(context.Background(), Entry{Text: "x", Time: now, Fields: []Field{
+			ByteString("bs", buf),
+			Ints64("ints", ints),
+			Group("g", ByteString("nested", buf)),
+		}})
+	}
+	copy(buf, "mutated!")
+	ints[0] = 100
+
+	require.NoError(t, h.Close())
+	entries := sink.Entries()
+	require.Len(t, entries, 2)
+
+	enc := JSON().DisableTime().DisableLevel().DisableMsg().Build()
+	out, err := enc.Encode(Entry{Fields: entries[1].Fields[:3]})
+	require.NoError(t, err)
+	assert.Equal(t, `{"bs":"original","ints":[1,2,3],"g":{"nested":"original"}}`+"\n", out.String())
+	out.Free()
+}
+
+func TestDedupHandlerSummaryKeys(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewDedupHandler(sink, time.Hour, DedupSummaryKeys("dedup.repeated", "", "dedup.last"))
+
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		_ = h.Handle(context.Background(), Entry{Text: "x", Time: now, Fields: []Field{
+			Int("repeated", 7), String("first", "a"), String("last", "z"),
+		}})
+	}
+	require.NoError(t, h.Close())
+	entries := sink.Entries()
+	require.Len(t, entries, 2)
+
+	enc := JSON().DisableTime().DisableLevel().DisableMsg().Build()
+	out, err := enc.Encode(Entry{Fields: entries[1].Fields})
+	require.NoError(t, err)
+	assert.Equal(t, `{"repeated":7,"first":"a","last":"z","dedup.repeated":1,`+
+		`"first":"2024-05-01T10:00:00Z","dedup.last":"2024-05-01T10:00:00Z"}`+"\n", out.String())
+	out.Free()
+}
+
+func TestDedupHandlerPassThroughAfterClose(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewDedupHandler(sink, time.Hour)
+	require.NoError(t, h.Close())
+
+	_ = h.Handle(context.Background(), Entry{Text: "x"})
+	_ = h.Handle(context.Background(), Entry{Text: "x"})
+	assert.Len(t, sink.Entries(), 2)
+}
+
+func TestDedupHandlerEnabledDelegates(t *testing.T) {
+	h := NewDedupHandler(newLeveledTestHandler(LevelError), time.Second)
+
+	assert.True(t, h.Enabled(context.Background(), LevelError))
+	assert.False(t, h.Enabled(context.Background(), LevelWarn))
+}
@@ -0,0 +1,91 @@
+package logf
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"unsafe"
+)
+
+// ECSVersion is the version of the Elastic Common Schema written as
+// ecs.version by the ECS preset.
+const ECSVersion = "8.11.0"
+
+// ECS switches the encoder to the Elastic Common Schema, the layout
+// Elasticsearch and Kibana expect:
+//
+//	{"log.level":"info","@timestamp":"2024-05-01T10:00:00.123456789Z","log.logger":"http",
+//	 "message":"request served","log.origin":{"file.name":"server/http.go","file.line":42,
+//	 "function":"main.serve"},"ecs.version":"8.11.0","error":{"message":"boom","type":"*errors.errorString"}}
+//
+// It sets the keys of the built-in fields, nanosecond RFC3339 timestamps,
+// ECSCallerEncoder and ECSErrorEncoder, and adds ecs.version to every
+// entry. Call it first: later builder calls override its settings.
+func (b *JSONEncoderBuilder) ECS() *JSONEncoderBuilder {
+	b.cfg.FieldKeyTime = "@timestamp"
+	b.cfg.FieldKeyLevel = "log.level"
+	b.cfg.FieldKeyMsg = "message"
+	b.cfg.FieldKeyName = "log.logger"
+	b.cfg.FieldKeyCaller = "log.origin"
+	b.cfg.EncodeTime = RFC3339NanoTimeEncoder
+	b.cfg.EncodeLevel = DefaultLevelEncoder
+	b.cfg.EncodeCaller = ECSCallerEncoder
+	b.cfg.EncodeError = ECSErrorEncoder
+	b.cfg.static = []byte(`"ecs.version":"` + ECSVersion + `"`)
+	return b
+}
+
+// ECSCallerEncoder formats the caller as an ECS log.origin object with
+// the short file name, line and function:
+//
+//	{"file.name":"server/http.go","file.line":42,"function":"main.serve"}
+func ECSCallerEncoder(pc uintptr, m TypeEncoder) {
+	o := ecsOrigin{pc: pc}
+	m.EncodeTypeObject((*ecsOrigin)(noescape(unsafe.Pointer(&o))))
+	runtime.KeepAlive(&o)
+}
+
+type ecsOrigin struct {
+	pc uintptr
+}
+
+func (o *ecsOrigin) EncodeLogfObject(e FieldEncoder) error {
+	frame, _ := runtime.CallersFrames([]uintptr{o.pc}).Next()
+	e.EncodeFieldString("file.name", fileWithPackage(frame.File))
+	e.EncodeFieldInt64("file.line", int64(frame.Line))
+	if frame.Function != "" {
+		e.EncodeFieldString("function", frame.Function)
+	}
+	return nil
+}
+
+// ECSErrorEncoder encodes an error as an ECS error object under the given
+// key: the message, the dynamic type, and, if the error implements
+// fmt.Formatter and "%+v" adds to the message, the stack trace.
+//
+//	{"message":"boom","type":"*errors.errorString"}
+func ECSErrorEncoder(key string, err error, enc FieldEncoder) {
+	o := ecsError{err: err}
+	enc.EncodeFieldObject(key, (*ecsError)(noescape(unsafe.Pointer(&o))))
+	runtime.KeepAlive(&o)
+}
+
+type ecsError struct {
+	err error
+}
+
+func (o *ecsError) EncodeLogfObject(e FieldEncoder) error {
+	if o.err == nil {
+		e.EncodeFieldString("message", "<nil>")
+		return nil
+	}
+	msg := o.err.Error()
+	e.EncodeFieldString("message", msg)
+	e.EncodeFieldString("type", reflect.TypeOf(o.err).String())
+	if _, ok := o.err.(fmt.Formatter); ok {
+		if verbose := fmt.Sprintf("%+v", o.err); verbose != msg {
+			e.EncodeFieldString("stack_trace", verbose)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package logf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stackError is an error that prints a stack trace with "%+v".
+type stackError struct{ msg string }
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		fmt.Fprintf(s, "%s\nmain.handler\n\tmain.go:42", e.msg)
+		return
+	}
+	fmt.Fprint(s, e.msg)
+}
+
+func TestJSONEncoderECS(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	testCases := []encoderTestCase{
+		{
+			"Header",
+			Entry{Level: LevelInfo, Time: ts, LoggerName: "http", Text: "request served"},
+			`{"log.level":"info","@timestamp":"2024-05-01T10:00:00.123456789Z","log.logger":"http",` +
+				`"message":"request served","ecs.version":"` + ECSVersion + `"}` + "\n",
+		},
+		{
+			"Errors",
+			Entry{Level: LevelError, Time: ts, Text: "failed", Fields: []Field{
+				Error(errors.New("boom")),
+				NamedError("cause", &stackError{"deep"}),
+				Error(nil),
+			}},
+			`{"log.level":"error","@timestamp":"2024-05-01T10:00:00.123456789Z","message":"failed","ecs.version":"` + ECSVersion + `",` +
+				`"error":{"message":"boom","type":"*errors.errorString"},` +
+				`"cause":{"message":"deep","type":"*logf.stackError","stack_trace":"deep\nmain.handler\n\tmain.go:42"},` +
+				`"error":{"message":"<nil>"}}` + "\n",
+		},
+		{
+			"Bags",
+			Entry{
+				Level:     LevelInfo,
+				Time:      ts,
+				Text:      "m",
+				LoggerBag: NewBag(String("service.name", "api")).WithGroup("http"),
+				Fields:    []Field{Int("status", 200)},
+			},
+			`{"log.level":"info","@timestamp":"2024-05-01T10:00:00.123456789Z","message":"m","ecs.version":"` + ECSVersion + `",` +
+				`"service.name":"api","http":{"status":200}}` + "\n",
+		},
+	}
+
+	enc := JSON().ECS().Build()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden, b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestJSONEncoderECSCaller(t *testing.T) {
+	enc := JSON().ECS().Build()
+	b, err := enc.Encode(Entry{Level: LevelInfo, Text: "m", CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	defer b.Free()
+
+	var m struct {
+		Origin struct {
+			File     string `json:"file.name"`
+			Line     int    `json:"file.line"`
+			Function string `json:"function"`
+		} `json:"log.origin"`
+	}
+	require.NoError(t, json.Unmarshal(b.Bytes(), &m))
+	assert.Regexp(t, `^[^/]+/ecs_test.go$`, m.Origin.File)
+	assert.Positive(t, m.Origin.Line)
+	assert.Equal(t, "github.com/ssgreg/logf/v2.TestJSONEncoderECSCaller", m.Origin.Function)
+}
+
+func TestJSONEncoderECSOverride(t *testing.T) {
+	// Builder calls after ECS override the preset; ecs.version stays.
+	enc := JSON().ECS().TimeKey("time").EncodeTime(UnixNanoTimeEncoder).DisableLevel().Build()
+	b, err := enc.Encode(Entry{Time: time.Unix(0, 42), Text: "m"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"time":42,"message":"m","ecs.version":"`+ECSVersion+`"}`+"\n", b.String())
+	b.Free()
+}
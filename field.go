@@ -187,6 +187,17 @@ func Group(k string, fs ...Field) Field {
 	return Field{Key: k, Type: FieldTypeGroup, Any: fs}
 }
 
+// SamplingKey returns a pseudo-field that is never written to the output.
+// Instead, it gives SamplingHandler an explicit grouping key: entries
+// carrying the same sampling key share one counter, no matter which call
+// site produced them. It works both as a per-call field and in a Bag
+// (Logger.With or logf.With), where the nearest one wins.
+//
+//	logger.Info(ctx, "request", logf.SamplingKey("http.request"), ...)
+func SamplingKey(k string) Field {
+	return Field{Key: k, Type: FieldTypeSamplingKey}
+}
+
 // Stringer returns a Field that calls v.String() and logs the result as
 // a string under the given key. Nil values are logged as "nil".
 func Stringer(k string, v fmt.Stringer) Field {
@@ -392,6 +403,9 @@ const (
 	FieldTypeArray
 	FieldTypeObject
 	FieldTypeGroup
+
+	// Pseudo-fields (consumed by handlers, never encoded).
+	FieldTypeSamplingKey
 )
 
 // Field is the fundamental key-value unit in logf's structured logging.
@@ -468,6 +482,55 @@ func (fd Field) Optional() Field {
 	return fd
 }
 
+// cloneFields returns a deep copy of fs that stays valid after the caller
+// reuses or mutates the memory the original fields point to (ByteString,
+// Bytes, Strings, Ints64, etc.). Handlers that keep entries beyond the
+// Handle call must hold clones, not the originals. Values behind
+// interfaces (Any, Object, Array, Error) are copied by reference.
+func cloneFields(fs []Field) []Field {
+	if len(fs) == 0 {
+		return nil
+	}
+	out := make([]Field, len(fs))
+	for i := range fs {
+		out[i] = fs[i].clone()
+	}
+	return out
+}
+
+// clone returns a copy of the field that owns its Ptr-based data.
+func (fd Field) clone() Field {
+	switch fd.Type {
+	case FieldTypeBytes:
+		fd.Ptr = cloneSlice[byte](fd.Ptr, fd.Val)
+	case FieldTypeBytesToString:
+		fd.Ptr = cloneSlice[byte](fd.Ptr, fd.Val)
+	case FieldTypeBytesToInts64:
+		fd.Ptr = cloneSlice[int64](fd.Ptr, fd.Val)
+	case FieldTypeBytesToFloats64:
+		fd.Ptr = cloneSlice[float64](fd.Ptr, fd.Val)
+	case FieldTypeBytesToDurations:
+		fd.Ptr = cloneSlice[time.Duration](fd.Ptr, fd.Val)
+	case FieldTypeBytesToStrings:
+		fd.Ptr = cloneSlice[string](fd.Ptr, fd.Val)
+	case FieldTypeGroup:
+		if fs, ok := fd.Any.([]Field); ok {
+			fd.Any = cloneFields(fs)
+		}
+	}
+	return fd
+}
+
+// cloneSlice copies n elements of type T starting at p into a new array.
+func cloneSlice[T any](p unsafe.Pointer, n int64) unsafe.Pointer {
+	if p == nil || n == 0 {
+		return p
+	}
+	dst := make([]T, n)
+	copy(dst, unsafe.Slice((*T)(p), int(n)))
+	return unsafe.Pointer(unsafe.SliceData(dst))
+}
+
 // isNilValue reports whether v is nil, including typed nil pointers,
 // channels, maps, slices, and functions wrapped in an interface.
 func isNilValue(v interface{}) bool {
@@ -487,6 +550,9 @@ func (fd Field) Accept(v FieldEncoder) {
 	case FieldTypeUnknown:
 		// Skip — empty field produced by If(false) or Optional().
 		return
+	case FieldTypeSamplingKey:
+		// Skip — consumed by SamplingHandler, not part of the output.
+		return
 	case FieldTypeAny:
 		v.EncodeFieldAny(fd.Key, fd.Any)
 	case FieldTypeBool:
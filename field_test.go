@@ -840,3 +840,31 @@ func TestFieldIfAndOptionalJSON(t *testing.T) {
 	assert.NotContains(t, gotText, "d=")
 	assert.NotContains(t, gotText, "error=")
 }
+
+func TestFieldSamplingKeyNotEncoded(t *testing.T) {
+	e := newTestFieldEncoder()
+	SamplingKey("http.request").Accept(e)
+	assert.Empty(t, e.result)
+
+	var buf bytes.Buffer
+	logger := NewLogger().Output(&buf).Build().WithCaller(false).
+		With(SamplingKey("bag.key"), String("a", "1"))
+
+	logger.Info(context.Background(), "test", SamplingKey("http.request"), Int("b", 2))
+
+	got := buf.String()
+	assert.Contains(t, got, `"a":"1","b":2}`)
+	assert.NotContains(t, got, "http.request")
+	assert.NotContains(t, got, "bag.key")
+
+	buf.Reset()
+	loggerText := NewLogger().Output(&buf).
+		EncoderFrom(Text().NoColor().DisableTime().DisableCaller()).
+		Build()
+
+	loggerText.Info(context.Background(), "test", SamplingKey("http.request"), Int("b", 2))
+
+	gotText := buf.String()
+	assert.Contains(t, gotText, "b=2")
+	assert.NotContains(t, gotText, "http.request")
+}
@@ -0,0 +1,353 @@
+package logf
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultForwardBatchSize is the default size in bytes of the entries a
+// ForwardWriter collects before it sends them.
+const DefaultForwardBatchSize = 64 * 1024
+
+// NewForwardWriter returns a Writer that sends entries to Fluentd or Fluent
+// Bit using the Forward protocol. network is "tcp" or "unix", tag is the
+// Fluent tag of all entries. Entries must be encoded by MsgPack().Forward():
+//
+//	w, err := logf.NewForwardWriter("tcp", "fluent-bit:24224", "app.api", logf.ForwardAck(5*time.Second))
+//	router, close, _ := logf.NewRouter().
+//	    Route(logf.MsgPack().Forward().Build(), logf.OutputCloser(logf.LevelInfo, w)).
+//	    Build()
+//
+// Entries are collected and sent as one PackedForward message when the
+// batch reaches ForwardBatchSize bytes, every ForwardFlushInterval, and on
+// Flush, Sync and Close. With ForwardAck every message carries a random
+// chunk id and the writer waits for the receiver to acknowledge it. A
+// failed send reconnects and retries once; a message that fails twice is
+// dropped with an error. The shared key handshake is not supported.
+//
+// Sending happens on the goroutine that fills the batch, so use an
+// AsyncHandler to keep it off the logging goroutine. Pass the writer to
+// OutputCloser to send the last batch when the router closes.
+// ForwardWriter is safe for concurrent use.
+func NewForwardWriter(network, addr, tag string, opts ...ForwardWriterOption) (*ForwardWriter, error) {
+	w := &ForwardWriter{
+		network:       network,
+		addr:          addr,
+		tag:           tag,
+		batchSize:     DefaultForwardBatchSize,
+		flushInterval: time.Second,
+		dialTimeout:   5 * time.Second,
+		msg:           NewBuffer(),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("logf: unsupported forward network %q", network)
+	}
+	if tag == "" {
+		return nil, errors.New("logf: forward tag is empty")
+	}
+
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+
+	w.stopped.Add(1)
+	go w.flushLoop()
+	return w, nil
+}
+
+// ForwardWriterOption configures a ForwardWriter at creation time.
+type ForwardWriterOption func(*ForwardWriter)
+
+// ForwardBatchSize sets the size in bytes of the entries collected before
+// they are sent (default 64 KiB).
+func ForwardBatchSize(n int) ForwardWriterOption {
+	return func(w *ForwardWriter) {
+		w.batchSize = n
+	}
+}
+
+// ForwardFlushInterval sets how often a partial batch is sent (default
+// 1s). Zero or negative disables periodic sends.
+func ForwardFlushInterval(d time.Duration) ForwardWriterOption {
+	return func(w *ForwardWriter) {
+		w.flushInterval = d
+	}
+}
+
+// ForwardAck requests an acknowledgement for every message and waits up
+// to timeout for it. An unacknowledged message is resent once.
+func ForwardAck(timeout time.Duration) ForwardWriterOption {
+	return func(w *ForwardWriter) {
+		w.ackTimeout = timeout
+	}
+}
+
+// ForwardDialTimeout sets the timeout for connecting to the receiver
+// (default 5s).
+func ForwardDialTimeout(d time.Duration) ForwardWriterOption {
+	return func(w *ForwardWriter) {
+		w.dialTimeout = d
+	}
+}
+
+// ForwardWriter is the Writer built by NewForwardWriter.
+type ForwardWriter struct {
+	network       string
+	addr          string
+	tag           string
+	batchSize     int
+	flushInterval time.Duration
+	ackTimeout    time.Duration
+	dialTimeout   time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+	entries []byte
+	count   int
+	msg     *Buffer
+	closed  bool
+
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// Write adds one entry to the batch and sends the batch when it is full.
+func (w *ForwardWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 || p[0] != 0x92 {
+		return 0, errors.New("logf: forward writer needs entries encoded by MsgPack().Forward()")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, errors.New("logf: forward writer is closed")
+	}
+
+	w.entries = append(w.entries, p...)
+	w.count++
+	if len(w.entries) >= w.batchSize {
+		if err := w.send(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends the collected entries.
+func (w *ForwardWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.send()
+}
+
+// Sync sends the collected entries.
+func (w *ForwardWriter) Sync() error {
+	return w.Flush()
+}
+
+// Close sends the collected entries and closes the connection. Writes
+// after Close fail.
+func (w *ForwardWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.done)
+	w.stopped.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.send()
+	if w.conn != nil {
+		if cerr := w.conn.Close(); err == nil {
+			err = cerr
+		}
+		w.conn = nil
+	}
+	return err
+}
+
+func (w *ForwardWriter) flushLoop() {
+	defer w.stopped.Done()
+	if w.flushInterval <= 0 {
+		<-w.done
+		return
+	}
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			_ = w.Flush()
+		}
+	}
+}
+
+func (w *ForwardWriter) dial() error {
+	conn, err := net.DialTimeout(w.network, w.addr, w.dialTimeout)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	if w.ackTimeout > 0 {
+		w.reader = bufio.NewReader(conn)
+	}
+	return nil
+}
+
+// send writes the batch as a PackedForward message:
+// [tag, bin(entries), {"size": count, "chunk": id}].
+func (w *ForwardWriter) send() error {
+	if w.count == 0 {
+		return nil
+	}
+
+	msg := w.msg
+	msg.Reset()
+	msg.AppendByte(0x93)
+	appendMsgpackStr(msg, w.tag)
+	appendMsgpackBin(msg, w.entries)
+	var chunk string
+	if w.ackTimeout > 0 {
+		var id [16]byte
+		_, _ = rand.Read(id[:])
+		chunk = base64.StdEncoding.EncodeToString(id[:])
+		msg.AppendByte(0x82)
+		appendMsgpackStr(msg, "size")
+		appendMsgpackUint(msg, uint64(w.count))
+		appendMsgpackStr(msg, "chunk")
+		appendMsgpackStr(msg, chunk)
+	} else {
+		msg.AppendByte(0x81)
+		appendMsgpackStr(msg, "size")
+		appendMsgpackUint(msg, uint64(w.count))
+	}
+	w.entries = w.entries[:0]
+	w.count = 0
+
+	for attempt := 0; ; attempt++ {
+		err := w.write(msg.Data, chunk)
+		if err == nil {
+			return nil
+		}
+		if w.conn != nil {
+			_ = w.conn.Close()
+			w.conn = nil
+		}
+		if attempt > 0 {
+			return fmt.Errorf("logf: forward message dropped: %w", err)
+		}
+	}
+}
+
+func (w *ForwardWriter) write(p []byte, chunk string) error {
+	if w.conn == nil {
+		if err := w.dial(); err != nil {
+			return err
+		}
+	}
+	if _, err := w.conn.Write(p); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+
+	_ = w.conn.SetReadDeadline(time.Now().Add(w.ackTimeout))
+	ack, err := readForwardAck(w.reader)
+	if err != nil {
+		return err
+	}
+	if ack != chunk {
+		return fmt.Errorf("logf: forward ack %q does not match chunk %q", ack, chunk)
+	}
+	return nil
+}
+
+// readForwardAck reads the receiver's response {"ack": chunk} and returns
+// the chunk id.
+func readForwardAck(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if b&0xf0 != 0x80 {
+		return "", fmt.Errorf("logf: unexpected forward response 0x%02x", b)
+	}
+
+	var ack string
+	for n := int(b & 0x0f); n > 0; n-- {
+		k, err := readMsgpackStr(r)
+		if err != nil {
+			return "", err
+		}
+		v, err := readMsgpackStr(r)
+		if err != nil {
+			return "", err
+		}
+		if k == "ack" {
+			ack = v
+		}
+	}
+	return ack, nil
+}
+
+func readMsgpackStr(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	var n int
+	var size [4]byte
+	switch {
+	case b&0xe0 == 0xa0:
+		n = int(b & 0x1f)
+	case b == 0xd9:
+		if _, err = io.ReadFull(r, size[:1]); err != nil {
+			return "", err
+		}
+		n = int(size[0])
+	case b == 0xda:
+		if _, err = io.ReadFull(r, size[:2]); err != nil {
+			return "", err
+		}
+		n = int(binary.BigEndian.Uint16(size[:]))
+	case b == 0xdb:
+		if _, err = io.ReadFull(r, size[:]); err != nil {
+			return "", err
+		}
+		n = int(binary.BigEndian.Uint32(size[:]))
+	default:
+		return "", fmt.Errorf("logf: unexpected forward response 0x%02x", b)
+	}
+
+	s := make([]byte, n)
+	if _, err = io.ReadFull(r, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
@@ -0,0 +1,324 @@
+package logf
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// forwardTestMessage is a PackedForward message received by
+// forwardTestServer, with its entries rendered by msgpackTestReader.
+type forwardTestMessage struct {
+	tag     string
+	entries []string
+	size    int
+	chunk   string
+}
+
+// forwardTestServer is a stand-in for Fluentd: it decodes PackedForward
+// messages and acknowledges chunks.
+type forwardTestServer struct {
+	ln       net.Listener
+	messages chan forwardTestMessage
+
+	// dropAcks is the number of chunks to answer by closing the
+	// connection instead of acknowledging them.
+	dropAcks atomic.Int32
+}
+
+func newForwardTestServer(t *testing.T) *forwardTestServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	s := &forwardTestServer{ln: ln, messages: make(chan forwardTestMessage, 10)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *forwardTestServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *forwardTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := readForwardTestMessage(r)
+		if err != nil {
+			return
+		}
+		s.messages <- msg
+		if msg.chunk == "" {
+			continue
+		}
+		if s.dropAcks.Add(-1) >= 0 {
+			return
+		}
+		ack := NewBuffer()
+		ack.AppendByte(0x81)
+		appendMsgpackStr(ack, "ack")
+		appendMsgpackStr(ack, msg.chunk)
+		if _, err := conn.Write(ack.Bytes()); err != nil {
+			return
+		}
+	}
+}
+
+func (s *forwardTestServer) next(t *testing.T) forwardTestMessage {
+	t.Helper()
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no forward message received")
+		return forwardTestMessage{}
+	}
+}
+
+func readForwardTestMessage(r *bufio.Reader) (forwardTestMessage, error) {
+	var msg forwardTestMessage
+	if b, err := r.ReadByte(); err != nil || b != 0x93 {
+		return msg, fmt.Errorf("not a PackedForward message: %v", err)
+	}
+	tag, err := readMsgpackStr(r)
+	if err != nil {
+		return msg, err
+	}
+	msg.tag = tag
+
+	b, err := r.ReadByte()
+	if err != nil || b < 0xc4 || b > 0xc6 {
+		return msg, fmt.Errorf("entries are not bin: %v", err)
+	}
+	size := make([]byte, 1<<(b-0xc4))
+	if _, err = io.ReadFull(r, size); err != nil {
+		return msg, err
+	}
+	n := uint32(0)
+	for _, c := range size {
+		n = n<<8 | uint32(c)
+	}
+	entries := make([]byte, n)
+	if _, err = io.ReadFull(r, entries); err != nil {
+		return msg, err
+	}
+	er := msgpackTestReader{b: entries}
+	for len(er.b) != 0 && er.err == nil {
+		var sb strings.Builder
+		er.value(&sb)
+		msg.entries = append(msg.entries, sb.String())
+	}
+	if er.err != nil {
+		return msg, er.err
+	}
+
+	b, err = r.ReadByte()
+	if err != nil || b&0xf0 != 0x80 {
+		return msg, fmt.Errorf("option is not a map: %v", err)
+	}
+	for i := 0; i < int(b&0x0f); i++ {
+		k, err := readMsgpackStr(r)
+		if err != nil {
+			return msg, err
+		}
+		switch k {
+		case "size":
+			c, err := r.ReadByte()
+			if err != nil {
+				return msg, err
+			}
+			switch {
+			case c <= 0x7f:
+				msg.size = int(c)
+			case c == 0xcd:
+				var v [2]byte
+				if _, err = io.ReadFull(r, v[:]); err != nil {
+					return msg, err
+				}
+				msg.size = int(binary.BigEndian.Uint16(v[:]))
+			default:
+				return msg, fmt.Errorf("unexpected size 0x%02x", c)
+			}
+		case "chunk":
+			if msg.chunk, err = readMsgpackStr(r); err != nil {
+				return msg, err
+			}
+		default:
+			return msg, fmt.Errorf("unexpected option %q", k)
+		}
+	}
+	return msg, nil
+}
+
+func encodeForwardEntry(t *testing.T, enc Encoder, text string) []byte {
+	t.Helper()
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	b, err := enc.Encode(Entry{Level: LevelInfo, Time: ts, Text: text})
+	require.NoError(t, err)
+	defer b.Free()
+	return append([]byte(nil), b.Bytes()...)
+}
+
+func TestForwardWriter(t *testing.T) {
+	s := newForwardTestServer(t)
+	w, err := NewForwardWriter("tcp", s.addr(), "app.api", ForwardFlushInterval(0))
+	require.NoError(t, err)
+	defer w.Close()
+
+	enc := MsgPack().Forward().Build()
+	for _, text := range []string{"a", "b"} {
+		_, err = w.Write(encodeForwardEntry(t, enc, text))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Flush())
+
+	msg := s.next(t)
+	assert.Equal(t, "app.api", msg.tag)
+	assert.Equal(t, 2, msg.size)
+	assert.Empty(t, msg.chunk)
+	assert.Equal(t, []string{
+		`[time(1714557600.000000000),{"level":"info","msg":"a"}]`,
+		`[time(1714557600.000000000),{"level":"info","msg":"b"}]`,
+	}, msg.entries)
+
+	// Nothing to send.
+	require.NoError(t, w.Sync())
+	select {
+	case msg := <-s.messages:
+		t.Fatalf("unexpected message %v", msg)
+	default:
+	}
+
+	// A broken connection is replaced on the next send.
+	w.mu.Lock()
+	w.conn.Close()
+	w.mu.Unlock()
+	_, err = w.Write(encodeForwardEntry(t, enc, "c"))
+	require.NoError(t, err)
+	require.NoError(t, w.Sync())
+	assert.Equal(t, []string{`[time(1714557600.000000000),{"level":"info","msg":"c"}]`}, s.next(t).entries)
+}
+
+func TestForwardWriterBatchSize(t *testing.T) {
+	s := newForwardTestServer(t)
+	w, err := NewForwardWriter("tcp", s.addr(), "t", ForwardBatchSize(1), ForwardFlushInterval(0))
+	require.NoError(t, err)
+	defer w.Close()
+
+	enc := MsgPack().Forward().Build()
+	_, err = w.Write(encodeForwardEntry(t, enc, "a"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.next(t).size, "a full batch is sent by Write")
+}
+
+func TestForwardWriterFlushInterval(t *testing.T) {
+	s := newForwardTestServer(t)
+	w, err := NewForwardWriter("tcp", s.addr(), "t", ForwardFlushInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write(encodeForwardEntry(t, MsgPack().Forward().Build(), "a"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.next(t).size, "a partial batch is sent by the timer")
+}
+
+func TestForwardWriterAck(t *testing.T) {
+	s := newForwardTestServer(t)
+	w, err := NewForwardWriter("tcp", s.addr(), "t", ForwardAck(time.Second), ForwardFlushInterval(0))
+	require.NoError(t, err)
+	defer w.Close()
+
+	enc := MsgPack().Forward().Build()
+	_, err = w.Write(encodeForwardEntry(t, enc, "a"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	first := s.next(t)
+	assert.Equal(t, 1, first.size)
+	assert.Len(t, first.chunk, 24, "base64 of 16 random bytes")
+
+	// An unacknowledged message is resent with the same chunk id.
+	s.dropAcks.Store(1)
+	_, err = w.Write(encodeForwardEntry(t, enc, "b"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	sent, resent := s.next(t), s.next(t)
+	assert.Equal(t, sent, resent)
+	assert.NotEqual(t, first.chunk, sent.chunk)
+
+	// A message that fails twice is dropped.
+	s.dropAcks.Store(2)
+	_, err = w.Write(encodeForwardEntry(t, enc, "c"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, w.Flush(), "dropped")
+	assert.NoError(t, w.Flush(), "the batch is gone")
+}
+
+func TestForwardWriterRouter(t *testing.T) {
+	s := newForwardTestServer(t)
+	w, err := NewForwardWriter("tcp", s.addr(), "app")
+	require.NoError(t, err)
+
+	router, closeRouter, err := NewRouter().Route(MsgPack().Forward().Build(), OutputCloser(LevelInfo, w)).Build()
+	require.NoError(t, err)
+	logger := New(router)
+	logger.Info(context.Background(), "hello", String("k", "v"))
+
+	// The last batch is sent when the router closes the writer.
+	closeRouter()
+	msg := s.next(t)
+	require.Len(t, msg.entries, 1)
+	assert.Regexp(t, `^\[time\(\d+\.\d{9}\),\{"level":"info","msg":"hello","caller":"[^"]+","k":"v"\}\]$`, msg.entries[0])
+
+	_, err = w.Write(encodeForwardEntry(t, MsgPack().Forward().Build(), "a"))
+	assert.Error(t, err, "closed by the router")
+	assert.NoError(t, w.Close())
+}
+
+func TestForwardWriterErrors(t *testing.T) {
+	_, err := NewForwardWriter("udp", "127.0.0.1:1", "t")
+	assert.Error(t, err)
+	_, err = NewForwardWriter("tcp", "127.0.0.1:1", "")
+	assert.Error(t, err)
+	_, err = NewForwardWriter("tcp", "127.0.0.1:1", "t", ForwardDialTimeout(time.Second))
+	assert.Error(t, err, "nothing listens on port 1")
+
+	s := newForwardTestServer(t)
+	w, err := NewForwardWriter("tcp", s.addr(), "t")
+	require.NoError(t, err)
+	defer w.Close()
+	b, err := MsgPack().Build().Encode(Entry{Text: "m"})
+	require.NoError(t, err)
+	_, err = w.Write(b.Bytes())
+	assert.Error(t, err, "not a Forward entry")
+
+	// The ack reader rejects responses other than a map of strings.
+	_, err = readForwardAck(bufio.NewReader(strings.NewReader("\x91\xa1a")))
+	assert.Error(t, err)
+	_, err = readForwardAck(bufio.NewReader(strings.NewReader("\x81\xa3ack\x01")))
+	assert.Error(t, err)
+	ack, err := readForwardAck(bufio.NewReader(strings.NewReader("\x81\xa3ack\xa2id")))
+	assert.NoError(t, err)
+	assert.Equal(t, "id", ack)
+	_, err = readForwardAck(bufio.NewReader(strings.NewReader("")))
+	assert.True(t, errors.Is(err, io.EOF))
+}
@@ -0,0 +1,520 @@
+package logf
+
+import (
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// GELFVersion is the version of the Graylog Extended Log Format written
+// by the GELF encoder.
+const GELFVersion = "1.1"
+
+// GELFEncoderConfig controls how the GELF encoder formats log entries.
+// For a friendlier builder-style API, use GELF() instead.
+type GELFEncoderConfig struct {
+	// Host is the "host" of every message. Defaults to os.Hostname().
+	Host string
+
+	DisableFieldName   bool
+	DisableFieldCaller bool
+
+	EncodeTime     TimeEncoder
+	EncodeDuration DurationEncoder
+	EncodeError    ErrorEncoder
+	EncodeCaller   CallerEncoder
+}
+
+// WithDefaults returns a copy of the config with all zero-value fields
+// replaced by sensible defaults (the local host name, RFC3339 times,
+// string durations, short caller format, etc.).
+func (c GELFEncoderConfig) WithDefaults() GELFEncoderConfig {
+	if c.Host == "" {
+		c.Host, _ = os.Hostname()
+		if c.Host == "" {
+			c.Host = "unknown"
+		}
+	}
+	if c.EncodeDuration == nil {
+		c.EncodeDuration = StringDurationEncoder
+	}
+	if c.EncodeTime == nil {
+		c.EncodeTime = RFC3339TimeEncoder
+	}
+	if c.EncodeError == nil {
+		c.EncodeError = DefaultErrorEncoder
+	}
+	if c.EncodeCaller == nil {
+		c.EncodeCaller = ShortCallerEncoder
+	}
+	return c
+}
+
+// GELFEncoderBuilder configures and builds a GELF Encoder using a clean
+// builder-style API. Create one with GELF(), chain methods to customize,
+// then call Build() or pass directly to LoggerBuilder.EncoderFrom().
+type GELFEncoderBuilder struct {
+	cfg GELFEncoderConfig
+}
+
+// GELF returns a new GELFEncoderBuilder with default settings. The
+// encoder writes GELF 1.1 messages for Graylog, one JSON object per
+// entry:
+//
+//	{"version":"1.1","host":"api-1","short_message":"request served",
+//	 "timestamp":1714557600.123,"level":6,"_logger":"http",
+//	 "_caller":"server/http.go:42","_http.method":"GET","_status":200}
+//
+// Level is mapped to the syslog severity: error 3, warn 4, info 6 and
+// debug 7. An empty message is sent as "-", as Graylog rejects empty
+// ones. The logger name and caller become the additional fields _logger
+// and _caller.
+//
+// GELF only allows flat additional fields with string or number values,
+// so fields are flattened: every key gets the "_" prefix, groups and
+// objects become dotted keys (_http.method), arrays and other Any values
+// are sent as JSON strings, and bools as "true" and "false". Bytes that
+// GELF does not allow in keys are replaced with '_', and the reserved
+// key "id" is sent as "__id".
+//
+// Pair it with NewGELFWriter to send messages over UDP or TCP.
+func GELF() *GELFEncoderBuilder {
+	return &GELFEncoderBuilder{}
+}
+
+// Host sets the "host" field (default os.Hostname()).
+func (b *GELFEncoderBuilder) Host(host string) *GELFEncoderBuilder {
+	b.cfg.Host = host
+	return b
+}
+
+// DisableName omits the _logger field.
+func (b *GELFEncoderBuilder) DisableName() *GELFEncoderBuilder {
+	b.cfg.DisableFieldName = true
+	return b
+}
+
+// DisableCaller omits the _caller field.
+func (b *GELFEncoderBuilder) DisableCaller() *GELFEncoderBuilder {
+	b.cfg.DisableFieldCaller = true
+	return b
+}
+
+// EncodeTime sets a custom TimeEncoder for Time fields (default RFC3339).
+// The message timestamp always uses the GELF format.
+func (b *GELFEncoderBuilder) EncodeTime(e TimeEncoder) *GELFEncoderBuilder {
+	b.cfg.EncodeTime = e
+	return b
+}
+
+// EncodeDuration sets a custom DurationEncoder for formatting durations (default string representation).
+func (b *GELFEncoderBuilder) EncodeDuration(e DurationEncoder) *GELFEncoderBuilder {
+	b.cfg.EncodeDuration = e
+	return b
+}
+
+// EncodeCaller sets a custom CallerEncoder for formatting caller locations (default short format).
+func (b *GELFEncoderBuilder) EncodeCaller(e CallerEncoder) *GELFEncoderBuilder {
+	b.cfg.EncodeCaller = e
+	return b
+}
+
+// EncodeError sets a custom ErrorEncoder for formatting error values.
+func (b *GELFEncoderBuilder) EncodeError(e ErrorEncoder) *GELFEncoderBuilder {
+	b.cfg.EncodeError = e
+	return b
+}
+
+// Build finalizes the configuration and returns a ready-to-use GELF Encoder.
+func (b *GELFEncoderBuilder) Build() Encoder {
+	return buildGELFEncoder(b.cfg)
+}
+
+// NewGELFEncoder creates a GELF Encoder from a GELFEncoderConfig struct.
+// For a friendlier builder-style API, use GELF() instead.
+func NewGELFEncoder(cfg GELFEncoderConfig) Encoder {
+	return buildGELFEncoder(cfg)
+}
+
+func buildGELFEncoder(cfg GELFEncoderConfig) Encoder {
+	cfg = cfg.WithDefaults()
+
+	// The constant head of every message.
+	head := GetBuffer()
+	head.AppendString(`{"version":"` + GELFVersion + `","host":"`)
+	_ = EscapeString(head, cfg.Host)
+	head.AppendString(`","short_message":`)
+	enc := &gelfEncoder{
+		GELFEncoderConfig: cfg,
+		slot:              AllocEncoderSlot(),
+		head:              append([]byte(nil), head.Data...),
+		json: jsonEncoder{JSONEncoderConfig: JSONEncoderConfig{
+			EncodeTime:     cfg.EncodeTime,
+			EncodeDuration: cfg.EncodeDuration,
+			EncodeError:    cfg.EncodeError,
+			EncodeCaller:   cfg.EncodeCaller,
+		}.WithDefaults()},
+	}
+	head.Free()
+	enc.pool = &sync.Pool{New: func() any {
+		return enc.Clone()
+	}}
+	return enc
+}
+
+type gelfEncoder struct {
+	GELFEncoderConfig
+	pool *sync.Pool
+	slot int
+	head []byte // {"version":"1.1","host":"...","short_message":
+
+	// Internal state.
+	json   jsonEncoder // renders values into the same buffer
+	prefix []byte      // dotted key prefix of the current group or object
+}
+
+func (f *gelfEncoder) Clone() Encoder {
+	return &gelfEncoder{
+		GELFEncoderConfig: f.GELFEncoderConfig,
+		pool:              f.pool,
+		slot:              f.slot,
+		head:              f.head,
+		json:              jsonEncoder{JSONEncoderConfig: f.json.JSONEncoderConfig},
+	}
+}
+
+func (f *gelfEncoder) Encode(e Entry) (*Buffer, error) {
+	clone := f.pool.Get().(*gelfEncoder)
+
+	buf := GetBuffer()
+	err := clone.encode(buf, e)
+
+	clone.json.buf = nil
+	clone.prefix = clone.prefix[:0]
+	f.pool.Put(clone)
+
+	if err != nil {
+		buf.Free()
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (f *gelfEncoder) encode(buf *Buffer, e Entry) error {
+	f.json.buf = buf
+	f.json.startBufLen = buf.Len()
+
+	buf.AppendBytes(f.head)
+	if e.Text != "" {
+		f.json.EncodeTypeString(e.Text)
+	} else {
+		buf.AppendString(`"-"`)
+	}
+
+	// Timestamp: seconds since the epoch with milliseconds.
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	buf.AppendString(`,"timestamp":`)
+	appendUnixMilliFrac(buf, t)
+
+	buf.AppendString(`,"level":`)
+	buf.AppendInt(int64(syslogSeverity(e.Level)))
+
+	if !f.DisableFieldName && e.LoggerName != "" {
+		buf.AppendString(`,"_logger":`)
+		f.json.EncodeTypeString(e.LoggerName)
+	}
+
+	if !f.DisableFieldCaller && e.CallerPC != 0 {
+		buf.AppendString(`,"_caller":`)
+		cur := buf.Len()
+		f.EncodeCaller(e.CallerPC, &f.json)
+		if buf.Len() == cur {
+			f.json.EncodeTypeString("unknown")
+		}
+	}
+
+	// Skip trailing groups that would produce dangling prefixes.
+	loggerBag := e.LoggerBag
+	ctxBag := e.Bag
+	if len(e.Fields) == 0 {
+		loggerBag = skipTrailingGroups(loggerBag)
+		if !bagHasFields(loggerBag) {
+			ctxBag = skipTrailingGroups(ctxBag)
+		}
+	}
+
+	// Context fields (request-scoped).
+	f.encodeBag(ctxBag, true)
+
+	// Logger's fields (service-scoped).
+	f.encodeBag(loggerBag, flatBagCacheable(f.prefix))
+
+	// Entry's fields.
+	for i := range e.Fields {
+		e.Fields[i].Accept(f)
+	}
+
+	buf.AppendByte('}')
+	buf.AppendByte('\n')
+	return nil
+}
+
+// syslogSeverity maps a Level to the syslog severity (RFC 5424).
+func syslogSeverity(lvl Level) int {
+	switch lvl {
+	case LevelDebug:
+		return 7
+	case LevelInfo:
+		return 6
+	case LevelWarn:
+		return 4
+	default:
+		return 3
+	}
+}
+
+// appendUnixMilliFrac appends t as seconds since the epoch with three
+// decimal places.
+func appendUnixMilliFrac(buf *Buffer, t time.Time) {
+	ms := t.UnixMilli()
+	sec, frac := ms/1000, ms%1000
+	if frac < 0 {
+		sec, frac = sec-1, frac+1000
+	}
+	buf.AppendInt(sec)
+	buf.AppendByte('.')
+	buf.AppendByte(byte('0' + frac/100))
+	buf.AppendByte(byte('0' + frac/10%10))
+	buf.AppendByte(byte('0' + frac%10))
+}
+
+func (f *gelfEncoder) encodeBag(bag *Bag, cache bool) {
+	if bag == nil {
+		return
+	}
+
+	// Group node: extend the key prefix, nothing to cache.
+	if bag.group != "" {
+		f.encodeBag(bag.parent, cache)
+		f.pushPrefix(bag.group)
+		return
+	}
+
+	// Field node: use cache.
+	if cache {
+		if data := bag.LoadCache(f.slot); data != nil {
+			f.json.buf.AppendBytes(data)
+			f.pushBagGroups(bag.parent)
+			return
+		}
+	}
+
+	start := f.json.buf.Len()
+
+	// Walk parent first to preserve field order (parent before child).
+	f.encodeBag(bag.parent, cache)
+
+	for _, field := range bag.fields {
+		field.Accept(f)
+	}
+
+	if cache && f.slot != 0 {
+		encoded := make([]byte, f.json.buf.Len()-start)
+		copy(encoded, f.json.buf.Data[start:])
+		bag.StoreCache(f.slot, encoded)
+	}
+}
+
+// pushBagGroups extends the key prefix with the groups of a Bag chain
+// whose fields were served from the cache.
+func (f *gelfEncoder) pushBagGroups(bag *Bag) {
+	if bag == nil {
+		return
+	}
+	f.pushBagGroups(bag.parent)
+	if bag.group != "" {
+		f.pushPrefix(bag.group)
+	}
+}
+
+// --- FieldEncoder ---
+
+func (f *gelfEncoder) EncodeFieldAny(k string, v interface{}) {
+	f.addKey(k)
+	start := f.json.buf.Len()
+	f.json.EncodeTypeAny(v)
+	if start < f.json.buf.Len() {
+		switch c := f.json.buf.Data[start]; {
+		case c == '"', c == '-', c >= '0' && c <= '9':
+			// Already a string or a number.
+		default:
+			f.stringify(start)
+		}
+	}
+}
+
+func (f *gelfEncoder) EncodeFieldBool(k string, v bool) {
+	f.addKey(k)
+	if v {
+		f.json.buf.AppendString(`"true"`)
+	} else {
+		f.json.buf.AppendString(`"false"`)
+	}
+}
+
+func (f *gelfEncoder) EncodeFieldInt64(k string, v int64) {
+	f.addKey(k)
+	f.json.EncodeTypeInt64(v)
+}
+
+func (f *gelfEncoder) EncodeFieldUint64(k string, v uint64) {
+	f.addKey(k)
+	f.json.EncodeTypeUint64(v)
+}
+
+func (f *gelfEncoder) EncodeFieldFloat64(k string, v float64) {
+	f.addKey(k)
+	f.json.EncodeTypeFloat64(v)
+}
+
+func (f *gelfEncoder) EncodeFieldDuration(k string, v time.Duration) {
+	f.addKey(k)
+	f.json.EncodeTypeDuration(v)
+}
+
+func (f *gelfEncoder) EncodeFieldError(k string, v error) {
+	f.EncodeError(k, v, f)
+}
+
+func (f *gelfEncoder) EncodeFieldTime(k string, v time.Time) {
+	f.addKey(k)
+	f.json.EncodeTypeTime(v)
+}
+
+func (f *gelfEncoder) EncodeFieldString(k string, v string) {
+	f.addKey(k)
+	f.json.EncodeTypeString(v)
+}
+
+func (f *gelfEncoder) EncodeFieldBytes(k string, v []byte) {
+	f.addKey(k)
+	f.json.EncodeTypeBytes(v)
+}
+
+func (f *gelfEncoder) EncodeFieldStrings(k string, v []string) {
+	f.addKey(k)
+	start := f.json.buf.Len()
+	f.json.EncodeTypeStrings(v)
+	f.stringify(start)
+}
+
+func (f *gelfEncoder) EncodeFieldInts64(k string, v []int64) {
+	f.addKey(k)
+	start := f.json.buf.Len()
+	f.json.EncodeTypeInts64(v)
+	f.stringify(start)
+}
+
+func (f *gelfEncoder) EncodeFieldFloats64(k string, v []float64) {
+	f.addKey(k)
+	start := f.json.buf.Len()
+	f.json.EncodeTypeFloats64(v)
+	f.stringify(start)
+}
+
+func (f *gelfEncoder) EncodeFieldDurations(k string, v []time.Duration) {
+	f.addKey(k)
+	start := f.json.buf.Len()
+	f.json.EncodeTypeDurations(v)
+	f.stringify(start)
+}
+
+func (f *gelfEncoder) EncodeFieldArray(k string, v ArrayEncoder) {
+	f.addKey(k)
+	start := f.json.buf.Len()
+	f.json.EncodeTypeArray(v)
+	f.stringify(start)
+}
+
+func (f *gelfEncoder) EncodeFieldObject(k string, v ObjectEncoder) {
+	if k == "" {
+		_ = v.EncodeLogfObject(f)
+		return
+	}
+	saved := len(f.prefix)
+	f.pushPrefix(k)
+	_ = v.EncodeLogfObject(f)
+	f.prefix = f.prefix[:saved]
+}
+
+func (f *gelfEncoder) EncodeFieldGroup(k string, fs []Field) {
+	if k == "" {
+		// Inline group: emit fields at current level.
+		for _, field := range fs {
+			field.Accept(f)
+		}
+		return
+	}
+	saved := len(f.prefix)
+	f.pushPrefix(k)
+	for _, field := range fs {
+		field.Accept(f)
+	}
+	f.prefix = f.prefix[:saved]
+}
+
+// --- helpers ---
+
+// addKey writes an additional field key: "_" + prefix + k.
+func (f *gelfEncoder) addKey(k string) {
+	buf := f.json.buf
+	buf.AppendString(`,"_`)
+	if len(f.prefix) == 0 && k == "id" {
+		buf.AppendString("_id")
+	} else {
+		buf.AppendBytes(f.prefix)
+		appendGELFKey(buf, k)
+	}
+	buf.AppendString(`":`)
+}
+
+func (f *gelfEncoder) pushPrefix(k string) {
+	start := len(f.prefix)
+	f.prefix = append(f.prefix, k...)
+	for i := start; i < len(f.prefix); i++ {
+		if !isGELFKeyByte(f.prefix[i]) {
+			f.prefix[i] = '_'
+		}
+	}
+	f.prefix = append(f.prefix, '.')
+}
+
+// stringify turns the JSON value written since start into a JSON string.
+func (f *gelfEncoder) stringify(start int) {
+	buf := f.json.buf
+	tmp := GetBuffer()
+	tmp.AppendBytes(buf.Data[start:])
+	buf.Truncate(start)
+	f.json.EncodeTypeString(unsafe.String(unsafe.SliceData(tmp.Data), tmp.Len()))
+	tmp.Free()
+}
+
+// appendGELFKey appends k, replacing bytes that GELF does not allow in
+// field names (anything but letters, digits, '_', '.' and '-') with '_'.
+func appendGELFKey(buf *Buffer, k string) {
+	start := buf.Len()
+	buf.AppendString(k)
+	for i := start; i < buf.Len(); i++ {
+		if !isGELFKeyByte(buf.Data[i]) {
+			buf.Data[i] = '_'
+		}
+	}
+}
+
+func isGELFKeyByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-'
+}
@@ -0,0 +1,141 @@
+package logf
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGELFEncoder(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	head := `{"version":"1.1","host":"api-1","short_message":`
+	testCases := []encoderTestCase{
+		{
+			"Header",
+			Entry{Level: LevelInfo, Time: ts, LoggerName: "http", Text: "request served"},
+			head + `"request served","timestamp":1714557600.123,"level":6,"_logger":"http"}` + "\n",
+		},
+		{
+			"EmptyMessage",
+			Entry{Level: LevelDebug, Time: ts},
+			head + `"-","timestamp":1714557600.123,"level":7}` + "\n",
+		},
+		{
+			"Levels",
+			Entry{Level: LevelWarn, Time: ts, Text: "m", Fields: []Field{Int("n", 1)}},
+			head + `"m","timestamp":1714557600.123,"level":4,"_n":1}` + "\n",
+		},
+		{
+			"Fields",
+			Entry{Level: LevelError, Time: ts, Text: "m", Fields: []Field{
+				String("s", "a\"b"), Bool("ok", true), Bool("no", false), Float64("f", 0.5),
+				Uint64("u", 7), Duration("d", time.Second), Time("t", ts.Truncate(time.Second)),
+				Bytes("b", []byte("hi")), NamedError("err", errors.New("boom")),
+			}},
+			head + `"m","timestamp":1714557600.123,"level":3,"_s":"a\"b","_ok":"true","_no":"false","_f":0.5,` +
+				`"_u":7,"_d":"1s","_t":"2024-05-01T10:00:00Z","_b":"aGk=","_err":"boom"}` + "\n",
+		},
+		{
+			"Flattening",
+			Entry{Level: LevelInfo, Time: ts, Text: "m", Fields: []Field{
+				Group("http", String("method", "GET"), Group("req", Int("size", 10))),
+				Group("", String("inline", "x")),
+				Object("user", testObjectEncoder{}),
+				Strings("tags", []string{"a", "b"}),
+				Ints64("ids", []int64{1, 2}),
+				Array("arr", testArrayEncoder{}),
+				Any("map", map[string]int{"a": 1}),
+				Any("nil", nil),
+			}},
+			head + `"m","timestamp":1714557600.123,"level":6,"_http.method":"GET","_http.req.size":10,"_inline":"x",` +
+				`"_user.username":"username","_user.code":42,"_tags":"[\"a\",\"b\"]","_ids":"[1,2]","_arr":"[42]",` +
+				`"_map":"{\"a\":1}","_nil":"null"}` + "\n",
+		},
+		{
+			"Keys",
+			Entry{Level: LevelInfo, Time: ts, Text: "m", Fields: []Field{
+				String("id", "1"), String("a b", "2"), Group("x y", String("id", "3")),
+			}},
+			head + `"m","timestamp":1714557600.123,"level":6,"__id":"1","_a_b":"2","_x_y.id":"3"}` + "\n",
+		},
+		{
+			"Bags",
+			Entry{
+				Level:     LevelInfo,
+				Time:      ts,
+				Text:      "m",
+				Bag:       NewBag(String("request_id", "r1")),
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http").With(String("method", "GET")),
+				Fields:    []Field{Int("status", 200)},
+			},
+			head + `"m","timestamp":1714557600.123,"level":6,"_request_id":"r1","_service":"api","_http.method":"GET","_http.status":200}` + "\n",
+		},
+	}
+
+	enc := GELF().Host("api-1").Build()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden, b.String())
+			assert.True(t, json.Valid(b.Bytes()))
+			b.Free()
+		})
+	}
+}
+
+func TestGELFEncoderBagCache(t *testing.T) {
+	enc := withCacheSlot(GELF().Host("h").Build())
+	logger := NewBag(String("id", "7"))
+	head := `{"version":"1.1","host":"h","short_message":"m","timestamp":0.000,"level":6,`
+
+	// The logger Bag is cached with its renamed "_id" key, which a
+	// context group has to prefix instead.
+	cases := []struct {
+		entry Entry
+		want  string
+	}{
+		{Entry{LoggerBag: logger}, `"__id":"7"}`},
+		{Entry{Bag: NewBag().WithGroup("req"), LoggerBag: logger, Fields: []Field{Int("n", 1)}}, `"_req.id":"7","_req.n":1}`},
+		{Entry{Bag: NewBag(String("request_id", "r1")), LoggerBag: logger}, `"_request_id":"r1","__id":"7"}`},
+	}
+	for _, c := range cases {
+		c.entry.Level, c.entry.Time, c.entry.Text = LevelInfo, time.Unix(0, 0), "m"
+		b, err := enc.Encode(c.entry)
+		require.NoError(t, err)
+		assert.Equal(t, head+c.want+"\n", b.String())
+		b.Free()
+	}
+}
+
+func TestGELFEncoderCaller(t *testing.T) {
+	enc := GELF().Host("h").Build()
+	b, err := enc.Encode(Entry{Level: LevelInfo, Text: "m", CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(b.Bytes(), &m))
+	assert.Contains(t, m["_caller"], "gelfencoder_test.go:")
+	assert.Greater(t, m["timestamp"], float64(time.Now().Add(-time.Minute).Unix()), "zero time is sent as now")
+	b.Free()
+
+	enc = GELF().Host("h").DisableCaller().DisableName().Build()
+	b, err = enc.Encode(Entry{Level: LevelInfo, Time: time.Unix(1, 0), LoggerName: "n", Text: "m", CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	assert.Equal(t, `{"version":"1.1","host":"h","short_message":"m","timestamp":1.000,"level":6}`+"\n", b.String())
+	b.Free()
+}
+
+func TestNewGELFEncoder(t *testing.T) {
+	enc := NewGELFEncoder(GELFEncoderConfig{Host: `a"b`, EncodeDuration: NanoDurationEncoder})
+	b, err := enc.Encode(Entry{Level: LevelInfo, Time: time.Unix(0, 0), Text: "m", Fields: []Field{Duration("d", time.Second)}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"version":"1.1","host":"a\"b","short_message":"m","timestamp":0.000,"level":6,"_d":1000000000}`+"\n", b.String())
+	b.Free()
+
+	host := NewGELFEncoder(GELFEncoderConfig{}).(*gelfEncoder).Host
+	assert.NotEmpty(t, host)
+}
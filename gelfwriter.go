@@ -0,0 +1,264 @@
+package logf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Defaults and limits of GELF over UDP.
+const (
+	// DefaultGELFChunkSize is the default maximum UDP datagram size. It
+	// fits a typical 1500-byte Ethernet MTU with room for IP and UDP
+	// headers.
+	DefaultGELFChunkSize = 1420
+
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+// NewGELFWriter returns a Writer that sends each Write as one GELF
+// message to a Graylog input. network is "udp" or "tcp":
+//
+//	w, err := logf.NewGELFWriter("udp", "graylog:12201", logf.GELFCompress(gzip.BestSpeed))
+//	router, close, _ := logf.NewRouter().
+//	    Route(logf.GELF().Build(), logf.OutputCloser(logf.LevelInfo, w)).
+//	    Build()
+//
+// Over UDP, messages larger than the chunk size (GELFChunkSize) are split
+// into GELF chunks, at most 128 of them; larger messages are dropped with
+// an error. GELFCompress gzips messages before chunking.
+//
+// Over TCP, messages are framed by a null byte, and a failed write
+// reconnects and retries once. GELF over TCP does not support
+// compression.
+//
+// Every Write must carry exactly one message, as Router outputs do. Do
+// not put a SlabWriter in front of it, since it batches messages; use an
+// AsyncHandler to move sending off the logging goroutine instead. A
+// trailing newline, as written by the GELF encoder, is stripped.
+// GELFWriter is safe for concurrent use.
+func NewGELFWriter(network, addr string, opts ...GELFWriterOption) (*GELFWriter, error) {
+	w := &GELFWriter{
+		network:     network,
+		addr:        addr,
+		chunkSize:   DefaultGELFChunkSize,
+		dialTimeout: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	switch network {
+	case "udp", "udp4", "udp6":
+		if w.chunkSize <= gelfChunkHeaderSize {
+			return nil, fmt.Errorf("logf: GELF chunk size %d is too small", w.chunkSize)
+		}
+	case "tcp", "tcp4", "tcp6":
+		if w.compress {
+			return nil, errors.New("logf: GELF over TCP does not support compression")
+		}
+		w.tcp = true
+	default:
+		return nil, fmt.Errorf("logf: unsupported GELF network %q", network)
+	}
+
+	var seed [8]byte
+	_, _ = rand.Read(seed[:])
+	w.nextID = binary.BigEndian.Uint64(seed[:])
+
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// GELFWriterOption configures a GELFWriter at creation time.
+type GELFWriterOption func(*GELFWriter)
+
+// GELFChunkSize sets the maximum UDP datagram size, chunk header
+// included (default DefaultGELFChunkSize). Raise it for networks with a
+// larger MTU, such as loopback.
+func GELFChunkSize(n int) GELFWriterOption {
+	return func(w *GELFWriter) {
+		w.chunkSize = n
+	}
+}
+
+// GELFCompress gzips UDP messages at the given compression level
+// (gzip.BestSpeed to gzip.BestCompression, or gzip.DefaultCompression).
+func GELFCompress(level int) GELFWriterOption {
+	return func(w *GELFWriter) {
+		w.compress = true
+		w.level = level
+	}
+}
+
+// GELFDialTimeout sets the timeout for connecting to a TCP input
+// (default 5s).
+func GELFDialTimeout(d time.Duration) GELFWriterOption {
+	return func(w *GELFWriter) {
+		w.dialTimeout = d
+	}
+}
+
+// GELFWriter is the Writer built by NewGELFWriter.
+type GELFWriter struct {
+	network     string
+	addr        string
+	tcp         bool
+	chunkSize   int
+	compress    bool
+	level       int
+	dialTimeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	nextID uint64
+	zbuf   bytes.Buffer
+	zw     *gzip.Writer
+	frame  []byte
+	closed bool
+}
+
+// Write sends p as one GELF message.
+func (w *GELFWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if n > 0 && p[n-1] == '\n' {
+		p = p[:n-1]
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, errors.New("logf: GELF writer is closed")
+	}
+
+	var err error
+	if w.tcp {
+		err = w.writeTCP(p)
+	} else {
+		err = w.writeUDP(p)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Flush is a no-op: every Write is sent immediately.
+func (w *GELFWriter) Flush() error {
+	return nil
+}
+
+// Sync is a no-op: every Write is sent immediately.
+func (w *GELFWriter) Sync() error {
+	return nil
+}
+
+// Close closes the connection. Writes after Close fail.
+func (w *GELFWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	return w.conn.Close()
+}
+
+func (w *GELFWriter) dial() error {
+	conn, err := net.DialTimeout(w.network, w.addr, w.dialTimeout)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// writeTCP sends p followed by the null byte delimiter, reconnecting
+// once if the connection has failed. Must be called with mu held.
+func (w *GELFWriter) writeTCP(p []byte) error {
+	w.frame = append(append(w.frame[:0], p...), 0)
+	for attempt := 0; ; attempt++ {
+		if w.conn == nil {
+			if err := w.dial(); err != nil {
+				return err
+			}
+		}
+		_, err := w.conn.Write(w.frame)
+		if err == nil {
+			return nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+// writeUDP sends p in one datagram or in chunks. Must be called with mu
+// held.
+func (w *GELFWriter) writeUDP(p []byte) error {
+	if w.compress {
+		if err := w.gzip(p); err != nil {
+			return err
+		}
+		p = w.zbuf.Bytes()
+	}
+	if len(p) <= w.chunkSize {
+		_, err := w.conn.Write(p)
+		return err
+	}
+
+	payload := w.chunkSize - gelfChunkHeaderSize
+	count := (len(p) + payload - 1) / payload
+	if count > gelfMaxChunks {
+		return fmt.Errorf("logf: GELF message of %d bytes needs %d chunks, the limit is %d", len(p), count, gelfMaxChunks)
+	}
+
+	id := w.nextID
+	w.nextID++
+	for seq := 0; seq < count; seq++ {
+		chunk := p[seq*payload:]
+		if len(chunk) > payload {
+			chunk = chunk[:payload]
+		}
+		w.frame = append(w.frame[:0], 0x1e, 0x0f)
+		w.frame = binary.BigEndian.AppendUint64(w.frame, id)
+		w.frame = append(w.frame, byte(seq), byte(count))
+		w.frame = append(w.frame, chunk...)
+		if _, err := w.conn.Write(w.frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gzip compresses p into zbuf. Must be called with mu held.
+func (w *GELFWriter) gzip(p []byte) error {
+	w.zbuf.Reset()
+	if w.zw == nil {
+		zw, err := gzip.NewWriterLevel(&w.zbuf, w.level)
+		if err != nil {
+			return err
+		}
+		w.zw = zw
+	} else {
+		w.zw.Reset(&w.zbuf)
+	}
+	if _, err := w.zw.Write(p); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
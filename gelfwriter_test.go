@@ -0,0 +1,181 @@
+package logf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listenGELFUDP(t *testing.T) net.PacketConn {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { pc.Close() })
+	return pc
+}
+
+func readDatagram(t *testing.T, pc net.PacketConn) []byte {
+	t.Helper()
+	require.NoError(t, pc.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, 65536)
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	return buf[:n]
+}
+
+// readGELFMessage reads datagrams until a whole message is assembled.
+func readGELFMessage(t *testing.T, pc net.PacketConn) (msg []byte, chunks int) {
+	t.Helper()
+	d := readDatagram(t, pc)
+	if len(d) < 2 || d[0] != 0x1e || d[1] != 0x0f {
+		return d, 1
+	}
+	count := int(d[11])
+	parts := make([][]byte, count)
+	id := string(d[2:10])
+	for {
+		require.Equal(t, id, string(d[2:10]), "chunks of one message share the id")
+		require.Equal(t, count, int(d[11]))
+		parts[d[10]] = d[12:]
+		chunks++
+		if chunks == count {
+			break
+		}
+		d = readDatagram(t, pc)
+	}
+	return bytes.Join(parts, nil), chunks
+}
+
+func TestGELFWriterUDP(t *testing.T) {
+	pc := listenGELFUDP(t)
+	w, err := NewGELFWriter("udp", pc.LocalAddr().String())
+	require.NoError(t, err)
+	defer w.Close()
+
+	router, closeRouter, err := NewRouter().Route(GELF().Host("h").Build(), OutputCloser(LevelInfo, w)).Build()
+	require.NoError(t, err)
+	logger := New(router)
+	logger.Info(context.Background(), "hello", String("k", "v"))
+
+	msg, chunks := readGELFMessage(t, pc)
+	assert.Equal(t, 1, chunks)
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(msg, &m))
+	assert.Equal(t, "hello", m["short_message"])
+	assert.Equal(t, "v", m["_k"])
+	assert.False(t, bytes.HasSuffix(msg, []byte("\n")), "the trailing newline is stripped")
+
+	closeRouter()
+	_, err = w.Write([]byte("{}"))
+	assert.Error(t, err, "closed by the router")
+}
+
+func TestGELFWriterUDPChunked(t *testing.T) {
+	pc := listenGELFUDP(t)
+	w, err := NewGELFWriter("udp", pc.LocalAddr().String(), GELFChunkSize(100))
+	require.NoError(t, err)
+	defer w.Close()
+
+	payload := `{"short_message":"` + strings.Repeat("x", 500) + `"}`
+	n, err := w.Write([]byte(payload + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, len(payload)+1, n)
+
+	msg, chunks := readGELFMessage(t, pc)
+	assert.Equal(t, 6, chunks, "88 payload bytes per chunk")
+	assert.Equal(t, payload, string(msg))
+
+	// A second message gets a new id.
+	_, err = w.Write([]byte(payload))
+	require.NoError(t, err)
+	msg, _ = readGELFMessage(t, pc)
+	assert.Equal(t, payload, string(msg))
+
+	_, err = w.Write(bytes.Repeat([]byte("x"), 88*128+1))
+	assert.Error(t, err, "more than 128 chunks")
+}
+
+func TestGELFWriterUDPGzip(t *testing.T) {
+	pc := listenGELFUDP(t)
+	w, err := NewGELFWriter("udp", pc.LocalAddr().String(), GELFCompress(gzip.BestSpeed), GELFChunkSize(64))
+	require.NoError(t, err)
+	defer w.Close()
+
+	payload := `{"short_message":"` + strings.Repeat("compressible ", 200) + `"}`
+	_, err = w.Write([]byte(payload))
+	require.NoError(t, err)
+
+	msg, chunks := readGELFMessage(t, pc)
+	assert.Greater(t, chunks, 1)
+	zr, err := gzip.NewReader(bytes.NewReader(msg))
+	require.NoError(t, err)
+	plain, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(plain))
+}
+
+func TestGELFWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				r := bufio.NewReader(conn)
+				for {
+					msg, err := r.ReadString(0)
+					if err != nil {
+						return
+					}
+					received <- msg
+				}
+			}()
+		}
+	}()
+
+	w, err := NewGELFWriter("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("{\"a\":1}\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte(`{"a":2}`))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":1}\x00", <-received)
+	assert.Equal(t, "{\"a\":2}\x00", <-received)
+
+	// A broken connection is replaced on the next write.
+	w.mu.Lock()
+	w.conn.Close()
+	w.mu.Unlock()
+	_, err = w.Write([]byte(`{"a":3}`))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":3}\x00", <-received)
+}
+
+func TestGELFWriterOptions(t *testing.T) {
+	_, err := NewGELFWriter("tcp", "127.0.0.1:1", GELFCompress(gzip.BestSpeed))
+	assert.Error(t, err)
+	_, err = NewGELFWriter("unix", "/nonexistent")
+	assert.Error(t, err)
+	_, err = NewGELFWriter("udp", "127.0.0.1:1", GELFChunkSize(12))
+	assert.Error(t, err)
+	_, err = NewGELFWriter("tcp", "127.0.0.1:1", GELFDialTimeout(time.Second))
+	assert.Error(t, err, "nothing listens on port 1")
+}
@@ -0,0 +1,115 @@
+package logf
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"unsafe"
+)
+
+// Special keys of Google Cloud Logging structured payloads.
+const (
+	GoogleCloudKeySourceLocation = "logging.googleapis.com/sourceLocation"
+	GoogleCloudKeyTrace          = "logging.googleapis.com/trace"
+	GoogleCloudKeySpanID         = "logging.googleapis.com/spanId"
+	GoogleCloudKeyTraceSampled   = "logging.googleapis.com/trace_sampled"
+)
+
+// GoogleCloud switches the encoder to the structured JSON layout Google
+// Cloud Logging reads from stdout on GKE, Cloud Run and Cloud Functions:
+//
+//	{"severity":"WARNING","timestamp":"2024-05-01T10:00:00.123456789Z","logger":"http",
+//	 "message":"slow request","logging.googleapis.com/sourceLocation":{"file":"server/http.go",
+//	 "line":"42","function":"main.serve"},"took":"2.5s"}
+//
+// It sets the keys of the built-in fields, nanosecond RFC3339 timestamps,
+// GoogleCloudLevelEncoder and GoogleCloudCallerEncoder. Add
+// GoogleCloudTrace to the context sources to correlate entries with
+// Cloud Trace. Call it first: later builder calls override its settings.
+func (b *JSONEncoderBuilder) GoogleCloud() *JSONEncoderBuilder {
+	b.cfg.FieldKeyTime = "timestamp"
+	b.cfg.FieldKeyLevel = "severity"
+	b.cfg.FieldKeyMsg = "message"
+	b.cfg.FieldKeyCaller = GoogleCloudKeySourceLocation
+	b.cfg.EncodeTime = RFC3339NanoTimeEncoder
+	b.cfg.EncodeLevel = GoogleCloudLevelEncoder
+	b.cfg.EncodeCaller = GoogleCloudCallerEncoder
+	return b
+}
+
+// GoogleCloudLevelEncoder formats levels as Cloud Logging severities
+// (DEBUG, INFO, WARNING, ERROR).
+func GoogleCloudLevelEncoder(lvl Level, m TypeEncoder) {
+	switch lvl {
+	case LevelDebug:
+		m.EncodeTypeString("DEBUG")
+	case LevelInfo:
+		m.EncodeTypeString("INFO")
+	case LevelWarn:
+		m.EncodeTypeString("WARNING")
+	case LevelError:
+		m.EncodeTypeString("ERROR")
+	default:
+		m.EncodeTypeString("DEFAULT")
+	}
+}
+
+// GoogleCloudCallerEncoder formats the caller as a Cloud Logging
+// sourceLocation object. The line is a string, as in the LogEntry JSON
+// representation:
+//
+//	{"file":"server/http.go","line":"42","function":"main.serve"}
+func GoogleCloudCallerEncoder(pc uintptr, m TypeEncoder) {
+	o := googleCloudSourceLocation{pc: pc}
+	m.EncodeTypeObject((*googleCloudSourceLocation)(noescape(unsafe.Pointer(&o))))
+	runtime.KeepAlive(&o)
+}
+
+type googleCloudSourceLocation struct {
+	pc uintptr
+}
+
+func (o *googleCloudSourceLocation) EncodeLogfObject(e FieldEncoder) error {
+	frame, _ := runtime.CallersFrames([]uintptr{o.pc}).Next()
+	e.EncodeFieldString("file", fileWithPackage(frame.File))
+
+	var lineBuf [20]byte
+	line := strconv.AppendInt(lineBuf[:0], int64(frame.Line), 10)
+	e.EncodeFieldString("line", unsafe.String(unsafe.SliceData(line), len(line)))
+
+	if frame.Function != "" {
+		e.EncodeFieldString("function", frame.Function)
+	}
+	return nil
+}
+
+// GoogleCloudTrace returns a FieldSource that adds the Cloud Logging
+// trace fields for the span in the context. trace extracts the trace and
+// span ids from the context, typically from an OpenTelemetry span;
+// nothing is added when it returns an empty trace id:
+//
+//	logger := logf.NewLogger().
+//	    EncoderFrom(logf.JSON().GoogleCloud()).
+//	    Context(logf.GoogleCloudTrace("my-project", func(ctx context.Context) (string, string, bool) {
+//	        sc := trace.SpanContextFromContext(ctx)
+//	        return sc.TraceID().String(), sc.SpanID().String(), sc.IsSampled()
+//	    })).
+//	    Build()
+//
+// Like all per-entry fields, the trace fields are written inside the
+// groups opened by WithGroup, where Cloud Logging does not look for them.
+// Keep loggers that need tracing free of groups.
+func GoogleCloudTrace(projectID string, trace func(ctx context.Context) (traceID, spanID string, sampled bool)) FieldSource {
+	prefix := "projects/" + projectID + "/traces/"
+	return func(ctx context.Context) []Field {
+		traceID, spanID, sampled := trace(ctx)
+		if traceID == "" {
+			return nil
+		}
+		fields := []Field{String(GoogleCloudKeyTrace, prefix+traceID)}
+		if spanID != "" {
+			fields = append(fields, String(GoogleCloudKeySpanID, spanID))
+		}
+		return append(fields, Bool(GoogleCloudKeyTraceSampled, sampled))
+	}
+}
@@ -0,0 +1,78 @@
+package logf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONEncoderGoogleCloud(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	enc := JSON().GoogleCloud().Build()
+
+	for _, c := range []struct {
+		lvl  Level
+		want string
+	}{
+		{LevelDebug, "DEBUG"}, {LevelInfo, "INFO"}, {LevelWarn, "WARNING"}, {LevelError, "ERROR"}, {Level(42), "DEFAULT"},
+	} {
+		b, err := enc.Encode(Entry{Level: c.lvl, Time: ts, LoggerName: "http", Text: "m", Fields: []Field{Int("n", 1)}})
+		require.NoError(t, err)
+		assert.Equal(t, `{"severity":"`+c.want+`","timestamp":"2024-05-01T10:00:00.123456789Z","logger":"http","message":"m","n":1}`+"\n", b.String())
+		b.Free()
+	}
+}
+
+func TestJSONEncoderGoogleCloudSourceLocation(t *testing.T) {
+	enc := JSON().GoogleCloud().Build()
+	b, err := enc.Encode(Entry{Level: LevelInfo, Text: "m", CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	defer b.Free()
+
+	var m struct {
+		Location struct {
+			File     string `json:"file"`
+			Line     string `json:"line"`
+			Function string `json:"function"`
+		} `json:"logging.googleapis.com/sourceLocation"`
+	}
+	require.NoError(t, json.Unmarshal(b.Bytes(), &m))
+	assert.Regexp(t, `^[^/]+/googlecloud_test.go$`, m.Location.File)
+	assert.Regexp(t, `^\d+$`, m.Location.Line)
+	assert.Equal(t, "github.com/ssgreg/logf/v2.TestJSONEncoderGoogleCloudSourceLocation", m.Location.Function)
+}
+
+func TestGoogleCloudTrace(t *testing.T) {
+	type spanKey struct{}
+	src := GoogleCloudTrace("my-project", func(ctx context.Context) (string, string, bool) {
+		span, _ := ctx.Value(spanKey{}).([2]string)
+		return span[0], span[1], span[1] != ""
+	})
+
+	var out bytes.Buffer
+	logger := NewLogger().
+		EncoderFrom(JSON().GoogleCloud().DisableTime().DisableCaller()).
+		Output(&out).
+		Context(src).
+		Build()
+
+	ctx := context.WithValue(context.Background(), spanKey{}, [2]string{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"})
+	logger.Info(ctx, "traced")
+	logger.Info(context.WithValue(context.Background(), spanKey{}, [2]string{"4bf92f3577b34da6a3ce929d0e0e4736", ""}), "no span")
+	logger.Info(context.Background(), "untraced")
+
+	assert.Equal(t,
+		`{"severity":"INFO","message":"traced",`+
+			`"logging.googleapis.com/trace":"projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736",`+
+			`"logging.googleapis.com/spanId":"00f067aa0ba902b7","logging.googleapis.com/trace_sampled":true}`+"\n"+
+			`{"severity":"INFO","message":"no span",`+
+			`"logging.googleapis.com/trace":"projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736",`+
+			`"logging.googleapis.com/trace_sampled":false}`+"\n"+
+			`{"severity":"INFO","message":"untraced"}`+"\n",
+		out.String())
+}
@@ -52,6 +52,24 @@ type Handler interface {
 	Enabled(context.Context, Level) bool
 }
 
+// NameEnabler is an optional interface for Handlers whose level decision
+// depends on the logger name, such as NamedLevels. Handler.Enabled only
+// gets the context and the level, so Logger calls EnabledName instead
+// when its Handler implements it. Middleware that delegates Enabled
+// should implement NameEnabler too and forward the name downstream.
+type NameEnabler interface {
+	EnabledName(ctx context.Context, name string, lvl Level) bool
+}
+
+// enabledName asks h whether lvl is enabled for the logger name, via
+// NameEnabler if h implements it and via Enabled otherwise.
+func enabledName(h Handler, ctx context.Context, name string, lvl Level) bool {
+	if ne, ok := h.(NameEnabler); ok {
+		return ne.EnabledName(ctx, name, lvl)
+	}
+	return h.Enabled(ctx, lvl)
+}
+
 // NewSyncHandler returns the simplest possible Handler — it encodes each
 // entry right there in the calling goroutine and writes it immediately.
 // No routing, no buffering, no background goroutines. Think of it as the
@@ -81,7 +99,10 @@ func (h *syncHandler) Handle(_ context.Context, entry Entry) error {
 	return err
 }
 
-func (h *syncHandler) Enabled(_ context.Context, lvl Level) bool {
+func (h *syncHandler) Enabled(ctx context.Context, lvl Level) bool {
+	if override, ok := LevelFromContext(ctx); ok {
+		return override.Enabled(lvl)
+	}
 	return h.level.Enabled(lvl)
 }
 
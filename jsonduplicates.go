@@ -0,0 +1,363 @@
+package logf
+
+import (
+	"bytes"
+	"hash/maphash"
+	"strconv"
+)
+
+// DuplicateKeyPolicy selects what the JSON encoder does with keys that
+// appear more than once in the same object — e.g. a "user" field in the
+// context Bag, the logger's Bag and the entry itself.
+type DuplicateKeyPolicy int8
+
+// Available duplicate key policies.
+const (
+	// KeepDuplicateKeys writes every occurrence, as is. This is the
+	// default and the fastest.
+	KeepDuplicateKeys DuplicateKeyPolicy = iota
+
+	// LastKeyWins keeps only the last occurrence, so entry fields
+	// override logger fields which override context fields.
+	//
+	// Built-in fields, such as the level and the message, and the
+	// constant fields of a preset are the exception: they are always
+	// kept, so the last field with the key of one is kept too, renamed
+	// as with RenameDuplicateKeys ("msg_1"), and earlier ones are
+	// dropped.
+	LastKeyWins
+
+	// FirstKeyWins keeps only the first occurrence.
+	FirstKeyWins
+
+	// RenameDuplicateKeys keeps every occurrence and appends the
+	// DuplicateKeySuffix and a counter to the repeated keys:
+	// "id", "id_1", "id_2". Counters that would give a key already in
+	// the object are skipped.
+	RenameDuplicateKeys
+)
+
+// jsonKeySetMin is the number of keys from which the keys of an object
+// are looked up in a hash table rather than compared one by one.
+const jsonKeySetMin = 8
+
+var jsonKeySeed = maphash.MakeSeed()
+
+// jsonMember is an object member found while resolving duplicate keys.
+type jsonMember struct {
+	start  int // opening quote of the key
+	keyEnd int // closing quote of the key
+	end    int // end of the value
+	object int // id of the enclosing object
+	dups   int // repeats of the key so far
+	rename int // counter to append to the key, if not zero
+	drop   bool
+
+	// For the first occurrence of a key: the member kept under the key,
+	// and the one kept under a new name with LastKeyWins, or -1. Both
+	// are -1 for the other occurrences.
+	live    int
+	renamed int
+}
+
+// jsonFrame is an object or an array open while resolving duplicate keys.
+type jsonFrame struct {
+	object int // object id, or -1 for arrays
+	first  int // first member of the object
+	cur    int // member being scanned, or -1
+	keys   int // distinct keys of the object so far
+}
+
+// jsonKeyTable is an open addressing hash table of the first occurrences
+// of the keys of one object, once it has jsonKeySetMin keys.
+type jsonKeyTable struct {
+	slots []int32 // member index + 1, or 0 for a free slot
+	n     int
+}
+
+// resolveDuplicateKeys applies the duplicate key policy to the record
+// written from start. The record is scanned once; it is only rewritten
+// when there are duplicates.
+func (f *jsonEncoder) resolveDuplicateKeys(start int) {
+	data := f.buf.Data[start:]
+	f.members = f.members[:0]
+	f.frames = f.frames[:0]
+	objects := 0
+	changed := false
+	renamed := false
+
+	for i := 0; i < len(data); {
+		switch data[i] {
+		case '"':
+			j := skipJSONString(data, i)
+			top := len(f.frames) - 1
+			if top >= 0 && f.frames[top].object >= 0 && f.frames[top].cur < 0 {
+				f.frames[top].cur = len(f.members)
+				f.members = append(f.members, jsonMember{start: i, keyEnd: j - 1, object: f.frames[top].object})
+				if f.checkDuplicateKey(data, top) {
+					changed = true
+					renamed = renamed || f.members[len(f.members)-1].rename != 0
+				}
+			}
+			i = j
+			continue
+		case '{':
+			f.frames = append(f.frames, jsonFrame{object: objects, first: len(f.members), cur: -1})
+			objects++
+		case '[':
+			f.frames = append(f.frames, jsonFrame{object: -1, cur: -1})
+		case ',', '}', ']':
+			top := len(f.frames) - 1
+			if top < 0 {
+				break
+			}
+			if cur := f.frames[top].cur; cur >= 0 {
+				f.members[cur].end = i
+				f.frames[top].cur = -1
+			}
+			if data[i] != ',' {
+				f.frames = f.frames[:top]
+			}
+		}
+		i++
+	}
+
+	if renamed {
+		f.avoidKeyCollisions(data)
+	}
+	if changed {
+		f.rewriteDuplicateKeys(start)
+	}
+}
+
+// checkDuplicateKey looks up the last member's key among the earlier
+// keys of the object in the frame at depth top, and marks the members to
+// drop or rename.
+func (f *jsonEncoder) checkDuplicateKey(data []byte, top int) bool {
+	n := len(f.members) - 1
+	m := &f.members[n]
+	m.live, m.renamed = n, -1
+	head := f.findKey(data, top, n)
+	if head < 0 {
+		return false
+	}
+	m.live = -1
+	h := &f.members[head]
+	p := &f.members[h.live]
+
+	switch {
+	case f.DuplicateKeys == LastKeyWins && f.isBuiltinMember(p):
+		// The built-in stays: the last field with its key is kept
+		// under a new name, dropping the one renamed before it.
+		m.rename = 1
+		if h.renamed >= 0 {
+			f.members[h.renamed].drop = true
+		}
+		h.renamed = n
+	case f.DuplicateKeys == LastKeyWins:
+		p.drop = true
+		h.live = n
+	case f.DuplicateKeys == FirstKeyWins:
+		m.drop = true
+	default:
+		p.dups++
+		m.rename = p.dups
+	}
+	return true
+}
+
+// findKey returns the first occurrence of the key of member n in the
+// object in the frame at depth top, or -1 if the key is new there.
+func (f *jsonEncoder) findKey(data []byte, top, n int) int {
+	fr := &f.frames[top]
+	key := data[f.members[n].start:f.members[n].keyEnd]
+
+	if fr.keys < jsonKeySetMin {
+		for i := fr.first; i < n; i++ {
+			p := &f.members[i]
+			if p.object == fr.object && p.live >= 0 && bytes.Equal(data[p.start:p.keyEnd], key) {
+				return i
+			}
+		}
+		fr.keys++
+		if fr.keys == jsonKeySetMin {
+			// From now on use a hash table, one per nesting level.
+			for len(f.keyTables) <= top {
+				f.keyTables = append(f.keyTables, jsonKeyTable{})
+			}
+			t := &f.keyTables[top]
+			t.reset(2 * jsonKeySetMin)
+			for i := fr.first; i <= n; i++ {
+				if p := &f.members[i]; p.object == fr.object && p.live >= 0 {
+					t.insert(f.members, data, i)
+				}
+			}
+		}
+		return -1
+	}
+
+	t := &f.keyTables[top]
+	if i := t.find(f.members, data, key); i >= 0 {
+		return i
+	}
+	fr.keys++
+	t.insert(f.members, data, n)
+	return -1
+}
+
+// reset empties the table and makes room for size keys.
+func (t *jsonKeyTable) reset(size int) {
+	c := 4
+	for c < 2*size {
+		c <<= 1
+	}
+	if cap(t.slots) < c {
+		t.slots = make([]int32, c)
+	} else {
+		t.slots = t.slots[:c]
+		clear(t.slots)
+	}
+	t.n = 0
+}
+
+// find returns the member with the given key, or -1.
+func (t *jsonKeyTable) find(members []jsonMember, data, key []byte) int {
+	mask := uint64(len(t.slots) - 1)
+	for i := maphash.Bytes(jsonKeySeed, key) & mask; ; i = (i + 1) & mask {
+		s := t.slots[i]
+		if s == 0 {
+			return -1
+		}
+		p := &members[s-1]
+		if bytes.Equal(data[p.start:p.keyEnd], key) {
+			return int(s - 1)
+		}
+	}
+}
+
+// insert adds member i, whose key is not in the table yet.
+func (t *jsonKeyTable) insert(members []jsonMember, data []byte, i int) {
+	if 2*(t.n+1) > len(t.slots) {
+		old := t.slots
+		t.slots = make([]int32, 2*len(old))
+		t.n = 0
+		for _, s := range old {
+			if s != 0 {
+				t.insert(members, data, int(s-1))
+			}
+		}
+	}
+	p := &members[i]
+	mask := uint64(len(t.slots) - 1)
+	j := maphash.Bytes(jsonKeySeed, data[p.start:p.keyEnd]) & mask
+	for t.slots[j] != 0 {
+		j = (j + 1) & mask
+	}
+	t.slots[j] = int32(i + 1)
+	t.n++
+}
+
+// avoidKeyCollisions raises the counters of renamed members whose new
+// key is already taken in their object, by a key written as is or by a
+// member renamed before.
+func (f *jsonEncoder) avoidKeyCollisions(data []byte) {
+	if f.keySuffix == nil {
+		var b Buffer
+		_ = EscapeString(&b, f.DuplicateKeySuffix)
+		f.keySuffix = b.Data
+	}
+	for i := range f.members {
+		m := &f.members[i]
+		if m.rename == 0 || m.drop {
+			continue
+		}
+		for f.keyTaken(data, i) {
+			m.rename++
+		}
+	}
+}
+
+// keyTaken reports whether the new key of the renamed member i is the
+// key of another member of its object.
+func (f *jsonEncoder) keyTaken(data []byte, i int) bool {
+	m := &f.members[i]
+	f.renameKey = f.appendRenamedKey(f.renameKey[:0], data, m)
+	for j := range f.members {
+		p := &f.members[j]
+		if j == i || p.object != m.object || p.drop {
+			continue
+		}
+		switch {
+		case p.rename == 0:
+			if bytes.Equal(data[p.start:p.keyEnd], f.renameKey) {
+				return true
+			}
+		case j < i:
+			f.otherKey = f.appendRenamedKey(f.otherKey[:0], data, p)
+			if bytes.Equal(f.otherKey, f.renameKey) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appendRenamedKey appends the escaped new key of a renamed member, with
+// the opening quote.
+func (f *jsonEncoder) appendRenamedKey(dst, data []byte, m *jsonMember) []byte {
+	dst = append(dst, data[m.start:m.keyEnd]...)
+	dst = append(dst, f.keySuffix...)
+	return strconv.AppendInt(dst, int64(m.rename), 10)
+}
+
+// isBuiltinMember reports whether m is one of the built-in fields or the
+// constant fields of a preset, written before any Bag or entry field.
+func (f *jsonEncoder) isBuiltinMember(m *jsonMember) bool {
+	return m.object == 0 && m.start < f.builtinEnd
+}
+
+// rewriteDuplicateKeys rewrites the record written from start, leaving
+// out dropped members and renaming the others.
+func (f *jsonEncoder) rewriteDuplicateKeys(start int) {
+	f.scratch = append(f.scratch[:0], f.buf.Data[start:]...)
+	data := f.scratch
+	f.buf.Truncate(start)
+
+	pos := 0
+	for i := range f.members {
+		m := &f.members[i]
+		if m.start < pos {
+			// Inside a dropped member.
+			continue
+		}
+		switch {
+		case m.drop:
+			f.buf.AppendBytes(data[pos:m.start])
+			pos = m.end
+			if f.buf.Back() == ',' {
+				f.buf.Truncate(f.buf.Len() - 1)
+			} else if data[pos] == ',' {
+				pos++
+			}
+		case m.rename != 0:
+			f.buf.AppendBytes(data[pos:m.keyEnd])
+			f.buf.AppendBytes(f.keySuffix)
+			f.buf.AppendInt(int64(m.rename))
+			pos = m.keyEnd
+		}
+	}
+	f.buf.AppendBytes(data[pos:])
+}
+
+// skipJSONString returns the index after the string starting at b[i].
+func skipJSONString(b []byte, i int) int {
+	for i++; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,219 @@
+package logf
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONEncoderDuplicateKeys(t *testing.T) {
+	entry := Entry{
+		Level:     LevelInfo,
+		Text:      "m",
+		Bag:       NewBag(String("user", "ctx"), Int("n", 1)),
+		LoggerBag: NewBag(String("user", "logger")),
+		Fields:    []Field{String("user", "entry"), Int("status", 200)},
+	}
+	grouped := Entry{
+		Level:     LevelInfo,
+		Text:      "m",
+		LoggerBag: NewBag(Int("id", 1)).WithGroup("http").With(String("method", "GET"), Int("id", 2)),
+		Fields:    []Field{String("method", "POST"), Group("req", Int("id", 3), Int("id", 4))},
+	}
+	nested := Entry{
+		Level: LevelInfo,
+		Text:  "m",
+		Fields: []Field{
+			Group("a", Int("x", 1), Group("b", Int("y", 1))),
+			Group("a", Int("x", 2)),
+			Strings("arr", []string{"x", "x"}),
+			Any("any", map[string]any{"a": []any{map[string]int{"a": 1}}}),
+			String("msg", "field"),
+		},
+	}
+
+	testCases := []struct {
+		name   string
+		policy DuplicateKeyPolicy
+		entry  Entry
+		golden string
+	}{
+		{
+			"Keep", KeepDuplicateKeys, entry,
+			`{"level":"info","msg":"m","user":"ctx","n":1,"user":"logger","user":"entry","status":200}`,
+		},
+		{
+			"LastWins", LastKeyWins, entry,
+			`{"level":"info","msg":"m","n":1,"user":"entry","status":200}`,
+		},
+		{
+			"FirstWins", FirstKeyWins, entry,
+			`{"level":"info","msg":"m","user":"ctx","n":1,"status":200}`,
+		},
+		{
+			"Rename", RenameDuplicateKeys, entry,
+			`{"level":"info","msg":"m","user":"ctx","n":1,"user_1":"logger","user_2":"entry","status":200}`,
+		},
+		{
+			"LastWinsGroups", LastKeyWins, grouped,
+			`{"level":"info","msg":"m","id":1,"http":{"id":2,"method":"POST","req":{"id":4}}}`,
+		},
+		{
+			"FirstWinsGroups", FirstKeyWins, grouped,
+			`{"level":"info","msg":"m","id":1,"http":{"method":"GET","id":2,"req":{"id":3}}}`,
+		},
+		{
+			"RenameGroups", RenameDuplicateKeys, grouped,
+			`{"level":"info","msg":"m","id":1,"http":{"method":"GET","id":2,"method_1":"POST","req":{"id":3,"id_1":4}}}`,
+		},
+		{
+			"LastWinsNested", LastKeyWins, nested,
+			`{"level":"info","msg":"m","a":{"x":2},"arr":["x","x"],"any":{"a":[{"a":1}]},"msg_1":"field"}`,
+		},
+		{
+			"FirstWinsNested", FirstKeyWins, nested,
+			`{"level":"info","msg":"m","a":{"x":1,"b":{"y":1}},"arr":["x","x"],"any":{"a":[{"a":1}]}}`,
+		},
+		{
+			"RenameNested", RenameDuplicateKeys, nested,
+			`{"level":"info","msg":"m","a":{"x":1,"b":{"y":1}},"a_1":{"x":2},"arr":["x","x"],` +
+				`"any":{"a":[{"a":1}]},"msg_1":"field"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc := JSON().DuplicateKeys(tc.policy).Build()
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden+"\n", b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestJSONEncoderDuplicateKeysEscaped(t *testing.T) {
+	enc := JSON().DuplicateKeys(RenameDuplicateKeys).DuplicateKeySuffix(`"#`).DisableLevel().DisableMsg().Build()
+	b, err := enc.Encode(Entry{Fields: []Field{
+		String(`k"}`, `v{",`), String(`k"}`, "w"), String("k", "x"),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"k\"}":"v{\",","k\"}\"#1":"w","k":"x"}`+"\n", b.String())
+	b.Free()
+
+	// Dropping every member leaves an empty object.
+	enc = JSON().DuplicateKeys(LastKeyWins).DisableLevel().DisableMsg().Build()
+	b, err = enc.Encode(Entry{Fields: []Field{Group("g", Int("a", 1), Int("a", 2)), Int("g", 3)}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"g":3}`+"\n", b.String())
+	b.Free()
+}
+
+func TestJSONEncoderDuplicateKeysManyKeys(t *testing.T) {
+	// Objects with many keys are looked up in a hash table.
+	var fields []Field
+	var keys []string
+	for i := 0; i < 40; i++ {
+		fields = append(fields, Int("k"+strconv.Itoa(i), i))
+		keys = append(keys, `"k`+strconv.Itoa(i)+`":`+strconv.Itoa(i))
+	}
+	fields = append(fields, Int("k3", 100), Int("k35", 200))
+	e := Entry{Fields: append([]Field{Group("g", fields...)}, fields...)}
+	object := func(keys ...string) string {
+		return "{" + strings.Join(keys, ",") + "}"
+	}
+	without := func(drop ...int) []string {
+		var out []string
+		for i, k := range keys {
+			if i != drop[0] && i != drop[1] {
+				out = append(out, k)
+			}
+		}
+		return out
+	}
+
+	lastWins := object(append(without(3, 35), `"k3":100`, `"k35":200`)...)
+	firstWins := object(keys...)
+	rename := object(append(keys[:len(keys):len(keys)], `"k3_1":100`, `"k35_1":200`)...)
+	testCases := []struct {
+		name   string
+		policy DuplicateKeyPolicy
+		object string
+	}{
+		{"LastWins", LastKeyWins, lastWins},
+		{"FirstWins", FirstKeyWins, firstWins},
+		{"Rename", RenameDuplicateKeys, rename},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc := JSON().DuplicateKeys(tc.policy).DisableLevel().DisableMsg().Build()
+			b, err := enc.Encode(e)
+			require.NoError(t, err)
+			assert.Equal(t, `{"g":`+tc.object+","+tc.object[1:]+"\n", b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestJSONEncoderDuplicateKeysRenameCollisions(t *testing.T) {
+	// New keys never repeat a key of the object.
+	enc := JSON().DuplicateKeys(RenameDuplicateKeys).DisableLevel().DisableMsg().Build()
+	b, err := enc.Encode(Entry{Fields: []Field{
+		Int("id", 1), Int("id", 2), Int("id_1", 3), Int("id_2", 4), Int("id", 5),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1,"id_3":2,"id_1":3,"id_2":4,"id_4":5}`+"\n", b.String())
+	b.Free()
+
+	enc = JSON().DuplicateKeys(LastKeyWins).DisableTime().Build()
+	b, err = enc.Encode(Entry{Level: LevelInfo, Text: "t", Fields: []Field{
+		String("msg_1", "a"), String("msg", "b"),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"level":"info","msg":"t","msg_1":"a","msg_2":"b"}`+"\n", b.String())
+	b.Free()
+}
+
+func TestJSONEncoderDuplicateKeysBuiltins(t *testing.T) {
+	// Bag and entry fields never replace the built-in fields; with
+	// LastKeyWins they are renamed instead.
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	e := Entry{
+		Level:     LevelInfo,
+		Time:      ts,
+		Text:      "t",
+		Bag:       NewBag(String("msg", "m")),
+		LoggerBag: NewBag(String("level", "l")),
+		Fields:    []Field{String("ts", "x"), String("msg", "n")},
+	}
+	testCases := []struct {
+		name   string
+		policy DuplicateKeyPolicy
+		golden string
+	}{
+		{"LastWins", LastKeyWins, `{"level":"info","ts":"2024-05-01T10:00:00Z","msg":"t","level_1":"l","ts_1":"x","msg_1":"n"}`},
+		{"FirstWins", FirstKeyWins, `{"level":"info","ts":"2024-05-01T10:00:00Z","msg":"t"}`},
+		{"Rename", RenameDuplicateKeys, `{"level":"info","ts":"2024-05-01T10:00:00Z","msg":"t","msg_1":"m","level_1":"l","ts_1":"x","msg_2":"n"}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc := JSON().DuplicateKeys(tc.policy).Build()
+			b, err := enc.Encode(e)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden+"\n", b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestNewJSONEncoderDuplicateKeys(t *testing.T) {
+	enc := NewJSONEncoder(JSONEncoderConfig{DuplicateKeys: RenameDuplicateKeys, DuplicateKeySuffix: "#"})
+	b, err := enc.Encode(Entry{Level: LevelInfo, Text: "m", Fields: []Field{Int("level", 1)}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"level":"info","msg":"m","level#1":1}`+"\n", b.String())
+	b.Free()
+}
@@ -42,12 +42,58 @@ type JSONEncoderConfig struct {
 	EncodeLevel    LevelEncoder
 	EncodeCaller   CallerEncoder
 
+	// ReplaceField, if set, is called for every field before it is
+	// written, with the names of the groups it is nested in, outermost
+	// first. It returns the field to write instead: the same field, one
+	// with another key or type, or an empty Field to drop it. It is not
+	// called for Group fields, only for their members, and it must not
+	// retain groups. Bag fields are replaced once per encoder and cached
+	// with the Bag, so ReplaceField must be a pure function.
+	//
+	// Built-in fields are passed with no groups and their configured
+	// keys: the level as an Any field holding a Level, the time as a Time
+	// field, the logger name and the message as String fields and the
+	// caller as an Any field holding its program counter (uintptr). A
+	// result of the same type changes the key or value of the built-in;
+	// any other result is written as a regular field.
+	ReplaceField func(groups []string, f Field) Field
+
+	// DuplicateKeys selects what to do with keys that appear more than
+	// once in the same object (default KeepDuplicateKeys). Any other
+	// policy scans each record once more; records are only rewritten
+	// when they do have duplicates.
+	DuplicateKeys DuplicateKeyPolicy
+
+	// DuplicateKeySuffix goes between a renamed key and its counter
+	// with RenameDuplicateKeys (default "_").
+	DuplicateKeySuffix string
+
+	// FlattenGroups, if set, writes Group fields, Object fields and Bag
+	// groups as keys joined with this separator instead of nested
+	// objects: "http.status":200 rather than "http":{"status":200}.
+	// Groups and objects with no fields are left out.
+	FlattenGroups string
+
+	// FlattenDepth limits how many levels FlattenGroups flattens
+	// (default 0, no limit). Deeper groups and objects are written as
+	// nested objects under the flattened key.
+	FlattenDepth int
+
+	// Limits bounds the message, values and the whole entry (default no
+	// limits). See SizeLimits.
+	Limits SizeLimits
+
 	// Precomputed escaped key fragments: `"key":` — avoids EscapeString on every call.
-	keyLevel  []byte
-	keyTime   []byte
-	keyName   []byte
-	keyMsg    []byte
-	keyCaller []byte
+	keyLevel     []byte
+	keyTime      []byte
+	keyName      []byte
+	keyMsg       []byte
+	keyCaller    []byte
+	keyTruncated []byte
+
+	// Precomputed `"key":value` pairs written after the built-in fields
+	// of every entry, set by presets such as ECS.
+	static []byte
 }
 
 // WithDefaults returns a copy of the config with all zero-value fields
@@ -87,12 +133,17 @@ func (c JSONEncoderConfig) WithDefaults() JSONEncoderConfig {
 	if c.EncodeCaller == nil {
 		c.EncodeCaller = ShortCallerEncoder
 	}
+	if c.DuplicateKeySuffix == "" {
+		c.DuplicateKeySuffix = "_"
+	}
+	c.Limits = c.Limits.WithDefaults()
 
 	c.keyLevel = precomputeKey(c.FieldKeyLevel)
 	c.keyTime = precomputeKey(c.FieldKeyTime)
 	c.keyName = precomputeKey(c.FieldKeyName)
 	c.keyMsg = precomputeKey(c.FieldKeyMsg)
 	c.keyCaller = precomputeKey(c.FieldKeyCaller)
+	c.keyTruncated = precomputeKey(c.Limits.FieldKeyTruncated)
 
 	return c
 }
@@ -204,6 +255,74 @@ func (b *JSONEncoderBuilder) EncodeError(e ErrorEncoder) *JSONEncoderBuilder {
 	return b
 }
 
+// ReplaceField sets a hook that renames, converts or drops fields,
+// including Bag fields and the built-in ones, before they are written:
+//
+//	enc := logf.JSON().ReplaceField(func(groups []string, f logf.Field) logf.Field {
+//	    if f.Key == "password" {
+//	        return logf.Field{}
+//	    }
+//	    return f
+//	}).Build()
+//
+// See JSONEncoderConfig.ReplaceField for details.
+func (b *JSONEncoderBuilder) ReplaceField(fn func(groups []string, f Field) Field) *JSONEncoderBuilder {
+	b.cfg.ReplaceField = fn
+	return b
+}
+
+// DuplicateKeys sets what to do with keys that appear more than once in
+// the same object, e.g. in the logger's Bag and in the entry:
+//
+//	enc := logf.JSON().DuplicateKeys(logf.LastKeyWins).Build()
+//	// {"level":"info","msg":"m","user":"bob"} instead of
+//	// {"level":"info","msg":"m","user":"alice","user":"bob"}
+//
+// Built-in keys such as "msg" take part too.
+func (b *JSONEncoderBuilder) DuplicateKeys(p DuplicateKeyPolicy) *JSONEncoderBuilder {
+	b.cfg.DuplicateKeys = p
+	return b
+}
+
+// DuplicateKeySuffix sets the separator between a renamed key and its
+// counter with RenameDuplicateKeys (default "_").
+func (b *JSONEncoderBuilder) DuplicateKeySuffix(s string) *JSONEncoderBuilder {
+	b.cfg.DuplicateKeySuffix = s
+	return b
+}
+
+// FlattenGroups writes groups and objects as keys joined with sep
+// instead of nested objects, for sinks that do not handle nesting well:
+//
+//	enc := logf.JSON().FlattenGroups(".").Build()
+//	// {"level":"info","msg":"m","http.status":200} instead of
+//	// {"level":"info","msg":"m","http":{"status":200}}
+func (b *JSONEncoderBuilder) FlattenGroups(sep string) *JSONEncoderBuilder {
+	b.cfg.FlattenGroups = sep
+	return b
+}
+
+// FlattenDepth limits FlattenGroups to the n outermost levels; deeper
+// groups and objects stay nested (default 0, no limit).
+func (b *JSONEncoderBuilder) FlattenDepth(n int) *JSONEncoderBuilder {
+	b.cfg.FlattenDepth = n
+	return b
+}
+
+// Limits bounds the size of entries, so that one oversized value cannot
+// produce a log line a collector chokes on:
+//
+//	enc := logf.JSON().Limits(logf.SizeLimits{
+//	    MaxStringLength: 4 << 10,
+//	    MaxEntrySize:    64 << 10,
+//	}).Build()
+//
+// Entries with anything truncated get a "_truncated":true field.
+func (b *JSONEncoderBuilder) Limits(l SizeLimits) *JSONEncoderBuilder {
+	b.cfg.Limits = l
+	return b
+}
+
 // Build finalizes the configuration and returns a ready-to-use JSON Encoder.
 func (b *JSONEncoderBuilder) Build() Encoder {
 	return buildJSONEncoder(b.cfg)
@@ -241,6 +360,39 @@ type jsonEncoder struct {
 	// Internal state.
 	buf         *Buffer
 	startBufLen int
+
+	// Open group names for ReplaceField, and whether Bags must be
+	// encoded without their cache.
+	groups     []string
+	noBagCache bool
+
+	// FlattenGroups state: the escaped key prefix of the flattened
+	// levels, their number, and the number of objects open past them.
+	prefix     Buffer
+	flatLevels int
+	nested     int
+
+	// Limits state: nesting depth inside fields, the number of values
+	// truncated or dropped, whether the entry is full, and the array
+	// limiters of the open arrays.
+	depth       int
+	truncations int
+	full        bool
+	arrays      []*jsonArrayLimiter
+	openArrays  int
+	anyWriter   jsonLimitWriter
+
+	// Duplicate key resolution state, reused across entries. builtinEnd
+	// is where the fields after the built-in ones start, relative to
+	// startBufLen.
+	members    []jsonMember
+	frames     []jsonFrame
+	keyTables  []jsonKeyTable
+	scratch    []byte
+	keySuffix  []byte // escaped DuplicateKeySuffix
+	renameKey  []byte
+	otherKey   []byte
+	builtinEnd int
 }
 
 func (f *jsonEncoder) TypeEncoder(buf *Buffer) TypeEncoder {
@@ -278,51 +430,105 @@ func (f *jsonEncoder) Encode(e Entry) (*Buffer, error) {
 func (f *jsonEncoder) encode(buf *Buffer, e Entry) error {
 	f.buf = buf
 	f.startBufLen = buf.Len()
+	f.truncations = 0
+	f.full = false
 
 	f.buf.AppendByte('{')
 
 	// Level.
 	if !f.DisableFieldLevel {
-		f.addPrecomputedKey(f.keyLevel)
-		cur := f.buf.Len()
-		f.EncodeLevel(e.Level, f)
-		if f.buf.Len() == cur {
-			f.EncodeTypeString(e.Level.String())
+		key, lvl, ok := f.FieldKeyLevel, e.Level, true
+		if f.ReplaceField != nil {
+			var r Field
+			if r, ok = f.replaceBuiltin(levelField(key, lvl)); ok {
+				key, lvl = r.Key, r.Any.(Level)
+			}
+		}
+		if ok {
+			f.addBuiltinKey(f.keyLevel, f.FieldKeyLevel, key)
+			cur := f.buf.Len()
+			f.EncodeLevel(lvl, f)
+			if f.buf.Len() == cur {
+				f.EncodeTypeString(lvl.String())
+			}
 		}
 	}
 
 	// Time.
 	if !f.DisableFieldTime && !e.Time.IsZero() {
-		f.addPrecomputedKey(f.keyTime)
-		cur := f.buf.Len()
-		f.EncodeTime(e.Time, f)
-		if f.buf.Len() == cur {
-			f.EncodeTypeInt64(e.Time.UnixNano())
+		key, t, ok := f.FieldKeyTime, e.Time, true
+		if f.ReplaceField != nil {
+			var r Field
+			if r, ok = f.replaceBuiltin(Time(key, t)); ok {
+				key, t = r.Key, fieldTime(r)
+			}
+		}
+		if ok {
+			f.addBuiltinKey(f.keyTime, f.FieldKeyTime, key)
+			cur := f.buf.Len()
+			f.EncodeTime(t, f)
+			if f.buf.Len() == cur {
+				f.EncodeTypeInt64(t.UnixNano())
+			}
 		}
 	}
 
 	// Logger name.
 	if !f.DisableFieldName && e.LoggerName != "" {
-		f.addPrecomputedKey(f.keyName)
-		f.EncodeTypeString(e.LoggerName)
+		key, name, ok := f.FieldKeyName, e.LoggerName, true
+		if f.ReplaceField != nil {
+			var r Field
+			if r, ok = f.replaceBuiltin(String(key, name)); ok {
+				key, name = r.Key, fieldString(r)
+			}
+		}
+		if ok {
+			f.addBuiltinKey(f.keyName, f.FieldKeyName, key)
+			f.EncodeTypeString(name)
+		}
 	}
 
 	// Message.
 	if !f.DisableFieldMsg {
-		f.addPrecomputedKey(f.keyMsg)
-		f.EncodeTypeString(e.Text)
+		key, text, ok := f.FieldKeyMsg, e.Text, true
+		if f.ReplaceField != nil {
+			var r Field
+			if r, ok = f.replaceBuiltin(String(key, text)); ok {
+				key, text = r.Key, fieldString(r)
+			}
+		}
+		if ok {
+			f.addBuiltinKey(f.keyMsg, f.FieldKeyMsg, key)
+			f.encodeString(text, f.Limits.MaxMessageLength)
+		}
 	}
 
 	// Caller.
 	if !f.DisableFieldCaller && e.CallerPC != 0 {
-		f.addPrecomputedKey(f.keyCaller)
-		cur := f.buf.Len()
-		f.EncodeCaller(e.CallerPC, f)
-		if f.buf.Len() == cur {
-			f.EncodeTypeString("unknown")
+		key, pc, ok := f.FieldKeyCaller, e.CallerPC, true
+		if f.ReplaceField != nil {
+			var r Field
+			if r, ok = f.replaceBuiltin(callerField(key, pc)); ok {
+				key, pc = r.Key, r.Any.(uintptr)
+			}
+		}
+		if ok {
+			f.addBuiltinKey(f.keyCaller, f.FieldKeyCaller, key)
+			cur := f.buf.Len()
+			f.EncodeCaller(pc, f)
+			if f.buf.Len() == cur {
+				f.EncodeTypeString("unknown")
+			}
 		}
 	}
 
+	// Constant fields of a preset.
+	if len(f.static) != 0 {
+		f.appendSeparator()
+		f.buf.AppendBytes(f.static)
+	}
+	f.builtinEnd = f.buf.Len() - f.startBufLen
+
 	// Skip trailing groups that would produce empty objects.
 	loggerBag := e.LoggerBag
 	ctxBag := e.Bag
@@ -336,20 +542,40 @@ func (f *jsonEncoder) encode(buf *Buffer, e Entry) error {
 	// Context fields (request-scoped).
 	f.encodeBag(ctxBag)
 
-	// Logger's fields (service-scoped).
+	// Logger's fields (service-scoped). Their cached replacements and
+	// flattened keys were made for the groups of the logger's Bag alone.
+	f.noBagCache = (f.ReplaceField != nil && len(f.groups) != 0) || f.prefix.Len() != 0 || f.nested != 0
 	f.encodeBag(loggerBag)
+	f.noBagCache = false
 
 	// Entry's fields.
 	for i := range e.Fields {
-		e.Fields[i].Accept(f)
+		f.acceptField(e.Fields[i])
 	}
+	f.groups = f.groups[:0]
 
 	// Close open groups (from WithGroup in Bag chains).
-	for n := countGroups(ctxBag) + countGroups(loggerBag); n > 0; n-- {
-		f.buf.AppendByte('}')
+	if f.FlattenGroups == "" {
+		for n := countGroups(ctxBag) + countGroups(loggerBag); n > 0; n-- {
+			f.buf.AppendByte('}')
+		}
+	} else {
+		for ; f.nested > 0; f.nested-- {
+			f.buf.AppendByte('}')
+		}
+		f.prefix.Reset()
+		f.flatLevels = 0
+	}
+
+	if f.truncations != 0 {
+		f.addPrecomputedKey(f.keyTruncated)
+		f.buf.AppendBool(true)
 	}
 
 	f.buf.AppendByte('}')
+	if f.DuplicateKeys != KeepDuplicateKeys {
+		f.resolveDuplicateKeys(f.startBufLen)
+	}
 	f.buf.AppendByte('\n')
 
 	return nil
@@ -394,34 +620,142 @@ func (f *jsonEncoder) encodeBag(bag *Bag) {
 	// Group node: just open a nested JSON object, no caching needed.
 	if bag.group != "" {
 		f.encodeBag(bag.parent)
-		f.addKey(bag.group)
-		f.buf.AppendByte('{')
+		f.openBagGroup(bag.group, true)
 		return
 	}
 
 	// Field node: use cache.
-	if data := bag.LoadCache(f.slot); data != nil {
+	if f.noBagCache {
+		f.encodeBag(bag.parent)
+		for _, field := range bag.fields {
+			f.acceptField(field)
+		}
+		return
+	}
+	if data := bag.LoadCache(f.slot); data != nil && f.fits(len(data)) {
+		if f.ReplaceField != nil || f.FlattenGroups != "" {
+			f.pushBagGroups(bag.parent)
+		}
 		f.buf.AppendBytes(data)
 		return
 	}
 
 	start := f.buf.Len()
+	truncations := f.truncations
 
 	// Walk parent first to preserve field order (parent before child).
 	f.encodeBag(bag.parent)
 
 	for _, field := range bag.fields {
-		field.Accept(f)
+		f.acceptField(field)
 	}
 
-	// Cache the encoded bytes (includes parent content).
-	if f.slot != 0 {
+	// Cache the encoded bytes (includes parent content), unless they
+	// were cut to the limits of this entry.
+	if f.slot != 0 && f.truncations == truncations {
 		encoded := make([]byte, f.buf.Len()-start)
 		copy(encoded, f.buf.Data[start:])
 		bag.StoreCache(f.slot, encoded)
 	}
 }
 
+// openBagGroup opens a group of a Bag chain: a nested object, or a
+// level of the key prefix with FlattenGroups. Unless write is set, the
+// object is already written and only the state is updated.
+func (f *jsonEncoder) openBagGroup(group string, write bool) {
+	if f.ReplaceField != nil {
+		f.groups = append(f.groups, group)
+	}
+	if f.flatten() {
+		f.pushPrefix(group)
+		return
+	}
+	if write {
+		f.addKey(group)
+		f.buf.AppendByte('{')
+	}
+	if f.FlattenGroups != "" {
+		f.nested++
+	}
+}
+
+// pushBagGroups opens the groups of a Bag chain, as encodeBag does, for
+// a Bag served from the cache.
+func (f *jsonEncoder) pushBagGroups(bag *Bag) {
+	if bag == nil {
+		return
+	}
+	f.pushBagGroups(bag.parent)
+	if bag.group != "" {
+		f.openBagGroup(bag.group, false)
+	}
+}
+
+// flatten reports whether the next group or object is flattened.
+func (f *jsonEncoder) flatten() bool {
+	return f.FlattenGroups != "" && f.nested == 0 && (f.FlattenDepth <= 0 || f.flatLevels < f.FlattenDepth)
+}
+
+// pushPrefix adds k to the key prefix and returns the previous prefix
+// length for popPrefix.
+func (f *jsonEncoder) pushPrefix(k string) int {
+	n := f.prefix.Len()
+	_ = EscapeString(&f.prefix, k)
+	_ = EscapeString(&f.prefix, f.FlattenGroups)
+	f.flatLevels++
+	return n
+}
+
+// popPrefix restores the key prefix returned by pushPrefix.
+func (f *jsonEncoder) popPrefix(n int) {
+	f.prefix.Truncate(n)
+	f.flatLevels--
+}
+
+// acceptField encodes a field, passing it through ReplaceField first.
+func (f *jsonEncoder) acceptField(fd Field) {
+	if f.ReplaceField != nil && fd.Type != FieldTypeGroup {
+		fd = f.ReplaceField(f.groups, fd)
+	}
+	if f.Limits.MaxEntrySize > 0 {
+		f.acceptFieldLimited(fd)
+		return
+	}
+	fd.Accept(f)
+}
+
+// acceptFieldLimited encodes a field unless the entry is full, and drops
+// it again if it takes the entry past MaxEntrySize. Fields cut to fit
+// by the values they hold mark the entry full themselves and are kept.
+func (f *jsonEncoder) acceptFieldLimited(fd Field) {
+	if f.full {
+		return
+	}
+	start := f.buf.Len()
+	fd.Accept(f)
+	f.dropOverflow(start)
+}
+
+// replaceBuiltin passes a built-in field through ReplaceField. A result
+// that is no longer a built-in is written as a regular field.
+func (f *jsonEncoder) replaceBuiltin(fd Field) (Field, bool) {
+	r, ok := replaceBuiltin(f.ReplaceField, fd)
+	if !ok {
+		r.Accept(f)
+	}
+	return r, ok
+}
+
+// addBuiltinKey adds the key of a built-in field, precomputed unless
+// ReplaceField renamed it.
+func (f *jsonEncoder) addBuiltinKey(precomputed []byte, orig, key string) {
+	if key == orig {
+		f.addPrecomputedKey(precomputed)
+		return
+	}
+	f.addKey(key)
+}
+
 func (f *jsonEncoder) EncodeFieldAny(k string, v interface{}) {
 	f.addKey(k)
 	f.EncodeTypeAny(v)
@@ -479,6 +813,14 @@ func (f *jsonEncoder) EncodeFieldArray(k string, v ArrayEncoder) {
 }
 
 func (f *jsonEncoder) EncodeFieldObject(k string, v ObjectEncoder) {
+	if f.flatten() && !f.tooDeep() {
+		n := f.pushPrefix(k)
+		f.depth++
+		_ = v.EncodeLogfObject(f)
+		f.depth--
+		f.popPrefix(n)
+		return
+	}
 	f.addKey(k)
 	f.EncodeTypeObject(v)
 }
@@ -487,17 +829,40 @@ func (f *jsonEncoder) EncodeFieldGroup(k string, fs []Field) {
 	if k == "" {
 		// Inline group: emit fields at current level.
 		for _, field := range fs {
-			field.Accept(f)
+			f.acceptField(field)
 		}
 		return
 	}
-	f.addKey(k)
-	f.appendSeparator()
-	f.buf.AppendByte('{')
-	for _, field := range fs {
-		field.Accept(f)
+	if f.tooDeep() {
+		f.addKey(k)
+		f.encodeMarker()
+		return
+	}
+	if f.ReplaceField != nil {
+		f.groups = append(f.groups, k)
+	}
+	f.depth++
+	if f.flatten() {
+		n := f.pushPrefix(k)
+		for _, field := range fs {
+			f.acceptField(field)
+		}
+		f.popPrefix(n)
+	} else {
+		f.addKey(k)
+		f.appendSeparator()
+		f.buf.AppendByte('{')
+		f.nested++
+		for _, field := range fs {
+			f.acceptField(field)
+		}
+		f.nested--
+		f.buf.AppendByte('}')
+	}
+	f.depth--
+	if f.ReplaceField != nil {
+		f.groups = f.groups[:len(f.groups)-1]
 	}
-	f.buf.AppendByte('}')
 }
 
 func (f *jsonEncoder) EncodeFieldBytes(k string, v []byte) {
@@ -521,18 +886,51 @@ func (f *jsonEncoder) EncodeFieldDurations(k string, v []time.Duration) {
 }
 
 func (f *jsonEncoder) EncodeTypeAny(v interface{}) {
-	e := json.NewEncoder(f.buf)
-	_ = e.Encode(v)
+	start := f.buf.Len()
+	n, room := f.Limits.limit(f.Limits.MaxStringLength, f.buf.Len()-f.startBufLen)
+
+	if n >= 0 && anyTooLong(v, n+anyMarshalSlack) {
+		// Too long to be worth marshalling only to cut.
+		f.appendAnySummary(v, n, room)
+		return
+	}
+	if n < 0 {
+		e := json.NewEncoder(f.buf)
+		_ = e.Encode(v)
+	} else {
+		// The JSON goes to the entry only up to the byte that shows it is
+		// too long.
+		f.anyWriter = jsonLimitWriter{buf: f.buf, left: n + 1}
+		e := json.NewEncoder(&f.anyWriter)
+		_ = e.Encode(v)
+		f.anyWriter.buf = nil
+	}
 
 	if !f.empty() && f.buf.Back() == '\n' {
 		f.buf.Data = f.buf.Data[0 : f.buf.Len()-1]
 	}
+
+	// JSON too long to cut as is goes in a truncated string instead.
+	if n >= 0 && f.buf.Len()-start > n {
+		f.scratch = append(f.scratch[:0], f.buf.Data[start:start+n+1]...)
+		f.buf.Truncate(start)
+		f.buf.AppendByte('"')
+		_ = EscapeString(f.buf, truncate(f.scratch, n))
+		f.appendMarker(room)
+		f.buf.AppendByte('"')
+	}
 }
 
 func (f *jsonEncoder) EncodeTypeUnsafeBytes(v unsafe.Pointer) {
+	b := *(*[]byte)(v)
 	f.appendSeparator()
 	f.buf.AppendByte('"')
-	_ = EscapeString(f.buf, *(*[]byte)(v))
+	if n, room := f.Limits.limit(f.Limits.MaxStringLength, f.buf.Len()-f.startBufLen); n >= 0 && len(b) > n {
+		_ = EscapeString(f.buf, truncate(b, n))
+		f.appendMarker(room)
+	} else {
+		_ = EscapeString(f.buf, b)
+	}
 	f.buf.AppendByte('"')
 }
 
@@ -542,9 +940,20 @@ func (f *jsonEncoder) EncodeTypeBool(v bool) {
 }
 
 func (f *jsonEncoder) EncodeTypeString(v string) {
+	f.encodeString(v, f.Limits.MaxStringLength)
+}
+
+// encodeString encodes a string value cut to max bytes, if positive,
+// and to the room left in the entry.
+func (f *jsonEncoder) encodeString(v string, max int) {
 	f.appendSeparator()
 	f.buf.AppendByte('"')
-	_ = EscapeString(f.buf, v)
+	if n, room := f.Limits.limit(max, f.buf.Len()-f.startBufLen); n >= 0 && len(v) > n {
+		_ = EscapeString(f.buf, truncate(v, n))
+		f.appendMarker(room)
+	} else {
+		_ = EscapeString(f.buf, v)
+	}
 	f.buf.AppendByte('"')
 }
 
@@ -585,57 +994,113 @@ func (f *jsonEncoder) EncodeTypeTime(v time.Time) {
 func (f *jsonEncoder) EncodeTypeBytes(v []byte) {
 	f.appendSeparator()
 	f.buf.AppendByte('"')
+	n, room := f.Limits.limit(f.Limits.MaxStringLength, f.buf.Len()-f.startBufLen)
+	if room {
+		n = n / 4 * 3
+	}
+	cut := n >= 0 && len(v) > n
+	if cut {
+		v = v[:n]
+	}
 	base64.StdEncoding.Encode(f.buf.ExtendBytes(base64.StdEncoding.EncodedLen(len(v))), v)
+	if cut {
+		f.appendMarker(room)
+	}
 	f.buf.AppendByte('"')
 }
 
 func (f *jsonEncoder) EncodeTypeStrings(v []string) {
+	if f.tooDeep() {
+		f.encodeMarker()
+		return
+	}
 	f.appendSeparator()
 	f.buf.AppendByte('[')
-	for i := range v {
+	for i, n := 0, f.arrayLen(len(v)); i < n && !f.full; i++ {
+		start := f.buf.Len()
 		f.EncodeTypeString(v[i])
+		f.dropOverflow(start)
 	}
 	f.buf.AppendByte(']')
 }
 
 func (f *jsonEncoder) EncodeTypeInts64(v []int64) {
+	if f.tooDeep() {
+		f.encodeMarker()
+		return
+	}
 	f.appendSeparator()
 	f.buf.AppendByte('[')
-	for i := range v {
+	for i, n := 0, f.arrayLen(len(v)); i < n && !f.full; i++ {
+		start := f.buf.Len()
 		f.EncodeTypeInt64(v[i])
+		f.dropOverflow(start)
 	}
 	f.buf.AppendByte(']')
 }
 
 func (f *jsonEncoder) EncodeTypeFloats64(v []float64) {
+	if f.tooDeep() {
+		f.encodeMarker()
+		return
+	}
 	f.appendSeparator()
 	f.buf.AppendByte('[')
-	for i := range v {
+	for i, n := 0, f.arrayLen(len(v)); i < n && !f.full; i++ {
+		start := f.buf.Len()
 		f.EncodeTypeFloat64(v[i])
+		f.dropOverflow(start)
 	}
 	f.buf.AppendByte(']')
 }
 
 func (f *jsonEncoder) EncodeTypeDurations(v []time.Duration) {
+	if f.tooDeep() {
+		f.encodeMarker()
+		return
+	}
 	f.appendSeparator()
 	f.buf.AppendByte('[')
-	for i := range v {
+	for i, n := 0, f.arrayLen(len(v)); i < n && !f.full; i++ {
+		start := f.buf.Len()
 		f.EncodeTypeDuration(v[i])
+		f.dropOverflow(start)
 	}
 	f.buf.AppendByte(']')
 }
 
 func (f *jsonEncoder) EncodeTypeArray(v ArrayEncoder) {
+	if f.tooDeep() {
+		f.encodeMarker()
+		return
+	}
 	f.appendSeparator()
 	f.buf.AppendByte('[')
-	_ = v.EncodeLogfArray(f)
+	f.nested++
+	f.depth++
+	if f.Limits.MaxArrayLength > 0 || f.Limits.MaxEntrySize > 0 {
+		_ = v.EncodeLogfArray(f.arrayLimiter())
+		f.openArrays--
+	} else {
+		_ = v.EncodeLogfArray(f)
+	}
+	f.depth--
+	f.nested--
 	f.buf.AppendByte(']')
 }
 
 func (f *jsonEncoder) EncodeTypeObject(v ObjectEncoder) {
+	if f.tooDeep() {
+		f.encodeMarker()
+		return
+	}
 	f.appendSeparator()
 	f.buf.AppendByte('{')
+	f.nested++
+	f.depth++
 	_ = v.EncodeLogfObject(f)
+	f.depth--
+	f.nested--
 	f.buf.AppendByte('}')
 }
 
@@ -663,6 +1128,9 @@ func (f *jsonEncoder) addPrecomputedKey(key []byte) {
 func (f *jsonEncoder) addKey(k string) {
 	f.appendSeparator()
 	f.buf.AppendByte('"')
+	if f.prefix.Len() != 0 && f.nested == 0 {
+		f.buf.AppendBytes(f.prefix.Data)
+	}
 	_ = EscapeString(f.buf, k)
 	f.buf.AppendByte('"')
 	f.buf.AppendByte(':')
@@ -580,3 +580,91 @@ func TestNewJSONEncoder(t *testing.T) {
 	b.Free()
 }
 
+
+func TestJSONEncoderFlattenGroups(t *testing.T) {
+	entry := Entry{
+		Level:     LevelInfo,
+		Text:      "m",
+		Bag:       NewBag(String("request_id", "r1")),
+		LoggerBag: NewBag(String("service", "api")).WithGroup("http").With(String("method", "GET")),
+		Fields: []Field{
+			Int("status", 200),
+			Group("req", Int("size", 10), Group("hdr", String("host", "a")), Group("empty")),
+			Object("user", testObjectEncoder{}),
+			Array("objs", testObjectsArray{}),
+		},
+	}
+	ctxGroups := Entry{
+		Level:     LevelInfo,
+		Text:      "m",
+		Bag:       NewBag(String("request_id", "r1")).WithGroup("ctx"),
+		LoggerBag: NewBag(String("service", "api")).WithGroup("http"),
+		Fields:    []Field{Int("status", 200)},
+	}
+
+	testCases := []struct {
+		name   string
+		depth  int
+		entry  Entry
+		golden string
+	}{
+		{
+			"Unlimited", 0, entry,
+			`{"level":"info","msg":"m","request_id":"r1","service":"api","http.method":"GET","http.status":200,` +
+				`"http.req.size":10,"http.req.hdr.host":"a","http.user.username":"username","http.user.code":42,` +
+				`"http.objs":[{"username":"username","code":42}]}`,
+		},
+		{
+			"Depth1", 1, entry,
+			`{"level":"info","msg":"m","request_id":"r1","service":"api","http.method":"GET","http.status":200,` +
+				`"http.req":{"size":10,"hdr":{"host":"a"},"empty":{}},"http.user":{"username":"username","code":42},` +
+				`"http.objs":[{"username":"username","code":42}]}`,
+		},
+		{
+			"Depth2", 2, entry,
+			`{"level":"info","msg":"m","request_id":"r1","service":"api","http.method":"GET","http.status":200,` +
+				`"http.req.size":10,"http.req.hdr":{"host":"a"},"http.req.empty":{},"http.user.username":"username",` +
+				`"http.user.code":42,"http.objs":[{"username":"username","code":42}]}`,
+		},
+		{
+			"CtxGroups", 0, ctxGroups,
+			`{"level":"info","msg":"m","request_id":"r1","ctx.service":"api","ctx.http.status":200}`,
+		},
+		{
+			"CtxGroupsDepth1", 1, ctxGroups,
+			`{"level":"info","msg":"m","request_id":"r1","ctx.service":"api","ctx.http":{"status":200}}`,
+		},
+		{
+			"LoggerGroupsDepth0", 0,
+			Entry{Level: LevelInfo, Text: "m", LoggerBag: NewBag().WithGroup("a").WithGroup("b").With(Int("x", 1))},
+			`{"level":"info","msg":"m","a.b.x":1}`,
+		},
+		{
+			"LoggerGroupsDepth1", 1,
+			Entry{Level: LevelInfo, Text: "m", LoggerBag: NewBag().WithGroup("a").WithGroup("b").With(Int("x", 1))},
+			`{"level":"info","msg":"m","a.b":{"x":1}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc := withCacheSlot(JSON().FlattenGroups(".").FlattenDepth(tc.depth).Build())
+			// The second run takes the Bags from the cache, where their
+			// keys are already flattened.
+			for i := 0; i < 2; i++ {
+				b, err := enc.Encode(tc.entry)
+				require.NoError(t, err)
+				assert.Equal(t, tc.golden+"\n", b.String())
+				b.Free()
+			}
+		})
+	}
+}
+
+func TestJSONEncoderFlattenGroupsEscaped(t *testing.T) {
+	enc := NewJSONEncoder(JSONEncoderConfig{FlattenGroups: `"/`, DisableFieldLevel: true, DisableFieldMsg: true})
+	b, err := enc.Encode(Entry{Fields: []Field{Group(`a"`, Int("b", 1))}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a\"\"/b":1}`+"\n", b.String())
+	b.Free()
+}
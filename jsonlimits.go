@@ -0,0 +1,454 @@
+package logf
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// appendMarker ends a truncated value. Values cut to the room left fill
+// the entry.
+func (f *jsonEncoder) appendMarker(room bool) {
+	_ = EscapeString(f.buf, f.Limits.Marker)
+	f.truncations++
+	if room {
+		f.full = true
+	}
+}
+
+// encodeMarker writes the marker in place of a value nested too deep.
+func (f *jsonEncoder) encodeMarker() {
+	f.appendSeparator()
+	f.buf.AppendByte('"')
+	f.appendMarker(false)
+	f.buf.AppendByte('"')
+}
+
+// tooDeep reports whether a group, object or array opened now would
+// nest deeper than MaxDepth.
+func (f *jsonEncoder) tooDeep() bool {
+	return f.Limits.MaxDepth > 0 && f.depth >= f.Limits.MaxDepth
+}
+
+// fits reports whether n more bytes fit in the entry.
+func (f *jsonEncoder) fits(n int) bool {
+	return f.Limits.MaxEntrySize <= 0 || f.buf.Len()-f.startBufLen+n <= f.Limits.MaxEntrySize
+}
+
+// arrayLen returns how many of n array elements to write.
+func (f *jsonEncoder) arrayLen(n int) int {
+	if max := f.Limits.MaxArrayLength; max > 0 && n > max {
+		f.truncations++
+		return max
+	}
+	return n
+}
+
+// dropOverflow drops what was written from start if it took the entry
+// past MaxEntrySize, and marks the entry full.
+func (f *jsonEncoder) dropOverflow(start int) {
+	if f.Limits.MaxEntrySize > 0 && !f.full && f.buf.Len()-f.startBufLen > f.Limits.MaxEntrySize {
+		f.buf.Truncate(start)
+		f.full = true
+		f.truncations++
+	}
+}
+
+// errJSONLimit stops an encoding/json Encoder writing past a limit.
+var errJSONLimit = errors.New("logf: value exceeds the size limit")
+
+// jsonLimitWriter appends to buf up to left bytes and then fails, so that
+// Any values never grow the entry past what is kept of them.
+type jsonLimitWriter struct {
+	buf  *Buffer
+	left int
+}
+
+func (w *jsonLimitWriter) Write(p []byte) (int, error) {
+	if len(p) > w.left {
+		w.buf.AppendBytes(p[:w.left])
+		n := w.left
+		w.left = 0
+		return n, errJSONLimit
+	}
+	w.buf.AppendBytes(p)
+	w.left -= len(p)
+	return len(p), nil
+}
+
+// appendAnySummary writes a string naming the type, and the length for
+// collections, of an Any value too long to marshal, cut to n bytes.
+func (f *jsonEncoder) appendAnySummary(v interface{}, n int, room bool) {
+	rv := reflect.ValueOf(v)
+	f.scratch = append(f.scratch[:0], rv.Type().String()...)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Chan, reflect.Map, reflect.Slice, reflect.String:
+		f.scratch = append(f.scratch, " len="...)
+		f.scratch = strconv.AppendInt(f.scratch, int64(rv.Len()), 10)
+	}
+	if len(f.scratch) > n {
+		f.scratch = truncate(f.scratch, n)
+	}
+	f.appendSeparator()
+	f.buf.AppendByte('"')
+	_ = EscapeString(f.buf, f.scratch)
+	f.appendMarker(room)
+	f.buf.AppendByte('"')
+}
+
+const (
+	// Any values whose JSON is certainly longer than the limit plus
+	// anyMarshalSlack bytes are summarized instead of marshalled.
+	anyMarshalSlack = 4 << 10
+
+	// Cycles nested deeper than this are left to encoding/json to report.
+	anySizeMaxDepth = 1000
+)
+
+var (
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// anyTooLong reports whether encoding/json certainly writes more than n
+// bytes for v. It walks v adding up the bytes its JSON takes at the
+// least and stops as soon as they exceed n, so it costs no more for a
+// huge value than for one of n bytes. Marshalers count as one byte.
+func anyTooLong(v interface{}, n int) bool {
+	s := anySizer{left: n}
+	s.walk(reflect.ValueOf(v), 0)
+	return s.left < 0
+}
+
+type anySizer struct {
+	left int
+}
+
+// add counts n bytes and reports whether they still fit.
+func (s *anySizer) add(n int) bool {
+	s.left -= n
+	return s.left >= 0
+}
+
+func (s *anySizer) walk(v reflect.Value, depth int) bool {
+	if !v.IsValid() {
+		return s.add(4) // null
+	}
+	if depth > anySizeMaxDepth {
+		return true
+	}
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return s.add(1)
+	}
+	if v.CanAddr() && (reflect.PointerTo(t).Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)) {
+		return s.add(1)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return s.add(4)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return s.add(1)
+	case reflect.String:
+		if t == jsonNumberType {
+			return s.add(v.Len())
+		}
+		return s.add(2 + v.Len())
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return s.add(4)
+		}
+		return s.walk(v.Elem(), depth+1)
+	case reflect.Slice:
+		if v.IsNil() {
+			return s.add(4)
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Base64 is longer than the bytes.
+			return s.add(2 + v.Len())
+		}
+		return s.walkArray(v, depth)
+	case reflect.Array:
+		return s.walkArray(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			return s.add(4)
+		}
+		if !s.add(1 + v.Len()) { // braces and commas
+			return false
+		}
+		for it := v.MapRange(); it.Next(); {
+			// Keys are quoted and followed by a colon.
+			k := it.Key()
+			if k.Kind() == reflect.String {
+				if !s.add(3 + k.Len()) {
+					return false
+				}
+			} else if !s.add(3) {
+				return false
+			}
+			if !s.walk(it.Value(), depth+1) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		if !s.add(2) {
+			return false
+		}
+		for _, i := range anyStructFields(t) {
+			// The key is quoted and followed by a colon and a comma.
+			if !s.add(4) || !s.walk(v.Field(i), depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+	// Channels, functions and complex numbers fail to marshal.
+	return true
+}
+
+// anyStructFieldsCache maps struct types to their anyStructFields.
+var anyStructFieldsCache sync.Map
+
+// anyStructFields returns the indexes of the fields of struct type t that
+// encoding/json always writes: exported ones declared in t, neither
+// omitted when empty nor hidden by another field of the same name. The
+// fields of embedded structs are left out.
+func anyStructFields(t reflect.Type) []int {
+	if fields, ok := anyStructFieldsCache.Load(t); ok {
+		return fields.([]int)
+	}
+
+	type field struct {
+		name   string
+		tagged bool
+		count  bool
+	}
+	all := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" || !sf.IsExported() && !sf.Anonymous {
+			all = append(all, field{})
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		f := field{name: name, tagged: name != "", count: !sf.Anonymous && !strings.Contains(opts, "omit")}
+		if !f.tagged {
+			if sf.Anonymous {
+				all = append(all, field{})
+				continue
+			}
+			f.name = sf.Name
+		}
+		all = append(all, f)
+	}
+
+	fields := []int{}
+	for i, f := range all {
+		if !f.count {
+			continue
+		}
+		hidden := false
+		for j, g := range all {
+			// Of fields sharing a name only a tagged one is written.
+			if j != i && g.name == f.name && (g.tagged || !f.tagged) {
+				hidden = true
+				break
+			}
+		}
+		if !hidden {
+			fields = append(fields, i)
+		}
+	}
+	anyStructFieldsCache.Store(t, fields)
+	return fields
+}
+
+func (s *anySizer) walkArray(v reflect.Value, depth int) bool {
+	if !s.add(1 + v.Len()) { // brackets and commas
+		return false
+	}
+	for i := 0; i < v.Len(); i++ {
+		if !s.walk(v.Index(i), depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayLimiter returns the limiter for an array opened at the current
+// depth. Limiters are reused across entries; the caller decrements
+// openArrays when the array is done.
+func (f *jsonEncoder) arrayLimiter() *jsonArrayLimiter {
+	if f.openArrays == len(f.arrays) {
+		f.arrays = append(f.arrays, &jsonArrayLimiter{f: f})
+	}
+	a := f.arrays[f.openArrays]
+	a.n, a.cut = 0, false
+	f.openArrays++
+	return a
+}
+
+// jsonArrayLimiter is the TypeEncoder an ArrayEncoder writes its elements
+// to when limits are set. It passes them to the JSON encoder up to
+// MaxArrayLength and while they fit in the entry, and skips the rest
+// without encoding them.
+type jsonArrayLimiter struct {
+	f   *jsonEncoder
+	n   int
+	cut bool
+}
+
+// next reports whether the next element is written.
+func (a *jsonArrayLimiter) next() bool {
+	if a.cut || a.f.full {
+		return false
+	}
+	if max := a.f.Limits.MaxArrayLength; max > 0 && a.n == max {
+		a.f.truncations++
+		a.cut = true
+		return false
+	}
+	a.n++
+	return true
+}
+
+func (a *jsonArrayLimiter) EncodeTypeAny(v interface{}) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeAny(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeBool(v bool) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeBool(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeInt64(v int64) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeInt64(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeUint64(v uint64) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeUint64(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeFloat64(v float64) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeFloat64(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeDuration(v time.Duration) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeDuration(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeTime(v time.Time) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeTime(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeString(v string) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeString(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeStrings(v []string) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeStrings(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeBytes(v []byte) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeBytes(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeInts64(v []int64) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeInts64(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeFloats64(v []float64) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeFloats64(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeDurations(v []time.Duration) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeDurations(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeArray(v ArrayEncoder) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeArray(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeObject(v ObjectEncoder) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeObject(v)
+		a.f.dropOverflow(start)
+	}
+}
+
+func (a *jsonArrayLimiter) EncodeTypeUnsafeBytes(v unsafe.Pointer) {
+	if a.next() {
+		start := a.f.buf.Len()
+		a.f.EncodeTypeUnsafeBytes(v)
+		a.f.dropOverflow(start)
+	}
+}
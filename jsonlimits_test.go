@@ -0,0 +1,308 @@
+package logf
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCountArray encodes the numbers from 0 to n-1.
+type testCountArray int
+
+func (n testCountArray) EncodeLogfArray(e TypeEncoder) error {
+	for i := 0; i < int(n); i++ {
+		e.EncodeTypeInt64(int64(i))
+	}
+	return nil
+}
+
+// testDeepObject nests objects n levels deep.
+type testDeepObject int
+
+func (n testDeepObject) EncodeLogfObject(e FieldEncoder) error {
+	if n == 0 {
+		e.EncodeFieldInt64("x", 1)
+		return nil
+	}
+	e.EncodeFieldObject("o", n-1)
+	return nil
+}
+
+func TestJSONEncoderLimits(t *testing.T) {
+	testCases := []struct {
+		name   string
+		limits SizeLimits
+		entry  Entry
+		golden string
+	}{
+		{
+			"NoLimits",
+			SizeLimits{},
+			Entry{Text: "message", Fields: []Field{String("s", "value"), Ints64("a", []int64{1, 2, 3})}},
+			`{"msg":"message","s":"value","a":[1,2,3]}`,
+		},
+		{
+			"Message",
+			SizeLimits{MaxMessageLength: 4},
+			Entry{Text: "message", Fields: []Field{String("s", "value")}},
+			`{"msg":"mess…","s":"value","_truncated":true}`,
+		},
+		{
+			"Strings",
+			SizeLimits{MaxStringLength: 3},
+			Entry{Text: "message", Fields: []Field{
+				String("s", "value"), String("short", "abc"), Bytes("b", []byte("abcdef")),
+				Error(errors.New("failure")), Strings("ss", []string{"abcdef"}), ByteString("bs", []byte("abcdef")),
+			}},
+			`{"msg":"message","s":"val…","short":"abc","b":"YWJj…","error":"fai…","ss":["abc…"],"bs":"abc…","_truncated":true}`,
+		},
+		{
+			"UTF8",
+			SizeLimits{MaxStringLength: 3},
+			Entry{Fields: []Field{String("s", "héllo"), String("t", "日本")}},
+			`{"msg":"","s":"hé…","t":"日…","_truncated":true}`,
+		},
+		{
+			"Any",
+			SizeLimits{MaxStringLength: 8},
+			Entry{Fields: []Field{Any("short", []int{1}), Any("long", map[string]string{"key": "value"})}},
+			`{"msg":"","short":[1],"long":"{\"key\":\"…","_truncated":true}`,
+		},
+		{
+			"Arrays",
+			SizeLimits{MaxArrayLength: 2},
+			Entry{Fields: []Field{
+				Ints64("i", []int64{1, 2, 3}), Strings("s", []string{"a"}), Array("a", testCountArray(5)),
+				Array("nested", testObjectsArray{}),
+			}},
+			`{"msg":"","i":[1,2],"s":["a"],"a":[0,1],"nested":[{"username":"username","code":42}],"_truncated":true}`,
+		},
+		{
+			"Depth",
+			SizeLimits{MaxDepth: 2},
+			Entry{Fields: []Field{
+				Object("o", testDeepObject(3)),
+				Group("g", Group("h", Int("x", 1), Group("i", Int("y", 2)), Ints64("z", []int64{1}))),
+				Array("a", testObjectsArray{}),
+			}},
+			`{"msg":"","o":{"o":{"o":"…"}},"g":{"h":{"x":1,"i":"…","z":"…"}},"a":[{"username":"username","code":42}],"_truncated":true}`,
+		},
+		{
+			"EntryDropsFields",
+			SizeLimits{MaxEntrySize: 40},
+			Entry{Text: "m", Fields: []Field{Int("a", 1), Int("bbbbbbbbbbbbbbbbbbbbb", 2), Int("c", 3)}},
+			`{"msg":"m","a":1,"_truncated":true}`,
+		},
+		{
+			"EntryCutsValues",
+			SizeLimits{MaxEntrySize: 30},
+			Entry{Text: "m", Fields: []Field{String("s", strings.Repeat("x", 100)), Int("c", 3)}},
+			`{"msg":"m","s":"xxxxxxxxxxxxxx…","_truncated":true}`,
+		},
+		{
+			"EntryCutsArrays",
+			SizeLimits{MaxEntrySize: 30},
+			Entry{Text: "m", Fields: []Field{Array("a", testCountArray(100)), Ints64("b", []int64{1, 2, 3})}},
+			`{"msg":"m","a":[0,1,2,3,4,5,6],"_truncated":true}`,
+		},
+		{
+			"EntryCutsMessage",
+			SizeLimits{MaxEntrySize: 16},
+			Entry{Text: strings.Repeat("m", 100), Fields: []Field{Int("a", 1)}},
+			`{"msg":"mmmmmmmm…","_truncated":true}`,
+		},
+		{
+			"EntryDropsGroupMembers",
+			SizeLimits{MaxEntrySize: 30},
+			Entry{Text: "m", Fields: []Field{Group("g", Int("a", 1), Int("bbbbbbbbbbbbb", 2))}},
+			`{"msg":"m","g":{"a":1},"_truncated":true}`,
+		},
+		{
+			"Marker",
+			SizeLimits{MaxStringLength: 1, Marker: "...", FieldKeyTruncated: "cut"},
+			Entry{Fields: []Field{String("s", "ab")}},
+			`{"msg":"","s":"a...","cut":true}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc := JSON().DisableLevel().Limits(tc.limits).Build()
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden+"\n", b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestJSONEncoderLimitsBag(t *testing.T) {
+	enc := withCacheSlot(JSON().DisableLevel().Limits(SizeLimits{MaxStringLength: 4, MaxEntrySize: 64}).Build())
+	testCases := []struct {
+		name   string
+		entry  Entry
+		golden string
+	}{
+		{
+			"Fits",
+			Entry{Text: "m", LoggerBag: NewBag(String("service", "api")).WithGroup("http"), Fields: []Field{Int("status", 200)}},
+			`{"msg":"m","service":"api","http":{"status":200}}`,
+		},
+		{
+			"Truncated",
+			Entry{Text: "m", LoggerBag: NewBag(String("service", "billing")), Fields: []Field{Int("status", 200)}},
+			`{"msg":"m","service":"bill…","status":200,"_truncated":true}`,
+		},
+		{
+			"Full",
+			Entry{
+				Text:      "m",
+				Bag:       NewBag(String("a", "1234"), String("b", "1234"), String("c", "1234")),
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http"),
+				Fields:    []Field{Int("status", 200)},
+			},
+			`{"msg":"m","a":"1234","b":"1234","c":"1234","service":"api","http":{},"_truncated":true}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The second run must not take a truncated Bag from the
+			// cache: the cache never holds them.
+			for i := 0; i < 2; i++ {
+				b, err := enc.Encode(tc.entry)
+				require.NoError(t, err)
+				assert.Equal(t, tc.golden+"\n", b.String())
+				b.Free()
+			}
+		})
+	}
+}
+
+func TestJSONEncoderLimitsAnyBuffer(t *testing.T) {
+	// An Any value longer than the buffer never grows it past what is
+	// kept.
+	blob := map[string]string{"body": strings.Repeat("x", 2<<10)}
+	for _, limits := range []SizeLimits{{MaxStringLength: 32}, {MaxEntrySize: 64}} {
+		enc := JSON().DisableLevel().Limits(limits).Build().(*jsonEncoder).Clone().(*jsonEncoder)
+		buf := NewBufferWithCapacity(1024)
+		require.NoError(t, enc.encode(buf, Entry{Text: "m", Fields: []Field{Any("req", blob), Int("a", 1)}}))
+		assert.Equal(t, 1024, buf.Cap(), "the buffer did not grow")
+		assert.Contains(t, buf.String(), `"req":"{\"body\":\"xxx`)
+		assert.Contains(t, buf.String(), `…"`)
+		assert.Contains(t, buf.String(), `"_truncated":true}`)
+	}
+}
+
+// testPanicMarshaler fails the test if it is marshalled.
+type testPanicMarshaler struct{}
+
+func (testPanicMarshaler) MarshalJSON() ([]byte, error) {
+	panic("marshalled")
+}
+
+func TestJSONEncoderLimitsAnySummary(t *testing.T) {
+	testCases := []struct {
+		name   string
+		limits SizeLimits
+		v      interface{}
+		golden string
+	}{
+		{
+			"String",
+			SizeLimits{MaxStringLength: 32},
+			map[string]string{"body": strings.Repeat("x", 8<<20)},
+			`{"msg":"m","req":"map[string]string len=1…","a":1,"_truncated":true}`,
+		},
+		{
+			"Elements",
+			SizeLimits{MaxStringLength: 40},
+			make([]testPanicMarshaler, 1<<20),
+			`{"msg":"m","req":"[]logf.testPanicMarshaler len=1048576…","a":1,"_truncated":true}`,
+		},
+		{
+			"Struct",
+			SizeLimits{MaxStringLength: 8},
+			struct{ Body []byte }{make([]byte, 1<<20)},
+			`{"msg":"m","req":"struct {…","a":1,"_truncated":true}`,
+		},
+		{
+			"Entry",
+			SizeLimits{MaxEntrySize: 32},
+			map[string]string{"body": strings.Repeat("x", 8<<20)},
+			`{"msg":"m","req":"map[string]stri…","_truncated":true}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc := JSON().DisableLevel().Limits(tc.limits).Build()
+			b, err := enc.Encode(Entry{Text: "m", Fields: []Field{Any("req", tc.v), Int("a", 1)}})
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden+"\n", b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestAnyTooLong(t *testing.T) {
+	type inner struct{ A, B string }
+	type tagged struct {
+		A string `json:"b"`
+		B string
+		C string `json:",omitempty"`
+		D string `json:"-"`
+		g string
+		inner
+	}
+	// Values are never too long for their own JSON, and no shorter.
+	values := []interface{}{
+		nil,
+		true,
+		-12.5,
+		"a\"b",
+		[]byte("abcd"),
+		[]int{},
+		[3]int{1, 2, 3},
+		map[string]int{"a": 1, "bb": 2},
+		map[int]bool{1: false},
+		(*int)(nil),
+		[]interface{}{nil, "x", 1},
+		json.Number("12"),
+		time.Unix(0, 0),
+		inner{"a", "b"},
+		tagged{A: "x", B: "y", C: "z", D: "w", g: "h", inner: inner{"a", "b"}},
+		// Vet rejects repeated tags in source.
+		reflect.New(reflect.StructOf([]reflect.StructField{
+			{Name: "A", Type: reflect.TypeOf(""), Tag: `json:"e"`},
+			{Name: "B", Type: reflect.TypeOf(""), Tag: `json:"e"`},
+		})).Elem().Interface(),
+		struct {
+			A string `json:"B"`
+			B string
+		}{},
+	}
+	for _, v := range values {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		assert.False(t, anyTooLong(v, len(data)), "%T %s", v, data)
+	}
+
+	assert.True(t, anyTooLong(strings.Repeat("x", 100), 64))
+	assert.True(t, anyTooLong(make([]int, 100), 64))
+	assert.True(t, anyTooLong(map[int]int{1: 1, 2: 2, 3: 3}, 8))
+	assert.True(t, anyTooLong(struct{ A, B, C int }{}, 8))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "ab", truncate("abc", 2))
+	assert.Equal(t, "", truncate("abc", 0))
+	assert.Equal(t, "a", truncate("aé", 2))
+	assert.Equal(t, "aé", truncate("aéb", 3))
+	assert.Equal(t, []byte("\xff\xff"), truncate([]byte("\xff\xff\xff"), 2))
+}
@@ -135,6 +135,29 @@ func ShortTextLevelEncoder(lvl Level, m TypeEncoder) {
 	}
 }
 
+// WithLevel returns a new context that overrides the level for everything
+// logged with it. Use it to trace a single request at debug level without
+// touching the global configuration:
+//
+//	if r.Header.Get("X-Debug") != "" {
+//	    ctx = logf.WithLevel(ctx, logf.LevelDebug)
+//	}
+//
+// The override replaces the static level of ContextHandler, SyncHandler,
+// NamedLevels and every Router output, so it can make logging quieter as
+// well as more verbose. Custom Handlers can honor it via LevelFromContext.
+func WithLevel(ctx context.Context, lvl Level) context.Context {
+	return context.WithValue(ctx, contextKeyLevel{}, lvl)
+}
+
+// LevelFromContext returns the level set by WithLevel, if any.
+func LevelFromContext(ctx context.Context) (Level, bool) {
+	lvl, ok := ctx.Value(contextKeyLevel{}).(Level)
+	return lvl, ok
+}
+
+type contextKeyLevel struct{}
+
 // NewMutableLevel creates a MutableLevel starting at the given level.
 // Pass it where a Level is expected and call Set later to change the
 // threshold at runtime without restarting.
@@ -151,8 +174,12 @@ type MutableLevel struct {
 }
 
 // Enabled reports whether the given level is enabled at the current mutable
-// level. Safe for concurrent use.
-func (l *MutableLevel) Enabled(_ context.Context, lvl Level) bool {
+// level, or at the level set by WithLevel if ctx has one. Safe for
+// concurrent use.
+func (l *MutableLevel) Enabled(ctx context.Context, lvl Level) bool {
+	if override, ok := LevelFromContext(ctx); ok {
+		return override.Enabled(lvl)
+	}
 	return l.Level().Enabled(lvl)
 }
 
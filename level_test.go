@@ -1,11 +1,14 @@
 package logf
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLevelEnabled(t *testing.T) {
@@ -180,3 +183,71 @@ func TestLevelUnmarshalInvalid(t *testing.T) {
 	assert.EqualError(t, err, `invalid logging level "some-invalid-value"`)
 	assert.Equal(t, LevelError, v.Level)
 }
+
+func TestWithLevel(t *testing.T) {
+	ctx := context.Background()
+	_, ok := LevelFromContext(ctx)
+	assert.False(t, ok)
+
+	ctx = WithLevel(ctx, LevelDebug)
+	lvl, ok := LevelFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, LevelDebug, lvl)
+
+	ml := NewMutableLevel(LevelError)
+	assert.True(t, ml.Enabled(ctx, LevelDebug))
+	assert.False(t, ml.Enabled(WithLevel(ctx, LevelError), LevelWarn))
+}
+
+func TestWithLevelLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger().
+		Level(LevelInfo).
+		Output(&buf).
+		EncoderFrom(JSON().DisableTime()).
+		Context().
+		Build().
+		WithCaller(false)
+
+	logger.Debug(context.Background(), "hidden")
+	logger.Debug(WithLevel(context.Background(), LevelDebug), "traced")
+	logger.Info(WithLevel(context.Background(), LevelError), "silenced")
+
+	assert.Equal(t, `{"level":"debug","msg":"traced"}`+"\n", buf.String())
+}
+
+func TestWithLevelRouter(t *testing.T) {
+	console := &spyWriter{}
+	alerts := &spyWriter{}
+	h, closeFn, err := NewRouter().
+		Route(&testEncoder{},
+			Output(LevelInfo, console),
+			Output(LevelError, alerts),
+		).
+		Build()
+	require.NoError(t, err)
+	ctx := WithLevel(context.Background(), LevelDebug)
+
+	assert.False(t, h.Enabled(context.Background(), LevelDebug))
+	assert.True(t, h.Enabled(ctx, LevelDebug))
+	require.NoError(t, h.Handle(ctx, Entry{Text: "debug", Level: LevelDebug}))
+	require.NoError(t, h.Handle(context.Background(), Entry{Text: "dropped", Level: LevelDebug}))
+	_ = closeFn()
+
+	assert.Equal(t, "debug", console.allData())
+	assert.Equal(t, "debug", alerts.allData(), "the override applies to every output")
+}
+
+func TestWithLevelRouterSkipsEncoding(t *testing.T) {
+	// The encoder fails if called: a silencing override must be checked
+	// before any route encodes the entry.
+	h, closeFn, err := NewRouter().
+		Route(&testEncoder{err: errors.New("encoded")}, Output(LevelDebug, &spyWriter{})).
+		Route(&testEncoder{err: errors.New("encoded")}, Output(LevelInfo, &spyWriter{})).
+		Build()
+	require.NoError(t, err)
+	defer closeFn()
+
+	assert.NoError(t, h.Handle(WithLevel(context.Background(), LevelError), Entry{Level: LevelInfo}))
+	assert.Error(t, h.Handle(WithLevel(context.Background(), LevelError), Entry{Level: LevelError}))
+}
@@ -0,0 +1,272 @@
+package logf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LevelHandler returns an http.Handler that reads and changes lvl on a
+// running process — mount it on an admin port and flip a pod to debug
+// without a restart:
+//
+//	level := logf.NewMutableLevel(logf.LevelInfo)
+//	http.Handle("/log/level", logf.LevelHandler(level, logf.LevelTTL(15*time.Minute)))
+//
+// GET returns the current level:
+//
+//	{"level":"info"}
+//
+// PUT or POST changes it. The request is a JSON body when the content
+// type is application/json, and form values (body or query) otherwise:
+//
+//	curl -X PUT localhost:6060/log/level -H 'Content-Type: application/json' \
+//	    -d '{"level":"debug","ttl":"10m"}'
+//	curl -X PUT 'localhost:6060/log/level?level=debug&ttl=10m'
+//
+// A change with a ttl reverts to the level that was set before when the
+// ttl expires; the response then also reports where and when it goes
+// back ("revert_to", "revert_at"). Without a ttl in the request the
+// LevelTTL option applies, and "ttl":"0" makes a change permanent.
+// Invalid requests get a 400 with {"error":"..."}.
+func LevelHandler(lvl *MutableLevel, opts ...LevelHandlerOption) http.Handler {
+	return newLevelHandler(map[string]*MutableLevel{"": lvl}, true, opts)
+}
+
+// LevelsHandler is like LevelHandler but serves several named levels,
+// e.g. one per Router output (see MutableOutput):
+//
+//	logf.LevelsHandler(map[string]*logf.MutableLevel{
+//	    "console": consoleLevel,
+//	    "file":    fileLevel,
+//	})
+//
+// GET without a name returns all of them, sorted by name:
+//
+//	{"levels":[{"name":"console","level":"info"},{"name":"file","level":"debug"}]}
+//
+// GET with ?name=file returns a single level, and PUT/POST require the
+// name, as a query or form value or as "name" in a JSON body. Unknown
+// names get a 404.
+func LevelsHandler(levels map[string]*MutableLevel, opts ...LevelHandlerOption) http.Handler {
+	return newLevelHandler(levels, false, opts)
+}
+
+// LevelHandlerOption configures LevelHandler and LevelsHandler.
+type LevelHandlerOption func(*levelHandler)
+
+// LevelTTL sets how long a change made through the handler lasts when
+// the request does not specify a ttl. Zero (the default) keeps changes
+// until the next one.
+func LevelTTL(d time.Duration) LevelHandlerOption {
+	return func(h *levelHandler) {
+		h.ttl = d
+	}
+}
+
+type levelHandler struct {
+	single bool
+	ttl    time.Duration
+	names  []string // sorted
+
+	mu     sync.Mutex
+	levels map[string]*levelState
+}
+
+// levelState is a served level with its pending revert, if any.
+type levelState struct {
+	lvl      *MutableLevel
+	timer    *time.Timer // non-nil while a revert is pending
+	revertTo Level
+	revertAt time.Time
+}
+
+// levelPayload is the JSON shape of requests and responses.
+type levelPayload struct {
+	Name     string     `json:"name,omitempty"`
+	Level    string     `json:"level"`
+	TTL      string     `json:"ttl,omitempty"` // request only
+	RevertTo string     `json:"revert_to,omitempty"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+func newLevelHandler(levels map[string]*MutableLevel, single bool, opts []LevelHandlerOption) *levelHandler {
+	h := &levelHandler{single: single, levels: make(map[string]*levelState, len(levels))}
+	for _, opt := range opts {
+		opt(h)
+	}
+	for name, lvl := range levels {
+		h.levels[name] = &levelState{lvl: lvl}
+		h.names = append(h.names, name)
+	}
+	sort.Strings(h.names)
+	return h
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, r)
+	case http.MethodPut, http.MethodPost:
+		h.put(w, r)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		writeLevelError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+func (h *levelHandler) get(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	name := r.URL.Query().Get("name")
+	if !h.single && name == "" {
+		all := make([]levelPayload, 0, len(h.names))
+		for _, name := range h.names {
+			all = append(all, h.levels[name].payload(name))
+		}
+		writeLevelJSON(w, http.StatusOK, struct {
+			Levels []levelPayload `json:"levels"`
+		}{all})
+		return
+	}
+
+	st, err := h.lookup(name)
+	if err != nil {
+		writeLevelError(w, http.StatusNotFound, err)
+		return
+	}
+	writeLevelJSON(w, http.StatusOK, st.payload(name))
+}
+
+func (h *levelHandler) put(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeLevelRequest(r)
+	if err != nil {
+		writeLevelError(w, http.StatusBadRequest, err)
+		return
+	}
+	lvl, ok := LevelFromString(req.Level)
+	if !ok {
+		writeLevelError(w, http.StatusBadRequest, fmt.Errorf("invalid logging level %q", req.Level))
+		return
+	}
+	ttl := h.ttl
+	if req.TTL != "" {
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl < 0 {
+			writeLevelError(w, http.StatusBadRequest, fmt.Errorf("invalid ttl %q", req.TTL))
+			return
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.single && req.Name == "" {
+		writeLevelError(w, http.StatusBadRequest, errors.New("name is required"))
+		return
+	}
+	st, err := h.lookup(req.Name)
+	if err != nil {
+		writeLevelError(w, http.StatusNotFound, err)
+		return
+	}
+	h.set(st, lvl, ttl)
+	writeLevelJSON(w, http.StatusOK, st.payload(req.Name))
+}
+
+// lookup returns the state for name. Must be called with mu held.
+func (h *levelHandler) lookup(name string) (*levelState, error) {
+	if h.single {
+		return h.levels[""], nil
+	}
+	st, ok := h.levels[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown level %q", name)
+	}
+	return st, nil
+}
+
+// set changes the level and schedules the revert. Repeated changes
+// within a ttl keep reverting to the level from before the first one.
+// Must be called with mu held.
+func (h *levelHandler) set(st *levelState, lvl Level, ttl time.Duration) {
+	pending := st.timer != nil
+	if pending {
+		st.timer.Stop()
+		st.timer = nil
+	}
+	if ttl <= 0 {
+		st.revertAt = time.Time{}
+		st.lvl.Set(lvl)
+		return
+	}
+
+	if !pending {
+		st.revertTo = st.lvl.Level()
+	}
+	st.lvl.Set(lvl)
+	st.revertAt = time.Now().Add(ttl)
+	var t *time.Timer
+	t = time.AfterFunc(ttl, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if st.timer != t {
+			// Superseded by a later change.
+			return
+		}
+		st.lvl.Set(st.revertTo)
+		st.timer = nil
+		st.revertAt = time.Time{}
+	})
+	st.timer = t
+}
+
+// payload describes the state. Must be called with mu held.
+func (st *levelState) payload(name string) levelPayload {
+	p := levelPayload{Name: name, Level: st.lvl.Level().String()}
+	if st.timer != nil {
+		at := st.revertAt.UTC()
+		p.RevertTo = st.revertTo.String()
+		p.RevertAt = &at
+	}
+	return p
+}
+
+func decodeLevelRequest(r *http.Request) (levelPayload, error) {
+	var req levelPayload
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, fmt.Errorf("invalid request body: %w", err)
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return req, err
+		}
+		req.Name = r.Form.Get("name")
+		req.Level = r.Form.Get("level")
+		req.TTL = r.Form.Get("ttl")
+	}
+	if req.Name == "" {
+		req.Name = r.URL.Query().Get("name")
+	}
+	if req.Level == "" {
+		return req, errors.New("level is required")
+	}
+	return req, nil
+}
+
+func writeLevelJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeLevelError(w http.ResponseWriter, code int, err error) {
+	writeLevelJSON(w, code, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
@@ -0,0 +1,147 @@
+package logf
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveLevel(t *testing.T, h http.Handler, method, target, contentType, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec.Code, resp
+}
+
+func TestLevelHandlerGet(t *testing.T) {
+	h := LevelHandler(NewMutableLevel(LevelInfo))
+
+	code, resp := serveLevel(t, h, http.MethodGet, "/", "", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{"level": "info"}, resp)
+}
+
+func TestLevelHandlerSet(t *testing.T) {
+	lvl := NewMutableLevel(LevelInfo)
+	h := LevelHandler(lvl)
+
+	code, resp := serveLevel(t, h, http.MethodPut, "/", "application/json", `{"level":"debug"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{"level": "debug"}, resp)
+	assert.Equal(t, LevelDebug, lvl.Level())
+
+	code, _ = serveLevel(t, h, http.MethodPost, "/", "application/x-www-form-urlencoded", "level=warn")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, LevelWarn, lvl.Level())
+
+	code, _ = serveLevel(t, h, http.MethodPut, "/?level=error", "", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, LevelError, lvl.Level())
+}
+
+func TestLevelHandlerBadRequests(t *testing.T) {
+	lvl := NewMutableLevel(LevelInfo)
+	h := LevelHandler(lvl)
+
+	cases := []struct {
+		name, contentType, body string
+		code                    int
+	}{
+		{"bad json", "application/json", `{"level":`, http.StatusBadRequest},
+		{"no level", "application/json", `{}`, http.StatusBadRequest},
+		{"bad level", "application/json", `{"level":"loud"}`, http.StatusBadRequest},
+		{"bad ttl", "application/json", `{"level":"debug","ttl":"soon"}`, http.StatusBadRequest},
+		{"negative ttl", "application/json", `{"level":"debug","ttl":"-1s"}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			code, resp := serveLevel(t, h, http.MethodPut, "/", c.contentType, c.body)
+			assert.Equal(t, c.code, code)
+			assert.NotEmpty(t, resp["error"])
+		})
+	}
+	assert.Equal(t, LevelInfo, lvl.Level())
+
+	code, resp := serveLevel(t, h, http.MethodDelete, "/", "", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	assert.NotEmpty(t, resp["error"])
+}
+
+func TestLevelHandlerTTL(t *testing.T) {
+	lvl := NewMutableLevel(LevelInfo)
+	h := LevelHandler(lvl)
+
+	code, resp := serveLevel(t, h, http.MethodPut, "/", "application/json", `{"level":"debug","ttl":"50ms"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "debug", resp["level"])
+	assert.Equal(t, "info", resp["revert_to"])
+	assert.NotEmpty(t, resp["revert_at"])
+
+	// A second change within the ttl still reverts to the original level.
+	code, resp = serveLevel(t, h, http.MethodPut, "/", "application/json", `{"level":"warn","ttl":"50ms"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "info", resp["revert_to"])
+
+	assert.Eventually(t, func() bool { return lvl.Level() == LevelInfo }, time.Second, 5*time.Millisecond)
+	_, resp = serveLevel(t, h, http.MethodGet, "/", "", "")
+	assert.Equal(t, map[string]any{"level": "info"}, resp)
+}
+
+func TestLevelHandlerDefaultTTL(t *testing.T) {
+	lvl := NewMutableLevel(LevelInfo)
+	h := LevelHandler(lvl, LevelTTL(time.Hour))
+
+	_, resp := serveLevel(t, h, http.MethodPut, "/", "application/json", `{"level":"debug"}`)
+	assert.Equal(t, "info", resp["revert_to"])
+
+	// An explicit zero ttl makes the change permanent and cancels the revert.
+	_, resp = serveLevel(t, h, http.MethodPut, "/", "application/json", `{"level":"debug","ttl":"0"}`)
+	assert.Equal(t, map[string]any{"level": "debug"}, resp)
+	assert.Equal(t, LevelDebug, lvl.Level())
+}
+
+func TestLevelsHandler(t *testing.T) {
+	console := NewMutableLevel(LevelInfo)
+	file := NewMutableLevel(LevelDebug)
+	h := LevelsHandler(map[string]*MutableLevel{"file": file, "console": console})
+
+	code, resp := serveLevel(t, h, http.MethodGet, "/", "", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{"levels": []any{
+		map[string]any{"name": "console", "level": "info"},
+		map[string]any{"name": "file", "level": "debug"},
+	}}, resp)
+
+	_, resp = serveLevel(t, h, http.MethodGet, "/?name=file", "", "")
+	assert.Equal(t, map[string]any{"name": "file", "level": "debug"}, resp)
+
+	code, _ = serveLevel(t, h, http.MethodPut, "/", "application/json", `{"name":"console","level":"error"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, LevelError, console.Level())
+
+	code, _ = serveLevel(t, h, http.MethodPut, "/?name=file", "application/json", `{"level":"warn"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, LevelWarn, file.Level())
+
+	code, _ = serveLevel(t, h, http.MethodPut, "/", "application/json", `{"level":"warn"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = serveLevel(t, h, http.MethodGet, "/?name=syslog", "", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = serveLevel(t, h, http.MethodPut, "/?name=syslog&level=debug", "", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
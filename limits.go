@@ -0,0 +1,102 @@
+package logf
+
+import "unicode/utf8"
+
+// Default SizeLimits values.
+const (
+	DefaultTruncationMarker  = "…"
+	DefaultFieldKeyTruncated = "_truncated"
+)
+
+// SizeLimits bounds what an encoder writes for a single entry, so that
+// one accidental Any("body", hugeBlob) cannot produce a log line that
+// knocks over a collector. Zero fields mean no limit.
+//
+// Only the JSON and text encoders take limits (JSONEncoderConfig.Limits
+// and TextEncoderConfig.Limits). The logfmt, GELF, syslog, MessagePack,
+// CBOR and pattern encoders have none and write every value in full.
+//
+// Limits are checked while encoding: values are cut before they are
+// escaped, array elements past the limit are never encoded and, once an
+// entry is full, the remaining fields are skipped. Truncated values end
+// with Marker, and an entry with anything truncated or dropped gets a
+// FieldKeyTruncated field set to true.
+type SizeLimits struct {
+	// MaxMessageLength limits the message text, in bytes.
+	MaxMessageLength int
+
+	// MaxStringLength limits string, bytes and error values, in bytes
+	// before escaping. Any values whose JSON is longer are written as
+	// truncated strings of that JSON. Values that are certainly a few
+	// kilobytes longer are not marshalled at all: a truncated string
+	// naming their type, and the length of collections, takes their
+	// place. A json.Marshaler or encoding.TextMarshaler has no size
+	// known in advance, so a value that is one is always marshalled.
+	MaxStringLength int
+
+	// MaxArrayLength limits the number of array elements. Elements past
+	// it are dropped.
+	MaxArrayLength int
+
+	// MaxDepth limits how deeply groups, objects and arrays nest inside
+	// a field; Bag groups do not count. Deeper values are replaced with
+	// Marker.
+	MaxDepth int
+
+	// MaxEntrySize limits the encoded entry, in bytes. Fields that do
+	// not fit are dropped, together with the fields after them. The
+	// built-in fields, closing brackets and the FieldKeyTruncated field
+	// are always written and may exceed it by a few bytes.
+	MaxEntrySize int
+
+	// Marker ends truncated values (default "…").
+	Marker string
+
+	// FieldKeyTruncated is the key of the field added to truncated
+	// entries (default "_truncated").
+	FieldKeyTruncated string
+}
+
+// WithDefaults returns a copy of the limits with the marker and the
+// field key set to their defaults if empty.
+func (l SizeLimits) WithDefaults() SizeLimits {
+	if l.Marker == "" {
+		l.Marker = DefaultTruncationMarker
+	}
+	if l.FieldKeyTruncated == "" {
+		l.FieldKeyTruncated = DefaultFieldKeyTruncated
+	}
+	return l
+}
+
+// limit returns how many bytes of a value an entry of used bytes can
+// take: max, if positive, or the room left before MaxEntrySize, whichever
+// is less. It returns -1 if there is no limit, and whether the room
+// decided it.
+func (l SizeLimits) limit(max, used int) (int, bool) {
+	n := -1
+	if max > 0 {
+		n = max
+	}
+	if l.MaxEntrySize > 0 {
+		room := l.MaxEntrySize - used
+		if room < 0 {
+			room = 0
+		}
+		if n < 0 || room < n {
+			return room, true
+		}
+	}
+	return n, false
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+// The caller must ensure len(s) > n.
+func truncate[S []byte | string](s S, n int) S {
+	for i := n; i > 0 && i > n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s[i]) {
+			return s[:i]
+		}
+	}
+	return s[:n]
+}
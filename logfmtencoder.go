@@ -0,0 +1,887 @@
+package logf
+
+import (
+	"encoding/base64"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// LogfmtEncoderConfig controls how the logfmt encoder formats log entries
+// — field keys, which fields to include, and how types like time,
+// duration, and errors are rendered. For a friendlier builder-style API,
+// use Logfmt() instead.
+type LogfmtEncoderConfig struct {
+	FieldKeyMsg    string
+	FieldKeyTime   string
+	FieldKeyLevel  string
+	FieldKeyName   string
+	FieldKeyCaller string
+
+	DisableFieldMsg    bool
+	DisableFieldTime   bool
+	DisableFieldLevel  bool
+	DisableFieldName   bool
+	DisableFieldCaller bool
+
+	EncodeTime     TimeEncoder
+	EncodeDuration DurationEncoder
+	EncodeError    ErrorEncoder
+	EncodeLevel    LevelEncoder
+	EncodeCaller   CallerEncoder
+}
+
+// WithDefaults returns a copy of the config with all zero-value fields
+// replaced by sensible defaults (the JSON field keys, RFC3339 timestamps,
+// string durations, short caller format, etc.).
+func (c LogfmtEncoderConfig) WithDefaults() LogfmtEncoderConfig {
+	if c.FieldKeyMsg == "" {
+		c.FieldKeyMsg = DefaultFieldKeyMsg
+	}
+	if c.FieldKeyTime == "" {
+		c.FieldKeyTime = DefaultFieldKeyTime
+	}
+	if c.FieldKeyLevel == "" {
+		c.FieldKeyLevel = DefaultFieldKeyLevel
+	}
+	if c.FieldKeyName == "" {
+		c.FieldKeyName = DefaultFieldKeyName
+	}
+	if c.FieldKeyCaller == "" {
+		c.FieldKeyCaller = DefaultFieldKeyCaller
+	}
+
+	if c.EncodeDuration == nil {
+		c.EncodeDuration = StringDurationEncoder
+	}
+	if c.EncodeTime == nil {
+		c.EncodeTime = RFC3339TimeEncoder
+	}
+	if c.EncodeError == nil {
+		c.EncodeError = DefaultErrorEncoder
+	}
+	if c.EncodeLevel == nil {
+		c.EncodeLevel = DefaultLevelEncoder
+	}
+	if c.EncodeCaller == nil {
+		c.EncodeCaller = ShortCallerEncoder
+	}
+	return c
+}
+
+// LogfmtEncoderBuilder configures and builds a logfmt Encoder using a
+// clean builder-style API. Create one with Logfmt(), chain methods to
+// customize, then call Build() or pass directly to
+// LoggerBuilder.EncoderFrom().
+type LogfmtEncoderBuilder struct {
+	cfg LogfmtEncoderConfig
+}
+
+// Logfmt returns a new LogfmtEncoderBuilder with default settings. The
+// encoder writes one line of space-separated key=value pairs per entry,
+// the format Loki, Grafana and many ops tools parse natively:
+//
+//	level=info ts=2024-05-01T10:00:00Z msg="request served" http.method=GET status=200
+//
+// Values are quoted, with JSON escapes, when they are empty or contain
+// spaces, '=', quotes, backslashes, control characters or invalid UTF-8.
+// Bytes in keys that logfmt does not allow are replaced with '_'.
+//
+// Everything is flattened into dotted keys:
+//
+//   - groups (Group, WithGroup) and objects prefix their fields:
+//     http.method=GET;
+//   - arrays get the element index: tags.0=a tags.1=b;
+//   - empty arrays and objects are written as tags=[] and user={}, and
+//     empty groups are omitted;
+//   - values of Any fields are rendered as JSON strings.
+func Logfmt() *LogfmtEncoderBuilder {
+	return &LogfmtEncoderBuilder{}
+}
+
+// TimeKey sets the key for the timestamp field (default "ts").
+func (b *LogfmtEncoderBuilder) TimeKey(k string) *LogfmtEncoderBuilder {
+	b.cfg.FieldKeyTime = k
+	return b
+}
+
+// LevelKey sets the key for the severity level field (default "level").
+func (b *LogfmtEncoderBuilder) LevelKey(k string) *LogfmtEncoderBuilder {
+	b.cfg.FieldKeyLevel = k
+	return b
+}
+
+// MsgKey sets the key for the log message field (default "msg").
+func (b *LogfmtEncoderBuilder) MsgKey(k string) *LogfmtEncoderBuilder {
+	b.cfg.FieldKeyMsg = k
+	return b
+}
+
+// NameKey sets the key for the logger name field (default "logger").
+func (b *LogfmtEncoderBuilder) NameKey(k string) *LogfmtEncoderBuilder {
+	b.cfg.FieldKeyName = k
+	return b
+}
+
+// CallerKey sets the key for the caller location field (default "caller").
+func (b *LogfmtEncoderBuilder) CallerKey(k string) *LogfmtEncoderBuilder {
+	b.cfg.FieldKeyCaller = k
+	return b
+}
+
+// DisableTime omits the timestamp field from logfmt output entirely.
+func (b *LogfmtEncoderBuilder) DisableTime() *LogfmtEncoderBuilder {
+	b.cfg.DisableFieldTime = true
+	return b
+}
+
+// DisableLevel omits the severity level field from logfmt output entirely.
+func (b *LogfmtEncoderBuilder) DisableLevel() *LogfmtEncoderBuilder {
+	b.cfg.DisableFieldLevel = true
+	return b
+}
+
+// DisableMsg omits the message text field from logfmt output entirely.
+func (b *LogfmtEncoderBuilder) DisableMsg() *LogfmtEncoderBuilder {
+	b.cfg.DisableFieldMsg = true
+	return b
+}
+
+// DisableName omits the logger name field from logfmt output entirely.
+func (b *LogfmtEncoderBuilder) DisableName() *LogfmtEncoderBuilder {
+	b.cfg.DisableFieldName = true
+	return b
+}
+
+// DisableCaller omits the caller location field from logfmt output entirely.
+func (b *LogfmtEncoderBuilder) DisableCaller() *LogfmtEncoderBuilder {
+	b.cfg.DisableFieldCaller = true
+	return b
+}
+
+// EncodeTime sets a custom TimeEncoder for formatting timestamps (default RFC3339).
+func (b *LogfmtEncoderBuilder) EncodeTime(e TimeEncoder) *LogfmtEncoderBuilder {
+	b.cfg.EncodeTime = e
+	return b
+}
+
+// EncodeDuration sets a custom DurationEncoder for formatting durations (default string representation).
+func (b *LogfmtEncoderBuilder) EncodeDuration(e DurationEncoder) *LogfmtEncoderBuilder {
+	b.cfg.EncodeDuration = e
+	return b
+}
+
+// EncodeLevel sets a custom LevelEncoder for formatting severity levels.
+func (b *LogfmtEncoderBuilder) EncodeLevel(e LevelEncoder) *LogfmtEncoderBuilder {
+	b.cfg.EncodeLevel = e
+	return b
+}
+
+// EncodeCaller sets a custom CallerEncoder for formatting caller locations (default short format).
+func (b *LogfmtEncoderBuilder) EncodeCaller(e CallerEncoder) *LogfmtEncoderBuilder {
+	b.cfg.EncodeCaller = e
+	return b
+}
+
+// EncodeError sets a custom ErrorEncoder for formatting error values.
+func (b *LogfmtEncoderBuilder) EncodeError(e ErrorEncoder) *LogfmtEncoderBuilder {
+	b.cfg.EncodeError = e
+	return b
+}
+
+// Build finalizes the configuration and returns a ready-to-use logfmt Encoder.
+func (b *LogfmtEncoderBuilder) Build() Encoder {
+	return buildLogfmtEncoder(b.cfg)
+}
+
+// NewLogfmtEncoder creates a logfmt Encoder from a LogfmtEncoderConfig
+// struct. For a friendlier builder-style API, use Logfmt() instead.
+func NewLogfmtEncoder(cfg LogfmtEncoderConfig) Encoder {
+	return buildLogfmtEncoder(cfg)
+}
+
+func buildLogfmtEncoder(cfg LogfmtEncoderConfig) Encoder {
+	cfg = cfg.WithDefaults()
+	// JSON config for Any values and for arrays or objects that cannot
+	// be flattened. Each encoder instance gets its own jsonEncoder, since
+	// it keeps the target buffer.
+	jsonCfg := JSONEncoderConfig{
+		EncodeTime:     cfg.EncodeTime,
+		EncodeDuration: cfg.EncodeDuration,
+		EncodeError:    cfg.EncodeError,
+	}.WithDefaults()
+	enc := &logfmtEncoder{
+		LogfmtEncoderConfig: cfg,
+		slot:                AllocEncoderSlot(),
+		json:                &jsonEncoder{JSONEncoderConfig: jsonCfg},
+		index:               -1,
+	}
+	enc.pool = &sync.Pool{New: func() any {
+		return enc.Clone()
+	}}
+	return enc
+}
+
+type logfmtEncoder struct {
+	LogfmtEncoderConfig
+	pool *sync.Pool
+	slot int
+	json *jsonEncoder
+
+	// Internal state.
+	buf         *Buffer
+	startBufLen int
+	prefix      []byte // dotted key prefix of the current group, object or array
+	index       int    // index of the next array element; -1 outside arrays
+}
+
+func (f *logfmtEncoder) TypeEncoder(buf *Buffer) TypeEncoder {
+	f.buf = buf
+	f.startBufLen = f.buf.Len()
+	return f
+}
+
+func (f *logfmtEncoder) Clone() Encoder {
+	return &logfmtEncoder{
+		LogfmtEncoderConfig: f.LogfmtEncoderConfig,
+		slot:                f.slot,
+		pool:                f.pool,
+		json:                &jsonEncoder{JSONEncoderConfig: f.json.JSONEncoderConfig},
+		index:               -1,
+	}
+}
+
+func (f *logfmtEncoder) Encode(e Entry) (*Buffer, error) {
+	clone := f.pool.Get().(*logfmtEncoder)
+
+	buf := GetBuffer()
+	err := clone.encode(buf, e)
+
+	clone.buf = nil
+	clone.prefix = clone.prefix[:0]
+	clone.index = -1
+	f.pool.Put(clone)
+
+	if err != nil {
+		buf.Free()
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (f *logfmtEncoder) encode(buf *Buffer, e Entry) error {
+	f.buf = buf
+	f.startBufLen = buf.Len()
+
+	// Level.
+	if !f.DisableFieldLevel {
+		f.addKey(f.FieldKeyLevel)
+		cur := f.buf.Len()
+		f.EncodeLevel(e.Level, f)
+		if f.buf.Len() == cur {
+			f.EncodeTypeString(e.Level.String())
+		}
+	}
+
+	// Time.
+	if !f.DisableFieldTime && !e.Time.IsZero() {
+		f.addKey(f.FieldKeyTime)
+		cur := f.buf.Len()
+		f.EncodeTime(e.Time, f)
+		if f.buf.Len() == cur {
+			f.EncodeTypeInt64(e.Time.UnixNano())
+		}
+	}
+
+	// Logger name.
+	if !f.DisableFieldName && e.LoggerName != "" {
+		f.addKey(f.FieldKeyName)
+		f.EncodeTypeString(e.LoggerName)
+	}
+
+	// Message.
+	if !f.DisableFieldMsg {
+		f.addKey(f.FieldKeyMsg)
+		f.EncodeTypeString(e.Text)
+	}
+
+	// Caller.
+	if !f.DisableFieldCaller && e.CallerPC != 0 {
+		f.addKey(f.FieldKeyCaller)
+		cur := f.buf.Len()
+		f.EncodeCaller(e.CallerPC, f)
+		if f.buf.Len() == cur {
+			f.EncodeTypeString("unknown")
+		}
+	}
+
+	// Skip trailing groups that would produce dangling prefixes.
+	loggerBag := e.LoggerBag
+	ctxBag := e.Bag
+	if len(e.Fields) == 0 {
+		loggerBag = skipTrailingGroups(loggerBag)
+		if !bagHasFields(loggerBag) {
+			ctxBag = skipTrailingGroups(ctxBag)
+		}
+	}
+
+	// Context fields (request-scoped).
+	f.encodeBag(ctxBag, true)
+
+	// Logger's fields (service-scoped).
+	f.encodeBag(loggerBag, flatBagCacheable(f.prefix))
+
+	// Entry's fields.
+	for i := range e.Fields {
+		e.Fields[i].Accept(f)
+	}
+
+	f.buf.AppendByte('\n')
+	return nil
+}
+
+// flatBagCacheable reports whether the logger's Bag may use the Bag cache
+// of an encoder that writes groups as key prefixes — logfmt, GELF and
+// syslog. The cached keys only carry the groups of the logger's Bag, so
+// they are wrong after a context Bag that leaves a group prefix open.
+func flatBagCacheable(ctxPrefix []byte) bool {
+	return len(ctxPrefix) == 0
+}
+
+func (f *logfmtEncoder) encodeBag(bag *Bag, cache bool) {
+	if bag == nil {
+		return
+	}
+
+	// Group node: extend the key prefix, nothing to cache.
+	if bag.group != "" {
+		f.encodeBag(bag.parent, cache)
+		f.pushPrefix(bag.group)
+		return
+	}
+
+	// Field node: use cache. Cached bytes always start with the
+	// separator, which is dropped at the start of a line.
+	atStart := f.empty()
+	if cache {
+		if data := bag.LoadCache(f.slot); data != nil {
+			if atStart {
+				data = data[1:]
+			}
+			f.buf.AppendBytes(data)
+			f.pushBagGroups(bag.parent)
+			return
+		}
+	}
+
+	start := f.buf.Len()
+
+	// Walk parent first to preserve field order (parent before child).
+	f.encodeBag(bag.parent, cache)
+
+	for _, field := range bag.fields {
+		field.Accept(f)
+	}
+
+	if cache && f.slot != 0 && f.buf.Len() > start {
+		n := f.buf.Len() - start
+		var encoded []byte
+		if atStart {
+			encoded = make([]byte, n+1)
+			encoded[0] = ' '
+			copy(encoded[1:], f.buf.Data[start:])
+		} else {
+			encoded = make([]byte, n)
+			copy(encoded, f.buf.Data[start:])
+		}
+		bag.StoreCache(f.slot, encoded)
+	}
+}
+
+// pushBagGroups extends the key prefix with the groups of a Bag chain
+// whose fields were served from the cache.
+func (f *logfmtEncoder) pushBagGroups(bag *Bag) {
+	if bag == nil {
+		return
+	}
+	f.pushBagGroups(bag.parent)
+	if bag.group != "" {
+		f.pushPrefix(bag.group)
+	}
+}
+
+// --- FieldEncoder ---
+
+func (f *logfmtEncoder) EncodeFieldAny(k string, v interface{}) {
+	f.addKey(k)
+	f.EncodeTypeAny(v)
+}
+
+func (f *logfmtEncoder) EncodeFieldBool(k string, v bool) {
+	f.addKey(k)
+	f.EncodeTypeBool(v)
+}
+
+func (f *logfmtEncoder) EncodeFieldInt64(k string, v int64) {
+	f.addKey(k)
+	f.EncodeTypeInt64(v)
+}
+
+func (f *logfmtEncoder) EncodeFieldUint64(k string, v uint64) {
+	f.addKey(k)
+	f.EncodeTypeUint64(v)
+}
+
+func (f *logfmtEncoder) EncodeFieldFloat64(k string, v float64) {
+	f.addKey(k)
+	f.EncodeTypeFloat64(v)
+}
+
+func (f *logfmtEncoder) EncodeFieldDuration(k string, v time.Duration) {
+	f.addKey(k)
+	f.EncodeTypeDuration(v)
+}
+
+func (f *logfmtEncoder) EncodeFieldError(k string, v error) {
+	f.EncodeError(k, v, f)
+}
+
+func (f *logfmtEncoder) EncodeFieldTime(k string, v time.Time) {
+	f.addKey(k)
+	f.EncodeTypeTime(v)
+}
+
+func (f *logfmtEncoder) EncodeFieldString(k string, v string) {
+	f.addKey(k)
+	f.EncodeTypeString(v)
+}
+
+func (f *logfmtEncoder) EncodeFieldBytes(k string, v []byte) {
+	f.addKey(k)
+	f.EncodeTypeBytes(v)
+}
+
+func (f *logfmtEncoder) EncodeFieldStrings(k string, v []string) {
+	saved := f.beginArray(k)
+	for i := range v {
+		f.EncodeTypeString(v[i])
+	}
+	f.endArray(saved)
+}
+
+func (f *logfmtEncoder) EncodeFieldInts64(k string, v []int64) {
+	saved := f.beginArray(k)
+	for i := range v {
+		f.EncodeTypeInt64(v[i])
+	}
+	f.endArray(saved)
+}
+
+func (f *logfmtEncoder) EncodeFieldFloats64(k string, v []float64) {
+	saved := f.beginArray(k)
+	for i := range v {
+		f.EncodeTypeFloat64(v[i])
+	}
+	f.endArray(saved)
+}
+
+func (f *logfmtEncoder) EncodeFieldDurations(k string, v []time.Duration) {
+	saved := f.beginArray(k)
+	for i := range v {
+		f.EncodeTypeDuration(v[i])
+	}
+	f.endArray(saved)
+}
+
+func (f *logfmtEncoder) EncodeFieldArray(k string, v ArrayEncoder) {
+	saved := f.beginArray(k)
+	_ = v.EncodeLogfArray(f)
+	f.endArray(saved)
+}
+
+func (f *logfmtEncoder) EncodeFieldObject(k string, v ObjectEncoder) {
+	if k == "" {
+		_ = v.EncodeLogfObject(f)
+		return
+	}
+	saved := f.beginObject(k)
+	_ = v.EncodeLogfObject(f)
+	f.endObject(saved)
+}
+
+func (f *logfmtEncoder) EncodeFieldGroup(k string, fs []Field) {
+	if k == "" {
+		// Inline group: emit fields at current level.
+		for _, field := range fs {
+			field.Accept(f)
+		}
+		return
+	}
+	saved := len(f.prefix)
+	f.pushPrefix(k)
+	for _, field := range fs {
+		field.Accept(f)
+	}
+	f.prefix = f.prefix[:saved]
+}
+
+// --- TypeEncoder ---
+//
+// Inside an array every value is written under its own indexed key;
+// elsewhere the key has already been written by a FieldEncoder method or
+// by encode.
+
+func (f *logfmtEncoder) EncodeTypeAny(v interface{}) {
+	idx := f.beginValue()
+	tmp := GetBuffer()
+	f.json.TypeEncoder(tmp).EncodeTypeAny(v)
+	appendLogfmtValue(f.buf, tmp.Data)
+	tmp.Free()
+	f.json.buf = nil
+	f.endValue(idx)
+}
+
+func (f *logfmtEncoder) EncodeTypeBool(v bool) {
+	idx := f.beginValue()
+	f.buf.AppendBool(v)
+	f.endValue(idx)
+}
+
+func (f *logfmtEncoder) EncodeTypeInt64(v int64) {
+	idx := f.beginValue()
+	f.buf.AppendInt(v)
+	f.endValue(idx)
+}
+
+func (f *logfmtEncoder) EncodeTypeUint64(v uint64) {
+	idx := f.beginValue()
+	f.buf.AppendUint(v)
+	f.endValue(idx)
+}
+
+func (f *logfmtEncoder) EncodeTypeFloat64(v float64) {
+	idx := f.beginValue()
+	switch {
+	case math.IsNaN(v):
+		f.buf.AppendString("NaN")
+	case math.IsInf(v, 1):
+		f.buf.AppendString("+Inf")
+	case math.IsInf(v, -1):
+		f.buf.AppendString("-Inf")
+	default:
+		f.buf.AppendFloat64(v)
+	}
+	f.endValue(idx)
+}
+
+func (f *logfmtEncoder) EncodeTypeDuration(v time.Duration) {
+	idx := f.beginValue()
+	f.EncodeDuration(v, f)
+	f.endValue(idx)
+}
+
+func (f *logfmtEncoder) EncodeTypeTime(v time.Time) {
+	idx := f.beginValue()
+	f.EncodeTime(v, f)
+	f.endValue(idx)
+}
+
+func (f *logfmtEncoder) EncodeTypeString(v string) {
+	idx := f.beginValue()
+	appendLogfmtValue(f.buf, v)
+	f.endValue(idx)
+}
+
+func (f *logfmtEncoder) EncodeTypeUnsafeBytes(v unsafe.Pointer) {
+	idx := f.beginValue()
+	appendLogfmtValue(f.buf, *(*[]byte)(v))
+	f.endValue(idx)
+}
+
+func (f *logfmtEncoder) EncodeTypeBytes(v []byte) {
+	idx := f.beginValue()
+	base64.StdEncoding.Encode(f.buf.ExtendBytes(base64.StdEncoding.EncodedLen(len(v))), v)
+	if len(v) == 0 {
+		f.buf.AppendString(`""`)
+	}
+	f.endValue(idx)
+}
+
+func (f *logfmtEncoder) EncodeTypeStrings(v []string) {
+	if f.index < 0 {
+		f.encodeJSONValue().EncodeTypeStrings(v)
+		f.flushJSONValue()
+		return
+	}
+	saved := f.beginElementArray()
+	for i := range v {
+		f.EncodeTypeString(v[i])
+	}
+	f.endArray(saved)
+}
+
+func (f *logfmtEncoder) EncodeTypeInts64(v []int64) {
+	if f.index < 0 {
+		f.encodeJSONValue().EncodeTypeInts64(v)
+		f.flushJSONValue()
+		return
+	}
+	saved := f.beginElementArray()
+	for i := range v {
+		f.EncodeTypeInt64(v[i])
+	}
+	f.endArray(saved)
+}
+
+func (f *logfmtEncoder) EncodeTypeFloats64(v []float64) {
+	if f.index < 0 {
+		f.encodeJSONValue().EncodeTypeFloats64(v)
+		f.flushJSONValue()
+		return
+	}
+	saved := f.beginElementArray()
+	for i := range v {
+		f.EncodeTypeFloat64(v[i])
+	}
+	f.endArray(saved)
+}
+
+func (f *logfmtEncoder) EncodeTypeDurations(v []time.Duration) {
+	if f.index < 0 {
+		f.encodeJSONValue().EncodeTypeDurations(v)
+		f.flushJSONValue()
+		return
+	}
+	saved := f.beginElementArray()
+	for i := range v {
+		f.EncodeTypeDuration(v[i])
+	}
+	f.endArray(saved)
+}
+
+func (f *logfmtEncoder) EncodeTypeArray(v ArrayEncoder) {
+	if f.index < 0 {
+		f.encodeJSONValue().EncodeTypeArray(v)
+		f.flushJSONValue()
+		return
+	}
+	saved := f.beginElementArray()
+	_ = v.EncodeLogfArray(f)
+	f.endArray(saved)
+}
+
+func (f *logfmtEncoder) EncodeTypeObject(v ObjectEncoder) {
+	if f.index < 0 {
+		f.encodeJSONValue().EncodeTypeObject(v)
+		f.flushJSONValue()
+		return
+	}
+	saved := f.beginElementObject()
+	_ = v.EncodeLogfObject(f)
+	f.endObject(saved)
+}
+
+// --- flattening ---
+
+// logfmtScope is the state saved when entering an array or object.
+type logfmtScope struct {
+	prefix int // length of the prefix to restore
+	index  int // index to restore; the next element of an enclosing array
+	start  int // buffer length on entry, to detect empty containers
+}
+
+// beginArray starts the array field k: its elements are written as
+// prefix.k.0, prefix.k.1 and so on.
+func (f *logfmtEncoder) beginArray(k string) logfmtScope {
+	s := logfmtScope{prefix: len(f.prefix), index: f.index, start: f.buf.Len()}
+	f.pushPrefix(k)
+	f.index = 0
+	return s
+}
+
+// beginElementArray starts an array that is itself an element of the
+// current array.
+func (f *logfmtEncoder) beginElementArray() logfmtScope {
+	s := logfmtScope{prefix: len(f.prefix), index: f.index + 1, start: f.buf.Len()}
+	f.prefix = strconv.AppendInt(f.prefix, int64(f.index), 10)
+	f.prefix = append(f.prefix, '.')
+	f.index = 0
+	return s
+}
+
+func (f *logfmtEncoder) endArray(s logfmtScope) {
+	if f.buf.Len() == s.start {
+		f.addPrefixKey()
+		f.buf.AppendString("[]")
+	}
+	f.prefix = f.prefix[:s.prefix]
+	f.index = s.index
+}
+
+// beginObject starts the object field k: its fields are written as
+// prefix.k.field.
+func (f *logfmtEncoder) beginObject(k string) logfmtScope {
+	s := logfmtScope{prefix: len(f.prefix), index: f.index, start: f.buf.Len()}
+	f.pushPrefix(k)
+	f.index = -1
+	return s
+}
+
+// beginElementObject starts an object that is an element of the current
+// array.
+func (f *logfmtEncoder) beginElementObject() logfmtScope {
+	s := logfmtScope{prefix: len(f.prefix), index: f.index + 1, start: f.buf.Len()}
+	f.prefix = strconv.AppendInt(f.prefix, int64(f.index), 10)
+	f.prefix = append(f.prefix, '.')
+	f.index = -1
+	return s
+}
+
+func (f *logfmtEncoder) endObject(s logfmtScope) {
+	if f.buf.Len() == s.start {
+		f.addPrefixKey()
+		f.buf.AppendString("{}")
+	}
+	f.prefix = f.prefix[:s.prefix]
+	f.index = s.index
+}
+
+// beginValue writes the indexed key of an array element and returns the
+// index to pass to endValue. Outside arrays it writes nothing.
+func (f *logfmtEncoder) beginValue() int {
+	idx := f.index
+	if idx >= 0 {
+		f.appendSeparator()
+		f.buf.AppendBytes(f.prefix)
+		f.buf.AppendInt(int64(idx))
+		f.buf.AppendByte('=')
+		// Encoders such as EncodeTime call back into the TypeEncoder
+		// for the value itself.
+		f.index = -1
+	}
+	return idx
+}
+
+func (f *logfmtEncoder) endValue(idx int) {
+	if idx >= 0 {
+		f.index = idx + 1
+	}
+}
+
+// encodeJSONValue returns a JSON TypeEncoder for a container whose key is
+// already written and so cannot be flattened. Call flushJSONValue after
+// encoding to append the result as a single value.
+func (f *logfmtEncoder) encodeJSONValue() TypeEncoder {
+	return f.json.TypeEncoder(GetBuffer())
+}
+
+func (f *logfmtEncoder) flushJSONValue() {
+	tmp := f.json.buf
+	f.json.buf = nil
+	appendLogfmtValue(f.buf, tmp.Data)
+	tmp.Free()
+}
+
+// --- helpers ---
+
+func (f *logfmtEncoder) empty() bool {
+	return f.buf.Len() == f.startBufLen
+}
+
+func (f *logfmtEncoder) appendSeparator() {
+	if !f.empty() {
+		f.buf.AppendByte(' ')
+	}
+}
+
+func (f *logfmtEncoder) addKey(k string) {
+	f.appendSeparator()
+	f.buf.AppendBytes(f.prefix)
+	appendLogfmtKey(f.buf, k)
+	f.buf.AppendByte('=')
+}
+
+// addPrefixKey writes the current prefix, without its trailing dot, as
+// a key. Used for empty containers.
+func (f *logfmtEncoder) addPrefixKey() {
+	f.appendSeparator()
+	f.buf.AppendBytes(f.prefix[:len(f.prefix)-1])
+	f.buf.AppendByte('=')
+}
+
+func (f *logfmtEncoder) pushPrefix(k string) {
+	start := len(f.prefix)
+	f.prefix = append(f.prefix, k...)
+	for i := start; i < len(f.prefix); i++ {
+		if !isLogfmtKeyByte(f.prefix[i]) {
+			f.prefix[i] = '_'
+		}
+	}
+	f.prefix = append(f.prefix, '.')
+}
+
+// appendLogfmtKey appends k, replacing bytes that are not allowed in a
+// logfmt key with '_'.
+func appendLogfmtKey(buf *Buffer, k string) {
+	for i := 0; i < len(k); i++ {
+		if !isLogfmtKeyByte(k[i]) {
+			start := buf.Len()
+			buf.AppendString(k)
+			for j := start + i; j < buf.Len(); j++ {
+				if !isLogfmtKeyByte(buf.Data[j]) {
+					buf.Data[j] = '_'
+				}
+			}
+			return
+		}
+	}
+	if k == "" {
+		buf.AppendByte('_')
+		return
+	}
+	buf.AppendString(k)
+}
+
+func isLogfmtKeyByte(c byte) bool {
+	return c > ' ' && c != '=' && c != '"' && c != 0x7f
+}
+
+// appendLogfmtValue appends v, quoted and JSON-escaped if logfmt requires.
+func appendLogfmtValue[S []byte | string](buf *Buffer, v S) {
+	if !logfmtNeedsQuote(v) {
+		appendBuf(buf, v)
+		return
+	}
+	buf.AppendByte('"')
+	_ = EscapeString(buf, v)
+	buf.AppendByte('"')
+}
+
+func logfmtNeedsQuote[S []byte | string](v S) bool {
+	if len(v) == 0 {
+		return true
+	}
+	ascii := true
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c <= ' ' || c == '=' || c == '"' || c == '\\' || c == 0x7f {
+			return true
+		}
+		if c >= utf8.RuneSelf {
+			ascii = false
+		}
+	}
+	if ascii {
+		return false
+	}
+	switch s := any(v).(type) {
+	case string:
+		return !utf8.ValidString(s)
+	case []byte:
+		return !utf8.Valid(s)
+	}
+	return false
+}
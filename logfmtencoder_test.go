@@ -0,0 +1,229 @@
+package logf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nestedArrayEncoder struct{}
+
+func (nestedArrayEncoder) EncodeLogfArray(e TypeEncoder) error {
+	e.EncodeTypeString("a")
+	e.EncodeTypeInts64([]int64{1, 2})
+	e.EncodeTypeObject(testObjectEncoder{})
+	e.EncodeTypeStrings(nil)
+	e.EncodeTypeDuration(time.Second)
+	return nil
+}
+
+type emptyObjectEncoder struct{}
+
+func (emptyObjectEncoder) EncodeLogfObject(FieldEncoder) error { return nil }
+
+func TestLogfmtEncoder(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	testCases := []encoderTestCase{
+		{
+			"Header",
+			Entry{Level: LevelInfo, Time: ts, LoggerName: "http.server", Text: "request served"},
+			`level=info ts=2024-05-01T10:00:00Z logger=http.server msg="request served"` + "\n",
+		},
+		{
+			"EmptyMessage",
+			Entry{Level: LevelDebug},
+			`level=debug msg=""` + "\n",
+		},
+		{
+			"Scalars",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Bool("bool", true), Int("int", -42), Uint64("uint", 42), Float64("float", 4.2),
+				Duration("dur", 1500*time.Millisecond), Time("time", ts),
+			}},
+			`level=info msg=m bool=true int=-42 uint=42 float=4.2 dur=1.5s time=2024-05-01T10:00:00Z` + "\n",
+		},
+		{
+			"Quoting",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				String("plain", "abc"),
+				String("empty", ""),
+				String("space", "a b"),
+				String("eq", "a=b"),
+				String("quote", `say "hi"`),
+				String("newline", "a\nb"),
+				String("esc", "\x1b[31m"),
+				String("unicode", "привет"),
+				String("invalid", "a\xffb"),
+				Bytes("bytes", []byte("hi")),
+			}},
+			`level=info msg=m plain=abc empty="" space="a b" eq="a=b" quote="say \"hi\"" newline="a\nb"` +
+				` esc="\u001b[31m" unicode=привет invalid="a\ufffdb" bytes=aGk=` + "\n",
+		},
+		{
+			"Keys",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				String("a b", "1"), String("a=b", "2"), String(`a"b`, "3"), String("", "4"),
+			}},
+			`level=info msg=m a_b=1 a_b=2 a_b=3 _=4` + "\n",
+		},
+		{
+			"Groups",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Group("http", String("method", "GET"), Group("request", String("path", "/api"))),
+				Group("", String("inline", "yes")),
+				Group("empty"),
+			}},
+			`level=info msg=m http.method=GET http.request.path=/api inline=yes` + "\n",
+		},
+		{
+			"Arrays",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Strings("tags", []string{"a", "b c"}),
+				Ints64("ids", []int64{1, 2}),
+				Floats64("ratios", []float64{0.5}),
+				Durations("lat", []time.Duration{time.Millisecond}),
+				Strings("none", nil),
+				Array("custom", testArrayEncoder{}),
+			}},
+			`level=info msg=m tags.0=a tags.1="b c" ids.0=1 ids.1=2 ratios.0=0.5 lat.0=1ms none=[] custom.0=42` + "\n",
+		},
+		{
+			"NestedArrays",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Array("x", nestedArrayEncoder{}),
+			}},
+			`level=info msg=m x.0=a x.1.0=1 x.1.1=2 x.2.username=username x.2.code=42 x.3=[] x.4=1s` + "\n",
+		},
+		{
+			"Objects",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Object("user", testObjectEncoder{}),
+				Object("", testObjectEncoder{}),
+				Object("nothing", emptyObjectEncoder{}),
+				Group("g", Object("user", testObjectEncoder{})),
+			}},
+			`level=info msg=m user.username=username user.code=42 username=username code=42 nothing={} g.user.username=username g.user.code=42` + "\n",
+		},
+		{
+			"Any",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Any("map", map[string]int{"a": 1}),
+				Any("nil", nil),
+			}},
+			`level=info msg=m map="{\"a\":1}" nil=null` + "\n",
+		},
+		{
+			"Error",
+			Entry{Level: LevelError, Text: "m", Fields: []Field{
+				NamedError("err", errors.New("boom")),
+				Group("db", Error(errors.New("timeout"))),
+			}},
+			`level=error msg=m err=boom db.error=timeout` + "\n",
+		},
+		{
+			"Bags",
+			Entry{
+				Level:     LevelInfo,
+				Text:      "m",
+				Bag:       NewBag(String("request_id", "r1")),
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http").With(String("method", "GET")),
+				Fields:    []Field{Int("status", 200)},
+			},
+			`level=info msg=m request_id=r1 service=api http.method=GET http.status=200` + "\n",
+		},
+		{
+			"BagTrailingGroup",
+			Entry{
+				Level:     LevelInfo,
+				Text:      "m",
+				LoggerBag: NewBag(String("host", "h")).WithGroup("request"),
+			},
+			`level=info msg=m host=h` + "\n",
+		},
+	}
+
+	enc := Logfmt().Build()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden, b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestLogfmtEncoderCaller(t *testing.T) {
+	enc := Logfmt().Build()
+	b, err := enc.Encode(Entry{Level: LevelInfo, CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	assert.Regexp(t, `^level=info msg="" caller=\S+logfmtencoder_test.go:\d+\n$`, b.String())
+	b.Free()
+}
+
+func TestLogfmtEncoderBagCache(t *testing.T) {
+	enc := withCacheSlot(Logfmt().DisableLevel().DisableMsg().Build())
+	logger := NewBag(String("service", "api"))
+
+	// The cached bytes of the logger Bag must fit at the start of the
+	// line as well as after other fields.
+	cases := []struct {
+		entry Entry
+		want  string
+	}{
+		{Entry{LoggerBag: logger}, "service=api\n"},
+		{Entry{Bag: NewBag(String("request_id", "r1")), LoggerBag: logger}, "request_id=r1 service=api\n"},
+		{Entry{LoggerBag: logger}, "service=api\n"},
+		// A context group prefixes the logger fields, bypassing the cache.
+		{Entry{Bag: NewBag().WithGroup("req"), LoggerBag: logger, Fields: []Field{Int("n", 1)}}, "req.service=api req.n=1\n"},
+		{Entry{LoggerBag: logger}, "service=api\n"},
+	}
+	for _, c := range cases {
+		b, err := enc.Encode(c.entry)
+		require.NoError(t, err)
+		assert.Equal(t, c.want, b.String())
+		b.Free()
+	}
+}
+
+func TestLogfmtEncoderBuilder(t *testing.T) {
+	enc := Logfmt().
+		LevelKey("lvl").TimeKey("time").MsgKey("message").NameKey("name").CallerKey("src").
+		EncodeLevel(UpperCaseLevelEncoder).
+		EncodeTime(UnixNanoTimeEncoder).
+		EncodeDuration(NanoDurationEncoder).
+		EncodeCaller(func(uintptr, TypeEncoder) {}).
+		EncodeError(func(k string, err error, e FieldEncoder) { e.EncodeFieldString(k, "E:"+err.Error()) }).
+		Build()
+
+	b, err := enc.Encode(Entry{
+		Level: LevelWarn, Time: time.Unix(0, 42), LoggerName: "n", Text: "hi", CallerPC: CallerPC(0),
+		Fields: []Field{Duration("d", time.Second), NamedError("e", errors.New("x"))},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "lvl=WARN time=42 name=n message=hi src=unknown d=1000000000 e=E:x\n", b.String())
+	b.Free()
+
+	enc = Logfmt().DisableLevel().DisableTime().DisableName().DisableMsg().DisableCaller().Build()
+	b, err = enc.Encode(Entry{Time: time.Now(), LoggerName: "n", Text: "hi", CallerPC: CallerPC(0), Fields: []Field{Int("a", 1)}})
+	require.NoError(t, err)
+	assert.Equal(t, "a=1\n", b.String())
+	b.Free()
+}
+
+func TestNewLogfmtEncoder(t *testing.T) {
+	enc := NewLogfmtEncoder(LogfmtEncoderConfig{FieldKeyMsg: "message", DisableFieldLevel: true})
+	b, err := enc.Encode(Entry{Text: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "message=hi\n", b.String())
+	b.Free()
+
+	clone := enc.Clone()
+	b, err = clone.Encode(Entry{Text: "hi", Fields: []Field{Any("v", []int{1})}})
+	require.NoError(t, err)
+	assert.Equal(t, "message=hi v.0=1\n", b.String())
+	b.Free()
+}
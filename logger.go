@@ -11,8 +11,10 @@ import (
 // implementation — SyncHandler, Router, ContextHandler, or your own.
 // For a friendlier builder-style API, use NewLogger() instead.
 func New(w Handler) *Logger {
+	ne, _ := w.(NameEnabler)
 	return &Logger{
 		w:         w,
+		ne:        ne,
 		addCaller: true,
 	}
 }
@@ -30,7 +32,8 @@ func DisabledLogger() *Logger {
 // With and WithGroup. Loggers are immutable — every With/WithName/WithGroup
 // call returns a new Logger, so they are safe to share across goroutines.
 type Logger struct {
-	w Handler
+	w  Handler
+	ne NameEnabler // w, if it implements NameEnabler
 
 	bag        *Bag
 	name       string
@@ -45,7 +48,7 @@ func (l *Logger) Enabled(ctx context.Context, lvl Level) bool {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	return l.w.Enabled(ctx, lvl)
+	return l.enabled(ctx, lvl)
 }
 
 // LogFunc is a logging function with a pre-bound level, used by AtLevel.
@@ -59,7 +62,7 @@ func (l *Logger) AtLevel(ctx context.Context, lvl Level, fn func(LogFunc)) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	if !l.w.Enabled(ctx, lvl) {
+	if !l.enabled(ctx, lvl) {
 		return
 	}
 
@@ -152,7 +155,7 @@ func (l *Logger) Debug(ctx context.Context, text string, fs ...Field) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	if !l.w.Enabled(ctx, LevelDebug) {
+	if !l.enabled(ctx, LevelDebug) {
 		return
 	}
 
@@ -166,7 +169,7 @@ func (l *Logger) Info(ctx context.Context, text string, fs ...Field) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	if !l.w.Enabled(ctx, LevelInfo) {
+	if !l.enabled(ctx, LevelInfo) {
 		return
 	}
 
@@ -180,7 +183,7 @@ func (l *Logger) Warn(ctx context.Context, text string, fs ...Field) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	if !l.w.Enabled(ctx, LevelWarn) {
+	if !l.enabled(ctx, LevelWarn) {
 		return
 	}
 
@@ -194,7 +197,7 @@ func (l *Logger) Error(ctx context.Context, text string, fs ...Field) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	if !l.w.Enabled(ctx, LevelError) {
+	if !l.enabled(ctx, LevelError) {
 		return
 	}
 
@@ -208,7 +211,7 @@ func (l *Logger) Log(ctx context.Context, lvl Level, text string, fs ...Field) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	if !l.w.Enabled(ctx, lvl) {
+	if !l.enabled(ctx, lvl) {
 		return
 	}
 
@@ -219,7 +222,7 @@ func (l *Logger) Log(ctx context.Context, lvl Level, text string, fs ...Field) {
 // Equivalent to Debug(context.Background(), text, fs...).
 func (l *Logger) Debugx(text string, fs ...Field) {
 	ctx := context.Background()
-	if !l.w.Enabled(ctx, LevelDebug) {
+	if !l.enabled(ctx, LevelDebug) {
 		return
 	}
 
@@ -230,7 +233,7 @@ func (l *Logger) Debugx(text string, fs ...Field) {
 // Equivalent to Info(context.Background(), text, fs...).
 func (l *Logger) Infox(text string, fs ...Field) {
 	ctx := context.Background()
-	if !l.w.Enabled(ctx, LevelInfo) {
+	if !l.enabled(ctx, LevelInfo) {
 		return
 	}
 
@@ -241,7 +244,7 @@ func (l *Logger) Infox(text string, fs ...Field) {
 // Equivalent to Warn(context.Background(), text, fs...).
 func (l *Logger) Warnx(text string, fs ...Field) {
 	ctx := context.Background()
-	if !l.w.Enabled(ctx, LevelWarn) {
+	if !l.enabled(ctx, LevelWarn) {
 		return
 	}
 
@@ -252,13 +255,21 @@ func (l *Logger) Warnx(text string, fs ...Field) {
 // Equivalent to Error(context.Background(), text, fs...).
 func (l *Logger) Errorx(text string, fs ...Field) {
 	ctx := context.Background()
-	if !l.w.Enabled(ctx, LevelError) {
+	if !l.enabled(ctx, LevelError) {
 		return
 	}
 
 	l.write(ctx, 1, LevelError, text, fs)
 }
 
+// enabled checks the level with the logger name if the Handler can use it.
+func (l *Logger) enabled(ctx context.Context, lvl Level) bool {
+	if l.ne != nil {
+		return l.ne.EnabledName(ctx, l.name, lvl)
+	}
+	return l.w.Enabled(ctx, lvl)
+}
+
 func (l *Logger) write(ctx context.Context, extraSkip int, lv Level, text string, fs []Field) {
 	e := Entry{
 		LoggerBag:  l.bag,
@@ -278,6 +289,7 @@ func (l *Logger) write(ctx context.Context, extraSkip int, lv Level, text string
 func (l *Logger) clone() *Logger {
 	return &Logger{
 		w:          l.w,
+		ne:         l.ne,
 		bag:        l.bag,
 		name:       l.name,
 		addCaller:  l.addCaller,
@@ -290,7 +302,7 @@ func (l *Logger) clone() *Logger {
 // packages like logfc can log through an existing Logger without allocating
 // a new one on every call.
 func LogDepth(l *Logger, ctx context.Context, depth int, lvl Level, text string, fs ...Field) {
-	if !l.w.Enabled(ctx, lvl) {
+	if !l.enabled(ctx, lvl) {
 		return
 	}
 
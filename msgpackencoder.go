@@ -0,0 +1,779 @@
+package logf
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// MsgPackEncoderConfig controls how the MessagePack encoder formats log
+// entries. For a friendlier builder-style API, use MsgPack() instead.
+type MsgPackEncoderConfig struct {
+	FieldKeyMsg    string
+	FieldKeyTime   string
+	FieldKeyLevel  string
+	FieldKeyName   string
+	FieldKeyCaller string
+
+	DisableFieldMsg    bool
+	DisableFieldTime   bool
+	DisableFieldLevel  bool
+	DisableFieldName   bool
+	DisableFieldCaller bool
+
+	// Forward wraps each record as a Fluent Forward entry: an array of
+	// the entry time and the record, which then has no time field.
+	Forward bool
+
+	// EncodeTime formats times. Nil, the default, writes them natively
+	// with the Fluent EventTime extension.
+	EncodeTime     TimeEncoder
+	EncodeDuration DurationEncoder
+	EncodeError    ErrorEncoder
+	EncodeLevel    LevelEncoder
+	EncodeCaller   CallerEncoder
+}
+
+// WithDefaults returns a copy of the config with all zero-value fields
+// replaced by sensible defaults (the JSON field keys, string durations,
+// short caller format, etc.). EncodeTime stays nil for native times.
+func (c MsgPackEncoderConfig) WithDefaults() MsgPackEncoderConfig {
+	if c.FieldKeyMsg == "" {
+		c.FieldKeyMsg = DefaultFieldKeyMsg
+	}
+	if c.FieldKeyTime == "" {
+		c.FieldKeyTime = DefaultFieldKeyTime
+	}
+	if c.FieldKeyLevel == "" {
+		c.FieldKeyLevel = DefaultFieldKeyLevel
+	}
+	if c.FieldKeyName == "" {
+		c.FieldKeyName = DefaultFieldKeyName
+	}
+	if c.FieldKeyCaller == "" {
+		c.FieldKeyCaller = DefaultFieldKeyCaller
+	}
+
+	if c.EncodeDuration == nil {
+		c.EncodeDuration = StringDurationEncoder
+	}
+	if c.EncodeError == nil {
+		c.EncodeError = DefaultErrorEncoder
+	}
+	if c.EncodeLevel == nil {
+		c.EncodeLevel = DefaultLevelEncoder
+	}
+	if c.EncodeCaller == nil {
+		c.EncodeCaller = ShortCallerEncoder
+	}
+	return c
+}
+
+// MsgPackEncoderBuilder configures and builds a MessagePack Encoder using
+// a clean builder-style API. Create one with MsgPack(), chain methods to
+// customize, then call Build() or pass directly to
+// LoggerBuilder.EncoderFrom().
+type MsgPackEncoderBuilder struct {
+	cfg MsgPackEncoderConfig
+}
+
+// MsgPack returns a new MsgPackEncoderBuilder with default settings. The
+// encoder writes each entry as a MessagePack map with the same layout as
+// the JSON encoder, using native types: integers in their shortest form,
+// float64, binary for Bytes, arrays, nested maps for groups and objects,
+// and times as the Fluent EventTime extension (type 0, seconds and
+// nanoseconds). Any values are written as JSON strings.
+//
+// Use Forward() to produce Fluent Forward entries for NewForwardWriter:
+//
+//	enc := logf.MsgPack().Forward().Build()
+func MsgPack() *MsgPackEncoderBuilder {
+	return &MsgPackEncoderBuilder{}
+}
+
+// Forward wraps each record as a Fluent Forward entry [time, record],
+// the form NewForwardWriter sends. The record then has no time field.
+func (b *MsgPackEncoderBuilder) Forward() *MsgPackEncoderBuilder {
+	b.cfg.Forward = true
+	return b
+}
+
+// TimeKey sets the key for the timestamp field (default "ts").
+func (b *MsgPackEncoderBuilder) TimeKey(k string) *MsgPackEncoderBuilder {
+	b.cfg.FieldKeyTime = k
+	return b
+}
+
+// LevelKey sets the key for the severity level field (default "level").
+func (b *MsgPackEncoderBuilder) LevelKey(k string) *MsgPackEncoderBuilder {
+	b.cfg.FieldKeyLevel = k
+	return b
+}
+
+// MsgKey sets the key for the log message field (default "msg").
+func (b *MsgPackEncoderBuilder) MsgKey(k string) *MsgPackEncoderBuilder {
+	b.cfg.FieldKeyMsg = k
+	return b
+}
+
+// NameKey sets the key for the logger name field (default "logger").
+func (b *MsgPackEncoderBuilder) NameKey(k string) *MsgPackEncoderBuilder {
+	b.cfg.FieldKeyName = k
+	return b
+}
+
+// CallerKey sets the key for the caller location field (default "caller").
+func (b *MsgPackEncoderBuilder) CallerKey(k string) *MsgPackEncoderBuilder {
+	b.cfg.FieldKeyCaller = k
+	return b
+}
+
+// DisableTime omits the timestamp field.
+func (b *MsgPackEncoderBuilder) DisableTime() *MsgPackEncoderBuilder {
+	b.cfg.DisableFieldTime = true
+	return b
+}
+
+// DisableLevel omits the severity level field.
+func (b *MsgPackEncoderBuilder) DisableLevel() *MsgPackEncoderBuilder {
+	b.cfg.DisableFieldLevel = true
+	return b
+}
+
+// DisableMsg omits the message text field.
+func (b *MsgPackEncoderBuilder) DisableMsg() *MsgPackEncoderBuilder {
+	b.cfg.DisableFieldMsg = true
+	return b
+}
+
+// DisableName omits the logger name field.
+func (b *MsgPackEncoderBuilder) DisableName() *MsgPackEncoderBuilder {
+	b.cfg.DisableFieldName = true
+	return b
+}
+
+// DisableCaller omits the caller location field.
+func (b *MsgPackEncoderBuilder) DisableCaller() *MsgPackEncoderBuilder {
+	b.cfg.DisableFieldCaller = true
+	return b
+}
+
+// EncodeTime sets a custom TimeEncoder for formatting times (default
+// native EventTime).
+func (b *MsgPackEncoderBuilder) EncodeTime(e TimeEncoder) *MsgPackEncoderBuilder {
+	b.cfg.EncodeTime = e
+	return b
+}
+
+// EncodeDuration sets a custom DurationEncoder for formatting durations (default string representation).
+func (b *MsgPackEncoderBuilder) EncodeDuration(e DurationEncoder) *MsgPackEncoderBuilder {
+	b.cfg.EncodeDuration = e
+	return b
+}
+
+// EncodeLevel sets a custom LevelEncoder for formatting severity levels.
+func (b *MsgPackEncoderBuilder) EncodeLevel(e LevelEncoder) *MsgPackEncoderBuilder {
+	b.cfg.EncodeLevel = e
+	return b
+}
+
+// EncodeCaller sets a custom CallerEncoder for formatting caller locations (default short format).
+func (b *MsgPackEncoderBuilder) EncodeCaller(e CallerEncoder) *MsgPackEncoderBuilder {
+	b.cfg.EncodeCaller = e
+	return b
+}
+
+// EncodeError sets a custom ErrorEncoder for formatting error values.
+func (b *MsgPackEncoderBuilder) EncodeError(e ErrorEncoder) *MsgPackEncoderBuilder {
+	b.cfg.EncodeError = e
+	return b
+}
+
+// Build finalizes the configuration and returns a ready-to-use
+// MessagePack Encoder.
+func (b *MsgPackEncoderBuilder) Build() Encoder {
+	return buildMsgPackEncoder(b.cfg)
+}
+
+// NewMsgPackEncoder creates a MessagePack Encoder from a
+// MsgPackEncoderConfig struct. For a friendlier builder-style API, use
+// MsgPack() instead.
+func NewMsgPackEncoder(cfg MsgPackEncoderConfig) Encoder {
+	return buildMsgPackEncoder(cfg)
+}
+
+func buildMsgPackEncoder(cfg MsgPackEncoderConfig) Encoder {
+	cfg = cfg.WithDefaults()
+	jsonEncodeTime := cfg.EncodeTime
+	if jsonEncodeTime == nil {
+		jsonEncodeTime = RFC3339NanoTimeEncoder
+	}
+	enc := &msgpackEncoder{
+		MsgPackEncoderConfig: cfg,
+		slot:                 AllocEncoderSlot(),
+		json: &jsonEncoder{JSONEncoderConfig: JSONEncoderConfig{
+			EncodeTime:     jsonEncodeTime,
+			EncodeDuration: cfg.EncodeDuration,
+			EncodeError:    cfg.EncodeError,
+		}.WithDefaults()},
+	}
+	enc.pool = &sync.Pool{New: func() any {
+		return enc.Clone()
+	}}
+	return enc
+}
+
+// msgpackContainer is an open map or array whose size is written when it
+// is closed.
+type msgpackContainer struct {
+	start int  // offset of the placeholder header
+	count int  // entries so far: keys of a map, elements of an array
+	array bool // array or map
+}
+
+type msgpackEncoder struct {
+	MsgPackEncoderConfig
+	pool *sync.Pool
+	slot int
+	json *jsonEncoder // Any values
+
+	// Internal state.
+	buf   *Buffer
+	stack []msgpackContainer
+}
+
+func (f *msgpackEncoder) Clone() Encoder {
+	return &msgpackEncoder{
+		MsgPackEncoderConfig: f.MsgPackEncoderConfig,
+		pool:                 f.pool,
+		slot:                 f.slot,
+		json:                 &jsonEncoder{JSONEncoderConfig: f.json.JSONEncoderConfig},
+	}
+}
+
+func (f *msgpackEncoder) Encode(e Entry) (*Buffer, error) {
+	clone := f.pool.Get().(*msgpackEncoder)
+
+	buf := GetBuffer()
+	err := clone.encode(buf, e)
+
+	clone.buf = nil
+	clone.stack = clone.stack[:0]
+	f.pool.Put(clone)
+
+	if err != nil {
+		buf.Free()
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (f *msgpackEncoder) encode(buf *Buffer, e Entry) error {
+	f.buf = buf
+	f.stack = f.stack[:0]
+
+	if f.Forward {
+		t := e.Time
+		if t.IsZero() {
+			t = time.Now()
+		}
+		buf.AppendByte(0x92)
+		appendMsgpackEventTime(buf, t)
+	}
+	f.openContainer(false)
+
+	// Level.
+	if !f.DisableFieldLevel {
+		f.addKey(f.FieldKeyLevel)
+		cur := buf.Len()
+		f.EncodeLevel(e.Level, f)
+		if buf.Len() == cur {
+			f.EncodeTypeString(e.Level.String())
+		}
+	}
+
+	// Time.
+	if !f.Forward && !f.DisableFieldTime && !e.Time.IsZero() {
+		f.addKey(f.FieldKeyTime)
+		f.EncodeTypeTime(e.Time)
+	}
+
+	// Logger name.
+	if !f.DisableFieldName && e.LoggerName != "" {
+		f.addKey(f.FieldKeyName)
+		f.EncodeTypeString(e.LoggerName)
+	}
+
+	// Message.
+	if !f.DisableFieldMsg {
+		f.addKey(f.FieldKeyMsg)
+		f.EncodeTypeString(e.Text)
+	}
+
+	// Caller.
+	if !f.DisableFieldCaller && e.CallerPC != 0 {
+		f.addKey(f.FieldKeyCaller)
+		cur := buf.Len()
+		f.EncodeCaller(e.CallerPC, f)
+		if buf.Len() == cur {
+			f.EncodeTypeString("unknown")
+		}
+	}
+
+	// Skip trailing groups that would produce empty maps.
+	loggerBag := e.LoggerBag
+	ctxBag := e.Bag
+	if len(e.Fields) == 0 {
+		loggerBag = skipTrailingGroups(loggerBag)
+		if !bagHasFields(loggerBag) {
+			ctxBag = skipTrailingGroups(ctxBag)
+		}
+	}
+
+	// Context fields (request-scoped).
+	f.encodeBag(ctxBag)
+
+	// Logger's fields (service-scoped).
+	f.encodeBag(loggerBag)
+
+	// Entry's fields.
+	for i := range e.Fields {
+		e.Fields[i].Accept(f)
+	}
+
+	// Close the maps of Bag groups and the record.
+	for len(f.stack) != 0 {
+		f.closeContainer()
+	}
+	return nil
+}
+
+// encodeBag writes the fields of a Bag chain. Group nodes open maps that
+// stay open until the end of the record, so a cache entry holds, before
+// the encoded bytes, the number of entries added to the map the chain
+// started in and the offsets and entry counts of the maps it left open.
+func (f *msgpackEncoder) encodeBag(bag *Bag) {
+	if bag == nil {
+		return
+	}
+
+	// Group node: open a nested map, no caching needed.
+	if bag.group != "" {
+		f.encodeBag(bag.parent)
+		f.addKey(bag.group)
+		f.openContainer(false)
+		return
+	}
+
+	base := len(f.stack) - 1
+
+	// Field node: use cache.
+	if data := bag.LoadCache(f.slot); data != nil {
+		added, n := binary.Uvarint(data)
+		data = data[n:]
+		open, n := binary.Uvarint(data)
+		data = data[n:]
+		offsets := data
+		for i := uint64(0); i < open*2; i++ {
+			_, n = binary.Uvarint(data)
+			data = data[n:]
+		}
+		start := f.buf.Len()
+		f.buf.AppendBytes(data)
+		f.stack[base].count += int(added)
+		for i := uint64(0); i < open; i++ {
+			off, n := binary.Uvarint(offsets)
+			offsets = offsets[n:]
+			count, n := binary.Uvarint(offsets)
+			offsets = offsets[n:]
+			f.stack = append(f.stack, msgpackContainer{start: start + int(off), count: int(count)})
+		}
+		return
+	}
+
+	start := f.buf.Len()
+	count := f.stack[base].count
+
+	// Walk parent first to preserve field order (parent before child).
+	f.encodeBag(bag.parent)
+
+	for _, field := range bag.fields {
+		field.Accept(f)
+	}
+
+	if f.slot != 0 {
+		var meta [2 * binary.MaxVarintLen64]byte
+		encoded := binary.AppendUvarint(meta[:0], uint64(f.stack[base].count-count))
+		encoded = binary.AppendUvarint(encoded, uint64(len(f.stack)-base-1))
+		for _, c := range f.stack[base+1:] {
+			encoded = binary.AppendUvarint(encoded, uint64(c.start-start))
+			encoded = binary.AppendUvarint(encoded, uint64(c.count))
+		}
+		encoded = append(encoded[:len(encoded):len(encoded)], f.buf.Data[start:]...)
+		bag.StoreCache(f.slot, encoded)
+	}
+}
+
+// --- FieldEncoder ---
+
+func (f *msgpackEncoder) EncodeFieldAny(k string, v interface{}) {
+	f.addKey(k)
+	f.EncodeTypeAny(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldBool(k string, v bool) {
+	f.addKey(k)
+	f.EncodeTypeBool(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldInt64(k string, v int64) {
+	f.addKey(k)
+	f.EncodeTypeInt64(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldUint64(k string, v uint64) {
+	f.addKey(k)
+	f.EncodeTypeUint64(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldFloat64(k string, v float64) {
+	f.addKey(k)
+	f.EncodeTypeFloat64(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldDuration(k string, v time.Duration) {
+	f.addKey(k)
+	f.EncodeTypeDuration(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldError(k string, v error) {
+	f.EncodeError(k, v, f)
+}
+
+func (f *msgpackEncoder) EncodeFieldTime(k string, v time.Time) {
+	f.addKey(k)
+	f.EncodeTypeTime(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldString(k string, v string) {
+	f.addKey(k)
+	f.EncodeTypeString(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldBytes(k string, v []byte) {
+	f.addKey(k)
+	f.EncodeTypeBytes(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldStrings(k string, v []string) {
+	f.addKey(k)
+	f.EncodeTypeStrings(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldInts64(k string, v []int64) {
+	f.addKey(k)
+	f.EncodeTypeInts64(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldFloats64(k string, v []float64) {
+	f.addKey(k)
+	f.EncodeTypeFloats64(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldDurations(k string, v []time.Duration) {
+	f.addKey(k)
+	f.EncodeTypeDurations(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldArray(k string, v ArrayEncoder) {
+	f.addKey(k)
+	f.EncodeTypeArray(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldObject(k string, v ObjectEncoder) {
+	f.addKey(k)
+	f.EncodeTypeObject(v)
+}
+
+func (f *msgpackEncoder) EncodeFieldGroup(k string, fs []Field) {
+	if k == "" {
+		// Inline group: emit fields at current level.
+		for _, field := range fs {
+			field.Accept(f)
+		}
+		return
+	}
+	f.addKey(k)
+	f.openContainer(false)
+	for _, field := range fs {
+		field.Accept(f)
+	}
+	f.closeContainer()
+}
+
+// --- TypeEncoder ---
+
+func (f *msgpackEncoder) EncodeTypeAny(v interface{}) {
+	f.element()
+	if v == nil {
+		f.buf.AppendByte(0xc0)
+		return
+	}
+	tmp := GetBuffer()
+	f.json.TypeEncoder(tmp).EncodeTypeAny(v)
+	appendMsgpackStr(f.buf, tmp.Data)
+	tmp.Free()
+	f.json.buf = nil
+}
+
+func (f *msgpackEncoder) EncodeTypeBool(v bool) {
+	f.element()
+	if v {
+		f.buf.AppendByte(0xc3)
+	} else {
+		f.buf.AppendByte(0xc2)
+	}
+}
+
+func (f *msgpackEncoder) EncodeTypeInt64(v int64) {
+	f.element()
+	appendMsgpackInt(f.buf, v)
+}
+
+func (f *msgpackEncoder) EncodeTypeUint64(v uint64) {
+	f.element()
+	appendMsgpackUint(f.buf, v)
+}
+
+func (f *msgpackEncoder) EncodeTypeFloat64(v float64) {
+	f.element()
+	appendMsgpackFloat64(f.buf, v)
+}
+
+func (f *msgpackEncoder) EncodeTypeDuration(v time.Duration) {
+	// The DurationEncoder counts the element.
+	f.EncodeDuration(v, f)
+}
+
+func (f *msgpackEncoder) EncodeTypeTime(v time.Time) {
+	if f.EncodeTime != nil {
+		// The TimeEncoder counts the element.
+		f.EncodeTime(v, f)
+		return
+	}
+	f.element()
+	appendMsgpackEventTime(f.buf, v)
+}
+
+func (f *msgpackEncoder) EncodeTypeString(v string) {
+	f.element()
+	appendMsgpackStr(f.buf, v)
+}
+
+func (f *msgpackEncoder) EncodeTypeUnsafeBytes(v unsafe.Pointer) {
+	f.element()
+	appendMsgpackStr(f.buf, *(*[]byte)(v))
+}
+
+func (f *msgpackEncoder) EncodeTypeBytes(v []byte) {
+	f.element()
+	appendMsgpackBin(f.buf, v)
+}
+
+func (f *msgpackEncoder) EncodeTypeStrings(v []string) {
+	f.element()
+	appendMsgpackArrayLen(f.buf, len(v))
+	for i := range v {
+		appendMsgpackStr(f.buf, v[i])
+	}
+}
+
+func (f *msgpackEncoder) EncodeTypeInts64(v []int64) {
+	f.element()
+	appendMsgpackArrayLen(f.buf, len(v))
+	for i := range v {
+		appendMsgpackInt(f.buf, v[i])
+	}
+}
+
+func (f *msgpackEncoder) EncodeTypeFloats64(v []float64) {
+	f.element()
+	appendMsgpackArrayLen(f.buf, len(v))
+	for i := range v {
+		appendMsgpackFloat64(f.buf, v[i])
+	}
+}
+
+func (f *msgpackEncoder) EncodeTypeDurations(v []time.Duration) {
+	f.element()
+	f.openContainer(true)
+	for i := range v {
+		f.EncodeTypeDuration(v[i])
+	}
+	f.closeContainer()
+}
+
+func (f *msgpackEncoder) EncodeTypeArray(v ArrayEncoder) {
+	f.element()
+	f.openContainer(true)
+	_ = v.EncodeLogfArray(f)
+	f.closeContainer()
+}
+
+func (f *msgpackEncoder) EncodeTypeObject(v ObjectEncoder) {
+	f.element()
+	f.openContainer(false)
+	_ = v.EncodeLogfObject(f)
+	f.closeContainer()
+}
+
+// --- helpers ---
+
+// addKey writes a map key.
+func (f *msgpackEncoder) addKey(k string) {
+	f.stack[len(f.stack)-1].count++
+	appendMsgpackStr(f.buf, k)
+}
+
+// element counts a value written into an open array.
+func (f *msgpackEncoder) element() {
+	if n := len(f.stack); n != 0 && f.stack[n-1].array {
+		f.stack[n-1].count++
+	}
+}
+
+// openContainer writes a 32-bit size placeholder for a map or an array.
+func (f *msgpackEncoder) openContainer(array bool) {
+	f.stack = append(f.stack, msgpackContainer{start: f.buf.Len(), array: array})
+	f.buf.AppendString("\x00\x00\x00\x00\x00")
+}
+
+// closeContainer writes the size of the innermost open container into
+// its placeholder, shrinking the header to the shortest form.
+func (f *msgpackEncoder) closeContainer() {
+	c := f.stack[len(f.stack)-1]
+	f.stack = f.stack[:len(f.stack)-1]
+
+	data := f.buf.Data
+	fix, b16, b32 := byte(0x80), byte(0xde), byte(0xdf)
+	if c.array {
+		fix, b16, b32 = 0x90, 0xdc, 0xdd
+	}
+	switch {
+	case c.count < 16:
+		data[c.start] = fix | byte(c.count)
+		copy(data[c.start+1:], data[c.start+5:])
+		f.buf.Truncate(len(data) - 4)
+	case c.count <= math.MaxUint16:
+		data[c.start] = b16
+		binary.BigEndian.PutUint16(data[c.start+1:], uint16(c.count))
+		copy(data[c.start+3:], data[c.start+5:])
+		f.buf.Truncate(len(data) - 2)
+	default:
+		data[c.start] = b32
+		binary.BigEndian.PutUint32(data[c.start+1:], uint32(c.count))
+	}
+}
+
+// appendMsgpackEventTime appends t as the Fluent EventTime extension:
+// fixext8 of type 0 holding big-endian 32-bit seconds and nanoseconds.
+func appendMsgpackEventTime(buf *Buffer, t time.Time) {
+	buf.AppendByte(0xd7)
+	buf.AppendByte(0x00)
+	buf.Data = binary.BigEndian.AppendUint32(buf.Data, uint32(t.Unix()))
+	buf.Data = binary.BigEndian.AppendUint32(buf.Data, uint32(t.Nanosecond()))
+}
+
+func appendMsgpackStr[S string | []byte](buf *Buffer, s S) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.AppendByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.AppendByte(0xd9)
+		buf.AppendByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.AppendByte(0xda)
+		buf.Data = binary.BigEndian.AppendUint16(buf.Data, uint16(n))
+	default:
+		buf.AppendByte(0xdb)
+		buf.Data = binary.BigEndian.AppendUint32(buf.Data, uint32(n))
+	}
+	appendBuf(buf, s)
+}
+
+func appendMsgpackBin(buf *Buffer, b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		buf.AppendByte(0xc4)
+		buf.AppendByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.AppendByte(0xc5)
+		buf.Data = binary.BigEndian.AppendUint16(buf.Data, uint16(n))
+	default:
+		buf.AppendByte(0xc6)
+		buf.Data = binary.BigEndian.AppendUint32(buf.Data, uint32(n))
+	}
+	buf.AppendBytes(b)
+}
+
+func appendMsgpackArrayLen(buf *Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.AppendByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		buf.AppendByte(0xdc)
+		buf.Data = binary.BigEndian.AppendUint16(buf.Data, uint16(n))
+	default:
+		buf.AppendByte(0xdd)
+		buf.Data = binary.BigEndian.AppendUint32(buf.Data, uint32(n))
+	}
+}
+
+func appendMsgpackInt(buf *Buffer, v int64) {
+	switch {
+	case v >= 0:
+		appendMsgpackUint(buf, uint64(v))
+	case v >= -32:
+		buf.AppendByte(byte(v))
+	case v >= math.MinInt8:
+		buf.AppendByte(0xd0)
+		buf.AppendByte(byte(v))
+	case v >= math.MinInt16:
+		buf.AppendByte(0xd1)
+		buf.Data = binary.BigEndian.AppendUint16(buf.Data, uint16(v))
+	case v >= math.MinInt32:
+		buf.AppendByte(0xd2)
+		buf.Data = binary.BigEndian.AppendUint32(buf.Data, uint32(v))
+	default:
+		buf.AppendByte(0xd3)
+		buf.Data = binary.BigEndian.AppendUint64(buf.Data, uint64(v))
+	}
+}
+
+func appendMsgpackUint(buf *Buffer, v uint64) {
+	switch {
+	case v <= math.MaxInt8:
+		buf.AppendByte(byte(v))
+	case v <= math.MaxUint8:
+		buf.AppendByte(0xcc)
+		buf.AppendByte(byte(v))
+	case v <= math.MaxUint16:
+		buf.AppendByte(0xcd)
+		buf.Data = binary.BigEndian.AppendUint16(buf.Data, uint16(v))
+	case v <= math.MaxUint32:
+		buf.AppendByte(0xce)
+		buf.Data = binary.BigEndian.AppendUint32(buf.Data, uint32(v))
+	default:
+		buf.AppendByte(0xcf)
+		buf.Data = binary.BigEndian.AppendUint64(buf.Data, v)
+	}
+}
+
+func appendMsgpackFloat64(buf *Buffer, v float64) {
+	buf.AppendByte(0xcb)
+	buf.Data = binary.BigEndian.AppendUint64(buf.Data, math.Float64bits(v))
+}
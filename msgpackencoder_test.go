@@ -0,0 +1,343 @@
+package logf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// msgpackText renders one MessagePack value as JSON-like text: strings
+// and keys quoted, binary as bin(hex), EventTime as time(sec.nsec). It
+// fails on malformed input and on trailing bytes.
+func msgpackText(t *testing.T, b []byte) string {
+	t.Helper()
+	var sb strings.Builder
+	r := msgpackTestReader{b: b}
+	r.value(&sb)
+	require.NoError(t, r.err)
+	require.Empty(t, r.b, "trailing bytes")
+	return sb.String()
+}
+
+type msgpackTestReader struct {
+	b   []byte
+	err error
+}
+
+func (r *msgpackTestReader) next(n int) []byte {
+	if r.err != nil || len(r.b) < n {
+		r.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+	p := r.b[:n]
+	r.b = r.b[n:]
+	return p
+}
+
+func (r *msgpackTestReader) uint(n int) uint64 {
+	var v uint64
+	for _, c := range r.next(n) {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func (r *msgpackTestReader) int(n int) int64 {
+	shift := 64 - 8*n
+	return int64(r.uint(n)<<shift) >> shift
+}
+
+func (r *msgpackTestReader) value(sb *strings.Builder) {
+	if r.err != nil {
+		return
+	}
+	c := r.next(1)[0]
+	switch {
+	case c <= 0x7f:
+		sb.WriteString(strconv.Itoa(int(c)))
+	case c >= 0xe0:
+		sb.WriteString(strconv.Itoa(int(int8(c))))
+	case c&0xf0 == 0x80:
+		r.container(sb, int(c&0x0f), false)
+	case c&0xf0 == 0x90:
+		r.container(sb, int(c&0x0f), true)
+	case c&0xe0 == 0xa0:
+		sb.WriteString(strconv.Quote(string(r.next(int(c & 0x1f)))))
+	case c == 0xc0:
+		sb.WriteString("nil")
+	case c == 0xc2:
+		sb.WriteString("false")
+	case c == 0xc3:
+		sb.WriteString("true")
+	case c >= 0xc4 && c <= 0xc6:
+		fmt.Fprintf(sb, "bin(%x)", r.next(int(r.uint(1<<(c-0xc4)))))
+	case c == 0xcb:
+		sb.WriteString(strconv.FormatFloat(math.Float64frombits(r.uint(8)), 'g', -1, 64))
+	case c >= 0xcc && c <= 0xcf:
+		sb.WriteString(strconv.FormatUint(r.uint(1<<(c-0xcc)), 10))
+	case c >= 0xd0 && c <= 0xd3:
+		sb.WriteString(strconv.FormatInt(r.int(1<<(c-0xd0)), 10))
+	case c == 0xd7:
+		if typ := r.next(1)[0]; typ != 0 {
+			r.err = fmt.Errorf("unexpected ext type %d", typ)
+			return
+		}
+		sec := r.uint(4)
+		fmt.Fprintf(sb, "time(%d.%09d)", sec, r.uint(4))
+	case c >= 0xd9 && c <= 0xdb:
+		sb.WriteString(strconv.Quote(string(r.next(int(r.uint(1 << (c - 0xd9)))))))
+	case c == 0xdc || c == 0xdd:
+		r.container(sb, int(r.uint(2<<(c-0xdc))), true)
+	case c == 0xde || c == 0xdf:
+		r.container(sb, int(r.uint(2<<(c-0xde))), false)
+	default:
+		r.err = fmt.Errorf("unexpected byte 0x%02x", c)
+	}
+}
+
+func (r *msgpackTestReader) container(sb *strings.Builder, n int, array bool) {
+	open, closing := "{", "}"
+	if array {
+		open, closing = "[", "]"
+	}
+	sb.WriteString(open)
+	for i := 0; i < n; i++ {
+		if i != 0 {
+			sb.WriteByte(',')
+		}
+		r.value(sb)
+		if !array {
+			sb.WriteByte(':')
+			r.value(sb)
+		}
+	}
+	sb.WriteString(closing)
+}
+
+func TestMsgPackEncoder(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	testCases := []encoderTestCase{
+		{
+			"Header",
+			Entry{Level: LevelInfo, Time: ts, LoggerName: "http", Text: "request served"},
+			`{"level":"info","ts":time(1714557600.123456789),"logger":"http","msg":"request served"}`,
+		},
+		{
+			"NoTime",
+			Entry{Level: LevelDebug, Text: "m"},
+			`{"level":"debug","msg":"m"}`,
+		},
+		{
+			"Types",
+			Entry{Level: LevelError, Time: ts, Text: "m", Fields: []Field{
+				String("s", "a\"b"), Bool("ok", true), Bool("no", false), Float64("f", 0.5),
+				Duration("d", time.Second), Time("t", ts), Bytes("b", []byte("hi")),
+				ByteString("cb", []byte("raw")), NamedError("err", errors.New("boom")),
+				Any("map", map[string]int{"a": 1}), Any("nil", nil),
+			}},
+			`{"level":"error","ts":time(1714557600.123456789),"msg":"m","s":"a\"b","ok":true,"no":false,"f":0.5,` +
+				`"d":"1s","t":time(1714557600.123456789),"b":bin(6869),"cb":"raw","err":"boom",` +
+				`"map":"{\"a\":1}","nil":nil}`,
+		},
+		{
+			"Ints",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Int("a", 5), Int("b", -5), Int("c", -100), Int("d", 200), Int("e", -1000),
+				Int("f", 70000), Int("g", -100000), Int64("h", -1e10), Uint64("i", 5e9),
+				Uint64("j", math.MaxUint64), Int64("k", math.MinInt64),
+			}},
+			`{"level":"info","msg":"m","a":5,"b":-5,"c":-100,"d":200,"e":-1000,"f":70000,"g":-100000,` +
+				`"h":-10000000000,"i":5000000000,"j":18446744073709551615,"k":-9223372036854775808}`,
+		},
+		{
+			"Containers",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Group("http", String("method", "GET"), Group("req", Int("size", 10))),
+				Group("", String("inline", "x")),
+				Group("empty"),
+				Object("user", testObjectEncoder{}),
+				Strings("tags", []string{"a", "b"}),
+				Ints64("ids", []int64{1, -2}),
+				Floats64("fs", []float64{1.5}),
+				Durations("ds", []time.Duration{time.Second, time.Millisecond}),
+				Array("arr", testArrayEncoder{}),
+			}},
+			`{"level":"info","msg":"m","http":{"method":"GET","req":{"size":10}},"inline":"x","empty":{},` +
+				`"user":{"username":"username","code":42},"tags":["a","b"],"ids":[1,-2],"fs":[1.5],` +
+				`"ds":["1s","1ms"],"arr":[42]}`,
+		},
+		{
+			"Bags",
+			Entry{
+				Level:     LevelInfo,
+				Text:      "m",
+				Bag:       NewBag(String("request_id", "r1")),
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http").With(String("method", "GET")),
+				Fields:    []Field{Int("status", 200)},
+			},
+			`{"level":"info","msg":"m","request_id":"r1","service":"api","http":{"method":"GET","status":200}}`,
+		},
+		{
+			"BagGroups",
+			Entry{
+				Level:     LevelInfo,
+				Text:      "m",
+				Bag:       NewBag(String("request_id", "r1")).WithGroup("ctx").With(Int("n", 1)),
+				LoggerBag: NewBag().WithGroup("a").With(Int("x", 1)).WithGroup("b").With(Int("y", 2)),
+				Fields:    []Field{Int("z", 3)},
+			},
+			`{"level":"info","msg":"m","request_id":"r1","ctx":{"n":1,"a":{"x":1,"b":{"y":2,"z":3}}}}`,
+		},
+		{
+			"TrailingGroups",
+			Entry{
+				Level:     LevelInfo,
+				Text:      "m",
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http"),
+			},
+			`{"level":"info","msg":"m","service":"api"}`,
+		},
+	}
+
+	enc := MsgPack().Build()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden, msgpackText(t, b.Bytes()))
+			b.Free()
+		})
+	}
+}
+
+func TestMsgPackEncoderBagCache(t *testing.T) {
+	enc := withCacheSlot(MsgPack().DisableLevel().DisableMsg().Build())
+	logger := NewBag(String("service", "api")).WithGroup("http").With(String("method", "GET"))
+
+	// The cached logger Bag leaves the "http" map open: its entry count
+	// has to be restored at a new offset and grown by the entry fields.
+	cases := []struct {
+		entry Entry
+		want  string
+	}{
+		{Entry{LoggerBag: logger}, `{"service":"api","http":{"method":"GET"}}`},
+		{Entry{LoggerBag: logger, Fields: []Field{Int("status", 200)}}, `{"service":"api","http":{"method":"GET","status":200}}`},
+		{
+			Entry{Bag: NewBag(String("request_id", "r1")), LoggerBag: logger, Fields: []Field{Int("status", 200), Int("size", 10)}},
+			`{"request_id":"r1","service":"api","http":{"method":"GET","status":200,"size":10}}`,
+		},
+	}
+	for _, c := range cases {
+		b, err := enc.Encode(c.entry)
+		require.NoError(t, err)
+		assert.Equal(t, c.want, msgpackText(t, b.Bytes()))
+		b.Free()
+	}
+}
+
+func TestMsgPackEncoderCompactHeaders(t *testing.T) {
+	enc := MsgPack().DisableLevel().DisableMsg().Build()
+
+	// Up to 15 entries fit a fixmap.
+	b, err := enc.Encode(Entry{Fields: []Field{Int("a", 1)}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x81, 0xa1, 'a', 0x01}, b.Bytes())
+	b.Free()
+
+	// 16 entries and more need a map16, arrays the same.
+	fields := make([]Field, 20)
+	values := make([]int64, 20)
+	for i := range fields {
+		fields[i] = Int("k"+strconv.Itoa(i), i)
+		values[i] = int64(i)
+	}
+	b, err = enc.Encode(Entry{Fields: []Field{
+		Group("g", fields...),
+		Array("arr", int64Array(values)),
+		String("long", strings.Repeat("x", 300)),
+	}})
+	require.NoError(t, err)
+	data := b.Bytes()
+	assert.Equal(t, []byte{0x83, 0xa1, 'g', 0xde, 0x00, 0x14}, data[:6])
+	text := msgpackText(t, data)
+	assert.Contains(t, text, `"k19":19}`)
+	assert.Contains(t, text, `"arr":[0,1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19]`)
+	assert.Contains(t, text, `"long":"xxx`)
+	b.Free()
+}
+
+type int64Array []int64
+
+func (a int64Array) EncodeLogfArray(e TypeEncoder) error {
+	for _, v := range a {
+		e.EncodeTypeInt64(v)
+	}
+	return nil
+}
+
+func TestMsgPackEncoderForward(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	enc := MsgPack().Forward().Build()
+
+	b, err := enc.Encode(Entry{Level: LevelWarn, Time: ts, Text: "m", Fields: []Field{Int("n", 1)}})
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x92), b.Bytes()[0])
+	assert.Equal(t, `[time(1714557600.123456789),{"level":"warn","msg":"m","n":1}]`, msgpackText(t, b.Bytes()))
+	b.Free()
+
+	// Entries without a time get the current one.
+	b, err = enc.Encode(Entry{Level: LevelInfo, Text: "m"})
+	require.NoError(t, err)
+	assert.Regexp(t, `^\[time\(\d+\.\d{9}\),\{"level":"info","msg":"m"\}\]$`, msgpackText(t, b.Bytes()))
+	b.Free()
+}
+
+func TestMsgPackEncoderHooks(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	enc := MsgPack().
+		TimeKey("time").LevelKey("severity").MsgKey("message").NameKey("name").
+		EncodeTime(RFC3339TimeEncoder).
+		EncodeDuration(NanoDurationEncoder).
+		EncodeLevel(UpperCaseLevelEncoder).
+		Build()
+
+	b, err := enc.Encode(Entry{Level: LevelInfo, Time: ts, LoggerName: "l", Text: "m", Fields: []Field{
+		Durations("ds", []time.Duration{1, 2}), Time("t", ts),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"severity":"INFO","time":"2024-05-01T10:00:00Z","name":"l","message":"m","ds":[1,2],`+
+		`"t":"2024-05-01T10:00:00Z"}`, msgpackText(t, b.Bytes()))
+	b.Free()
+}
+
+func TestMsgPackEncoderCaller(t *testing.T) {
+	enc := MsgPack().Build()
+	b, err := enc.Encode(Entry{Level: LevelInfo, Text: "m", CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	assert.Regexp(t, `"caller":"[^"]+/msgpackencoder_test.go:\d+"}$`, msgpackText(t, b.Bytes()))
+	b.Free()
+
+	// Object callers become nested maps.
+	enc = MsgPack().EncodeCaller(ECSCallerEncoder).Build()
+	b, err = enc.Encode(Entry{Level: LevelInfo, Text: "m", CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	assert.Regexp(t, `"caller":\{"file.name":"[^"]+/msgpackencoder_test.go","file.line":\d+,"function":"[^"]+"\}}$`,
+		msgpackText(t, b.Bytes()))
+	b.Free()
+}
+
+func TestNewMsgPackEncoder(t *testing.T) {
+	enc := NewMsgPackEncoder(MsgPackEncoderConfig{DisableFieldLevel: true, FieldKeyMsg: "message"})
+	b, err := enc.Encode(Entry{Text: "m"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"message":"m"}`, msgpackText(t, b.Bytes()))
+	b.Free()
+}
@@ -0,0 +1,219 @@
+package logf
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxNamedLevelsCache bounds the number of resolved logger names kept by
+// NamedLevels. When it is reached the cache starts over.
+const maxNamedLevelsCache = 4096
+
+// NewNamedLevels returns a Handler middleware that sets the level per
+// logger name. Rules map a dotted name prefix to a level; the longest
+// prefix of Entry.LoggerName that has a rule wins, where prefixes end at
+// dots ("db" covers "db" and "db.pool", not "dbx"). The "*" rule, or def
+// when there is none, applies to all other names:
+//
+//	levels := logf.NewNamedLevels(router, logf.LevelInfo)
+//	levels.SetRules("db=debug, db.pool=warn, http=info, *=info")
+//	logger := logf.New(logf.NewContextHandler(levels))
+//
+//	db := logger.WithName("db")
+//	db.Debug(ctx, "query")                     // written
+//	db.WithName("pool").Info(ctx, "conn open") // dropped
+//
+// Rules can be changed at any time. Resolved levels are cached per name,
+// so the check costs a single lock-free lookup; a rule change drops the
+// cache.
+//
+// NamedLevels replaces the level decision of the downstream Handler
+// rather than narrowing it: configure a downstream Router with its
+// outputs at LevelDebug, or entries enabled by a rule are dropped there.
+//
+// Handler.Enabled does not carry the logger name. Logger passes it
+// through the optional NameEnabler interface, which NamedLevels and the
+// middleware in this package implement. Behind a Handler that does not
+// forward names, Enabled answers for the most verbose rule and the
+// entries are filtered by name in Handle — correct, just less cheap.
+func NewNamedLevels(next Handler, def Level) *NamedLevels {
+	n := &NamedLevels{next: next, def: def, rules: map[string]Level{}}
+	n.update()
+	return n
+}
+
+// NamedLevels is the Handler built by NewNamedLevels.
+type NamedLevels struct {
+	next Handler
+	def  Level
+
+	mu    sync.Mutex
+	rules map[string]Level // name prefix ("*" for the rest) → level
+
+	cache    atomic.Pointer[namedLevelsCache]
+	broadest atomic.Int32 // most verbose level of all rules
+}
+
+// namedLevelsCache maps logger names to resolved levels. Entries are only
+// added with NamedLevels.mu held, so a rule change never races with a
+// level resolved from the old rules.
+type namedLevelsCache struct {
+	levels sync.Map // string → Level
+	size   int      // guarded by NamedLevels.mu
+}
+
+// Enabled reports whether any rule enables lvl. The exact per-name check
+// is done by EnabledName, or by Handle when the name did not reach it.
+// A level set by WithLevel takes precedence over the rules.
+func (n *NamedLevels) Enabled(ctx context.Context, lvl Level) bool {
+	if override, ok := LevelFromContext(ctx); ok {
+		return override.Enabled(lvl)
+	}
+	return Level(n.broadest.Load()).Enabled(lvl)
+}
+
+// EnabledName reports whether lvl is enabled for the given logger name.
+func (n *NamedLevels) EnabledName(ctx context.Context, name string, lvl Level) bool {
+	if override, ok := LevelFromContext(ctx); ok {
+		return override.Enabled(lvl)
+	}
+	return n.Level(name).Enabled(lvl)
+}
+
+// Handle passes the entry downstream if the level resolved for its
+// logger name enables it.
+func (n *NamedLevels) Handle(ctx context.Context, e Entry) error {
+	if !n.EnabledName(ctx, e.LoggerName, e.Level) {
+		return nil
+	}
+	return n.next.Handle(ctx, e)
+}
+
+// Level returns the level resolved for the given logger name.
+func (n *NamedLevels) Level(name string) Level {
+	if lvl, ok := n.cache.Load().levels.Load(name); ok {
+		return lvl.(Level)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	cache := n.cache.Load()
+	if lvl, ok := cache.levels.Load(name); ok {
+		return lvl.(Level)
+	}
+	if cache.size >= maxNamedLevelsCache {
+		cache = &namedLevelsCache{}
+		n.cache.Store(cache)
+	}
+	lvl := n.resolve(name)
+	cache.levels.Store(strings.Clone(name), lvl)
+	cache.size++
+	return lvl
+}
+
+// Set sets the level for the given name prefix. The name "*" sets the
+// level of names no other rule covers.
+func (n *NamedLevels) Set(name string, lvl Level) {
+	n.mu.Lock()
+	n.rules[name] = lvl
+	n.update()
+	n.mu.Unlock()
+}
+
+// Unset removes the rule for the given name prefix.
+func (n *NamedLevels) Unset(name string) {
+	n.mu.Lock()
+	delete(n.rules, name)
+	n.update()
+	n.mu.Unlock()
+}
+
+// SetRules replaces all rules with the ones parsed from spec, a comma
+// separated list of name=level pairs such as "db=debug,db.pool=warn,*=info".
+// On a parse error the rules are left unchanged.
+func (n *NamedLevels) SetRules(spec string) error {
+	rules := map[string]Level{}
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(rule, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return fmt.Errorf("invalid level rule %q", rule)
+		}
+		lvl, ok := LevelFromString(strings.TrimSpace(level))
+		if !ok {
+			return fmt.Errorf("invalid logging level in rule %q", rule)
+		}
+		rules[name] = lvl
+	}
+
+	n.mu.Lock()
+	n.rules = rules
+	n.update()
+	n.mu.Unlock()
+	return nil
+}
+
+// String returns the rules in the format accepted by SetRules, sorted by
+// name.
+func (n *NamedLevels) String() string {
+	n.mu.Lock()
+	names := make([]string, 0, len(n.rules))
+	for name := range n.rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(n.rules[name].String())
+	}
+	n.mu.Unlock()
+	return sb.String()
+}
+
+// resolve finds the rule with the longest prefix of name. Must be called
+// with mu held.
+func (n *NamedLevels) resolve(name string) Level {
+	for prefix := name; prefix != ""; {
+		if lvl, ok := n.rules[prefix]; ok {
+			return lvl
+		}
+		i := strings.LastIndexByte(prefix, '.')
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	if lvl, ok := n.rules["*"]; ok {
+		return lvl
+	}
+	return n.def
+}
+
+// update drops the cache and recomputes the broadest level after a rule
+// change. Must be called with mu held.
+func (n *NamedLevels) update() {
+	broadest := n.def
+	if lvl, ok := n.rules["*"]; ok {
+		broadest = lvl
+	}
+	for _, lvl := range n.rules {
+		if lvl > broadest {
+			broadest = lvl
+		}
+	}
+	n.broadest.Store(int32(broadest))
+	n.cache.Store(&namedLevelsCache{})
+}
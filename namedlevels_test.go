@@ -0,0 +1,133 @@
+package logf
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedLevelsResolve(t *testing.T) {
+	n := NewNamedLevels(&syncTestHandler{}, LevelWarn)
+	require.NoError(t, n.SetRules("db=debug, db.pool=warn, http=info"))
+
+	cases := []struct {
+		name string
+		want Level
+	}{
+		{"db", LevelDebug},
+		{"db.query", LevelDebug},
+		{"db.pool", LevelWarn},
+		{"db.pool.conn", LevelWarn},
+		{"dbx", LevelWarn},
+		{"http", LevelInfo},
+		{"", LevelWarn},
+		{"other", LevelWarn},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, n.Level(c.name), c.name)
+	}
+
+	n.Set("*", LevelError)
+	assert.Equal(t, LevelError, n.Level("other"))
+	assert.Equal(t, LevelError, n.Level(""))
+	n.Unset("*")
+	assert.Equal(t, LevelWarn, n.Level("other"))
+}
+
+func TestNamedLevelsRuntimeChange(t *testing.T) {
+	n := NewNamedLevels(&syncTestHandler{}, LevelInfo)
+	assert.Equal(t, LevelInfo, n.Level("db.pool"))
+
+	n.Set("db", LevelDebug)
+	assert.Equal(t, LevelDebug, n.Level("db.pool"))
+
+	n.Set("db.pool", LevelError)
+	assert.Equal(t, LevelError, n.Level("db.pool"))
+
+	n.Unset("db.pool")
+	assert.Equal(t, LevelDebug, n.Level("db.pool"))
+}
+
+func TestNamedLevelsSetRules(t *testing.T) {
+	n := NewNamedLevels(&syncTestHandler{}, LevelInfo)
+	require.NoError(t, n.SetRules("http=info,db=debug,*=warn"))
+	assert.Equal(t, "*=warn,db=debug,http=info", n.String())
+
+	assert.Error(t, n.SetRules("db=loud"))
+	assert.Error(t, n.SetRules("db"))
+	assert.Error(t, n.SetRules("=debug"))
+	assert.Equal(t, "*=warn,db=debug,http=info", n.String(), "rules must be unchanged on error")
+
+	require.NoError(t, n.SetRules(""))
+	assert.Equal(t, "", n.String())
+	assert.Equal(t, LevelInfo, n.Level("db"))
+}
+
+func TestNamedLevelsEnabled(t *testing.T) {
+	n := NewNamedLevels(&syncTestHandler{}, LevelInfo)
+	ctx := context.Background()
+	assert.False(t, n.Enabled(ctx, LevelDebug))
+
+	n.Set("db", LevelDebug)
+	assert.True(t, n.Enabled(ctx, LevelDebug), "Enabled answers for the most verbose rule")
+	assert.True(t, n.EnabledName(ctx, "db", LevelDebug))
+	assert.False(t, n.EnabledName(ctx, "http", LevelDebug))
+}
+
+func TestNamedLevelsLogger(t *testing.T) {
+	sink := &syncTestHandler{}
+	n := NewNamedLevels(sink, LevelInfo)
+	require.NoError(t, n.SetRules("db=debug,db.pool=warn"))
+	logger := New(NewContextHandler(n))
+	ctx := context.Background()
+
+	db := logger.WithName("db")
+	db.Debug(ctx, "query")
+	db.WithName("pool").Info(ctx, "conn open")
+	db.WithName("pool").Warn(ctx, "pool exhausted")
+	logger.Debug(ctx, "root debug")
+	logger.Info(ctx, "root info")
+
+	assert.Equal(t, []string{"query", "pool exhausted", "root info"}, entryTexts(sink.Entries()))
+	assert.True(t, db.Enabled(ctx, LevelDebug))
+	assert.False(t, logger.Enabled(ctx, LevelDebug))
+	assert.True(t, db.Slog().Enabled(ctx, -4))
+	assert.False(t, logger.Slog().Enabled(ctx, -4))
+}
+
+func TestNamedLevelsFiltersInHandle(t *testing.T) {
+	sink := &syncTestHandler{}
+	n := NewNamedLevels(sink, LevelInfo)
+	n.Set("db", LevelDebug)
+	// A Handler that does not forward names hides them from Enabled.
+	opaque := handlerFunc(n.Handle)
+	logger := New(opaque)
+
+	logger.WithName("http").Debug(context.Background(), "dropped in Handle")
+	logger.WithName("db").Debug(context.Background(), "kept")
+
+	assert.Equal(t, []string{"kept"}, entryTexts(sink.Entries()))
+}
+
+func TestNamedLevelsCacheStartsOver(t *testing.T) {
+	n := NewNamedLevels(&syncTestHandler{}, LevelInfo)
+	n.Set("db", LevelDebug)
+	for i := 0; i < maxNamedLevelsCache; i++ {
+		n.Level("db.n" + strconv.Itoa(i))
+	}
+	assert.Equal(t, maxNamedLevelsCache, n.cache.Load().size)
+
+	// A name past the cap is cached in a fresh cache.
+	assert.Equal(t, LevelDebug, n.Level("db.past"))
+	cache := n.cache.Load()
+	assert.Equal(t, 1, cache.size)
+	lvl, ok := cache.levels.Load("db.past")
+	require.True(t, ok)
+	assert.Equal(t, LevelDebug, lvl)
+
+	n.Set("db", LevelWarn)
+	assert.Equal(t, LevelWarn, n.Level("db.past"))
+}
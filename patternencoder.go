@@ -0,0 +1,578 @@
+package logf
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// DefaultPatternLayout is the layout used by the pattern encoder when
+// none is given.
+const DefaultPatternLayout = "%time %level{upper,5} %msg %fields"
+
+// PatternEncoderConfig controls how the pattern encoder formats log
+// entries. For a friendlier builder-style API, use Pattern() instead.
+type PatternEncoderConfig struct {
+	// Layout is the format of every line; see Pattern for the syntax.
+	// Defaults to DefaultPatternLayout.
+	Layout string
+
+	EncodeTime     TimeEncoder
+	EncodeDuration DurationEncoder
+	EncodeError    ErrorEncoder
+	EncodeLevel    LevelEncoder
+	EncodeCaller   CallerEncoder
+}
+
+// WithDefaults returns a copy of the config with all zero-value fields
+// replaced by sensible defaults (the default layout, RFC3339 timestamps,
+// string durations, lower-case levels, short caller format, etc.).
+func (c PatternEncoderConfig) WithDefaults() PatternEncoderConfig {
+	if c.Layout == "" {
+		c.Layout = DefaultPatternLayout
+	}
+	if c.EncodeDuration == nil {
+		c.EncodeDuration = StringDurationEncoder
+	}
+	if c.EncodeTime == nil {
+		c.EncodeTime = RFC3339TimeEncoder
+	}
+	if c.EncodeError == nil {
+		c.EncodeError = DefaultErrorEncoder
+	}
+	if c.EncodeLevel == nil {
+		c.EncodeLevel = DefaultLevelEncoder
+	}
+	if c.EncodeCaller == nil {
+		c.EncodeCaller = ShortCallerEncoder
+	}
+	return c
+}
+
+// PatternEncoderBuilder configures and builds a pattern Encoder using a
+// clean builder-style API. Create one with Pattern(), chain methods to
+// customize, then call Build() or pass directly to
+// LoggerBuilder.EncoderFrom().
+type PatternEncoderBuilder struct {
+	cfg PatternEncoderConfig
+}
+
+// Pattern returns a new PatternEncoderBuilder for the given layout. The
+// encoder writes one line per entry in a fixed format, for tools that
+// parse lines by position:
+//
+//	enc := logf.Pattern("%time{15:04:05.000} %level{upper,5} [%name] %msg %fields %caller").Build()
+//	// 10:00:00.123 INFO  [http] request served method=GET status=200 server/http.go:42
+//
+// The layout is compiled once. Text is copied as is, "%%" writes a '%',
+// and these verbs write the parts of an entry:
+//
+//	%time     the entry time, by EncodeTime; %time{layout} uses a Go time layout
+//	%level    the level, by EncodeLevel; upper, lower or short pick a built-in
+//	%name     the logger name
+//	%msg      the message
+//	%fields   the Bag and entry fields as logfmt; %fields{json} as a JSON object
+//	%caller   the caller, by EncodeCaller
+//
+// Verbs other than %time take comma-separated options in braces. A width
+// option [<|>]N[.M] pads the output to at least N characters, aligned
+// left ('<', the default) or right ('>'), and cuts it to at most M
+// characters. "%level{upper,5}" writes "INFO ", "%name{>10.10}" writes
+// the logger name right-aligned in exactly 10 characters.
+//
+// Verbs with nothing to write, such as %caller without a caller, write
+// only their padding. That padding, and the spaces written before it, are
+// trimmed from the end of a line; spaces in the message or written by
+// other verbs are kept. Control characters in the logger name and the
+// message are escaped as in the text encoder, so every entry stays on one
+// line.
+//
+// Build panics if the layout is invalid; use NewPatternEncoder to get the
+// error instead.
+func Pattern(layout string) *PatternEncoderBuilder {
+	return &PatternEncoderBuilder{cfg: PatternEncoderConfig{Layout: layout}}
+}
+
+// EncodeTime sets a custom TimeEncoder for %time without a layout and
+// for Time fields (default RFC3339).
+func (b *PatternEncoderBuilder) EncodeTime(e TimeEncoder) *PatternEncoderBuilder {
+	b.cfg.EncodeTime = e
+	return b
+}
+
+// EncodeDuration sets a custom DurationEncoder for formatting durations (default string representation).
+func (b *PatternEncoderBuilder) EncodeDuration(e DurationEncoder) *PatternEncoderBuilder {
+	b.cfg.EncodeDuration = e
+	return b
+}
+
+// EncodeLevel sets a custom LevelEncoder for %level without a case option.
+func (b *PatternEncoderBuilder) EncodeLevel(e LevelEncoder) *PatternEncoderBuilder {
+	b.cfg.EncodeLevel = e
+	return b
+}
+
+// EncodeCaller sets a custom CallerEncoder for formatting caller locations (default short format).
+func (b *PatternEncoderBuilder) EncodeCaller(e CallerEncoder) *PatternEncoderBuilder {
+	b.cfg.EncodeCaller = e
+	return b
+}
+
+// EncodeError sets a custom ErrorEncoder for formatting error values.
+func (b *PatternEncoderBuilder) EncodeError(e ErrorEncoder) *PatternEncoderBuilder {
+	b.cfg.EncodeError = e
+	return b
+}
+
+// Build compiles the layout and returns a ready-to-use pattern Encoder.
+// It panics if the layout is invalid.
+func (b *PatternEncoderBuilder) Build() Encoder {
+	enc, err := NewPatternEncoder(b.cfg)
+	if err != nil {
+		panic(err)
+	}
+	return enc
+}
+
+// NewPatternEncoder creates a pattern Encoder from a PatternEncoderConfig
+// struct, or returns an error if the layout is invalid. For a friendlier
+// builder-style API, use Pattern() instead.
+func NewPatternEncoder(cfg PatternEncoderConfig) (Encoder, error) {
+	cfg = cfg.WithDefaults()
+	tokens, err := compilePattern(cfg.Layout)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON config for container values and %fields{json}.
+	jsonCfg := JSONEncoderConfig{
+		DisableFieldMsg:    true,
+		DisableFieldTime:   true,
+		DisableFieldLevel:  true,
+		DisableFieldName:   true,
+		DisableFieldCaller: true,
+		EncodeTime:         cfg.EncodeTime,
+		EncodeDuration:     cfg.EncodeDuration,
+		EncodeError:        cfg.EncodeError,
+	}.WithDefaults()
+	enc := &patternEncoder{
+		PatternEncoderConfig: cfg,
+		tokens:               tokens,
+		json:                 &jsonEncoder{JSONEncoderConfig: jsonCfg},
+	}
+	for _, t := range tokens {
+		if t.kind != patternFields {
+			continue
+		}
+		// Fields are rendered by the encoders of their format, each with
+		// its own Bag cache slot.
+		if t.json && enc.json.slot == 0 {
+			enc.json.slot = AllocEncoderSlot()
+		}
+		if !t.json && enc.logfmt == nil {
+			enc.logfmt = buildLogfmtEncoder(LogfmtEncoderConfig{
+				DisableFieldMsg:    true,
+				DisableFieldTime:   true,
+				DisableFieldLevel:  true,
+				DisableFieldName:   true,
+				DisableFieldCaller: true,
+				EncodeTime:         cfg.EncodeTime,
+				EncodeDuration:     cfg.EncodeDuration,
+				EncodeError:        cfg.EncodeError,
+			}).(*logfmtEncoder)
+		}
+	}
+
+	enc.pool = &sync.Pool{New: func() any {
+		return enc.Clone()
+	}}
+	return enc, nil
+}
+
+type patternKind int
+
+const (
+	patternText patternKind = iota
+	patternTime
+	patternLevel
+	patternName
+	patternMsg
+	patternFields
+	patternCaller
+)
+
+var patternVerbs = map[string]patternKind{
+	"time":   patternTime,
+	"level":  patternLevel,
+	"name":   patternName,
+	"msg":    patternMsg,
+	"fields": patternFields,
+	"caller": patternCaller,
+}
+
+// patternToken is a compiled piece of a layout.
+type patternToken struct {
+	kind  patternKind
+	text  string       // literal text, or the time layout
+	level LevelEncoder // level case option
+	json  bool         // %fields{json}
+	min   int          // pad to this many characters
+	max   int          // cut to this many characters; 0 means no limit
+	right bool         // align right
+}
+
+// compilePattern parses a layout into tokens.
+func compilePattern(layout string) ([]patternToken, error) {
+	var tokens []patternToken
+	var text strings.Builder
+	flush := func() {
+		if text.Len() != 0 {
+			tokens = append(tokens, patternToken{kind: patternText, text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(layout); {
+		c := layout[i]
+		if c != '%' {
+			text.WriteByte(c)
+			i++
+			continue
+		}
+		if i+1 < len(layout) && layout[i+1] == '%' {
+			text.WriteByte('%')
+			i += 2
+			continue
+		}
+
+		// Verb.
+		j := i + 1
+		for j < len(layout) && layout[j] >= 'a' && layout[j] <= 'z' {
+			j++
+		}
+		name := layout[i+1 : j]
+		kind, ok := patternVerbs[name]
+		if !ok {
+			return nil, fmt.Errorf("logf: pattern %q: unknown verb %%%s at %d", layout, name, i)
+		}
+		t := patternToken{kind: kind}
+
+		// Options.
+		if j < len(layout) && layout[j] == '{' {
+			end := strings.IndexByte(layout[j:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("logf: pattern %q: unclosed '{' at %d", layout, j)
+			}
+			if err := t.parseOptions(layout[j+1 : j+end]); err != nil {
+				return nil, fmt.Errorf("logf: pattern %q: %%%s: %w", layout, name, err)
+			}
+			j += end + 1
+		}
+
+		flush()
+		tokens = append(tokens, t)
+		i = j
+	}
+	flush()
+	return tokens, nil
+}
+
+func (t *patternToken) parseOptions(opts string) error {
+	if t.kind == patternTime {
+		t.text = opts
+		return nil
+	}
+	for _, opt := range strings.Split(opts, ",") {
+		opt = strings.TrimSpace(opt)
+		switch {
+		case opt == "":
+		case t.kind == patternLevel && opt == "upper":
+			t.level = UpperCaseLevelEncoder
+		case t.kind == patternLevel && opt == "lower":
+			t.level = DefaultLevelEncoder
+		case t.kind == patternLevel && opt == "short":
+			t.level = ShortTextLevelEncoder
+		case t.kind == patternFields && opt == "json":
+			t.json = true
+		case t.kind == patternFields && opt == "logfmt":
+			t.json = false
+		default:
+			if err := t.parseWidth(opt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseWidth parses a width option: [<|>]N[.M] or [<|>].M.
+func (t *patternToken) parseWidth(opt string) error {
+	s := opt
+	switch {
+	case strings.HasPrefix(s, "<"):
+		s = s[1:]
+	case strings.HasPrefix(s, ">"):
+		t.right = true
+		s = s[1:]
+	}
+	minStr, maxStr, hasMax := strings.Cut(s, ".")
+	if minStr == "" && !hasMax {
+		return fmt.Errorf("unknown option %q", opt)
+	}
+	if minStr != "" {
+		n, err := strconv.Atoi(minStr)
+		if err != nil || n < 0 {
+			return fmt.Errorf("unknown option %q", opt)
+		}
+		t.min = n
+	}
+	if hasMax {
+		n, err := strconv.Atoi(maxStr)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("bad width %q", opt)
+		}
+		t.max = n
+	}
+	return nil
+}
+
+type patternEncoder struct {
+	PatternEncoderConfig
+	pool   *sync.Pool
+	tokens []patternToken
+	json   *jsonEncoder   // container values and %fields{json}
+	logfmt *logfmtEncoder // %fields
+
+	// Internal state.
+	buf *Buffer
+}
+
+func (f *patternEncoder) Clone() Encoder {
+	clone := &patternEncoder{
+		PatternEncoderConfig: f.PatternEncoderConfig,
+		pool:                 f.pool,
+		tokens:               f.tokens,
+		json: &jsonEncoder{
+			JSONEncoderConfig: f.json.JSONEncoderConfig,
+			slot:              f.json.slot,
+		},
+	}
+	if f.logfmt != nil {
+		clone.logfmt = f.logfmt.Clone().(*logfmtEncoder)
+	}
+	return clone
+}
+
+func (f *patternEncoder) Encode(e Entry) (*Buffer, error) {
+	clone := f.pool.Get().(*patternEncoder)
+
+	buf := GetBuffer()
+	err := clone.encode(buf, e)
+
+	clone.buf = nil
+	clone.json.buf = nil
+	f.pool.Put(clone)
+
+	if err != nil {
+		buf.Free()
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (f *patternEncoder) encode(buf *Buffer, e Entry) error {
+	f.buf = buf
+	// Trailing spaces before keep are never trimmed. Text is kept unless
+	// an empty verb follows it, then the trim may reach back to sep.
+	keep := buf.Len()
+	sep := keep
+
+	for i := range f.tokens {
+		t := &f.tokens[i]
+		start := buf.Len()
+		switch t.kind {
+		case patternText:
+			buf.AppendString(t.text)
+			sep, keep = keep, buf.Len()
+			continue
+		case patternTime:
+			if e.Time.IsZero() {
+				break
+			}
+			if t.text != "" {
+				buf.Data = e.Time.AppendFormat(buf.Data, t.text)
+			} else {
+				f.EncodeTime(e.Time, f)
+			}
+		case patternLevel:
+			if t.level != nil {
+				t.level(e.Level, f)
+			} else {
+				f.EncodeLevel(e.Level, f)
+			}
+		case patternName:
+			appendTextEscaped(buf, e.LoggerName, false, false)
+		case patternMsg:
+			appendTextEscaped(buf, e.Text, false, false)
+		case patternFields:
+			if err := f.encodeFields(buf, e, t.json); err != nil {
+				return err
+			}
+		case patternCaller:
+			if e.CallerPC != 0 {
+				f.EncodeCaller(e.CallerPC, f)
+			}
+		}
+		empty := buf.Len() == start
+		if t.min != 0 || t.max != 0 {
+			f.align(start, t)
+		}
+		if empty {
+			keep = sep
+		} else {
+			keep, sep = buf.Len(), buf.Len()
+		}
+	}
+
+	// Trim trailing spaces left by empty verbs.
+	end := buf.Len()
+	for end > keep && buf.Data[end-1] == ' ' {
+		end--
+	}
+	buf.Truncate(end)
+	buf.AppendByte('\n')
+	return nil
+}
+
+// encodeFields writes the Bag and entry fields as logfmt or as a JSON
+// object, or nothing if there are none.
+func (f *patternEncoder) encodeFields(buf *Buffer, e Entry, json bool) error {
+	start := buf.Len()
+	if json {
+		err := f.json.encode(buf, e)
+		f.json.buf = nil
+		if err != nil {
+			return err
+		}
+		// Drop the newline, and the object if it is empty.
+		buf.Truncate(buf.Len() - 1)
+		if buf.Len()-start == 2 {
+			buf.Truncate(start)
+		}
+		return nil
+	}
+
+	err := f.logfmt.encode(buf, e)
+	f.logfmt.buf = nil
+	f.logfmt.prefix = f.logfmt.prefix[:0]
+	f.logfmt.index = -1
+	if err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+// align pads or cuts the output of a token written since start.
+func (f *patternEncoder) align(start int, t *patternToken) {
+	buf := f.buf
+	n := utf8.RuneCount(buf.Data[start:])
+	if t.max != 0 && n > t.max {
+		i := start
+		for r := 0; r < t.max; r++ {
+			_, size := utf8.DecodeRune(buf.Data[i:])
+			i += size
+		}
+		buf.Truncate(i)
+		n = t.max
+	}
+	if n >= t.min {
+		return
+	}
+
+	pad := t.min - n
+	end := buf.Len()
+	buf.ExtendBytes(pad)
+	if t.right {
+		copy(buf.Data[start+pad:], buf.Data[start:end])
+		end = start
+	}
+	for i := end; i < end+pad; i++ {
+		buf.Data[i] = ' '
+	}
+}
+
+// --- TypeEncoder ---
+//
+// Type-level methods write values of the built-in verbs as plain text.
+// They are called by the time, level and caller encoders; containers
+// are rendered as JSON.
+
+func (f *patternEncoder) EncodeTypeAny(v interface{}) {
+	f.json.TypeEncoder(f.buf).EncodeTypeAny(v)
+}
+
+func (f *patternEncoder) EncodeTypeBool(v bool) {
+	f.buf.AppendBool(v)
+}
+
+func (f *patternEncoder) EncodeTypeInt64(v int64) {
+	f.buf.AppendInt(v)
+}
+
+func (f *patternEncoder) EncodeTypeUint64(v uint64) {
+	f.buf.AppendUint(v)
+}
+
+func (f *patternEncoder) EncodeTypeFloat64(v float64) {
+	f.buf.AppendFloat64(v)
+}
+
+func (f *patternEncoder) EncodeTypeDuration(v time.Duration) {
+	f.EncodeDuration(v, f)
+}
+
+func (f *patternEncoder) EncodeTypeTime(v time.Time) {
+	f.EncodeTime(v, f)
+}
+
+func (f *patternEncoder) EncodeTypeString(v string) {
+	f.buf.AppendString(v)
+}
+
+func (f *patternEncoder) EncodeTypeUnsafeBytes(v unsafe.Pointer) {
+	f.buf.AppendBytes(*(*[]byte)(v))
+}
+
+func (f *patternEncoder) EncodeTypeBytes(v []byte) {
+	base64.StdEncoding.Encode(f.buf.ExtendBytes(base64.StdEncoding.EncodedLen(len(v))), v)
+}
+
+func (f *patternEncoder) EncodeTypeStrings(v []string) {
+	f.json.TypeEncoder(f.buf).EncodeTypeStrings(v)
+}
+
+func (f *patternEncoder) EncodeTypeInts64(v []int64) {
+	f.json.TypeEncoder(f.buf).EncodeTypeInts64(v)
+}
+
+func (f *patternEncoder) EncodeTypeFloats64(v []float64) {
+	f.json.TypeEncoder(f.buf).EncodeTypeFloats64(v)
+}
+
+func (f *patternEncoder) EncodeTypeDurations(v []time.Duration) {
+	f.json.TypeEncoder(f.buf).EncodeTypeDurations(v)
+}
+
+func (f *patternEncoder) EncodeTypeArray(v ArrayEncoder) {
+	f.json.TypeEncoder(f.buf).EncodeTypeArray(v)
+}
+
+func (f *patternEncoder) EncodeTypeObject(v ObjectEncoder) {
+	f.json.TypeEncoder(f.buf).EncodeTypeObject(v)
+}
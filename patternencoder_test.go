@@ -0,0 +1,162 @@
+package logf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatternEncoder(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	bag := NewBag(String("service", "api")).WithGroup("http")
+	cases := []struct {
+		name   string
+		layout string
+		entry  Entry
+		golden string
+	}{
+		{
+			"Example",
+			"%time{15:04:05.000} %level{upper,5} [%name] %msg %fields",
+			Entry{Level: LevelInfo, Time: ts, LoggerName: "http", Text: "request served", LoggerBag: bag, Fields: []Field{Int("status", 200)}},
+			"10:00:00.123 INFO  [http] request served service=api http.status=200\n",
+		},
+		{
+			"Hooks",
+			"%time %level %msg",
+			Entry{Level: LevelWarn, Time: ts, Text: "m"},
+			"2024-05-01T10:00:00Z warn m\n",
+		},
+		{
+			"LevelOptions",
+			"%level{upper}|%level{lower}|%level{short}|%level{>7}",
+			Entry{Level: LevelError},
+			"ERROR|error|ERR|  error\n",
+		},
+		{
+			"Width",
+			"[%name{8}][%name{>8}][%name{.3}][%name{>6.4}][%msg{3}]",
+			Entry{LoggerName: "привет", Text: "longer"},
+			"[привет  ][  привет][при][  прив][longer]\n",
+		},
+		{
+			"Escape",
+			"100%% %msg",
+			Entry{Text: "done"},
+			"100% done\n",
+		},
+		{
+			"ControlCharacters",
+			"[%name] %msg %fields",
+			Entry{LoggerName: "a\x1b[31m", Text: "line1\nline2\r\x1b[2J", Fields: []Field{String("s", "x\ny")}},
+			`[a\u001b[31m] line1\nline2\r\u001b[2J s="x\ny"` + "\n",
+		},
+		{
+			"EmptyVerbs",
+			"%time %msg %fields %caller",
+			Entry{Text: "m"},
+			" m\n",
+		},
+		{
+			"TrailingSpacesKept",
+			"%msg %caller{10}",
+			Entry{Text: "m  "},
+			"m  \n",
+		},
+		{
+			"TrailingLiteralKept",
+			"%level{<7}%caller| ",
+			Entry{Level: LevelInfo},
+			"info   | \n",
+		},
+		{
+			"FieldsJSON",
+			"%msg %fields{json}",
+			Entry{Text: "m", LoggerBag: bag, Fields: []Field{Strings("tags", []string{"a"}), NamedError("err", errors.New("x"))}},
+			`m {"service":"api","http":{"tags":["a"],"err":"x"}}` + "\n",
+		},
+		{
+			"FieldsJSONEmpty",
+			"%msg %fields{json}",
+			Entry{Text: "m"},
+			"m\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			enc := Pattern(c.layout).Build()
+			b, err := enc.Encode(c.entry)
+			require.NoError(t, err)
+			assert.Equal(t, c.golden, b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestPatternEncoderFieldsBagCache(t *testing.T) {
+	// Both formats of %fields cache the logger Bag in their own slot.
+	enc := withCacheSlot(Pattern("%fields | %fields{json}").Build())
+	e := Entry{LoggerBag: NewBag(String("service", "api")).WithGroup("http"), Fields: []Field{Int("status", 200)}}
+	for i := 0; i < 2; i++ {
+		b, err := enc.Encode(e)
+		require.NoError(t, err)
+		assert.Equal(t, `service=api http.status=200 | {"service":"api","http":{"status":200}}`+"\n", b.String())
+		b.Free()
+	}
+}
+
+func TestPatternEncoderCaller(t *testing.T) {
+	enc := Pattern("%msg %caller{>40}").Build()
+	b, err := enc.Encode(Entry{Text: "m", CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	assert.Regexp(t, `^m +\S+/patternencoder_test.go:\d+\n$`, b.String())
+	assert.Len(t, b.String(), len("m ")+40+1)
+	b.Free()
+
+	// Structured callers are written as JSON.
+	enc = Pattern("%caller").EncodeCaller(ECSCallerEncoder).Build()
+	b, err = enc.Encode(Entry{CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	assert.Regexp(t, `^\{"file.name":"\S+/patternencoder_test.go","file.line":\d+,"function":"\S+"\}\n$`, b.String())
+	b.Free()
+}
+
+func TestPatternEncoderHooks(t *testing.T) {
+	e, err := NewPatternEncoder(PatternEncoderConfig{
+		Layout:         "%time %level %fields",
+		EncodeTime:     UnixNanoTimeEncoder,
+		EncodeLevel:    ShortTextLevelEncoder,
+		EncodeDuration: NanoDurationEncoder,
+	})
+	require.NoError(t, err)
+	b, err := e.Encode(Entry{Level: LevelInfo, Time: time.Unix(0, 42), Fields: []Field{Duration("d", time.Second)}})
+	require.NoError(t, err)
+	assert.Equal(t, "42 INF d=1000000000\n", b.String())
+	b.Free()
+
+	e, err = NewPatternEncoder(PatternEncoderConfig{})
+	require.NoError(t, err)
+	b, err = e.Encode(Entry{Level: LevelInfo, Time: time.Unix(0, 0).UTC(), Text: "m", Fields: []Field{Int("n", 1)}})
+	require.NoError(t, err)
+	assert.Equal(t, "1970-01-01T00:00:00Z INFO  m n=1\n", b.String())
+	b.Free()
+}
+
+func TestPatternEncoderInvalid(t *testing.T) {
+	for _, layout := range []string{
+		"%unknown",
+		"%msg{",
+		"%msg{upper}",
+		"%level{5.0}",
+		"%name{>x}",
+		"%",
+	} {
+		_, err := NewPatternEncoder(PatternEncoderConfig{Layout: layout})
+		assert.Error(t, err, layout)
+	}
+	assert.Panics(t, func() { Pattern("%nope").Build() })
+}
@@ -0,0 +1,779 @@
+package logf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+const (
+	// prettyDefaultWidth is the wrap width when neither Width nor the
+	// COLUMNS environment variable is set.
+	prettyDefaultWidth = 120
+
+	// prettyMsgWidth is the column the message is padded to, so that the
+	// fields of short entries line up.
+	prettyMsgWidth = 40
+
+	// prettyMaxInlineFields is the largest number of fields written on
+	// the entry line; entries with more fields get one field per line.
+	prettyMaxInlineFields = 6
+
+	// prettyMaxNameWidth caps the logger name column.
+	prettyMaxNameWidth = 24
+
+	// prettyIndent indents the fields of multi-line entries.
+	prettyIndent = 4
+
+	// prettyMinWrap is the narrowest a wrapped value gets.
+	prettyMinWrap = 20
+)
+
+// prettyEncoder is the text encoder in pretty mode. Entries that fit the
+// terminal are written on one line with an aligned message column; larger
+// ones get one field per line with nested objects and groups indented.
+type prettyEncoder struct {
+	TextEncoderConfig
+	pool      *sync.Pool
+	eseq      escSeq
+	jsonTEF   TypeEncoderFactory
+	nameWidth *atomic.Int32 // shared by clones, so that names line up
+	width     int
+
+	// text writes the time, the level and the caller.
+	text *textEncoder
+
+	buf        *Buffer
+	block      bool          // one field per line
+	depth      int           // nesting of indented objects and groups
+	objDepth   int           // nesting of {...} objects on a line
+	arrayDepth int           // nesting of [...] arrays
+	prefix     []byte        // dotted group prefix on the entry line
+	levels     []prettyLevel // open containers
+	groups     []prettyGroup // open groups
+	pending    bool          // a key is written and its value is due
+	colon      bool          // the pending key ends with ':', not '='
+	count      int           // top-level fields
+	multiline  bool          // a value spans several lines
+	names      []string      // open group names for ReplaceField
+}
+
+// prettyLevel is an open container: the entry, an object, a group or an
+// array.
+type prettyLevel struct {
+	array bool
+	first bool
+}
+
+type prettyGroupKind int8
+
+const (
+	prettyGroupPrefix prettyGroupKind = iota // "group.key=" on the entry line
+	prettyGroupInline                        // "group={...}" inside an object
+	prettyGroupBlock                         // an indented block
+)
+
+type prettyGroup struct {
+	kind      prettyGroupKind
+	prefixLen int
+}
+
+func buildPrettyEncoder(cfg TextEncoderConfig, jsonTEF TypeEncoderFactory) Encoder {
+	width := cfg.Width
+	if width <= 0 {
+		width = prettyDefaultWidth
+		if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+			width = n
+		}
+	}
+	enc := &prettyEncoder{
+		TextEncoderConfig: cfg,
+		eseq:              escSeq{noColor: cfg.NoColor},
+		jsonTEF:           jsonTEF,
+		nameWidth:         new(atomic.Int32),
+		width:             width,
+	}
+	enc.pool = &sync.Pool{New: func() any {
+		return enc.Clone()
+	}}
+	return enc
+}
+
+func (f *prettyEncoder) Clone() Encoder {
+	return &prettyEncoder{
+		TextEncoderConfig: f.TextEncoderConfig,
+		pool:              f.pool,
+		eseq:              f.eseq,
+		jsonTEF:           f.jsonTEF,
+		nameWidth:         f.nameWidth,
+		width:             f.width,
+		text: &textEncoder{
+			TextEncoderConfig: f.TextEncoderConfig,
+			eseq:              f.eseq,
+			jsonTEF:           f.jsonTEF,
+		},
+	}
+}
+
+func (f *prettyEncoder) Encode(e Entry) (*Buffer, error) {
+	clone := f.pool.Get().(*prettyEncoder)
+
+	buf := GetBuffer()
+	err := clone.encode(buf, e)
+
+	clone.buf = nil
+	clone.text.buf = nil
+	f.pool.Put(clone)
+
+	if err != nil {
+		buf.Free()
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (f *prettyEncoder) encode(buf *Buffer, e Entry) error {
+	f.buf = buf
+	start := buf.Len()
+	f.text.buf = buf
+	f.text.startBufLen = start
+	f.text.extra = f.text.extra[:0]
+	if f.ReplaceField != nil {
+		e = f.text.replaceBuiltins(e)
+	}
+
+	// Time and level.
+	if !f.DisableFieldTime && !e.Time.IsZero() {
+		f.eseq.dim(buf, func() {
+			f.text.appendTime(e.Time)
+		})
+	}
+	if !f.DisableFieldLevel {
+		f.text.appendSeparator()
+		f.text.appendLevel(e.Level)
+	}
+
+	// Logger name, padded to the widest name seen so far.
+	if !f.DisableFieldName {
+		width := int(f.nameWidth.Load())
+		if e.LoggerName != "" {
+			f.text.appendSeparator()
+			nameStart := buf.Len()
+			f.eseq.dimItalic(buf, func() {
+				f.text.appendText(e.LoggerName, false)
+				buf.AppendByte(':')
+			})
+			n := visibleWidth(buf.Data[nameStart:])
+			for n > width && n <= prettyMaxNameWidth {
+				if f.nameWidth.CompareAndSwap(int32(width), int32(n)) {
+					width = n
+					break
+				}
+				width = int(f.nameWidth.Load())
+			}
+			f.appendSpaces(width - n)
+		} else if width > 0 {
+			f.text.appendSeparator()
+			f.appendSpaces(width)
+		}
+	}
+
+	// Message.
+	msgStart := -1
+	if !f.DisableFieldMsg && e.Text != "" {
+		f.text.appendSeparator()
+		msgStart = buf.Len()
+		mc := msgColor(e.Level)
+		if mc == escDefault {
+			f.eseq.at(buf, escBold, func() {
+				f.text.appendText(e.Text, f.Multiline)
+			})
+		} else {
+			f.eseq.at2(buf, escBold, mc, func() {
+				f.text.appendText(e.Text, f.Multiline)
+			})
+		}
+	}
+
+	// Skip trailing groups that would produce empty output.
+	loggerBag := e.LoggerBag
+	ctxBag := e.Bag
+	if len(e.Fields) == 0 {
+		loggerBag = skipTrailingGroups(loggerBag)
+		if !bagHasFields(loggerBag) {
+			ctxBag = skipTrailingGroups(ctxBag)
+		}
+	}
+	if len(e.Fields) == 0 && len(f.text.extra) == 0 && !bagHasFields(loggerBag) && !bagHasFields(ctxBag) {
+		f.appendCaller(e.CallerPC)
+		buf.AppendByte('\n')
+		return nil
+	}
+
+	// Try the entry line first.
+	mark := buf.Len()
+	f.text.appendSeparator()
+	f.eseq.dim(buf, func() {
+		buf.AppendString("›")
+	})
+	buf.AppendByte(' ')
+	f.block = false
+	f.encodeFields(ctxBag, loggerBag, e.Fields)
+	f.appendCaller(e.CallerPC)
+
+	width := visibleWidth(buf.Data[start:])
+	switch {
+	case f.multiline || f.count > prettyMaxInlineFields || width > f.width:
+		// Fall back to one field per line.
+		buf.Truncate(mark)
+		f.appendCaller(e.CallerPC)
+		f.block = true
+		f.encodeFields(ctxBag, loggerBag, e.Fields)
+	case msgStart >= 0:
+		// Line the fields up when there is room for it.
+		pad := prettyMsgWidth - visibleWidth(buf.Data[msgStart:mark])
+		if pad > f.width-width {
+			pad = f.width - width
+		}
+		f.insertSpaces(mark, pad)
+	}
+
+	buf.AppendByte('\n')
+	return nil
+}
+
+func (f *prettyEncoder) encodeFields(ctxBag, loggerBag *Bag, fs []Field) {
+	f.depth, f.objDepth, f.arrayDepth = 0, 0, 0
+	f.prefix = f.prefix[:0]
+	f.levels = append(f.levels[:0], prettyLevel{first: true})
+	f.groups = f.groups[:0]
+	f.pending = false
+	f.count = 0
+	f.multiline = false
+	f.names = f.names[:0]
+
+	// Built-ins that ReplaceField turned into regular fields.
+	for i := range f.text.extra {
+		f.text.extra[i].Accept(f)
+	}
+	f.encodeBag(ctxBag)
+	f.encodeBag(loggerBag)
+	for i := range fs {
+		f.acceptField(fs[i])
+	}
+	for len(f.groups) != 0 {
+		f.closeGroup()
+	}
+}
+
+func (f *prettyEncoder) encodeBag(bag *Bag) {
+	if bag == nil {
+		return
+	}
+	f.encodeBag(bag.parent)
+	if bag.group != "" {
+		f.openGroup(bag.group)
+		return
+	}
+	for _, field := range bag.fields {
+		f.acceptField(field)
+	}
+}
+
+func (f *prettyEncoder) appendCaller(pc uintptr) {
+	if f.DisableFieldCaller || pc == 0 {
+		return
+	}
+	f.text.appendSeparator()
+	f.eseq.dimItalic(f.buf, func() {
+		f.buf.AppendString("→ ")
+		f.EncodeCaller(pc, f.text)
+	})
+}
+
+// --- TypeEncoder ---
+
+func (f *prettyEncoder) EncodeTypeAny(v interface{}) {
+	f.beginValue()
+	start := f.buf.Len()
+	f.jsonTEF.TypeEncoder(f.buf).EncodeTypeAny(v)
+	if f.inline() || f.column() <= f.width {
+		return
+	}
+
+	// Too wide: indent the JSON under its key.
+	var out bytes.Buffer
+	prefix := strings.Repeat(" ", prettyIndent+2*f.depth)
+	if json.Indent(&out, f.buf.Data[start:], prefix, "  ") == nil {
+		f.buf.Truncate(start)
+		f.buf.AppendBytes(out.Bytes())
+	}
+}
+
+func (f *prettyEncoder) EncodeTypeBool(v bool) {
+	f.beginValue()
+	f.eseq.at(f.buf, escGreen, func() {
+		f.buf.AppendBool(v)
+	})
+}
+
+func (f *prettyEncoder) EncodeTypeInt64(v int64) {
+	f.beginValue()
+	f.eseq.at(f.buf, escGreen, func() {
+		f.buf.AppendInt(v)
+	})
+}
+
+func (f *prettyEncoder) EncodeTypeUint64(v uint64) {
+	f.beginValue()
+	f.eseq.at(f.buf, escGreen, func() {
+		f.buf.AppendUint(v)
+	})
+}
+
+func (f *prettyEncoder) EncodeTypeFloat64(v float64) {
+	f.beginValue()
+	f.eseq.at(f.buf, escGreen, func() {
+		switch {
+		case math.IsNaN(v):
+			f.buf.AppendString("NaN")
+		case math.IsInf(v, 1):
+			f.buf.AppendString("+Inf")
+		case math.IsInf(v, -1):
+			f.buf.AppendString("-Inf")
+		default:
+			f.buf.AppendFloat64(v)
+		}
+	})
+}
+
+func (f *prettyEncoder) EncodeTypeDuration(v time.Duration) {
+	f.EncodeDuration(v, f)
+}
+
+func (f *prettyEncoder) EncodeTypeTime(v time.Time) {
+	f.EncodeTime(v, f)
+}
+
+func (f *prettyEncoder) EncodeTypeString(v string) {
+	if strings.IndexByte(v, '\n') >= 0 {
+		f.multiline = true
+		if !f.inline() {
+			// A stack trace or the like: one indented line per line.
+			f.pending = false
+			f.appendLines(v)
+			return
+		}
+	}
+
+	f.beginValue()
+	if !f.inline() {
+		if v == "" {
+			f.buf.AppendString(`""`)
+			return
+		}
+		f.appendWrapped(v)
+		return
+	}
+
+	needsQuote := v == ""
+	for i := 0; i < len(v); i++ {
+		if v[i] <= ' ' || v[i] == '"' || v[i] == '\\' {
+			needsQuote = true
+			break
+		}
+	}
+	if needsQuote {
+		f.buf.AppendByte('"')
+		f.appendString(v, true)
+		f.buf.AppendByte('"')
+		return
+	}
+	f.appendString(v, false)
+}
+
+func (f *prettyEncoder) EncodeTypeStrings(v []string) {
+	f.beginValue()
+	f.openArray()
+	for _, s := range v {
+		f.EncodeTypeString(s)
+	}
+	f.closeArray()
+}
+
+func (f *prettyEncoder) EncodeTypeBytes(v []byte) {
+	f.beginValue()
+	f.buf.AppendByte('"')
+	base64.StdEncoding.Encode(f.buf.ExtendBytes(base64.StdEncoding.EncodedLen(len(v))), v)
+	f.buf.AppendByte('"')
+}
+
+func (f *prettyEncoder) EncodeTypeInts64(v []int64) {
+	f.beginValue()
+	f.openArray()
+	for _, n := range v {
+		f.EncodeTypeInt64(n)
+	}
+	f.closeArray()
+}
+
+func (f *prettyEncoder) EncodeTypeFloats64(v []float64) {
+	f.beginValue()
+	f.openArray()
+	for _, n := range v {
+		f.EncodeTypeFloat64(n)
+	}
+	f.closeArray()
+}
+
+func (f *prettyEncoder) EncodeTypeDurations(v []time.Duration) {
+	f.beginValue()
+	f.openArray()
+	for _, d := range v {
+		f.EncodeTypeDuration(d)
+	}
+	f.closeArray()
+}
+
+func (f *prettyEncoder) EncodeTypeArray(v ArrayEncoder) {
+	f.beginValue()
+	f.openArray()
+	_ = v.EncodeLogfArray(f)
+	f.closeArray()
+}
+
+func (f *prettyEncoder) EncodeTypeObject(v ObjectEncoder) {
+	if f.inline() {
+		f.beginValue()
+		f.buf.AppendByte('{')
+		f.levels = append(f.levels, prettyLevel{first: true})
+		f.objDepth++
+		_ = v.EncodeLogfObject(f)
+		f.objDepth--
+		f.levels = f.levels[:len(f.levels)-1]
+		f.buf.AppendByte('}')
+		return
+	}
+
+	f.pending = false
+	f.depth++
+	f.levels = append(f.levels, prettyLevel{first: true})
+	_ = v.EncodeLogfObject(f)
+	f.closeBlock()
+}
+
+func (f *prettyEncoder) EncodeTypeUnsafeBytes(v unsafe.Pointer) {
+	f.EncodeTypeString(*(*string)(v))
+}
+
+// --- FieldEncoder ---
+
+func (f *prettyEncoder) addKey(k string) {
+	if f.objDepth == 0 && f.arrayDepth == 0 {
+		f.count++
+	}
+	l := &f.levels[len(f.levels)-1]
+	first := l.first
+	l.first = false
+
+	if f.inline() {
+		if !first {
+			f.buf.AppendByte(' ')
+		}
+		f.eseq.at2(f.buf, escBrightBlue, escItalic, func() {
+			if f.objDepth == 0 && len(f.prefix) != 0 {
+				f.text.appendText(*(*string)(unsafe.Pointer(&f.prefix)), false)
+			}
+			f.text.appendText(k, false)
+		})
+		f.eseq.dim(f.buf, func() {
+			f.buf.AppendByte('=')
+		})
+		f.pending, f.colon = true, false
+		return
+	}
+
+	f.buf.AppendByte('\n')
+	f.appendSpaces(prettyIndent + 2*f.depth)
+	f.eseq.at2(f.buf, escBrightBlue, escItalic, func() {
+		f.text.appendText(k, false)
+	})
+	f.eseq.dim(f.buf, func() {
+		f.buf.AppendByte(':')
+	})
+	f.pending, f.colon = true, true
+}
+
+func (f *prettyEncoder) EncodeFieldAny(k string, v interface{}) { f.addKey(k); f.EncodeTypeAny(v) }
+func (f *prettyEncoder) EncodeFieldBool(k string, v bool)       { f.addKey(k); f.EncodeTypeBool(v) }
+func (f *prettyEncoder) EncodeFieldInt64(k string, v int64)     { f.addKey(k); f.EncodeTypeInt64(v) }
+func (f *prettyEncoder) EncodeFieldUint64(k string, v uint64)   { f.addKey(k); f.EncodeTypeUint64(v) }
+func (f *prettyEncoder) EncodeFieldFloat64(k string, v float64) { f.addKey(k); f.EncodeTypeFloat64(v) }
+func (f *prettyEncoder) EncodeFieldDuration(k string, v time.Duration) {
+	f.addKey(k)
+	f.EncodeTypeDuration(v)
+}
+func (f *prettyEncoder) EncodeFieldTime(k string, v time.Time)   { f.addKey(k); f.EncodeTypeTime(v) }
+func (f *prettyEncoder) EncodeFieldString(k string, v string)    { f.addKey(k); f.EncodeTypeString(v) }
+func (f *prettyEncoder) EncodeFieldStrings(k string, v []string) { f.addKey(k); f.EncodeTypeStrings(v) }
+func (f *prettyEncoder) EncodeFieldBytes(k string, v []byte)     { f.addKey(k); f.EncodeTypeBytes(v) }
+func (f *prettyEncoder) EncodeFieldInts64(k string, v []int64)   { f.addKey(k); f.EncodeTypeInts64(v) }
+func (f *prettyEncoder) EncodeFieldFloats64(k string, v []float64) {
+	f.addKey(k)
+	f.EncodeTypeFloats64(v)
+}
+func (f *prettyEncoder) EncodeFieldDurations(k string, v []time.Duration) {
+	f.addKey(k)
+	f.EncodeTypeDurations(v)
+}
+func (f *prettyEncoder) EncodeFieldArray(k string, v ArrayEncoder) { f.addKey(k); f.EncodeTypeArray(v) }
+
+func (f *prettyEncoder) EncodeFieldObject(k string, v ObjectEncoder) {
+	if k == "" {
+		_ = v.EncodeLogfObject(f)
+		return
+	}
+	f.addKey(k)
+	f.EncodeTypeObject(v)
+}
+
+func (f *prettyEncoder) EncodeFieldGroup(k string, fs []Field) {
+	if k == "" {
+		for _, field := range fs {
+			f.acceptField(field)
+		}
+		return
+	}
+	f.openGroup(k)
+	for _, field := range fs {
+		f.acceptField(field)
+	}
+	f.closeGroup()
+}
+
+func (f *prettyEncoder) EncodeFieldError(k string, v error) {
+	f.EncodeError(k, v, f)
+}
+
+// --- helpers ---
+
+// inline reports whether values are written on the current line: always
+// on the entry line, and inside arrays otherwise.
+func (f *prettyEncoder) inline() bool {
+	return !f.block || f.arrayDepth != 0
+}
+
+// beginValue writes what goes before a value: the space after "key:" or
+// the separator between array elements.
+func (f *prettyEncoder) beginValue() {
+	if f.pending {
+		f.pending = false
+		if f.colon {
+			f.buf.AppendByte(' ')
+		}
+		return
+	}
+	l := &f.levels[len(f.levels)-1]
+	if !l.array {
+		return
+	}
+	if !l.first {
+		f.buf.AppendString(", ")
+	}
+	l.first = false
+}
+
+func (f *prettyEncoder) openArray() {
+	f.buf.AppendByte('[')
+	f.levels = append(f.levels, prettyLevel{array: true, first: true})
+	f.arrayDepth++
+}
+
+func (f *prettyEncoder) closeArray() {
+	f.arrayDepth--
+	f.levels = f.levels[:len(f.levels)-1]
+	f.buf.AppendByte(']')
+}
+
+// closeBlock closes an indented object or group, marking empty ones.
+func (f *prettyEncoder) closeBlock() {
+	l := f.levels[len(f.levels)-1]
+	f.levels = f.levels[:len(f.levels)-1]
+	f.depth--
+	if l.first {
+		f.buf.AppendString(" {}")
+	}
+}
+
+// acceptField encodes a field, passing it through ReplaceField first.
+func (f *prettyEncoder) acceptField(fd Field) {
+	if f.ReplaceField != nil && fd.Type != FieldTypeGroup {
+		fd = f.ReplaceField(f.names, fd)
+	}
+	fd.Accept(f)
+}
+
+func (f *prettyEncoder) openGroup(k string) {
+	if f.ReplaceField != nil {
+		f.names = append(f.names, k)
+	}
+	switch {
+	case f.inline() && f.objDepth == 0:
+		f.groups = append(f.groups, prettyGroup{kind: prettyGroupPrefix, prefixLen: len(f.prefix)})
+		f.prefix = append(append(f.prefix, k...), '.')
+	case f.inline():
+		f.addKey(k)
+		f.beginValue()
+		f.buf.AppendByte('{')
+		f.levels = append(f.levels, prettyLevel{first: true})
+		f.objDepth++
+		f.groups = append(f.groups, prettyGroup{kind: prettyGroupInline})
+	default:
+		f.addKey(k)
+		f.pending = false
+		f.depth++
+		f.levels = append(f.levels, prettyLevel{first: true})
+		f.groups = append(f.groups, prettyGroup{kind: prettyGroupBlock})
+	}
+}
+
+func (f *prettyEncoder) closeGroup() {
+	if f.ReplaceField != nil {
+		f.names = f.names[:len(f.names)-1]
+	}
+	g := f.groups[len(f.groups)-1]
+	f.groups = f.groups[:len(f.groups)-1]
+	switch g.kind {
+	case prettyGroupPrefix:
+		f.prefix = f.prefix[:g.prefixLen]
+	case prettyGroupInline:
+		f.objDepth--
+		f.levels = f.levels[:len(f.levels)-1]
+		f.buf.AppendByte('}')
+	default:
+		f.closeBlock()
+	}
+}
+
+// appendString appends s, escaped unless escaping is disabled.
+func (f *prettyEncoder) appendString(s string, quoted bool) {
+	switch {
+	case f.DisableEscaping && quoted:
+		_ = EscapeString(f.buf, s)
+	case f.DisableEscaping:
+		f.buf.AppendString(s)
+	default:
+		appendTextEscaped(f.buf, s, quoted, false)
+	}
+}
+
+// appendLines writes each line of s on its own line, indented under the
+// key, with tabs expanded.
+func (f *prettyEncoder) appendLines(s string) {
+	s = strings.TrimSuffix(s, "\n")
+	indent := prettyIndent + 2*(f.depth+1)
+	for {
+		line := s
+		i := strings.IndexByte(s, '\n')
+		if i >= 0 {
+			line = s[:i]
+		}
+		f.buf.AppendByte('\n')
+		f.appendSpaces(indent)
+		for {
+			j := strings.IndexByte(line, '\t')
+			if j < 0 {
+				break
+			}
+			f.appendString(line[:j], false)
+			f.buf.AppendString("    ")
+			line = line[j+1:]
+		}
+		f.appendString(line, false)
+		if i < 0 {
+			return
+		}
+		s = s[i+1:]
+	}
+}
+
+// appendWrapped writes s, continuing it on indented lines where it would
+// run past the wrap width.
+func (f *prettyEncoder) appendWrapped(s string) {
+	avail := f.width - f.column()
+	if avail < prettyMinWrap {
+		avail = prettyMinWrap
+	}
+	indent := prettyIndent + 2*(f.depth+1)
+	for {
+		i, n := 0, 0
+		for i < len(s) && n < avail {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+			n++
+		}
+		f.appendString(s[:i], false)
+		s = s[i:]
+		if s == "" {
+			return
+		}
+		f.buf.AppendByte('\n')
+		f.appendSpaces(indent)
+		avail = f.width - indent
+		if avail < prettyMinWrap {
+			avail = prettyMinWrap
+		}
+	}
+}
+
+// column returns the visible width of the current line.
+func (f *prettyEncoder) column() int {
+	return visibleWidth(f.buf.Data[bytes.LastIndexByte(f.buf.Data, '\n')+1:])
+}
+
+// insertSpaces inserts n spaces at offset i.
+func (f *prettyEncoder) insertSpaces(i, n int) {
+	if n <= 0 {
+		return
+	}
+	f.buf.ExtendBytes(n)
+	copy(f.buf.Data[i+n:], f.buf.Data[i:])
+	for j := i; j < i+n; j++ {
+		f.buf.Data[j] = ' '
+	}
+}
+
+func (f *prettyEncoder) appendSpaces(n int) {
+	for ; n > 0; n-- {
+		f.buf.AppendByte(' ')
+	}
+}
+
+// visibleWidth returns the number of characters in b, not counting ANSI
+// escape sequences.
+func visibleWidth(b []byte) int {
+	n := 0
+	for i := 0; i < len(b); {
+		if b[i] == 0x1b && i+1 < len(b) && b[i+1] == '[' {
+			i += 2
+			for i < len(b) && (b[i] < 0x40 || b[i] > 0x7e) {
+				i++
+			}
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRune(b[i:])
+		i += size
+		n++
+	}
+	return n
+}
@@ -0,0 +1,206 @@
+package logf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prettyStackError formats like errors with stack traces do.
+type prettyStackError struct{}
+
+func (prettyStackError) Error() string { return "boom" }
+
+func (e prettyStackError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		fmt.Fprint(s, "boom\nmain.handler\n\t/src/main.go:42\nmain.main\n\t/src/main.go:10\n")
+		return
+	}
+	fmt.Fprint(s, e.Error())
+}
+
+func prettyEncode(t *testing.T, enc Encoder, e Entry) string {
+	t.Helper()
+	b, err := enc.Encode(e)
+	require.NoError(t, err)
+	defer b.Free()
+	return b.String()
+}
+
+func TestPrettyEncoder(t *testing.T) {
+	testCases := []struct {
+		name   string
+		entry  Entry
+		golden string
+	}{
+		{
+			"Header",
+			Entry{Level: LevelInfo, Text: "started"},
+			"[INF] started\n",
+		},
+		{
+			"Inline",
+			Entry{Level: LevelInfo, Text: "served", Fields: []Field{
+				Int("status", 200), String("path", "/a b"), Strings("tags", []string{"a", "b"}),
+			}},
+			"[INF] served                                   › status=200 path=\"/a b\" tags=[a, b]\n",
+		},
+		{
+			"InlineObjectsAndGroups",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Object("u", testObjectEncoder{}), Group("http", Int("status", 200)), Array("a", testArrayEncoder{}),
+			}},
+			"[INF] m                                       › u={username=username code=42} http.status=200 a=[42]\n",
+		},
+		{
+			"TooManyFields",
+			Entry{Level: LevelWarn, Text: "m", Fields: []Field{
+				Int("a", 1), Int("b", 2), Int("c", 3), Int("d", 4), Int("e", 5), Int("f", 6), Int("g", 7),
+			}},
+			"[WRN] m\n    a: 1\n    b: 2\n    c: 3\n    d: 4\n    e: 5\n    f: 6\n    g: 7\n",
+		},
+		{
+			"Nested",
+			Entry{Level: LevelError, Text: "m", Fields: []Field{
+				String("s", "x"), Group("g", Object("u", testObjectEncoder{}), Group("empty")),
+				Array("objs", testObjectsArray{}), String("multi", "a\nb"),
+			}},
+			"[ERR] m\n    s: x\n    g:\n      u:\n        username: username\n        code: 42\n      empty: {}\n" +
+				"    objs: [{username=username code=42}]\n    multi:\n      a\n      b\n",
+		},
+		{
+			"Stack",
+			Entry{Level: LevelError, Text: "failed", Fields: []Field{NamedError("error", prettyStackError{})}},
+			"[ERR] failed\n    error: boom\n    error.verbose:\n      boom\n      main.handler\n" +
+				"          /src/main.go:42\n      main.main\n          /src/main.go:10\n",
+		},
+		{
+			"Bags",
+			Entry{
+				Level:     LevelInfo,
+				Text:      "m",
+				Bag:       NewBag(String("request_id", "r1")),
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http"),
+				Fields:    []Field{Int("status", 200)},
+			},
+			"[INF] m                                        › request_id=r1 service=api http.status=200\n",
+		},
+		{
+			"BagsBlock",
+			Entry{
+				Level:     LevelInfo,
+				Text:      "m",
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http"),
+				Fields:    []Field{String("body", "a\nb")},
+			},
+			"[INF] m\n    service: api\n    http:\n      body:\n        a\n        b\n",
+		},
+		{
+			"TrailingGroups",
+			Entry{Level: LevelInfo, Text: "m", LoggerBag: NewBag(String("service", "api")).WithGroup("http")},
+			"[INF] m                                        › service=api\n",
+		},
+		{
+			"Escaping",
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				String("s", "\x1b[31mred"), Int("a", 1), Int("b", 2), Int("c", 3), Int("d", 4), Int("e", 5), Int("f", 6),
+			}},
+			"[INF] m\n    s: \\u001b[31mred\n    a: 1\n    b: 2\n    c: 3\n    d: 4\n    e: 5\n    f: 6\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc := Text().Pretty().Width(100).NoColor().DisableTime().Build()
+			assert.Equal(t, tc.golden, prettyEncode(t, enc, tc.entry))
+		})
+	}
+}
+
+// testObjectsArray is an array of objects.
+type testObjectsArray struct{}
+
+func (testObjectsArray) EncodeLogfArray(e TypeEncoder) error {
+	e.EncodeTypeObject(testObjectEncoder{})
+	return nil
+}
+
+func TestPrettyEncoderWidth(t *testing.T) {
+	enc := Text().Pretty().Width(40).NoColor().DisableTime().Build()
+
+	// The message is padded only as far as the line allows.
+	assert.Equal(t, "[INF] m                            › a=1\n",
+		prettyEncode(t, enc, Entry{Level: LevelInfo, Text: "m", Fields: []Field{Int("a", 1)}}))
+
+	// Long entries get one field per line, long values wrap.
+	out := prettyEncode(t, enc, Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+		String("long", strings.Repeat("x", 60)),
+	}})
+	assert.Equal(t, "[INF] m\n    long: "+strings.Repeat("x", 30)+"\n      "+strings.Repeat("x", 30)+"\n", out)
+
+	// Wide Any values are indented as JSON.
+	out = prettyEncode(t, enc, Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+		Any("m", map[string]any{"first_key": strings.Repeat("v", 20), "second": []int{1}}),
+	}})
+	assert.Equal(t, "[INF] m\n    m: {\n      \"first_key\": \"vvvvvvvvvvvvvvvvvvvv\",\n"+
+		"      \"second\": [\n        1\n      ]\n    }\n", out)
+}
+
+func TestPrettyEncoderNameColumn(t *testing.T) {
+	enc := Text().Pretty().Width(100).NoColor().DisableTime().Build()
+	entries := []Entry{
+		{Level: LevelInfo, LoggerName: "http", Text: "a"},
+		{Level: LevelInfo, LoggerName: "db", Text: "b"},
+		{Level: LevelInfo, Text: "c"},
+	}
+	var out []string
+	for _, e := range entries {
+		out = append(out, prettyEncode(t, enc, e))
+	}
+	assert.Equal(t, []string{"[INF] http: a\n", "[INF] db:   b\n", "[INF]       c\n"}, out)
+
+	// Clones share the column.
+	assert.Equal(t, "[INF] db:   b\n", prettyEncode(t, enc.Clone(), entries[1]))
+}
+
+func TestPrettyEncoderCaller(t *testing.T) {
+	enc := Text().Pretty().Width(100).NoColor().DisableTime().Build()
+
+	out := prettyEncode(t, enc, Entry{Level: LevelInfo, Text: "m", CallerPC: CallerPC(0), Fields: []Field{Int("a", 1)}})
+	assert.Regexp(t, `^\[INF\] m +› a=1 → [^ ]+/prettyencoder_test.go:\d+\n$`, out)
+
+	// In multi-line entries the caller ends the first line.
+	out = prettyEncode(t, enc, Entry{Level: LevelInfo, Text: "m", CallerPC: CallerPC(0), Fields: []Field{String("a", "x\ny")}})
+	assert.Regexp(t, `^\[INF\] m → [^ ]+/prettyencoder_test.go:\d+\n    a:\n      x\n      y\n$`, out)
+}
+
+func TestPrettyEncoderColors(t *testing.T) {
+	enc := Text().Pretty().Width(100).DisableTime().Build()
+	out := prettyEncode(t, enc, Entry{Level: LevelError, Text: "m", Fields: []Field{String("a", "x\ny")}})
+	assert.Equal(t, "\x1b[0;2m[\x1b[0m\x1b[1;91mERR\x1b[0m\x1b[0;2m]\x1b[0m \x1b[1;91mm\x1b[0m\n"+
+		"    \x1b[94;3ma\x1b[0m\x1b[0;2m:\x1b[0m\n      x\n      y\n", out)
+
+	// Escape sequences do not count towards the width.
+	assert.Equal(t, 3, visibleWidth([]byte("\x1b[1;91mERR\x1b[0m")))
+}
+
+func TestNewTextEncoderPretty(t *testing.T) {
+	enc := NewTextEncoder(TextEncoderConfig{Pretty: true, NoColor: true, Width: 80, DisableFieldLevel: true})
+	assert.IsType(t, &prettyEncoder{}, enc)
+	assert.Equal(t, "m\n", prettyEncode(t, enc, Entry{Text: "m"}))
+
+	t.Setenv("COLUMNS", "60")
+	enc = Text().Pretty().Build()
+	assert.Equal(t, 60, enc.(*prettyEncoder).width)
+}
+
+func TestPrettyEncoderTime(t *testing.T) {
+	enc := Text().Pretty().Width(100).NoColor().Build()
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123e6, time.UTC)
+	assert.Equal(t, "May  1 10:00:00.123 [INF] m                                        › t=\"May  1 10:00:00.123\"\n",
+		prettyEncode(t, enc, Entry{Level: LevelInfo, Time: ts, Text: "m", Fields: []Field{Time("t", ts)}}))
+}
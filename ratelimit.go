@@ -0,0 +1,331 @@
+package logf
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRateLimitRate    = 100
+	defaultRateLimitBurst   = 100
+	defaultRateLimitMaxKeys = 1024
+)
+
+// NewRateLimitHandler returns a Handler middleware that protects the
+// pipeline from runaway call sites — think of a retry loop erroring at
+// 50k/s and pushing every useful log out of a SlabWriter. Each call site
+// gets its own token bucket (keyed by Entry.LoggerName + Entry.CallerPC
+// by default). While the bucket is empty, entries from that call site
+// are dropped and counted. Once the bucket refills, a synthetic summary
+// entry reports them:
+//
+//	suppressed 4812 entries from db/pool.go:118
+//
+// If another entry from the call site gets through first, the summary
+// precedes it; otherwise a background timer emits it, so a call site that
+// goes quiet after a burst is still reported.
+// Unlike SamplingHandler, all levels are rate limited — a flood of
+// errors is exactly what this handler is for.
+//
+// Without options each key may log 100 entries per second with a burst
+// of 100, and up to 1024 keys are tracked. When the limit is reached the
+// least recently used bucket is evicted, and the summary of its pending
+// count is emitted right away.
+//
+//	h := logf.NewRateLimitHandler(router, logf.RateLimit(10, 50))
+//	logger := logf.New(logf.NewContextHandler(h))
+//	defer h.Close()
+//
+// Call Close on shutdown to flush pending summaries. RateLimitHandler is
+// safe for concurrent use.
+func NewRateLimitHandler(next Handler, opts ...RateLimitOption) *RateLimitHandler {
+	h := &RateLimitHandler{
+		next:    next,
+		rate:    defaultRateLimitRate,
+		burst:   defaultRateLimitBurst,
+		maxKeys: defaultRateLimitMaxKeys,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.buckets = make(map[rateLimitKey]*list.Element, h.maxKeys)
+	h.lru = list.New()
+	return h
+}
+
+// RateLimitHandler is the Handler built by NewRateLimitHandler.
+type RateLimitHandler struct {
+	next    Handler
+	rate    float64 // tokens per second
+	burst   float64
+	maxKeys int
+	keyFn   func(Entry) string
+
+	mu      sync.Mutex
+	buckets map[rateLimitKey]*list.Element // → *rateLimitBucket
+	lru     *list.List                     // front = most recently used
+	passed  int64
+	dropped int64
+	evicted int64
+	closed  bool
+}
+
+// RateLimitStats is a snapshot of RateLimitHandler counters.
+type RateLimitStats struct {
+	Passed  int64 // entries passed downstream (summaries not included)
+	Dropped int64 // entries dropped because their bucket was empty
+	Evicted int64 // buckets evicted to stay within the key limit
+	Keys    int   // buckets currently tracked
+}
+
+// RateLimitOption configures a RateLimitHandler at creation time.
+type RateLimitOption func(*RateLimitHandler)
+
+// RateLimit sets the refill rate (entries per second) and the bucket
+// capacity. A key that has been quiet can log up to burst entries at
+// once, then settles at perSecond entries per second.
+func RateLimit(perSecond float64, burst int) RateLimitOption {
+	return func(h *RateLimitHandler) {
+		if perSecond > 0 {
+			h.rate = perSecond
+		}
+		if burst > 0 {
+			h.burst = float64(burst)
+		}
+	}
+}
+
+// RateLimitMaxKeys caps the number of tracked buckets (default 1024).
+// Memory stays bounded no matter how many distinct keys show up.
+func RateLimitMaxKeys(n int) RateLimitOption {
+	return func(h *RateLimitHandler) {
+		if n > 0 {
+			h.maxKeys = n
+		}
+	}
+}
+
+// RateLimitKey replaces the default LoggerName + CallerPC key with a
+// custom one. Entries that map to the same string share a bucket:
+//
+//	logf.RateLimitKey(func(e logf.Entry) string { return e.Text })
+func RateLimitKey(fn func(Entry) string) RateLimitOption {
+	return func(h *RateLimitHandler) {
+		h.keyFn = fn
+	}
+}
+
+// Enabled delegates to the downstream Handler.
+func (h *RateLimitHandler) Enabled(ctx context.Context, lvl Level) bool {
+	return h.next.Enabled(ctx, lvl)
+}
+
+// EnabledName forwards the logger name to the downstream Handler.
+func (h *RateLimitHandler) EnabledName(ctx context.Context, name string, lvl Level) bool {
+	return enabledName(h.next, ctx, name, lvl)
+}
+
+// Handle takes a token from the entry's bucket and passes the entry
+// downstream, or drops it when the bucket is empty.
+func (h *RateLimitHandler) Handle(ctx context.Context, e Entry) error {
+	key := rateLimitKey{name: e.LoggerName, pc: e.CallerPC}
+	if h.keyFn != nil {
+		key = rateLimitKey{custom: h.keyFn(e)}
+	}
+	now := entryUnixNano(e)
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return h.next.Handle(ctx, e)
+	}
+	b, evicted := h.bucket(key, now)
+	var pending []Entry
+	if evicted != nil && evicted.suppressed > 0 {
+		pending = append(pending, evicted.flush(time.Unix(0, now)))
+	}
+	b.refill(now, h.rate, h.burst)
+	if b.tokens < 1 {
+		if b.suppressed == 0 {
+			b.name, b.pc = e.LoggerName, e.CallerPC
+			b.timer = time.AfterFunc(time.Duration((1-b.tokens)/h.rate*float64(time.Second)), func() {
+				h.expire(b)
+			})
+		}
+		b.suppressed++
+		if e.Level < b.level {
+			b.level = e.Level
+		}
+		h.dropped++
+		h.mu.Unlock()
+		return h.handlePending(ctx, pending)
+	}
+	b.tokens--
+	if b.suppressed > 0 {
+		pending = append(pending, b.flush(e.Time))
+	}
+	h.passed++
+	h.mu.Unlock()
+
+	if err := h.handlePending(ctx, pending); err != nil {
+		return err
+	}
+	return h.next.Handle(ctx, e)
+}
+
+// Close emits the summaries of all pending suppressed entries without
+// waiting for their buckets to refill. Entries handled after Close are
+// passed through unchanged.
+func (h *RateLimitHandler) Close() error {
+	h.mu.Lock()
+	h.closed = true
+	var pending []Entry
+	now := time.Now()
+	for el := h.lru.Back(); el != nil; el = el.Prev() {
+		if b := el.Value.(*rateLimitBucket); b.suppressed > 0 {
+			pending = append(pending, b.flush(now))
+		}
+	}
+	h.mu.Unlock()
+
+	var err error
+	for _, e := range pending {
+		err = errors.Join(err, h.next.Handle(context.Background(), e))
+	}
+	return err
+}
+
+// expire is called by the refill timer of b.
+func (h *RateLimitHandler) expire(b *rateLimitBucket) {
+	h.mu.Lock()
+	if b.suppressed == 0 {
+		// Already flushed by Handle, Close or eviction.
+		h.mu.Unlock()
+		return
+	}
+	summary := b.flush(time.Now())
+	h.mu.Unlock()
+
+	_ = h.next.Handle(context.Background(), summary)
+}
+
+// handlePending passes summaries downstream, stopping at the first error.
+func (h *RateLimitHandler) handlePending(ctx context.Context, pending []Entry) error {
+	for _, e := range pending {
+		if err := h.next.Handle(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the rate limiter counters.
+func (h *RateLimitHandler) Stats() RateLimitStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return RateLimitStats{
+		Passed:  h.passed,
+		Dropped: h.dropped,
+		Evicted: h.evicted,
+		Keys:    h.lru.Len(),
+	}
+}
+
+// bucket returns the bucket for key, creating a full one if needed. When
+// that evicts the least recently used bucket, it is returned as well so
+// that the caller can emit its summary. Must be called with mu held.
+func (h *RateLimitHandler) bucket(key rateLimitKey, now int64) (*rateLimitBucket, *rateLimitBucket) {
+	if el, ok := h.buckets[key]; ok {
+		h.lru.MoveToFront(el)
+		return el.Value.(*rateLimitBucket), nil
+	}
+
+	var evicted *rateLimitBucket
+	if h.lru.Len() >= h.maxKeys {
+		oldest := h.lru.Back()
+		h.lru.Remove(oldest)
+		evicted = oldest.Value.(*rateLimitBucket)
+		delete(h.buckets, evicted.key)
+		h.evicted++
+	}
+
+	// Custom keys may point into caller-owned memory; keep a private copy.
+	key.custom = strings.Clone(key.custom)
+	b := &rateLimitBucket{key: key, tokens: h.burst, last: now, level: LevelDebug}
+	h.buckets[key] = h.lru.PushFront(b)
+	return b, evicted
+}
+
+// rateLimitKey identifies a bucket. The default key uses name and pc;
+// a custom key function fills custom instead.
+type rateLimitKey struct {
+	name   string
+	pc     uintptr
+	custom string
+}
+
+type rateLimitBucket struct {
+	key        rateLimitKey
+	tokens     float64
+	last       int64       // unix nanoseconds of the last refill
+	suppressed int64       // entries dropped since the last summary
+	level      Level       // most severe level among suppressed entries
+	name       string      // logger name of the first suppressed entry
+	pc         uintptr     // caller of the first suppressed entry
+	timer      *time.Timer // emits the summary once the bucket refills
+}
+
+// refill adds tokens for the time elapsed since the last refill.
+func (b *rateLimitBucket) refill(now int64, rate, burst float64) {
+	if now <= b.last {
+		return
+	}
+	b.tokens += float64(now-b.last) / float64(time.Second) * rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+}
+
+// flush builds the summary of the suppressed entries, timestamped t, and
+// resets the count. Must be called with mu held.
+func (b *rateLimitBucket) flush(t time.Time) Entry {
+	b.timer.Stop()
+	e := suppressedEntry(Entry{Time: t, LoggerName: b.name, CallerPC: b.pc}, b.suppressed, b.level)
+	b.suppressed, b.level = 0, LevelDebug
+	return e
+}
+
+// suppressedEntry builds the summary entry reporting n dropped entries
+// from the call site of e.
+func suppressedEntry(e Entry, n int64, lvl Level) Entry {
+	var sb strings.Builder
+	sb.WriteString("suppressed ")
+	sb.WriteString(strconv.FormatInt(n, 10))
+	sb.WriteString(" entries from ")
+	switch {
+	case e.CallerPC != 0:
+		file, line := callerFrame(e.CallerPC)
+		sb.WriteString(fileWithPackage(file))
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(line))
+	case e.LoggerName != "":
+		sb.WriteString(e.LoggerName)
+	default:
+		sb.WriteString("unknown caller")
+	}
+
+	return Entry{
+		Fields:     []Field{Int64("suppressed", n)},
+		Level:      lvl,
+		Time:       e.Time,
+		LoggerName: e.LoggerName,
+		Text:       sb.String(),
+		CallerPC:   e.CallerPC,
+	}
+}
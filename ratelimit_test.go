@@ -0,0 +1,202 @@
+package logf
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitHandlerBurstAndDrop(t *testing.T) {
+	sink := &testHandler{}
+	h := NewRateLimitHandler(sink, RateLimit(1, 3))
+	defer h.Close() // stops the refill timers
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		_ = h.Handle(context.Background(), Entry{Level: LevelError, Time: now, CallerPC: 1})
+	}
+
+	assert.Len(t, sink.Entries, 3)
+	assert.Equal(t, RateLimitStats{Passed: 3, Dropped: 7, Keys: 1}, h.Stats())
+}
+
+func TestRateLimitHandlerSuppressedSummary(t *testing.T) {
+	sink := &testHandler{}
+	h := NewRateLimitHandler(sink, RateLimit(1, 1))
+
+	pc := CallerPC(0)
+	now := time.Now()
+	_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now, CallerPC: pc, Text: "first"})
+	_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now, CallerPC: pc, Text: "dropped"})
+	_ = h.Handle(context.Background(), Entry{Level: LevelError, Time: now, CallerPC: pc, Text: "dropped"})
+	require.Len(t, sink.Entries, 1)
+
+	// One second later the bucket holds a token again.
+	_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now.Add(time.Second), CallerPC: pc, Text: "after"})
+	require.Len(t, sink.Entries, 3)
+
+	summary := sink.Entries[1]
+	assert.Contains(t, summary.Text, "suppressed 2 entries from ")
+	assert.Contains(t, summary.Text, "ratelimit_test.go:")
+	assert.Equal(t, LevelError, summary.Level, "summary takes the most severe suppressed level")
+	assert.Equal(t, []Field{Int64("suppressed", 2)}, summary.Fields)
+	assert.Equal(t, "after", sink.Entries[2].Text)
+
+	// No pending drops — no second summary.
+	_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now.Add(2 * time.Second), CallerPC: pc, Text: "again"})
+	require.Len(t, sink.Entries, 4)
+	assert.Equal(t, "again", sink.Entries[3].Text)
+}
+
+func TestRateLimitHandlerKeyedByNameAndCaller(t *testing.T) {
+	sink := &testHandler{}
+	h := NewRateLimitHandler(sink, RateLimit(1, 1))
+	defer h.Close()
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		_ = h.Handle(context.Background(), Entry{Time: now, LoggerName: "a", CallerPC: 1})
+		_ = h.Handle(context.Background(), Entry{Time: now, LoggerName: "b", CallerPC: 1})
+		_ = h.Handle(context.Background(), Entry{Time: now, LoggerName: "a", CallerPC: 2})
+	}
+
+	assert.Len(t, sink.Entries, 3)
+	assert.Equal(t, 3, h.Stats().Keys)
+}
+
+func TestRateLimitHandlerCustomKey(t *testing.T) {
+	sink := &testHandler{}
+	h := NewRateLimitHandler(sink,
+		RateLimit(1, 1),
+		RateLimitKey(func(e Entry) string { return e.Text }),
+	)
+	defer h.Close()
+
+	now := time.Now()
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 1, Text: "same"})
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 2, Text: "same"})
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 3, Text: "other"})
+
+	assert.Len(t, sink.Entries, 2)
+}
+
+func TestRateLimitHandlerLRUEviction(t *testing.T) {
+	sink := &testHandler{}
+	h := NewRateLimitHandler(sink, RateLimit(1, 1), RateLimitMaxKeys(2))
+	defer h.Close()
+
+	now := time.Now()
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 1})
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 2})
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 1}) // dropped, 1 becomes most recent
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 3}) // evicts 2
+
+	stats := h.Stats()
+	assert.Equal(t, 2, stats.Keys)
+	assert.Equal(t, int64(1), stats.Evicted)
+
+	// Key 1 is still tracked and empty; key 2 starts over with a full bucket.
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 1})
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 2})
+	assert.Len(t, sink.Entries, 4)
+}
+
+func TestRateLimitHandlerSummaryOnRefill(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewRateLimitHandler(sink, RateLimit(50, 1))
+
+	// The call site goes quiet after the burst: the summary is emitted
+	// once the bucket refills, 20ms later.
+	for i := 0; i < 4; i++ {
+		_ = h.Handle(context.Background(), Entry{Level: LevelWarn, CallerPC: 1, LoggerName: "db"})
+	}
+	require.Len(t, sink.Entries(), 1)
+
+	assert.Eventually(t, func() bool {
+		return len(sink.Entries()) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	summary := sink.Entries()[1]
+	assert.Equal(t, "db", summary.LoggerName)
+	assert.Equal(t, LevelWarn, summary.Level)
+	assert.Equal(t, []Field{Int64("suppressed", 3)}, summary.Fields)
+
+	// Nothing pending: the next entry passes without a summary.
+	_ = h.Handle(context.Background(), Entry{Level: LevelWarn, CallerPC: 1, LoggerName: "db"})
+	assert.Len(t, sink.Entries(), 3)
+}
+
+func TestRateLimitHandlerSummaryOnEviction(t *testing.T) {
+	sink := &testHandler{}
+	h := NewRateLimitHandler(sink, RateLimit(0.001, 1), RateLimitMaxKeys(1))
+
+	now := time.Now()
+	_ = h.Handle(context.Background(), Entry{Time: now, LoggerName: "a", CallerPC: 1})
+	_ = h.Handle(context.Background(), Entry{Time: now, LoggerName: "a", CallerPC: 1})
+	_ = h.Handle(context.Background(), Entry{Time: now, LoggerName: "a", CallerPC: 1})
+	require.Len(t, sink.Entries, 1)
+
+	// Key b evicts key a along with its two pending entries.
+	_ = h.Handle(context.Background(), Entry{Time: now, LoggerName: "b", CallerPC: 2})
+	require.Len(t, sink.Entries, 3)
+	assert.Equal(t, "a", sink.Entries[1].LoggerName)
+	assert.Equal(t, []Field{Int64("suppressed", 2)}, sink.Entries[1].Fields)
+	assert.Equal(t, "b", sink.Entries[2].LoggerName)
+	assert.Equal(t, int64(1), h.Stats().Evicted)
+}
+
+func TestRateLimitHandlerClose(t *testing.T) {
+	sink := &testHandler{}
+	h := NewRateLimitHandler(sink, RateLimit(0.001, 1))
+
+	now := time.Now()
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 1})
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 1})
+	require.NoError(t, h.Close())
+	require.Len(t, sink.Entries, 2)
+	assert.Equal(t, []Field{Int64("suppressed", 1)}, sink.Entries[1].Fields)
+
+	// After Close entries pass through.
+	_ = h.Handle(context.Background(), Entry{Time: now, CallerPC: 1})
+	assert.Len(t, sink.Entries, 3)
+}
+
+func TestRateLimitHandlerEnabledDelegates(t *testing.T) {
+	h := NewRateLimitHandler(newLeveledTestHandler(LevelInfo))
+
+	assert.True(t, h.Enabled(context.Background(), LevelInfo))
+	assert.False(t, h.Enabled(context.Background(), LevelDebug))
+}
+
+func TestRateLimitHandlerConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	n := 0
+	sink := handlerFunc(func(context.Context, Entry) error {
+		mu.Lock()
+		n++
+		mu.Unlock()
+		return nil
+	})
+	h := NewRateLimitHandler(sink, RateLimit(0.001, 10), RateLimitMaxKeys(4))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				_ = h.Handle(context.Background(), Entry{Time: time.Now(), CallerPC: uintptr(g%2 + 1)})
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	stats := h.Stats()
+	assert.Equal(t, 20, n)
+	assert.Equal(t, int64(20), stats.Passed)
+	assert.Equal(t, int64(1580), stats.Dropped)
+}
@@ -0,0 +1,521 @@
+package logf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"path"
+	"strings"
+	"unsafe"
+)
+
+// DefaultRedactMask is the value that replaces redacted data unless
+// RedactMask or RedactHash says otherwise.
+const DefaultRedactMask = "[REDACTED]"
+
+// NewRedactHandler returns a Handler middleware that removes sensitive
+// data from entries before they reach the next Handler. It works in two
+// ways, which can be combined:
+//
+// Key rules (RedactKeys) replace the whole value of a field by its key,
+// wherever the field is: per-call fields, nested Group fields and the
+// logger and context Bags:
+//
+//	logf.NewRedactHandler(next,
+//	    logf.RedactKeys("password", "*token*", "http.headers.authorization"),
+//	    logf.RedactDetect(logf.DetectEmails, logf.DetectCardNumbers),
+//	)
+//
+// Detectors (RedactDetect) scan string values and the message text and
+// replace only the matching parts: "paid with 4111 1111 1111 1111"
+// becomes "paid with [REDACTED]".
+//
+// Redacted data is replaced by DefaultRedactMask, by a custom mask
+// (RedactMask) or by a keyed hash (RedactHash) that keeps values
+// correlatable across entries without revealing them.
+//
+// Entries without sensitive data are passed through as they are. Bags
+// are redacted once per node: the redacted copy is remembered on the
+// original node and has its own encoder cache, so the encoded bytes of
+// both stay correct and repeated entries cost no more than without
+// redaction.
+func NewRedactHandler(next Handler, opts ...RedactOption) *RedactHandler {
+	h := &RedactHandler{next: next, mask: DefaultRedactMask}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// RedactHandler is the Handler built by NewRedactHandler.
+type RedactHandler struct {
+	next      Handler
+	keys      []keyPattern   // rules for a bare key at any depth
+	paths     [][]keyPattern // rules for a full dotted group path
+	detectors []Detector
+	mask      string
+	hash      bool
+	salt      []byte
+}
+
+// RedactOption configures a RedactHandler at creation time.
+type RedactOption func(*RedactHandler)
+
+// RedactKeys redacts the values of fields whose key matches one of the
+// patterns. Matching is case-insensitive, and a pattern may use the glob
+// syntax of path.Match ("*token*", "x-*-key"):
+//
+//   - a pattern without dots matches the key at any depth, so "password"
+//     covers both {"password":...} and {"user":{"password":...}};
+//   - a pattern with dots matches the full path made of Bag groups
+//     (Logger.WithGroup, logf.WithGroup), Group field keys and the key
+//     itself, one glob per segment: "http.headers.authorization",
+//     "http.*.cookie".
+//
+// A matching Group field is redacted as a whole. Malformed patterns
+// match nothing.
+func RedactKeys(patterns ...string) RedactOption {
+	return func(h *RedactHandler) {
+		for _, p := range patterns {
+			if !strings.Contains(p, ".") {
+				h.keys = append(h.keys, newKeyPattern(p))
+				continue
+			}
+			var segs []keyPattern
+			for _, seg := range strings.Split(p, ".") {
+				segs = append(segs, newKeyPattern(seg))
+			}
+			h.paths = append(h.paths, segs)
+		}
+	}
+}
+
+// RedactDetect adds detectors that scan the message text and the values
+// of string fields (String, ByteString, Stringer, Strings and so on).
+// Values of other types, errors included, are not scanned.
+func RedactDetect(ds ...Detector) RedactOption {
+	return func(h *RedactHandler) {
+		h.detectors = append(h.detectors, ds...)
+	}
+}
+
+// RedactMask sets the value that replaces redacted data.
+func RedactMask(mask string) RedactOption {
+	return func(h *RedactHandler) {
+		h.mask = mask
+	}
+}
+
+// RedactHash replaces redacted strings with a keyed hash instead of the
+// mask: "sha256:" followed by the first 8 bytes of HMAC-SHA256(salt,
+// value) in hex. Equal values get equal hashes, so entries stay
+// searchable by them. Keep the salt secret, or short values can be
+// recovered by brute force. Redacted fields of non-string types are
+// still replaced by the mask.
+func RedactHash(salt []byte) RedactOption {
+	return func(h *RedactHandler) {
+		h.hash = true
+		h.salt = salt
+	}
+}
+
+// Enabled delegates to the downstream Handler.
+func (h *RedactHandler) Enabled(ctx context.Context, lvl Level) bool {
+	return h.next.Enabled(ctx, lvl)
+}
+
+// EnabledName forwards the logger name to the downstream Handler.
+func (h *RedactHandler) EnabledName(ctx context.Context, name string, lvl Level) bool {
+	return enabledName(h.next, ctx, name, lvl)
+}
+
+// Handle redacts the entry and passes it downstream. The caller's fields
+// are never modified; changed ones are copied.
+func (h *RedactHandler) Handle(ctx context.Context, e Entry) error {
+	// The encoder writes the logger Bag inside the groups of the context
+	// Bag, and the per-call fields inside both.
+	var groups []string
+	e.Bag, groups = h.redactBag(nil, e.Bag)
+	e.LoggerBag, groups = h.redactBag(groups, e.LoggerBag)
+	e.Fields, _ = h.redactFields(groups, e.Fields)
+	e.Text = h.scan(e.Text)
+	return h.next.Handle(ctx, e)
+}
+
+// bagRedaction is the redacted mirror of a Bag node, valid for the
+// handler and the group prefix it was built with.
+type bagRedaction struct {
+	owner  *RedactHandler
+	bag    *Bag
+	depth  int      // number of prefix groups
+	groups []string // prefix groups followed by the node's own groups
+}
+
+// redactBag returns the redacted mirror of b along with the group path
+// in effect after it. Nodes that need no change are their own mirror and
+// keep their cache; a changed node, or one below a changed parent, gets a
+// new node with a fresh cache. Mirrors are memoized on the original node,
+// so a chain is scanned once. Several RedactHandlers over the same Bags
+// are still correct, they just replace each other's memo.
+func (h *RedactHandler) redactBag(prefix []string, b *Bag) (*Bag, []string) {
+	if b == nil {
+		return nil, prefix
+	}
+	if m := b.redacted.Load(); m != nil && m.owner == h && m.depth == len(prefix) && equalStrings(m.groups[:m.depth], prefix) {
+		return m.bag, m.groups
+	}
+
+	parent, groups := h.redactBag(prefix, b.parent)
+	mirror := b
+	if b.group != "" {
+		groups = append(groups[:len(groups):len(groups)], b.group)
+		if parent != b.parent {
+			mirror = &Bag{group: b.group, parent: parent}
+		}
+	} else {
+		fs, changed := h.redactFields(groups, b.fields)
+		if changed || parent != b.parent {
+			mirror = &Bag{fields: fs, parent: parent}
+		}
+	}
+	groups = groups[:len(groups):len(groups)]
+	b.redacted.Store(&bagRedaction{owner: h, bag: mirror, depth: len(prefix), groups: groups})
+	return mirror, groups
+}
+
+// redactFields returns fs with sensitive data redacted. It reports false
+// and returns fs itself when nothing has changed.
+func (h *RedactHandler) redactFields(groups []string, fs []Field) ([]Field, bool) {
+	var out []Field
+	for i := range fs {
+		f, changed := h.redactField(groups, fs[i])
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = make([]Field, len(fs))
+			copy(out, fs)
+		}
+		out[i] = f
+	}
+	if out == nil {
+		return fs, false
+	}
+	return out, true
+}
+
+func (h *RedactHandler) redactField(groups []string, f Field) (Field, bool) {
+	switch f.Type {
+	case FieldTypeUnknown, FieldTypeSamplingKey:
+		return f, false
+	}
+	if f.Key != "" && h.matchKey(groups, f.Key) {
+		return h.replace(f), true
+	}
+
+	switch f.Type {
+	case FieldTypeGroup:
+		fs, ok := f.Any.([]Field)
+		if !ok {
+			return f, false
+		}
+		if f.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], f.Key)
+		}
+		if fs, changed := h.redactFields(groups, fs); changed {
+			return Group(f.Key, fs...), true
+		}
+	case FieldTypeBytesToString:
+		s := unsafe.String((*byte)(f.Ptr), int(f.Val))
+		if r := h.scan(s); r != s {
+			return String(f.Key, r), true
+		}
+	case FieldTypeBytesToStrings:
+		ss := unsafe.Slice((*string)(f.Ptr), int(f.Val))
+		var out []string
+		for i, s := range ss {
+			r := h.scan(s)
+			if r == s {
+				continue
+			}
+			if out == nil {
+				out = make([]string, len(ss))
+				copy(out, ss)
+			}
+			out[i] = r
+		}
+		if out != nil {
+			return Strings(f.Key, out), true
+		}
+	}
+	return f, false
+}
+
+// replace returns the redacted version of a field matched by a key rule.
+func (h *RedactHandler) replace(f Field) Field {
+	if !h.hash {
+		return String(f.Key, h.mask)
+	}
+	switch f.Type {
+	case FieldTypeBytesToString, FieldTypeBytes:
+		return String(f.Key, h.hashString(unsafe.String((*byte)(f.Ptr), int(f.Val))))
+	case FieldTypeBytesToStrings:
+		ss := unsafe.Slice((*string)(f.Ptr), int(f.Val))
+		out := make([]string, len(ss))
+		for i, s := range ss {
+			out[i] = h.hashString(s)
+		}
+		return Strings(f.Key, out)
+	}
+	return String(f.Key, h.mask)
+}
+
+func (h *RedactHandler) hashString(s string) string {
+	mac := hmac.New(sha256.New, h.salt)
+	mac.Write([]byte(s))
+	sum := mac.Sum(nil)
+	out := make([]byte, 0, len("sha256:")+16)
+	out = append(out, "sha256:"...)
+	for _, b := range sum[:8] {
+		out = append(out, hex[b>>4], hex[b&0xf])
+	}
+	return string(out)
+}
+
+// scan applies the detectors to s. It returns s itself, without
+// allocating, when nothing is found.
+func (h *RedactHandler) scan(s string) string {
+	for _, d := range h.detectors {
+		start, end := d(s, 0)
+		if start < 0 {
+			continue
+		}
+		var sb strings.Builder
+		last := 0
+		for start >= 0 {
+			sb.WriteString(s[last:start])
+			if h.hash {
+				sb.WriteString(h.hashString(s[start:end]))
+			} else {
+				sb.WriteString(h.mask)
+			}
+			last = end
+			if end == start {
+				end++ // guard against empty matches
+			}
+			if end > len(s) {
+				break
+			}
+			start, end = d(s, end)
+		}
+		sb.WriteString(s[last:])
+		s = sb.String()
+	}
+	return s
+}
+
+func (h *RedactHandler) matchKey(groups []string, key string) bool {
+	for _, p := range h.keys {
+		if p.match(key) {
+			return true
+		}
+	}
+	for _, segs := range h.paths {
+		if len(segs) != len(groups)+1 {
+			continue
+		}
+		ok := segs[len(groups)].match(key)
+		for i := 0; ok && i < len(groups); i++ {
+			ok = segs[i].match(groups[i])
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// keyPattern is a single key or path segment rule.
+type keyPattern struct {
+	pattern string // lower case
+	glob    bool
+}
+
+func newKeyPattern(p string) keyPattern {
+	p = strings.ToLower(p)
+	return keyPattern{pattern: p, glob: strings.ContainsAny(p, `*?[\`)}
+}
+
+func (p keyPattern) match(s string) bool {
+	if !p.glob {
+		return strings.EqualFold(p.pattern, s)
+	}
+	ok, _ := path.Match(p.pattern, strings.ToLower(s))
+	return ok
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Detector finds sensitive substrings for RedactDetect. It returns the
+// bounds of the first match in s that starts at or after from, or -1, -1
+// if there is none. Detectors are called for every scanned string, so
+// they should not allocate.
+type Detector func(s string, from int) (start, end int)
+
+// DetectEmails finds email addresses: a local part, '@' and a domain
+// with at least one dot and a top-level domain of two or more letters.
+func DetectEmails(s string, from int) (int, int) {
+	for i := from; i < len(s); i++ {
+		at := strings.IndexByte(s[i:], '@')
+		if at < 0 {
+			break
+		}
+		at += i
+		start := at
+		for start > from && isEmailLocalByte(s[start-1]) {
+			start--
+		}
+		end := at + 1
+		for end < len(s) && (isAlnum(s[end]) || s[end] == '-' || s[end] == '.') {
+			end++
+		}
+		// Drop a trailing sentence dot or dash.
+		for end > at+1 && (s[end-1] == '.' || s[end-1] == '-') {
+			end--
+		}
+		dot := strings.LastIndexByte(s[at+1:end], '.') + at + 1
+		if start < at && dot > at+1 && end-dot > 2 && isAlphaString(s[dot+1:end]) {
+			return start, end
+		}
+		i = at
+	}
+	return -1, -1
+}
+
+// DetectCardNumbers finds payment card numbers: 13 to 19 digits, either
+// contiguous or grouped by single spaces or dashes, that pass the Luhn
+// check. Longer digit runs are not considered card numbers.
+func DetectCardNumbers(s string, from int) (int, int) {
+	for i := from; i < len(s); i++ {
+		if !isDigit(s[i]) || (i > 0 && isDigit(s[i-1])) {
+			continue
+		}
+		var digits [19]byte
+		n, end := 0, i
+		for j := i; j < len(s); j++ {
+			c := s[j]
+			if isDigit(c) {
+				if n == len(digits) {
+					n++ // too long
+					break
+				}
+				digits[n] = c - '0'
+				n++
+				end = j + 1
+				continue
+			}
+			if (c == ' ' || c == '-') && j+1 < len(s) && isDigit(s[j+1]) && isDigit(s[j-1]) {
+				continue
+			}
+			break
+		}
+		if n >= 13 && n <= len(digits) && luhn(digits[:n]) {
+			return i, end
+		}
+		// Skip the rest of this run.
+		for i < len(s)-1 && (isDigit(s[i+1]) || ((s[i+1] == ' ' || s[i+1] == '-') && i+2 < len(s) && isDigit(s[i+2]))) {
+			i++
+		}
+	}
+	return -1, -1
+}
+
+// luhn reports whether the digits pass the Luhn checksum.
+func luhn(digits []byte) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i])
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// minBearerTokenLen keeps DetectBearerTokens away from prose such as
+// "the bearer of".
+const minBearerTokenLen = 8
+
+// DetectBearerTokens finds the token in "Bearer <token>" (any case), as
+// in Authorization headers. Only the token is matched, so the scheme
+// stays readable. Tokens shorter than 8 characters are ignored.
+func DetectBearerTokens(s string, from int) (int, int) {
+	const scheme = "bearer"
+	for i := from; i+len(scheme) < len(s); i++ {
+		if !strings.EqualFold(s[i:i+len(scheme)], scheme) || (i > 0 && isAlnum(s[i-1])) {
+			continue
+		}
+		start := i + len(scheme)
+		if s[start] != ' ' {
+			continue
+		}
+		for start < len(s) && s[start] == ' ' {
+			start++
+		}
+		end := start
+		for end < len(s) && isTokenByte(s[end]) {
+			end++
+		}
+		if end-start >= minBearerTokenLen {
+			return start, end
+		}
+		i = end - 1
+	}
+	return -1, -1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return c|0x20 >= 'a' && c|0x20 <= 'z'
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || isAlpha(c)
+}
+
+func isAlphaString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isAlpha(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isEmailLocalByte(c byte) bool {
+	return isAlnum(c) || strings.IndexByte("._%+-", c) >= 0
+}
+
+// isTokenByte reports whether c may appear in an RFC 6750 bearer token.
+func isTokenByte(c byte) bool {
+	return isAlnum(c) || strings.IndexByte("-._~+/=", c) >= 0
+}
@@ -0,0 +1,246 @@
+package logf
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redactJSON passes e through h into sink and encodes the result.
+func redactJSON(t *testing.T, enc Encoder, h *RedactHandler, sink *testHandler, e Entry) string {
+	t.Helper()
+	sink.Entries = sink.Entries[:0]
+	require.NoError(t, h.Handle(context.Background(), e))
+	require.Len(t, sink.Entries, 1)
+	out, err := enc.Encode(sink.Entries[0])
+	require.NoError(t, err)
+	defer out.Free()
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+func TestRedactKeys(t *testing.T) {
+	sink := newLeveledTestHandler(LevelDebug)
+	h := NewRedactHandler(sink, RedactKeys("password", "*token*", "http.headers.authorization"))
+	enc := JSON().DisableTime().DisableLevel().DisableMsg().Build()
+
+	got := redactJSON(t, enc, h, sink, Entry{Fields: []Field{
+		String("user", "alice"),
+		String("Password", "hunter2"),
+		Int("api_token_id", 42),
+		Group("http",
+			Group("headers",
+				String("Authorization", "Basic abc"),
+				String("Accept", "*/*"),
+			),
+			String("authorization", "not under headers"),
+		),
+		Group("db", String("password", "nested")),
+		Group("", String("password", "inline")),
+	}})
+	assert.Equal(t, `{"user":"alice","Password":"[REDACTED]","api_token_id":"[REDACTED]",`+
+		`"http":{"headers":{"Authorization":"[REDACTED]","Accept":"*/*"},"authorization":"not under headers"},`+
+		`"db":{"password":"[REDACTED]"},"password":"[REDACTED]"}`, got)
+}
+
+func TestRedactKeysWholeGroup(t *testing.T) {
+	sink := newLeveledTestHandler(LevelDebug)
+	h := NewRedactHandler(sink, RedactKeys("secrets"), RedactMask("***"))
+	enc := JSON().DisableTime().DisableLevel().DisableMsg().Build()
+
+	got := redactJSON(t, enc, h, sink, Entry{Fields: []Field{
+		Group("secrets", String("a", "1"), String("b", "2")),
+	}})
+	assert.Equal(t, `{"secrets":"***"}`, got)
+}
+
+func TestRedactPathGlob(t *testing.T) {
+	sink := newLeveledTestHandler(LevelDebug)
+	h := NewRedactHandler(sink, RedactKeys("http.*.cookie"))
+	enc := JSON().DisableTime().DisableLevel().DisableMsg().Build()
+
+	got := redactJSON(t, enc, h, sink, Entry{Fields: []Field{
+		Group("http",
+			Group("request", String("cookie", "a=1")),
+			Group("response", String("cookie", "b=2")),
+			String("cookie", "too shallow"),
+		),
+	}})
+	assert.Equal(t, `{"http":{"request":{"cookie":"[REDACTED]"},"response":{"cookie":"[REDACTED]"},"cookie":"too shallow"}}`, got)
+}
+
+func TestRedactBags(t *testing.T) {
+	sink := newLeveledTestHandler(LevelDebug)
+	h := NewRedactHandler(sink, RedactKeys("http.headers.authorization", "password"))
+	enc := JSON().DisableTime().DisableLevel().DisableMsg().Build()
+
+	loggerBag := NewBag(String("service", "api"), String("password", "p1")).WithGroup("headers")
+	ctxBag := NewBag(String("request_id", "r1")).WithGroup("http")
+	e := Entry{Bag: ctxBag, LoggerBag: loggerBag, Fields: []Field{String("authorization", "Bearer x")}}
+
+	want := `{"request_id":"r1","http":{"service":"api","password":"[REDACTED]","headers":{"authorization":"[REDACTED]"}}}`
+	assert.Equal(t, want, redactJSON(t, enc, h, sink, e))
+	// Served from the memoized mirrors and their cache.
+	assert.Equal(t, want, redactJSON(t, enc, h, sink, e))
+	assert.Same(t, ctxBag, sink.Entries[0].Bag, "an unchanged Bag is passed as is")
+	assert.NotSame(t, loggerBag, sink.Entries[0].LoggerBag)
+}
+
+func TestRedactBagCache(t *testing.T) {
+	sink := newLeveledTestHandler(LevelDebug)
+	h := NewRedactHandler(sink, RedactKeys("password"))
+	enc := withCacheSlot(JSON().DisableTime().DisableLevel().DisableMsg().Build())
+
+	root := NewBag(String("password", "p1"))
+	bag := root.With(String("user", "alice"))
+	e := Entry{LoggerBag: bag}
+
+	// The same encoder sees the original chain and the redacted one; each
+	// must keep its own cached bytes.
+	for i := 0; i < 2; i++ {
+		out, err := enc.Encode(e)
+		require.NoError(t, err)
+		assert.Equal(t, `{"password":"p1","user":"alice"}`+"\n", out.String())
+		out.Free()
+
+		assert.Equal(t, `{"password":"[REDACTED]","user":"alice"}`, redactJSON(t, enc, h, sink, e))
+	}
+
+	// Another handler with different rules gets its own mirror.
+	other := NewRedactHandler(sink, RedactKeys("user"))
+	assert.Equal(t, `{"password":"p1","user":"[REDACTED]"}`, redactJSON(t, enc, other, sink, e))
+	assert.Equal(t, `{"password":"[REDACTED]","user":"alice"}`, redactJSON(t, enc, h, sink, e))
+}
+
+func TestRedactDoesNotModifyInput(t *testing.T) {
+	sink := newLeveledTestHandler(LevelDebug)
+	h := NewRedactHandler(sink, RedactKeys("password"), RedactDetect(DetectEmails))
+
+	user := []Field{String("email", "bob@example.com")}
+	cc := []string{"x", "a@b.io"}
+	fields := []Field{String("password", "p1"), Group("user", user...), Strings("cc", cc)}
+	orig := append([]Field(nil), fields...)
+	origUser := append([]Field(nil), user...)
+	require.NoError(t, h.Handle(context.Background(), Entry{Fields: fields}))
+
+	assert.Equal(t, orig, fields)
+	assert.Equal(t, origUser, user)
+	assert.Equal(t, []string{"x", "a@b.io"}, cc)
+}
+
+func TestRedactDetectors(t *testing.T) {
+	sink := newLeveledTestHandler(LevelDebug)
+	h := NewRedactHandler(sink, RedactDetect(DetectEmails, DetectCardNumbers, DetectBearerTokens))
+	enc := JSON().DisableTime().DisableLevel().Build()
+
+	got := redactJSON(t, enc, h, sink, Entry{
+		Text: "charged 4111 1111 1111 1111 for alice@example.com",
+		Fields: []Field{
+			String("auth", "Bearer eyJhbGciOi.eyJzdWIi.SflKxwRJ"),
+			Strings("to", []string{"bob@mail.example.org", "nobody"}),
+			ByteString("note", []byte("order 1234567890123 ok")),
+			Int("card", 4111111111111111),
+			Bytes("raw", []byte("eve@example.com")),
+		},
+	})
+	assert.Equal(t, `{"msg":"charged [REDACTED] for [REDACTED]",`+
+		`"auth":"Bearer [REDACTED]","to":["[REDACTED]","nobody"],`+
+		`"note":"order 1234567890123 ok","card":4111111111111111,"raw":"ZXZlQGV4YW1wbGUuY29t"}`, got)
+}
+
+func TestDetectEmails(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"mail john.doe+tag@sub.example.com now", []string{"john.doe+tag@sub.example.com"}},
+		{"end of sentence a@b.io.", []string{"a@b.io"}},
+		{"a@b.io, c@d.org", []string{"a@b.io", "c@d.org"}},
+		{"user@localhost", nil},
+		{"@example.com", nil},
+		{"x@example.c", nil},
+		{"x@example.123", nil},
+		{"no at sign", nil},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, detectAll(DetectEmails, c.in), c.in)
+	}
+}
+
+func TestDetectCardNumbers(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"4111111111111111", []string{"4111111111111111"}},
+		{"card 4111-1111-1111-1111.", []string{"4111-1111-1111-1111"}},
+		{"amex 3782 822463 10005 ok", []string{"3782 822463 10005"}},
+		{"4111111111111112", nil},     // fails Luhn
+		{"41111111111111110000", nil}, // too long
+		{"411111111111", nil},         // too short
+		{"4111  1111 1111 1111", nil}, // double space splits it
+		{"id 12345 and 4111111111111111", []string{"4111111111111111"}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, detectAll(DetectCardNumbers, c.in), c.in)
+	}
+}
+
+func TestDetectBearerTokens(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"Authorization: Bearer abc.DEF-123_x~y+z/=", []string{"abc.DEF-123_x~y+z/="}},
+		{"bearer  0123456789", []string{"0123456789"}},
+		{"the bearer of bad news", nil},
+		{"Bearer short", nil},
+		{"xbearer 0123456789", nil},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, detectAll(DetectBearerTokens, c.in), c.in)
+	}
+}
+
+func TestRedactHash(t *testing.T) {
+	sink := newLeveledTestHandler(LevelDebug)
+	h := NewRedactHandler(sink, RedactKeys("email", "pin"), RedactDetect(DetectCardNumbers), RedactHash([]byte("salt")))
+	enc := JSON().DisableTime().DisableLevel().Build()
+
+	got := redactJSON(t, enc, h, sink, Entry{
+		Text:   "card 4111111111111111",
+		Fields: []Field{String("email", "a@b.io"), Int("pin", 1234)},
+	})
+	hashed := h.hashString("a@b.io")
+	assert.Regexp(t, `^sha256:[0-9a-f]{16}$`, hashed)
+	assert.Equal(t, `{"msg":"card `+h.hashString("4111111111111111")+`","email":"`+hashed+`","pin":"[REDACTED]"}`, got)
+
+	other := NewRedactHandler(sink, RedactHash([]byte("pepper")))
+	assert.NotEqual(t, hashed, other.hashString("a@b.io"), "the salt keys the hash")
+}
+
+func TestRedactLogger(t *testing.T) {
+	sink := &syncTestHandler{}
+	logger := New(NewContextHandler(NewRedactHandler(sink, RedactKeys("password"))))
+	ctx := With(context.Background(), String("password", "from ctx"))
+
+	logger.With(String("password", "from logger")).Info(ctx, "login", String("password", "per call"))
+
+	entries := sink.Entries()
+	require.Len(t, entries, 1)
+	enc := JSON().DisableTime().DisableLevel().DisableCaller().Build()
+	out, err := enc.Encode(entries[0])
+	require.NoError(t, err)
+	assert.Equal(t, `{"msg":"login","password":"[REDACTED]","password":"[REDACTED]","password":"[REDACTED]"}`+"\n", out.String())
+	out.Free()
+}
+
+func detectAll(d Detector, s string) []string {
+	var found []string
+	for start, end := d(s, 0); start >= 0; start, end = d(s, end) {
+		found = append(found, s[start:end])
+	}
+	return found
+}
@@ -0,0 +1,54 @@
+package logf
+
+import (
+	"time"
+	"unsafe"
+)
+
+// levelField is the built-in level as passed to ReplaceField.
+func levelField(key string, lvl Level) Field {
+	return Field{Key: key, Type: FieldTypeAny, Any: lvl}
+}
+
+// callerField is the built-in caller as passed to ReplaceField.
+func callerField(key string, pc uintptr) Field {
+	return Field{Key: key, Type: FieldTypeAny, Any: pc}
+}
+
+// replaceBuiltin passes the built-in field fd through replace. It
+// reports whether the result is still a built-in, i.e. has a key and the
+// type of fd.
+func replaceBuiltin(replace func([]string, Field) Field, fd Field) (Field, bool) {
+	r := replace(nil, fd)
+	if r.Key == "" || r.Type != fd.Type {
+		return r, false
+	}
+	if fd.Type == FieldTypeAny {
+		switch fd.Any.(type) {
+		case Level:
+			_, ok := r.Any.(Level)
+			return r, ok
+		case uintptr:
+			_, ok := r.Any.(uintptr)
+			return r, ok
+		}
+	}
+	return r, true
+}
+
+// fieldTime returns the value of a Time field.
+func fieldTime(fd Field) time.Time {
+	switch {
+	case fd.Any != nil:
+		return time.Unix(0, fd.Val).In(fd.Any.(*time.Location))
+	case fd.Val != 0:
+		return time.Unix(0, fd.Val)
+	default:
+		return time.Time{}
+	}
+}
+
+// fieldString returns the value of a String field.
+func fieldString(fd Field) string {
+	return unsafe.String((*byte)(fd.Ptr), int(fd.Val))
+}
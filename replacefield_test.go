@@ -0,0 +1,186 @@
+package logf
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReplaceField drops secrets, renames "user" to "user.name", turns
+// durations into milliseconds and records the groups it sees.
+func testReplaceField(seen *[]string) func([]string, Field) Field {
+	return func(groups []string, f Field) Field {
+		if seen != nil {
+			*seen = append(*seen, strings.Join(append(groups, f.Key), "."))
+		}
+		switch {
+		case f.Key == "password":
+			return Field{}
+		case f.Key == "user":
+			f.Key = "user.name"
+		case f.Type == FieldTypeDuration:
+			return Int64(f.Key+"_ms", time.Duration(f.Val).Milliseconds())
+		}
+		return f
+	}
+}
+
+func TestJSONEncoderReplaceField(t *testing.T) {
+	var seen []string
+	enc := withCacheSlot(JSON().DisableTime().ReplaceField(testReplaceField(&seen)).Build())
+	e := Entry{
+		Level:     LevelInfo,
+		Text:      "m",
+		Bag:       NewBag(String("user", "ctx")).WithGroup("ctx"),
+		LoggerBag: NewBag(String("password", "secret")).WithGroup("http").With(Duration("took", time.Second)),
+		Fields: []Field{
+			String("password", "x"),
+			Group("req", String("user", "bob"), Group("", Int("inline", 1))),
+			Object("obj", testObjectEncoder{}),
+		},
+	}
+
+	// The second run takes the context Bag from the cache. The logger
+	// Bag under the group of the context Bag bypasses it, so ReplaceField
+	// sees its full group path again.
+	for i := 0; i < 2; i++ {
+		seen = seen[:0]
+		b, err := enc.Encode(e)
+		require.NoError(t, err)
+		assert.Equal(t, `{"level":"info","msg":"m","user.name":"ctx","ctx":{"http":{"took_ms":1000,`+
+			`"req":{"user.name":"bob","inline":1},"obj":{"username":"username","code":42}}}}`+"\n", b.String())
+		b.Free()
+		assert.Subset(t, seen, []string{"level", "msg", "ctx.http.password", "ctx.http.req.user", "ctx.http.req.inline", "ctx.http.obj"})
+	}
+}
+
+func TestJSONEncoderReplaceFieldBuiltins(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	e := Entry{Level: LevelInfo, Time: ts, LoggerName: "http", Text: "m", CallerPC: CallerPC(0)}
+
+	testCases := []struct {
+		name    string
+		replace func([]string, Field) Field
+		golden  string
+	}{
+		{
+			"Unchanged",
+			func(_ []string, f Field) Field { return f },
+			`{"level":"info","ts":"2024-05-01T10:00:00Z","logger":"http","msg":"m","caller":"[^"]+"}`,
+		},
+		{
+			"Renamed",
+			func(_ []string, f Field) Field {
+				f.Key = strings.ToUpper(f.Key)
+				return f
+			},
+			`{"LEVEL":"info","TS":"2024-05-01T10:00:00Z","LOGGER":"http","MSG":"m","CALLER":"[^"]+"}`,
+		},
+		{
+			"Values",
+			func(_ []string, f Field) Field {
+				switch f.Key {
+				case "level":
+					return Field{Key: "severity", Type: FieldTypeAny, Any: LevelError}
+				case "ts":
+					return Time("ts", ts.Add(time.Hour))
+				case "msg":
+					return String("message", "changed")
+				}
+				return f
+			},
+			`{"severity":"error","ts":"2024-05-01T11:00:00Z","logger":"http","message":"changed","caller":"[^"]+"}`,
+		},
+		{
+			"Dropped",
+			func(_ []string, f Field) Field {
+				if f.Key == "msg" {
+					return f
+				}
+				return Field{}
+			},
+			`{"msg":"m"}`,
+		},
+		{
+			"Converted",
+			func(_ []string, f Field) Field {
+				switch f.Key {
+				case "level":
+					return String("level", "INFO")
+				case "ts":
+					return Int64("ts", fieldTime(f).Unix())
+				case "caller":
+					return Bool("has_caller", f.Any.(uintptr) != 0)
+				}
+				return f
+			},
+			`{"level":"INFO","ts":1714557600,"logger":"http","msg":"m","has_caller":true}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc := JSON().ReplaceField(tc.replace).Build()
+			b, err := enc.Encode(e)
+			require.NoError(t, err)
+			assert.Regexp(t, "^"+tc.golden+"\n$", b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestTextEncoderReplaceField(t *testing.T) {
+	var seen []string
+	enc := withCacheSlot(Text().NoColor().DisableTime().ReplaceField(testReplaceField(&seen)).Build())
+	e := Entry{
+		Level:     LevelInfo,
+		Text:      "m",
+		LoggerBag: NewBag(String("password", "secret")).WithGroup("http").With(Duration("took", time.Second)),
+		Fields:    []Field{String("password", "x"), Group("req", String("user", "bob"))},
+	}
+	for i := 0; i < 2; i++ {
+		seen = seen[:0]
+		b, err := enc.Encode(e)
+		require.NoError(t, err)
+		assert.Equal(t, "[INF] m › http.took_ms=1000 http.req.user.name=bob\n", b.String())
+		b.Free()
+		assert.Subset(t, seen, []string{"level", "msg", "http.password", "http.req.user"})
+	}
+
+	// Built-ins: values are replaced, other types become fields.
+	enc = Text().NoColor().ReplaceField(func(_ []string, f Field) Field {
+		switch f.Key {
+		case DefaultFieldKeyTime:
+			return Field{}
+		case DefaultFieldKeyLevel:
+			return Field{Key: f.Key, Type: FieldTypeAny, Any: LevelWarn}
+		case DefaultFieldKeyName:
+			return Int("shard", 3)
+		case DefaultFieldKeyMsg:
+			return String(f.Key, strings.ToUpper(fieldString(f)))
+		}
+		return f
+	}).Build()
+	b, err := enc.Encode(Entry{Level: LevelInfo, Time: time.Now(), LoggerName: "http", Text: "m", Fields: []Field{Int("a", 1)}})
+	require.NoError(t, err)
+	assert.Equal(t, "[WRN] M › shard=3 a=1\n", b.String())
+	b.Free()
+}
+
+func TestPrettyEncoderReplaceField(t *testing.T) {
+	var seen []string
+	enc := Text().Pretty().Width(100).NoColor().DisableTime().ReplaceField(testReplaceField(&seen)).Build()
+	b, err := enc.Encode(Entry{
+		Level:     LevelInfo,
+		Text:      "m",
+		LoggerBag: NewBag(String("password", "secret")).WithGroup("http"),
+		Fields:    []Field{Group("req", String("user", "bob"), Duration("took", time.Second))},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "[INF] m                                        › http.req.user.name=bob http.req.took_ms=1000\n", b.String())
+	b.Free()
+	assert.Equal(t, []string{"level", "msg", "password", "http.req.user", "http.req.took"}, seen)
+}
@@ -0,0 +1,212 @@
+package logf
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const defaultRequestBufferSize = 256 * 1024
+
+// BufferRequest returns a context carrying a per-request log buffer and
+// the buffer itself. This is tail-based logging for a single request:
+// ContextHandler holds back the request's Debug and Info entries (even
+// those below the configured level) instead of writing them. When the
+// request is done, call Finish — on success the held entries are thrown
+// away, on failure they are flushed in order, each with the request's
+// Bag, so a failed request comes with its full debug trace while
+// successful ones cost nothing in the output.
+//
+//	func handle(w http.ResponseWriter, r *http.Request) {
+//	    ctx, rb := logf.BufferRequest(r.Context())
+//	    err := serve(ctx, w, r)
+//	    rb.Finish(err)
+//	}
+//
+// An Error entry logged with the context flushes the buffer right away
+// (the held entries first, then the error), and from then on the
+// request's entries are written directly. Warn entries are never held.
+//
+// The buffer is bounded (256 KB by default, see RequestBufferSize): when
+// it is full the oldest held entries are discarded. It only works with a
+// ContextHandler in the pipeline — without one, the buffer is ignored.
+func BufferRequest(ctx context.Context, opts ...RequestBufferOption) (context.Context, *RequestBuffer) {
+	rb := &RequestBuffer{maxBytes: defaultRequestBufferSize}
+	for _, opt := range opts {
+		opt(rb)
+	}
+	return context.WithValue(ctx, requestBufferKey{}, rb), rb
+}
+
+// RequestBuffer holds the entries of one request until it finishes.
+// Create one with BufferRequest. It is safe for concurrent use, so the
+// request may log from several goroutines.
+type RequestBuffer struct {
+	maxBytes int
+
+	// flushMu is held by Flush until the held entries are written, so
+	// that entries written through do not overtake them.
+	flushMu sync.Mutex
+
+	mu      sync.Mutex
+	items   []requestBufferItem
+	bytes   int
+	state   requestBufferState
+	dropped int64
+}
+
+// RequestBufferOption configures a RequestBuffer at creation time.
+type RequestBufferOption func(*RequestBuffer)
+
+// RequestBufferSize sets the memory bound of the buffer in bytes (default
+// 256 KB). Entry sizes are accounted the same way as DebugRingHandler.
+func RequestBufferSize(n int) RequestBufferOption {
+	return func(rb *RequestBuffer) {
+		if n > 0 {
+			rb.maxBytes = n
+		}
+	}
+}
+
+type requestBufferState int8
+
+const (
+	requestBufferHolding requestBufferState = iota // holding Debug/Info
+	requestBufferFailed                            // flushed, writing through
+	requestBufferDone                              // finished successfully
+)
+
+// requestBufferItem is a held entry together with the Handler and
+// context it was headed for.
+type requestBufferItem struct {
+	ctx  context.Context
+	next Handler
+	e    Entry
+	size int
+}
+
+// Finish ends the request: a nil err discards the held entries, a
+// non-nil err flushes them. Entries logged after Finish are written
+// directly according to the usual level rules.
+func (rb *RequestBuffer) Finish(err error) error {
+	if err != nil {
+		return rb.Flush()
+	}
+	rb.Discard()
+	return nil
+}
+
+// Flush writes all held entries in order and switches the buffer to
+// write-through mode. The entries are handled with a LevelDebug override
+// (see WithLevel), so a Router at Info does not drop them again. Entries
+// logged while Flush runs wait until the held ones are written.
+func (rb *RequestBuffer) Flush() error {
+	rb.flushMu.Lock()
+	defer rb.flushMu.Unlock()
+
+	rb.mu.Lock()
+	items := rb.items
+	rb.items, rb.bytes = nil, 0
+	rb.state = requestBufferFailed
+	rb.mu.Unlock()
+
+	var err error
+	for _, it := range items {
+		err = errors.Join(err, it.next.Handle(WithLevel(it.ctx, LevelDebug), it.e))
+	}
+	return err
+}
+
+// waitFlush waits for a Flush in progress to write the held entries.
+func (rb *RequestBuffer) waitFlush() {
+	rb.flushMu.Lock()
+	rb.flushMu.Unlock()
+}
+
+// Discard drops all held entries and stops holding new ones.
+func (rb *RequestBuffer) Discard() {
+	rb.mu.Lock()
+	rb.items, rb.bytes = nil, 0
+	rb.state = requestBufferDone
+	rb.mu.Unlock()
+}
+
+// Len returns the number of entries currently held.
+func (rb *RequestBuffer) Len() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return len(rb.items)
+}
+
+// Dropped returns the number of held entries discarded because the
+// buffer was full.
+func (rb *RequestBuffer) Dropped() int64 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.dropped
+}
+
+// holds reports whether entries of the given level are currently held.
+func (rb *RequestBuffer) holds(lvl Level) bool {
+	if lvl != LevelDebug && lvl != LevelInfo {
+		return false
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.state == requestBufferHolding
+}
+
+// handle holds e if the buffer is holding entries of its level and
+// reports whether it did. An Error entry flushes the buffer first.
+func (rb *RequestBuffer) handle(ctx context.Context, next Handler, e Entry) (bool, error) {
+	switch e.Level {
+	case LevelDebug, LevelInfo:
+	case LevelError:
+		rb.mu.Lock()
+		holding := rb.state == requestBufferHolding
+		rb.mu.Unlock()
+		if holding {
+			return false, rb.Flush()
+		}
+		rb.waitFlush()
+		return false, nil
+	default:
+		rb.waitFlush()
+		return false, nil
+	}
+
+	size := entrySize(e)
+	rb.mu.Lock()
+	if rb.state != requestBufferHolding {
+		rb.mu.Unlock()
+		rb.waitFlush()
+		return false, nil
+	}
+	defer rb.mu.Unlock()
+	if size > rb.maxBytes {
+		rb.dropped++
+		return true, nil
+	}
+	drop := 0
+	for drop < len(rb.items) && rb.bytes+size > rb.maxBytes {
+		rb.bytes -= rb.items[drop].size
+		drop++
+	}
+	if drop > 0 {
+		rb.items = append(rb.items[:0], rb.items[drop:]...)
+		rb.dropped += int64(drop)
+	}
+	e.Fields = cloneFields(e.Fields)
+	rb.items = append(rb.items, requestBufferItem{ctx: ctx, next: next, e: e, size: size})
+	rb.bytes += size
+	return true, nil
+}
+
+type requestBufferKey struct{}
+
+// requestBufferFromContext returns the RequestBuffer installed by
+// BufferRequest, or nil.
+func requestBufferFromContext(ctx context.Context) *RequestBuffer {
+	rb, _ := ctx.Value(requestBufferKey{}).(*RequestBuffer)
+	return rb
+}
@@ -0,0 +1,225 @@
+package logf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRequestBufferLogger(buf *bytes.Buffer) *Logger {
+	return NewLogger().
+		Level(LevelInfo).
+		Output(buf).
+		EncoderFrom(JSON().DisableTime()).
+		Context().
+		Build().
+		WithCaller(false)
+}
+
+func TestBufferRequestDiscardOnSuccess(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newRequestBufferLogger(&buf)
+
+	ctx, rb := BufferRequest(context.Background())
+	logger.Debug(ctx, "debug")
+	logger.Info(ctx, "info")
+	logger.Warn(ctx, "warn")
+	assert.Equal(t, 2, rb.Len())
+
+	require.NoError(t, rb.Finish(nil))
+	assert.Equal(t, `{"level":"warn","msg":"warn"}`+"\n", buf.String())
+	assert.Equal(t, 0, rb.Len())
+}
+
+func TestBufferRequestFlushOnFailure(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newRequestBufferLogger(&buf)
+
+	ctx := With(context.Background(), String("rid", "r1"))
+	ctx, rb := BufferRequest(ctx)
+	logger.Debug(ctx, "debug")
+	logger.Info(ctx, "info")
+	assert.Empty(t, buf.String())
+
+	require.NoError(t, rb.Finish(errors.New("failed")))
+	assert.Equal(t,
+		`{"level":"debug","msg":"debug","rid":"r1"}`+"\n"+
+			`{"level":"info","msg":"info","rid":"r1"}`+"\n",
+		buf.String())
+
+	// After Finish the usual level rules apply.
+	buf.Reset()
+	logger.Debug(ctx, "late-debug")
+	logger.Info(ctx, "late-info")
+	assert.Equal(t, `{"level":"info","msg":"late-info","rid":"r1"}`+"\n", buf.String())
+}
+
+func TestBufferRequestFlushOnErrorEntry(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newRequestBufferLogger(&buf)
+
+	ctx, rb := BufferRequest(context.Background())
+	logger.Debug(ctx, "d1")
+	logger.Error(ctx, "boom")
+	logger.Info(ctx, "after")
+
+	assert.Equal(t,
+		`{"level":"debug","msg":"d1"}`+"\n"+
+			`{"level":"error","msg":"boom"}`+"\n"+
+			`{"level":"info","msg":"after"}`+"\n",
+		buf.String())
+
+	// Finishing a failed request does not write anything twice.
+	require.NoError(t, rb.Finish(nil))
+	assert.Equal(t, 3, strings.Count(buf.String(), "\n"))
+}
+
+func TestBufferRequestDiscardedRequestsDontMix(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newRequestBufferLogger(&buf)
+
+	ok, okRB := BufferRequest(context.Background())
+	bad, badRB := BufferRequest(context.Background())
+	logger.Info(ok, "ok-request")
+	logger.Info(bad, "bad-request")
+	logger.Info(context.Background(), "unbuffered")
+
+	okRB.Discard()
+	require.NoError(t, badRB.Flush())
+
+	out := buf.String()
+	assert.NotContains(t, out, "ok-request")
+	assert.Contains(t, out, "bad-request")
+	assert.Contains(t, out, "unbuffered")
+}
+
+func TestBufferRequestSizeLimit(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newRequestBufferLogger(&buf)
+
+	one := entrySize(Entry{Text: "m0"})
+	ctx, rb := BufferRequest(context.Background(), RequestBufferSize(2*one))
+	for i := 0; i < 5; i++ {
+		logger.Info(ctx, "m"+string(rune('0'+i)))
+	}
+
+	assert.Equal(t, 2, rb.Len())
+	assert.Equal(t, int64(3), rb.Dropped())
+
+	require.NoError(t, rb.Flush())
+	assert.Equal(t,
+		`{"level":"info","msg":"m3"}`+"\n"+
+			`{"level":"info","msg":"m4"}`+"\n",
+		buf.String())
+}
+
+func TestBufferRequestCopiesFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newRequestBufferLogger(&buf)
+
+	ctx, rb := BufferRequest(context.Background())
+	data := []byte("original")
+	logger.Info(ctx, "m", ByteString("data", data))
+	copy(data, "mutated!")
+
+	require.NoError(t, rb.Flush())
+	assert.Contains(t, buf.String(), `"data":"original"`)
+}
+
+func TestBufferRequestEnabled(t *testing.T) {
+	h := NewContextHandler(newLeveledTestHandler(LevelWarn))
+	ctx, rb := BufferRequest(context.Background())
+
+	assert.True(t, h.Enabled(ctx, LevelDebug))
+	assert.True(t, h.Enabled(ctx, LevelInfo))
+	assert.False(t, h.Enabled(context.Background(), LevelInfo))
+
+	rb.Discard()
+	assert.False(t, h.Enabled(ctx, LevelDebug))
+}
+
+func TestBufferRequestConcurrent(t *testing.T) {
+	sink := &syncTestHandler{}
+	h := NewContextHandler(sink)
+	ctx, rb := BufferRequest(context.Background())
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				_ = h.Handle(ctx, Entry{Level: LevelDebug})
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, rb.Flush())
+	assert.Len(t, sink.Entries(), 400)
+}
+
+func TestBufferRequestFlushKeepsOrder(t *testing.T) {
+	// The held entry blocks in the sink while a concurrent entry is
+	// logged: that entry must wait for the flush.
+	started, release := make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	var texts []string
+	sink := handlerFunc(func(_ context.Context, e Entry) error {
+		if e.Text == "held" {
+			close(started)
+			<-release
+		}
+		mu.Lock()
+		texts = append(texts, e.Text)
+		mu.Unlock()
+		return nil
+	})
+	h := NewContextHandler(sink)
+	ctx, rb := BufferRequest(context.Background())
+	require.NoError(t, h.Handle(ctx, Entry{Level: LevelInfo, Text: "held"}))
+
+	done := make(chan struct{})
+	go func() {
+		_ = rb.Flush()
+		close(done)
+	}()
+	<-started
+	logged := make(chan struct{})
+	go func() {
+		_ = h.Handle(ctx, Entry{Level: LevelInfo, Text: "after"})
+		close(logged)
+	}()
+	select {
+	case <-logged:
+		t.Fatal("the entry overtook the flush")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-done
+	<-logged
+	assert.Equal(t, []string{"held", "after"}, texts)
+}
+
+func TestBufferRequestFlushThroughRouter(t *testing.T) {
+	var buf bytes.Buffer
+	router, closeFn, err := NewRouter().
+		Route(JSON().DisableTime().Build(), Output(LevelInfo, &buf)).
+		Build()
+	require.NoError(t, err)
+	defer closeFn()
+	logger := New(NewContextHandler(router)).WithCaller(false)
+
+	ctx, rb := BufferRequest(context.Background())
+	logger.Debug(ctx, "debug")
+	require.NoError(t, rb.Finish(errors.New("failed")))
+
+	assert.Equal(t, `{"level":"debug","msg":"debug"}`+"\n", buf.String())
+}
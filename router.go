@@ -80,8 +80,13 @@ func (b *RouterBuilder) Build() (Handler, func() error, error) {
 			if o.level > eg.broadestLevel {
 				eg.broadestLevel = o.level
 			}
+			if o.mutable != nil {
+				eg.mutable = true
+				r.mutable = true
+			}
 			ro := &routerOutput{
 				level:   o.level,
+				mutable: o.mutable,
 				w:       o.w,
 				closeFn: o.closeFn,
 			}
@@ -110,6 +115,7 @@ type encoderGroup struct {
 
 type output struct {
 	level   Level
+	mutable *MutableLevel // overrides level if non-nil
 	w       Writer
 	closeFn func() error // non-nil if the writer should be closed
 }
@@ -153,11 +159,40 @@ func OutputCloser(level Level, w io.WriteCloser) RouteOption {
 	}
 }
 
+// MutableOutput is like Output but filters by a MutableLevel, so the
+// level of this destination can be changed at runtime — for example
+// through LevelsHandler:
+//
+//	fileLevel := logf.NewMutableLevel(logf.LevelInfo)
+//	router, close, _ := logf.NewRouter().
+//	    Route(enc,
+//	        logf.Output(logf.LevelInfo, os.Stderr),
+//	        logf.MutableOutput(fileLevel, file),
+//	    ).
+//	    Build()
+//
+// A router with mutable outputs recomputes its broadest level on every
+// Enabled call, which costs one atomic load per output.
+func MutableOutput(level *MutableLevel, w io.Writer) RouteOption {
+	return func(g *encoderGroup) {
+		g.outputs = append(g.outputs, output{level: level.Level(), mutable: level, w: WriterFromIO(w)})
+	}
+}
+
+// MutableOutputCloser is like MutableOutput but transfers ownership of
+// the writer to the router, as OutputCloser does.
+func MutableOutputCloser(level *MutableLevel, w io.WriteCloser) RouteOption {
+	return func(g *encoderGroup) {
+		g.outputs = append(g.outputs, output{level: level.Level(), mutable: level, w: WriterFromIO(w), closeFn: w.Close})
+	}
+}
+
 // router is the fan-out Handler built by RouterBuilder.
 type router struct {
 	groups     []routerEncoderGroup
 	allOutputs []*routerOutput
 	broadestLevel   Level
+	mutable    bool // some outputs have a MutableLevel
 	closed     sync.Once
 }
 
@@ -165,26 +200,71 @@ type routerEncoderGroup struct {
 	enc      Encoder
 	outputs  []*routerOutput
 	broadestLevel Level
+	mutable  bool // some outputs have a MutableLevel
+}
+
+// level returns the most permissive level of the group's outputs.
+func (g *routerEncoderGroup) level() Level {
+	if !g.mutable {
+		return g.broadestLevel
+	}
+	return g.mutableLevel()
+}
+
+func (g *routerEncoderGroup) mutableLevel() Level {
+	lvl := g.outputs[0].currentLevel()
+	for _, o := range g.outputs[1:] {
+		if l := o.currentLevel(); l > lvl {
+			lvl = l
+		}
+	}
+	return lvl
 }
 
 // routerOutput writes directly from the caller goroutine.
 type routerOutput struct {
 	level   Level
+	mutable *MutableLevel
 	w       Writer
 	closeFn func() error
 }
 
-func (r *router) Enabled(_ context.Context, lvl Level) bool {
+func (o *routerOutput) currentLevel() Level {
+	if o.mutable != nil {
+		return o.mutable.Level()
+	}
+	return o.level
+}
+
+func (r *router) Enabled(ctx context.Context, lvl Level) bool {
+	if override, ok := LevelFromContext(ctx); ok {
+		return override.Enabled(lvl)
+	}
+	if r.mutable {
+		for i := range r.groups {
+			if r.groups[i].level().Enabled(lvl) {
+				return true
+			}
+		}
+		return false
+	}
 	return r.broadestLevel.Enabled(lvl)
 }
 
-func (r *router) Handle(_ context.Context, e Entry) error {
+func (r *router) Handle(ctx context.Context, e Entry) error {
 	var writeErr error
+	override, hasOverride := LevelFromContext(ctx)
 
 	for i := range r.groups {
 		g := &r.groups[i]
 
-		if !g.broadestLevel.Enabled(e.Level) {
+		// A WithLevel override replaces the level of every output; check
+		// it before paying for encoding.
+		lvl := override
+		if !hasOverride {
+			lvl = g.level()
+		}
+		if !lvl.Enabled(e.Level) {
 			continue
 		}
 
@@ -196,7 +276,11 @@ func (r *router) Handle(_ context.Context, e Entry) error {
 		encoded := buf.Bytes()
 
 		for _, o := range g.outputs {
-			if !o.level.Enabled(e.Level) {
+			lvl := override
+			if !hasOverride {
+				lvl = o.currentLevel()
+			}
+			if !lvl.Enabled(e.Level) {
 				continue
 			}
 			_, err := o.w.Write(encoded)
@@ -344,3 +344,32 @@ func TestRouterOrdering(t *testing.T) {
 
 	assert.Equal(t, expected, spy.allData())
 }
+
+func TestRouterMutableOutput(t *testing.T) {
+	fast := &spyWriter{}
+	mutable := &spyWriter{}
+	lvl := NewMutableLevel(LevelInfo)
+	h, closeFn, err := NewRouter().
+		Route(&testEncoder{},
+			Output(LevelError, fast),
+			MutableOutput(lvl, mutable),
+		).
+		Build()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	assert.False(t, h.Enabled(ctx, LevelDebug))
+	require.NoError(t, h.Handle(ctx, Entry{Text: "debug1", Level: LevelDebug}))
+
+	lvl.Set(LevelDebug)
+	assert.True(t, h.Enabled(ctx, LevelDebug))
+	require.NoError(t, h.Handle(ctx, Entry{Text: "debug2", Level: LevelDebug}))
+
+	lvl.Set(LevelError)
+	assert.False(t, h.Enabled(ctx, LevelInfo))
+	require.NoError(t, h.Handle(ctx, Entry{Text: "error", Level: LevelError}))
+	_ = closeFn()
+
+	assert.Equal(t, "debug2error", mutable.allData())
+	assert.Equal(t, "error", fast.allData())
+}
@@ -0,0 +1,251 @@
+package logf
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSamplingTick       = time.Second
+	defaultSamplingFirst      = 100
+	defaultSamplingThereafter = 100
+)
+
+// NewSamplingHandler returns a Handler middleware that thins out noisy
+// Debug and Info logging. Within each tick (one second by default) the
+// first N entries of a level are passed downstream, and after that only
+// every Mth entry gets through. Warn and Error entries are never sampled —
+// you always see the things that matter.
+//
+// Without options, Debug and Info each pass the first 100 entries per
+// second and every 100th entry thereafter:
+//
+//	h := logf.NewSamplingHandler(router,
+//	    logf.SampleLevel(logf.LevelInfo, 10, 1000),
+//	)
+//	logger := logf.New(logf.NewContextHandler(h))
+//
+// By default each level has a single counter shared by all call sites.
+// Entries carrying a SamplingKey field (per-call or in a Bag) are counted
+// separately, per key and level, so unrelated traffic cannot eat into
+// each other's budget. Sampling keys are expected to be a small, fixed
+// set — every distinct key holds its counters for the handler lifetime.
+//
+// The sampler is lock-free and safe for concurrent use. Use Stats to see
+// how many entries were kept and dropped.
+func NewSamplingHandler(next Handler, opts ...SamplingOption) *SamplingHandler {
+	h := &SamplingHandler{next: next, tick: defaultSamplingTick}
+	for _, lvl := range []Level{LevelDebug, LevelInfo} {
+		h.policies[lvl] = samplingPolicy{
+			enabled:    true,
+			first:      defaultSamplingFirst,
+			thereafter: defaultSamplingThereafter,
+		}
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// SamplingHandler is the Handler built by NewSamplingHandler.
+type SamplingHandler struct {
+	next     Handler
+	tick     time.Duration
+	policies [LevelDebug + 1]samplingPolicy
+	counters [LevelDebug + 1]samplingCounter
+	keyed    sync.Map // samplingKeyID → *samplingCounter
+	sampled  atomic.Int64
+	dropped  atomic.Int64
+}
+
+// SamplingStats is a snapshot of SamplingHandler counters. Feed it to
+// your metrics system to see how much logging the sampler is shedding.
+type SamplingStats struct {
+	Sampled int64 // entries subject to sampling that were passed downstream
+	Dropped int64 // entries dropped by the sampler
+}
+
+// SamplingOption configures a SamplingHandler at creation time.
+type SamplingOption func(*SamplingHandler)
+
+// SampleLevel sets the sampling policy for the given level: the first
+// entries per tick pass unconditionally, then every thereafter-th entry
+// passes. A thereafter of 0 drops everything after the first entries.
+// Only LevelDebug and LevelInfo can be sampled — the option is ignored
+// for LevelWarn and LevelError.
+func SampleLevel(lvl Level, first, thereafter int) SamplingOption {
+	return func(h *SamplingHandler) {
+		if lvl < LevelInfo || lvl > LevelDebug {
+			return
+		}
+		h.policies[lvl] = samplingPolicy{
+			enabled:    true,
+			first:      uint64(max(first, 0)),
+			thereafter: uint64(max(thereafter, 0)),
+		}
+	}
+}
+
+// NoSampleLevel disables sampling for the given level, so all its
+// entries are passed downstream.
+func NoSampleLevel(lvl Level) SamplingOption {
+	return func(h *SamplingHandler) {
+		if lvl < LevelError || lvl > LevelDebug {
+			return
+		}
+		h.policies[lvl] = samplingPolicy{}
+	}
+}
+
+// SamplingTick sets the length of a sampling period (default one
+// second). Counters reset at the start of every tick.
+func SamplingTick(d time.Duration) SamplingOption {
+	return func(h *SamplingHandler) {
+		if d > 0 {
+			h.tick = d
+		}
+	}
+}
+
+// Enabled delegates to the downstream Handler. Sampling is decided per
+// entry in Handle, so Enabled stays as cheap as the next Handler's.
+func (h *SamplingHandler) Enabled(ctx context.Context, lvl Level) bool {
+	return h.next.Enabled(ctx, lvl)
+}
+
+// EnabledName forwards the logger name to the downstream Handler.
+func (h *SamplingHandler) EnabledName(ctx context.Context, name string, lvl Level) bool {
+	return enabledName(h.next, ctx, name, lvl)
+}
+
+// Handle passes the entry downstream or drops it according to the
+// sampling policy for its level.
+func (h *SamplingHandler) Handle(ctx context.Context, e Entry) error {
+	if e.Level < LevelError || e.Level > LevelDebug {
+		return h.next.Handle(ctx, e)
+	}
+	p := &h.policies[e.Level]
+	if !p.enabled {
+		return h.next.Handle(ctx, e)
+	}
+
+	c := &h.counters[e.Level]
+	if key, ok := entrySamplingKey(e); ok {
+		c = h.keyedCounter(e.Level, key)
+	}
+
+	n := c.inc(entryUnixNano(e), h.tick)
+	if !p.allow(n) {
+		h.dropped.Add(1)
+		return nil
+	}
+	h.sampled.Add(1)
+
+	return h.next.Handle(ctx, e)
+}
+
+// Stats returns a snapshot of the sampling counters.
+func (h *SamplingHandler) Stats() SamplingStats {
+	return SamplingStats{
+		Sampled: h.sampled.Load(),
+		Dropped: h.dropped.Load(),
+	}
+}
+
+// keyedCounter returns the counter for the given level and sampling key,
+// creating it on first use.
+func (h *SamplingHandler) keyedCounter(lvl Level, key string) *samplingCounter {
+	if c, ok := h.keyed.Load(samplingKeyID{lvl, key}); ok {
+		return c.(*samplingCounter)
+	}
+	// The key may point into caller-owned memory; keep a private copy.
+	c, _ := h.keyed.LoadOrStore(samplingKeyID{lvl, strings.Clone(key)}, &samplingCounter{})
+	return c.(*samplingCounter)
+}
+
+// samplingKeyID identifies a keyed sampling counter.
+type samplingKeyID struct {
+	level Level
+	key   string
+}
+
+// entrySamplingKey finds the SamplingKey that applies to the entry. The
+// nearest one wins: per-call fields first (the last one), then the
+// context Bag, then the logger Bag (child nodes before parents).
+func entrySamplingKey(e Entry) (string, bool) {
+	for i := len(e.Fields) - 1; i >= 0; i-- {
+		if e.Fields[i].Type == FieldTypeSamplingKey {
+			return e.Fields[i].Key, true
+		}
+	}
+	if key, ok := bagSamplingKey(e.Bag); ok {
+		return key, true
+	}
+	return bagSamplingKey(e.LoggerBag)
+}
+
+// bagSamplingKey returns the nearest SamplingKey in the Bag chain.
+func bagSamplingKey(bag *Bag) (string, bool) {
+	for b := bag; b != nil; b = b.parent {
+		for i := len(b.fields) - 1; i >= 0; i-- {
+			if b.fields[i].Type == FieldTypeSamplingKey {
+				return b.fields[i].Key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// samplingPolicy is the first-N/thereafter-M rule for one level.
+type samplingPolicy struct {
+	enabled    bool
+	first      uint64
+	thereafter uint64
+}
+
+// allow reports whether the n-th entry (1-based) within a tick passes.
+func (p *samplingPolicy) allow(n uint64) bool {
+	if n <= p.first {
+		return true
+	}
+	if p.thereafter == 0 {
+		return false
+	}
+	return (n-p.first)%p.thereafter == 0
+}
+
+// samplingCounter counts entries within the current tick. The counter
+// is reset by whichever goroutine first observes an expired tick; a few
+// entries racing with the reset may be counted against either tick,
+// which is fine for sampling.
+type samplingCounter struct {
+	resetAt atomic.Int64
+	count   atomic.Uint64
+}
+
+// inc increments the counter and returns the new count within the tick
+// containing now.
+func (c *samplingCounter) inc(now int64, tick time.Duration) uint64 {
+	resetAt := c.resetAt.Load()
+	if now < resetAt {
+		return c.count.Add(1)
+	}
+	if c.resetAt.CompareAndSwap(resetAt, now+int64(tick)) {
+		c.count.Store(1)
+		return 1
+	}
+	return c.count.Add(1)
+}
+
+// entryUnixNano returns the entry timestamp, falling back to the current
+// time for entries built without one.
+func entryUnixNano(e Entry) int64 {
+	if e.Time.IsZero() {
+		return time.Now().UnixNano()
+	}
+	return e.Time.UnixNano()
+}
@@ -0,0 +1,216 @@
+package logf
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamplingHandlerFirstThereafter(t *testing.T) {
+	sink := &testHandler{}
+	h := NewSamplingHandler(sink, SampleLevel(LevelInfo, 3, 5))
+
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now})
+	}
+
+	// 1,2,3 (first) + 8,13,18 (every 5th thereafter).
+	assert.Len(t, sink.Entries, 6)
+	assert.Equal(t, SamplingStats{Sampled: 6, Dropped: 14}, h.Stats())
+}
+
+func TestSamplingHandlerZeroThereafter(t *testing.T) {
+	sink := &testHandler{}
+	h := NewSamplingHandler(sink, SampleLevel(LevelDebug, 2, 0))
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Time: now})
+	}
+
+	assert.Len(t, sink.Entries, 2)
+}
+
+func TestSamplingHandlerPassesWarnAndError(t *testing.T) {
+	sink := &testHandler{}
+	h := NewSamplingHandler(sink,
+		SampleLevel(LevelWarn, 0, 0),
+		SampleLevel(LevelError, 0, 0),
+	)
+
+	now := time.Now()
+	for i := 0; i < 50; i++ {
+		_ = h.Handle(context.Background(), Entry{Level: LevelWarn, Time: now})
+		_ = h.Handle(context.Background(), Entry{Level: LevelError, Time: now})
+	}
+
+	assert.Len(t, sink.Entries, 100)
+	assert.Equal(t, SamplingStats{}, h.Stats())
+}
+
+func TestSamplingHandlerPerLevelCounters(t *testing.T) {
+	sink := &testHandler{}
+	h := NewSamplingHandler(sink,
+		SampleLevel(LevelDebug, 1, 0),
+		SampleLevel(LevelInfo, 1, 0),
+	)
+
+	now := time.Now()
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Time: now, Text: "d1"})
+	_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now, Text: "i1"})
+	_ = h.Handle(context.Background(), Entry{Level: LevelDebug, Time: now, Text: "d2"})
+	_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now, Text: "i2"})
+
+	if assert.Len(t, sink.Entries, 2) {
+		assert.Equal(t, "d1", sink.Entries[0].Text)
+		assert.Equal(t, "i1", sink.Entries[1].Text)
+	}
+}
+
+func TestSamplingHandlerTickReset(t *testing.T) {
+	sink := &testHandler{}
+	h := NewSamplingHandler(sink, SampleLevel(LevelInfo, 2, 0), SamplingTick(time.Second))
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now})
+	}
+	assert.Len(t, sink.Entries, 2)
+
+	// Next tick starts a fresh budget.
+	later := now.Add(time.Second)
+	for i := 0; i < 5; i++ {
+		_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: later})
+	}
+	assert.Len(t, sink.Entries, 4)
+}
+
+func TestSamplingHandlerNoSampleLevel(t *testing.T) {
+	sink := &testHandler{}
+	h := NewSamplingHandler(sink, SampleLevel(LevelInfo, 1, 0), NoSampleLevel(LevelInfo))
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now})
+	}
+
+	assert.Len(t, sink.Entries, 10)
+}
+
+func TestSamplingHandlerEnabledDelegates(t *testing.T) {
+	h := NewSamplingHandler(newLeveledTestHandler(LevelWarn))
+
+	assert.True(t, h.Enabled(context.Background(), LevelError))
+	assert.True(t, h.Enabled(context.Background(), LevelWarn))
+	assert.False(t, h.Enabled(context.Background(), LevelInfo))
+}
+
+func TestSamplingHandlerConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	n := 0
+	sink := handlerFunc(func(context.Context, Entry) error {
+		mu.Lock()
+		n++
+		mu.Unlock()
+		return nil
+	})
+	h := NewSamplingHandler(sink, SampleLevel(LevelInfo, 10, 0), SamplingTick(time.Hour))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: time.Now()})
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, n)
+	assert.Equal(t, SamplingStats{Sampled: 10, Dropped: 790}, h.Stats())
+}
+
+func TestNewLoggerSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger().
+		Output(&buf).
+		Sampling(SampleLevel(LevelInfo, 1, 0)).
+		Build()
+
+	for i := 0; i < 5; i++ {
+		logger.Info(context.Background(), "info-msg")
+		logger.Error(context.Background(), "error-msg")
+	}
+
+	out := buf.String()
+	assert.Equal(t, 1, strings.Count(out, "info-msg"))
+	assert.Equal(t, 5, strings.Count(out, "error-msg"))
+}
+
+func TestSamplingHandlerSamplingKey(t *testing.T) {
+	sink := &testHandler{}
+	h := NewSamplingHandler(sink, SampleLevel(LevelInfo, 1, 0))
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		// Two call sites sharing a key share one counter.
+		_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now, Text: "a",
+			Fields: []Field{SamplingKey("http.request")}})
+		_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now, Text: "b",
+			Fields: []Field{SamplingKey("http.request")}})
+		// A different key has its own budget.
+		_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now, Text: "c",
+			Fields: []Field{SamplingKey("db.query")}})
+		// Unkeyed entries use the per-level counter.
+		_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: now, Text: "d"})
+	}
+
+	var texts []string
+	for _, e := range sink.Entries {
+		texts = append(texts, e.Text)
+	}
+	assert.Equal(t, []string{"a", "c", "d"}, texts)
+}
+
+func TestSamplingHandlerSamplingKeyFromBags(t *testing.T) {
+	sink := &testHandler{}
+	h := NewSamplingHandler(sink, SampleLevel(LevelInfo, 1, 0))
+	logger := New(NewContextHandler(h))
+
+	ctx := With(context.Background(), SamplingKey("ctx.key"))
+	bagLogger := logger.With(SamplingKey("logger.key")).With(String("x", "y"))
+
+	for i := 0; i < 3; i++ {
+		bagLogger.Info(context.Background(), "logger")
+		bagLogger.Info(ctx, "ctx")
+		// Per-call key wins over both bags.
+		bagLogger.Info(ctx, "call", SamplingKey("call.key"))
+	}
+
+	var texts []string
+	for _, e := range sink.Entries {
+		texts = append(texts, e.Text)
+	}
+	assert.Equal(t, []string{"logger", "ctx", "call"}, texts)
+}
+
+func TestSamplingHandlerSamplingKeyCopied(t *testing.T) {
+	h := NewSamplingHandler(&testHandler{}, SampleLevel(LevelInfo, 1, 0))
+
+	key := []byte("volatile")
+	_ = h.Handle(context.Background(), Entry{Level: LevelInfo, Time: time.Now(),
+		Fields: []Field{SamplingKey(unsafe.String(&key[0], len(key)))}})
+	copy(key, "mutated!")
+
+	_, ok := h.keyed.Load(samplingKeyID{LevelInfo, "volatile"})
+	assert.True(t, ok)
+}
@@ -11,7 +11,7 @@ import (
 // setups, reach for NewRouter + SlabWriter instead.
 //
 // Defaults: JSON encoder, LevelDebug, os.Stderr, caller enabled,
-// no ContextHandler.
+// no ContextHandler, no sampling.
 //
 //	logger := logf.NewLogger().Build()
 //
@@ -27,7 +27,8 @@ func NewLogger() *LoggerBuilder {
 }
 
 // LoggerBuilder accumulates options and builds a Logger with a sync
-// pipeline: Encoder -> SyncHandler -> [ContextHandler] -> Logger.
+// pipeline: Encoder -> SyncHandler -> [SamplingHandler] -> [ContextHandler]
+// -> Logger.
 // Chain its methods and finish with Build. For advanced multi-destination
 // pipelines, use NewRouter directly.
 type LoggerBuilder struct {
@@ -37,6 +38,9 @@ type LoggerBuilder struct {
 	w       io.Writer
 	context bool
 	sources []FieldSource
+
+	sampling     bool
+	samplingOpts []SamplingOption
 }
 
 // Level sets the minimum severity level. Messages below this level are
@@ -83,6 +87,16 @@ func (b *LoggerBuilder) Context(sources ...FieldSource) *LoggerBuilder {
 	return b
 }
 
+// Sampling enables the SamplingHandler middleware, which thins out
+// Debug and Info entries while always passing Warn and Error. Without
+// options the default policy applies (first 100 entries per second,
+// then every 100th); see NewSamplingHandler.
+func (b *LoggerBuilder) Sampling(opts ...SamplingOption) *LoggerBuilder {
+	b.sampling = true
+	b.samplingOpts = append(b.samplingOpts, opts...)
+	return b
+}
+
 // Build finalizes the configuration and returns a ready-to-use Logger.
 //
 //	logger := logf.NewLogger().Build()
@@ -106,6 +120,9 @@ func (b *LoggerBuilder) Build() *Logger {
 
 	// Handler pipeline.
 	var h Handler = NewSyncHandler(b.level, w, enc)
+	if b.sampling {
+		h = NewSamplingHandler(h, b.samplingOpts...)
+	}
 	if b.context {
 		h = NewContextHandler(h, b.sources...)
 	}
@@ -15,8 +15,27 @@ import (
 // Fields added with [slog.Logger.With] become [Entry.LoggerBag] (cached by
 // the encoder). The handler propagates context to [Handler.Handle], so
 // field bags attached via [With] are resolved by [NewContextHandler].
-func NewSlogHandler(w Handler) slog.Handler {
-	return &slogHandler{w: w, addCaller: true}
+func NewSlogHandler(w Handler, opts ...SlogOption) slog.Handler {
+	h := &slogHandler{w: w, addCaller: true}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// SlogOption configures the handler built by NewSlogHandler.
+type SlogOption func(*slogHandler)
+
+// SlogReplaceField sets a hook called for every attribute, converted to a
+// Field, with the names of the groups it is nested in — the slog
+// counterpart of ReplaceAttr with the semantics of
+// JSONEncoderConfig.ReplaceField. Attributes added with
+// [slog.Logger.With] are replaced once, when the logger is created.
+// Built-in fields are left to the encoder's own ReplaceField.
+func SlogReplaceField(fn func(groups []string, f Field) Field) SlogOption {
+	return func(h *slogHandler) {
+		h.replace = fn
+	}
 }
 
 type slogHandler struct {
@@ -25,11 +44,13 @@ type slogHandler struct {
 	bag       *Bag
 	name      string
 	addCaller bool
+	replace   func(groups []string, f Field) Field
+	groups    []string
 }
 
 // Enabled reports whether the handler is enabled for the given level.
 func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return h.w.Enabled(ctx, slogLevelToLogf(level))
+	return enabledName(h.w, ctx, h.name, slogLevelToLogf(level))
 }
 
 // Handle converts a slog.Record to a logf.Entry and writes it.
@@ -46,9 +67,11 @@ func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 
 	return &slogHandler{
 		w:         h.w,
-		bag:       h.bag.With(convertAttrs(attrs)...),
+		bag:       h.bag.With(h.replaceFields(convertAttrs(attrs))...),
 		name:      h.name,
 		addCaller: h.addCaller,
+		replace:   h.replace,
+		groups:    h.groups,
 	}
 }
 
@@ -64,6 +87,8 @@ func (h *slogHandler) WithGroup(name string) slog.Handler {
 		bag:       h.bag.WithGroup(name),
 		name:      h.name,
 		addCaller: h.addCaller,
+		replace:   h.replace,
+		groups:    append(h.groups[:len(h.groups):len(h.groups)], name),
 	}
 }
 
@@ -98,7 +123,37 @@ func (h *slogHandler) collectRecordFields(r slog.Record) []Field {
 		return true
 	})
 
-	return fields
+	return h.replaceFields(fields)
+}
+
+// replaceFields passes fields through the ReplaceField hook, if any,
+// dropping the fields it empties.
+func (h *slogHandler) replaceFields(fs []Field) []Field {
+	if h.replace == nil {
+		return fs
+	}
+	return replaceFieldsIn(h.replace, h.groups, fs)
+}
+
+func replaceFieldsIn(replace func([]string, Field) Field, groups []string, fs []Field) []Field {
+	out := fs[:0]
+	for _, f := range fs {
+		if f.Type == FieldTypeGroup {
+			sub := groups
+			if f.Key != "" {
+				sub = append(groups[:len(groups):len(groups)], f.Key)
+			}
+			members, _ := f.Any.([]Field)
+			f.Any = replaceFieldsIn(replace, sub, members)
+		} else if f.Type != FieldTypeSamplingKey {
+			// Pseudo-fields are not written, so they are not replaced.
+			if f = replace(groups, f); f.Type == FieldTypeUnknown {
+				continue
+			}
+		}
+		out = append(out, f)
+	}
+	return out
 }
 
 func slogLevelToLogf(l slog.Level) Level {
@@ -146,6 +201,13 @@ func attrToField(a slog.Attr) Field {
 		return Field{Key: key, Type: FieldTypeDuration, Val: int64(v.Duration())}
 	case slog.KindGroup:
 		return groupAttrToField(key, v.Group())
+	case slog.KindAny:
+		// Pass logf pseudo-fields (e.g. SamplingKey) through as is —
+		// the attribute key is irrelevant for them.
+		if f, ok := v.Any().(Field); ok && f.Type == FieldTypeSamplingKey {
+			return f
+		}
+		return Any(key, v.Any())
 	default:
 		return Any(key, v.Any())
 	}
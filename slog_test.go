@@ -301,3 +301,49 @@ func TestSlogHandlerImmutability(t *testing.T) {
 		}
 	}
 }
+
+func TestSlogHandlerSamplingKey(t *testing.T) {
+	sink := newSink()
+	slog.New(NewSlogHandler(sink)).Info("sampled",
+		slog.Any("ignored", SamplingKey("http.request")),
+		slog.Int("status", 200),
+	)
+
+	e := sink.last()
+	if len(e.Fields) != 2 || e.Fields[0].Type != FieldTypeSamplingKey || e.Fields[0].Key != "http.request" {
+		t.Fatalf("sampling key not passed through: %+v", e.Fields)
+	}
+	got := sink.lastJSON()
+	want := `{"level":"info","msg":"sampled","status":200}`
+	if got != want {
+		t.Errorf("\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestSlogHandlerReplaceField(t *testing.T) {
+	sink := newSink()
+	var seen []string
+	h := NewSlogHandler(sink, SlogReplaceField(func(groups []string, f Field) Field {
+		seen = append(seen, strings.Join(append(groups, f.Key), "."))
+		switch f.Key {
+		case "password":
+			return Field{}
+		case "user":
+			return String("user.name", "alice")
+		}
+		return f
+	}))
+
+	l := slog.New(h).With("password", "secret").WithGroup("http").With("user", 1)
+	l.Info("req", "password", "x", slog.Group("req", "user", 2), slog.Any("ignored", SamplingKey("k")))
+
+	got := sink.lastJSON()
+	want := `{"level":"info","msg":"req","http":{"user.name":"alice","req":{"user.name":"alice"}}}`
+	if got != want {
+		t.Errorf("\n got: %s\nwant: %s", got, want)
+	}
+	wantSeen := []string{"password", "http.user", "http.password", "http.req.user"}
+	if strings.Join(seen, ",") != strings.Join(wantSeen, ",") {
+		t.Errorf("groups:\n got: %v\nwant: %v", seen, wantSeen)
+	}
+}
@@ -0,0 +1,824 @@
+package logf
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// SyslogFacility is the facility part of the syslog priority.
+type SyslogFacility int
+
+// Syslog facilities (RFC 5424, section 6.2.1). The kernel facility is
+// left out, as user processes cannot log as the kernel.
+const (
+	SyslogUser     SyslogFacility = 1
+	SyslogMail     SyslogFacility = 2
+	SyslogDaemon   SyslogFacility = 3
+	SyslogAuth     SyslogFacility = 4
+	SyslogSyslog   SyslogFacility = 5
+	SyslogLPR      SyslogFacility = 6
+	SyslogNews     SyslogFacility = 7
+	SyslogUUCP     SyslogFacility = 8
+	SyslogCron     SyslogFacility = 9
+	SyslogAuthPriv SyslogFacility = 10
+	SyslogFTP      SyslogFacility = 11
+	SyslogLocal0   SyslogFacility = 16
+	SyslogLocal1   SyslogFacility = 17
+	SyslogLocal2   SyslogFacility = 18
+	SyslogLocal3   SyslogFacility = 19
+	SyslogLocal4   SyslogFacility = 20
+	SyslogLocal5   SyslogFacility = 21
+	SyslogLocal6   SyslogFacility = 22
+	SyslogLocal7   SyslogFacility = 23
+)
+
+// Maximum lengths of the RFC 5424 header fields and SD names.
+const (
+	syslogMaxHostname = 255
+	syslogMaxAppName  = 48
+	syslogMaxProcID   = 128
+	syslogMaxMsgID    = 32
+	syslogMaxSDName   = 32
+	syslogMaxTag      = 32
+
+	rfc5424TimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// SyslogEncoderConfig controls how the syslog encoder formats log
+// entries. For a friendlier builder-style API, use Syslog() instead.
+type SyslogEncoderConfig struct {
+	// Facility of every message. Defaults to SyslogUser.
+	Facility SyslogFacility
+
+	// Header fields. Hostname defaults to os.Hostname(), AppName to the
+	// base name of the executable, ProcID to the process id, and MsgID
+	// to the nil value "-".
+	Hostname string
+	AppName  string
+	ProcID   string
+	MsgID    string
+
+	// StructuredDataID is the SD-ID of the element holding the fields,
+	// "name@<private enterprise number>" (RFC 5424, section 7.2.2). With
+	// none the fields are appended to the message as logfmt.
+	StructuredDataID string
+
+	// RFC3164 switches to the legacy BSD syslog format.
+	RFC3164 bool
+
+	DisableFieldName   bool
+	DisableFieldCaller bool
+
+	EncodeTime     TimeEncoder
+	EncodeDuration DurationEncoder
+	EncodeError    ErrorEncoder
+	EncodeCaller   CallerEncoder
+}
+
+// WithDefaults returns a copy of the config with all zero-value fields
+// replaced by sensible defaults (user facility, the local host name,
+// executable name and process id, RFC3339 times, string durations, short
+// caller format, etc.).
+func (c SyslogEncoderConfig) WithDefaults() SyslogEncoderConfig {
+	if c.Facility == 0 {
+		c.Facility = SyslogUser
+	}
+	if c.Hostname == "" {
+		c.Hostname, _ = os.Hostname()
+	}
+	if c.AppName == "" && len(os.Args) > 0 {
+		c.AppName = filepath.Base(os.Args[0])
+	}
+	if c.ProcID == "" {
+		c.ProcID = strconv.Itoa(os.Getpid())
+	}
+	if c.EncodeDuration == nil {
+		c.EncodeDuration = StringDurationEncoder
+	}
+	if c.EncodeTime == nil {
+		c.EncodeTime = RFC3339TimeEncoder
+	}
+	if c.EncodeError == nil {
+		c.EncodeError = DefaultErrorEncoder
+	}
+	if c.EncodeCaller == nil {
+		c.EncodeCaller = ShortCallerEncoder
+	}
+	return c
+}
+
+// SyslogEncoderBuilder configures and builds a syslog Encoder using a
+// clean builder-style API. Create one with Syslog(), chain methods to
+// customize, then call Build() or pass directly to
+// LoggerBuilder.EncoderFrom().
+type SyslogEncoderBuilder struct {
+	cfg SyslogEncoderConfig
+}
+
+// Syslog returns a new SyslogEncoderBuilder with default settings. The
+// encoder writes one RFC 5424 message per entry, with the fields appended
+// to the message as logfmt:
+//
+//	<14>1 2024-05-01T10:00:00.123000Z api-1 billing 4242 - - request served logger=http http.method=GET status=200
+//
+// The priority combines the facility with the syslog severity of Level:
+// error 3, warn 4, info 6 and debug 7. Set StructuredDataID to an SD-ID
+// under your organization's private enterprise number to write the logger
+// name, caller and all fields as parameters of a single STRUCTURED-DATA
+// element instead:
+//
+//	<14>1 2024-05-01T10:00:00.123000Z api-1 billing 4242 - [app@12345 logger="http" http.method="GET" status="200"] request served
+//
+// Parameters are flattened like logfmt: groups and objects become dotted
+// names, arrays and Any values are rendered as JSON. Bytes that are not
+// allowed in parameter names are replaced with '_' and names are cut to
+// 32 bytes. An entry without fields has the nil STRUCTURED-DATA "-".
+//
+// In RFC 3164 mode the encoder writes the legacy BSD format instead, with
+// the fields appended to the message as logfmt:
+//
+//	<14>May  1 10:00:00 api-1 billing[4242]: request served logger=http http.method=GET status=200
+//
+// Each message ends with '\n', which SyslogWriter strips.
+func Syslog() *SyslogEncoderBuilder {
+	return &SyslogEncoderBuilder{}
+}
+
+// Facility sets the facility of every message (default SyslogUser).
+func (b *SyslogEncoderBuilder) Facility(f SyslogFacility) *SyslogEncoderBuilder {
+	b.cfg.Facility = f
+	return b
+}
+
+// Hostname sets the HOSTNAME header field (default os.Hostname()).
+func (b *SyslogEncoderBuilder) Hostname(host string) *SyslogEncoderBuilder {
+	b.cfg.Hostname = host
+	return b
+}
+
+// AppName sets the APP-NAME header field, the tag in RFC 3164 mode
+// (default the executable name).
+func (b *SyslogEncoderBuilder) AppName(name string) *SyslogEncoderBuilder {
+	b.cfg.AppName = name
+	return b
+}
+
+// ProcID sets the PROCID header field (default the process id).
+func (b *SyslogEncoderBuilder) ProcID(id string) *SyslogEncoderBuilder {
+	b.cfg.ProcID = id
+	return b
+}
+
+// MsgID sets the MSGID header field (default "-").
+func (b *SyslogEncoderBuilder) MsgID(id string) *SyslogEncoderBuilder {
+	b.cfg.MsgID = id
+	return b
+}
+
+// StructuredDataID sets the SD-ID of the element holding the fields, such
+// as "app@12345" with the private enterprise number of your organization.
+// Without it the fields are appended to the message as logfmt.
+func (b *SyslogEncoderBuilder) StructuredDataID(id string) *SyslogEncoderBuilder {
+	b.cfg.StructuredDataID = id
+	return b
+}
+
+// RFC3164 switches to the legacy BSD syslog format for receivers that do
+// not understand RFC 5424.
+func (b *SyslogEncoderBuilder) RFC3164() *SyslogEncoderBuilder {
+	b.cfg.RFC3164 = true
+	return b
+}
+
+// DisableName omits the logger name.
+func (b *SyslogEncoderBuilder) DisableName() *SyslogEncoderBuilder {
+	b.cfg.DisableFieldName = true
+	return b
+}
+
+// DisableCaller omits the caller.
+func (b *SyslogEncoderBuilder) DisableCaller() *SyslogEncoderBuilder {
+	b.cfg.DisableFieldCaller = true
+	return b
+}
+
+// EncodeTime sets a custom TimeEncoder for Time fields (default RFC3339).
+// The message timestamp always uses the syslog format.
+func (b *SyslogEncoderBuilder) EncodeTime(e TimeEncoder) *SyslogEncoderBuilder {
+	b.cfg.EncodeTime = e
+	return b
+}
+
+// EncodeDuration sets a custom DurationEncoder for formatting durations (default string representation).
+func (b *SyslogEncoderBuilder) EncodeDuration(e DurationEncoder) *SyslogEncoderBuilder {
+	b.cfg.EncodeDuration = e
+	return b
+}
+
+// EncodeCaller sets a custom CallerEncoder for formatting caller locations (default short format).
+func (b *SyslogEncoderBuilder) EncodeCaller(e CallerEncoder) *SyslogEncoderBuilder {
+	b.cfg.EncodeCaller = e
+	return b
+}
+
+// EncodeError sets a custom ErrorEncoder for formatting error values.
+func (b *SyslogEncoderBuilder) EncodeError(e ErrorEncoder) *SyslogEncoderBuilder {
+	b.cfg.EncodeError = e
+	return b
+}
+
+// Build finalizes the configuration and returns a ready-to-use syslog
+// Encoder.
+func (b *SyslogEncoderBuilder) Build() Encoder {
+	return buildSyslogEncoder(b.cfg)
+}
+
+// NewSyslogEncoder creates a syslog Encoder from a SyslogEncoderConfig
+// struct. For a friendlier builder-style API, use Syslog() instead.
+func NewSyslogEncoder(cfg SyslogEncoderConfig) Encoder {
+	return buildSyslogEncoder(cfg)
+}
+
+func buildSyslogEncoder(cfg SyslogEncoderConfig) Encoder {
+	cfg = cfg.WithDefaults()
+	enc := &syslogEncoder{
+		SyslogEncoderConfig: cfg,
+		slot:                AllocEncoderSlot(),
+		json: &jsonEncoder{JSONEncoderConfig: JSONEncoderConfig{
+			EncodeTime:     cfg.EncodeTime,
+			EncodeDuration: cfg.EncodeDuration,
+			EncodeError:    cfg.EncodeError,
+		}.WithDefaults()},
+	}
+
+	// The constant parts of every message.
+	if cfg.RFC3164 || cfg.StructuredDataID == "" {
+		enc.logfmt = buildLogfmtEncoder(LogfmtEncoderConfig{
+			DisableFieldLevel:  true,
+			DisableFieldTime:   true,
+			DisableFieldMsg:    true,
+			DisableFieldName:   cfg.DisableFieldName,
+			DisableFieldCaller: cfg.DisableFieldCaller,
+			EncodeTime:         cfg.EncodeTime,
+			EncodeDuration:     cfg.EncodeDuration,
+			EncodeError:        cfg.EncodeError,
+			EncodeCaller:       cfg.EncodeCaller,
+		}).(*logfmtEncoder)
+	}
+	if cfg.RFC3164 {
+		enc.head = " " + syslogHeaderField(cfg.Hostname, syslogMaxHostname) +
+			" " + syslogHeaderField(cfg.AppName, syslogMaxTag)
+		if cfg.ProcID != "-" {
+			enc.head += "[" + syslogHeaderField(cfg.ProcID, syslogMaxProcID) + "]"
+		}
+		enc.head += ": "
+	} else {
+		enc.head = " " + syslogHeaderField(cfg.Hostname, syslogMaxHostname) +
+			" " + syslogHeaderField(cfg.AppName, syslogMaxAppName) +
+			" " + syslogHeaderField(cfg.ProcID, syslogMaxProcID) +
+			" " + syslogHeaderField(cfg.MsgID, syslogMaxMsgID) + " "
+		if cfg.StructuredDataID != "" {
+			enc.sdOpen = "[" + syslogSDName(cfg.StructuredDataID)
+		}
+	}
+
+	enc.pool = &sync.Pool{New: func() any {
+		return enc.Clone()
+	}}
+	return enc
+}
+
+type syslogEncoder struct {
+	SyslogEncoderConfig
+	pool   *sync.Pool
+	slot   int
+	head   string // " HOSTNAME APP-NAME PROCID MSGID " or " HOSTNAME TAG[PID]: "
+	sdOpen string // "[SD-ID"
+	json   *jsonEncoder
+	logfmt *logfmtEncoder // renders fields in the message, without sdOpen
+
+	// Internal state.
+	buf    *Buffer
+	prefix []byte // dotted name prefix of the current group or object
+}
+
+func (f *syslogEncoder) Clone() Encoder {
+	clone := &syslogEncoder{
+		SyslogEncoderConfig: f.SyslogEncoderConfig,
+		pool:                f.pool,
+		slot:                f.slot,
+		head:                f.head,
+		sdOpen:              f.sdOpen,
+		json:                &jsonEncoder{JSONEncoderConfig: f.json.JSONEncoderConfig},
+	}
+	if f.logfmt != nil {
+		clone.logfmt = f.logfmt.Clone().(*logfmtEncoder)
+	}
+	return clone
+}
+
+func (f *syslogEncoder) Encode(e Entry) (*Buffer, error) {
+	clone := f.pool.Get().(*syslogEncoder)
+
+	buf := GetBuffer()
+	err := clone.encode(buf, e)
+
+	clone.buf = nil
+	clone.prefix = clone.prefix[:0]
+	f.pool.Put(clone)
+
+	if err != nil {
+		buf.Free()
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (f *syslogEncoder) encode(buf *Buffer, e Entry) error {
+	f.buf = buf
+
+	buf.AppendByte('<')
+	buf.AppendInt(int64(int(f.Facility)*8 + syslogSeverity(e.Level)))
+	buf.AppendByte('>')
+	if f.RFC3164 {
+		return f.encodeRFC3164(buf, e)
+	}
+
+	buf.AppendString("1 ")
+	if e.Time.IsZero() {
+		buf.AppendByte('-')
+	} else {
+		buf.Data = e.Time.AppendFormat(buf.Data, rfc5424TimeLayout)
+	}
+	buf.AppendString(f.head)
+	if f.sdOpen == "" {
+		buf.AppendByte('-')
+		if e.Text != "" {
+			buf.AppendByte(' ')
+			buf.AppendString(e.Text)
+		}
+		return f.encodeLogfmt(buf, e, true)
+	}
+
+	// STRUCTURED-DATA: one element with all fields, or "-" if none.
+	sdStart := buf.Len()
+	buf.AppendString(f.sdOpen)
+	paramsStart := buf.Len()
+
+	if !f.DisableFieldName && e.LoggerName != "" {
+		f.EncodeFieldString("logger", e.LoggerName)
+	}
+
+	if !f.DisableFieldCaller && e.CallerPC != 0 {
+		f.addKey("caller")
+		cur := buf.Len()
+		f.EncodeCaller(e.CallerPC, f)
+		if buf.Len() == cur {
+			buf.AppendString("unknown")
+		}
+		buf.AppendByte('"')
+	}
+
+	// Skip trailing groups that would produce dangling prefixes.
+	loggerBag := e.LoggerBag
+	ctxBag := e.Bag
+	if len(e.Fields) == 0 {
+		loggerBag = skipTrailingGroups(loggerBag)
+		if !bagHasFields(loggerBag) {
+			ctxBag = skipTrailingGroups(ctxBag)
+		}
+	}
+
+	// Context fields (request-scoped).
+	f.encodeBag(ctxBag, true)
+
+	// Logger's fields (service-scoped).
+	f.encodeBag(loggerBag, flatBagCacheable(f.prefix))
+
+	// Entry's fields.
+	for i := range e.Fields {
+		e.Fields[i].Accept(f)
+	}
+
+	if buf.Len() == paramsStart {
+		buf.Truncate(sdStart)
+		buf.AppendByte('-')
+	} else {
+		buf.AppendByte(']')
+	}
+
+	if e.Text != "" {
+		buf.AppendByte(' ')
+		buf.AppendString(e.Text)
+	}
+	buf.AppendByte('\n')
+	return nil
+}
+
+// encodeRFC3164 writes the rest of a legacy BSD syslog message after the
+// priority.
+func (f *syslogEncoder) encodeRFC3164(buf *Buffer, e Entry) error {
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	buf.Data = t.AppendFormat(buf.Data, time.Stamp)
+	buf.AppendString(f.head)
+
+	// Legacy receivers split messages on newlines.
+	appendSyslogText(buf, e.Text)
+	return f.encodeLogfmt(buf, e, e.Text != "")
+}
+
+// encodeLogfmt appends the fields to the message as logfmt, after a space
+// if sep is set, and ends the message.
+func (f *syslogEncoder) encodeLogfmt(buf *Buffer, e Entry, sep bool) error {
+	start := buf.Len()
+	if sep {
+		buf.AppendByte(' ')
+	}
+	err := f.logfmt.encode(buf, e)
+	f.logfmt.buf = nil
+	f.logfmt.prefix = f.logfmt.prefix[:0]
+	f.logfmt.index = -1
+	if err != nil {
+		return err
+	}
+	// Drop the separator if there are no fields.
+	if sep && buf.Len() == start+2 {
+		buf.Truncate(start)
+		buf.AppendByte('\n')
+	}
+	return nil
+}
+
+func (f *syslogEncoder) encodeBag(bag *Bag, cache bool) {
+	if bag == nil {
+		return
+	}
+
+	// Group node: extend the name prefix, nothing to cache.
+	if bag.group != "" {
+		f.encodeBag(bag.parent, cache)
+		f.pushPrefix(bag.group)
+		return
+	}
+
+	// Field node: use cache.
+	if cache {
+		if data := bag.LoadCache(f.slot); data != nil {
+			f.buf.AppendBytes(data)
+			f.pushBagGroups(bag.parent)
+			return
+		}
+	}
+
+	start := f.buf.Len()
+
+	// Walk parent first to preserve field order (parent before child).
+	f.encodeBag(bag.parent, cache)
+
+	for _, field := range bag.fields {
+		field.Accept(f)
+	}
+
+	if cache && f.slot != 0 {
+		encoded := make([]byte, f.buf.Len()-start)
+		copy(encoded, f.buf.Data[start:])
+		bag.StoreCache(f.slot, encoded)
+	}
+}
+
+// pushBagGroups extends the name prefix with the groups of a Bag chain
+// whose fields were served from the cache.
+func (f *syslogEncoder) pushBagGroups(bag *Bag) {
+	if bag == nil {
+		return
+	}
+	f.pushBagGroups(bag.parent)
+	if bag.group != "" {
+		f.pushPrefix(bag.group)
+	}
+}
+
+// --- FieldEncoder ---
+
+func (f *syslogEncoder) EncodeFieldAny(k string, v interface{}) {
+	f.addKey(k)
+	f.EncodeTypeAny(v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldBool(k string, v bool) {
+	f.addKey(k)
+	f.buf.AppendBool(v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldInt64(k string, v int64) {
+	f.addKey(k)
+	f.buf.AppendInt(v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldUint64(k string, v uint64) {
+	f.addKey(k)
+	f.buf.AppendUint(v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldFloat64(k string, v float64) {
+	f.addKey(k)
+	f.buf.AppendFloat64(v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldDuration(k string, v time.Duration) {
+	f.addKey(k)
+	f.EncodeDuration(v, f)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldError(k string, v error) {
+	f.EncodeError(k, v, f)
+}
+
+func (f *syslogEncoder) EncodeFieldTime(k string, v time.Time) {
+	f.addKey(k)
+	f.EncodeTime(v, f)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldString(k string, v string) {
+	f.addKey(k)
+	appendSDValue(f.buf, v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldBytes(k string, v []byte) {
+	f.addKey(k)
+	f.EncodeTypeBytes(v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldStrings(k string, v []string) {
+	f.addKey(k)
+	f.EncodeTypeStrings(v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldInts64(k string, v []int64) {
+	f.addKey(k)
+	f.EncodeTypeInts64(v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldFloats64(k string, v []float64) {
+	f.addKey(k)
+	f.EncodeTypeFloats64(v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldDurations(k string, v []time.Duration) {
+	f.addKey(k)
+	f.EncodeTypeDurations(v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldArray(k string, v ArrayEncoder) {
+	f.addKey(k)
+	f.EncodeTypeArray(v)
+	f.buf.AppendByte('"')
+}
+
+func (f *syslogEncoder) EncodeFieldObject(k string, v ObjectEncoder) {
+	if k == "" {
+		_ = v.EncodeLogfObject(f)
+		return
+	}
+	saved := len(f.prefix)
+	f.pushPrefix(k)
+	_ = v.EncodeLogfObject(f)
+	f.prefix = f.prefix[:saved]
+}
+
+func (f *syslogEncoder) EncodeFieldGroup(k string, fs []Field) {
+	if k == "" {
+		// Inline group: emit fields at current level.
+		for _, field := range fs {
+			field.Accept(f)
+		}
+		return
+	}
+	saved := len(f.prefix)
+	f.pushPrefix(k)
+	for _, field := range fs {
+		field.Accept(f)
+	}
+	f.prefix = f.prefix[:saved]
+}
+
+// --- TypeEncoder ---
+//
+// Type-level methods write the inside of a parameter value. They are
+// called by time, duration and caller encoders; containers are rendered
+// as JSON.
+
+func (f *syslogEncoder) EncodeTypeAny(v interface{}) {
+	tmp := GetBuffer()
+	f.json.TypeEncoder(tmp).EncodeTypeAny(v)
+	f.flushJSON(tmp)
+}
+
+func (f *syslogEncoder) EncodeTypeBool(v bool) {
+	f.buf.AppendBool(v)
+}
+
+func (f *syslogEncoder) EncodeTypeInt64(v int64) {
+	f.buf.AppendInt(v)
+}
+
+func (f *syslogEncoder) EncodeTypeUint64(v uint64) {
+	f.buf.AppendUint(v)
+}
+
+func (f *syslogEncoder) EncodeTypeFloat64(v float64) {
+	f.buf.AppendFloat64(v)
+}
+
+func (f *syslogEncoder) EncodeTypeDuration(v time.Duration) {
+	f.EncodeDuration(v, f)
+}
+
+func (f *syslogEncoder) EncodeTypeTime(v time.Time) {
+	f.EncodeTime(v, f)
+}
+
+func (f *syslogEncoder) EncodeTypeString(v string) {
+	appendSDValue(f.buf, v)
+}
+
+func (f *syslogEncoder) EncodeTypeUnsafeBytes(v unsafe.Pointer) {
+	appendSDValue(f.buf, *(*[]byte)(v))
+}
+
+func (f *syslogEncoder) EncodeTypeBytes(v []byte) {
+	base64.StdEncoding.Encode(f.buf.ExtendBytes(base64.StdEncoding.EncodedLen(len(v))), v)
+}
+
+func (f *syslogEncoder) EncodeTypeStrings(v []string) {
+	tmp := GetBuffer()
+	f.json.TypeEncoder(tmp).EncodeTypeStrings(v)
+	f.flushJSON(tmp)
+}
+
+func (f *syslogEncoder) EncodeTypeInts64(v []int64) {
+	tmp := GetBuffer()
+	f.json.TypeEncoder(tmp).EncodeTypeInts64(v)
+	f.flushJSON(tmp)
+}
+
+func (f *syslogEncoder) EncodeTypeFloats64(v []float64) {
+	tmp := GetBuffer()
+	f.json.TypeEncoder(tmp).EncodeTypeFloats64(v)
+	f.flushJSON(tmp)
+}
+
+func (f *syslogEncoder) EncodeTypeDurations(v []time.Duration) {
+	tmp := GetBuffer()
+	f.json.TypeEncoder(tmp).EncodeTypeDurations(v)
+	f.flushJSON(tmp)
+}
+
+func (f *syslogEncoder) EncodeTypeArray(v ArrayEncoder) {
+	tmp := GetBuffer()
+	f.json.TypeEncoder(tmp).EncodeTypeArray(v)
+	f.flushJSON(tmp)
+}
+
+func (f *syslogEncoder) EncodeTypeObject(v ObjectEncoder) {
+	tmp := GetBuffer()
+	f.json.TypeEncoder(tmp).EncodeTypeObject(v)
+	f.flushJSON(tmp)
+}
+
+// --- helpers ---
+
+// addKey opens a parameter: ` prefix.k="`.
+func (f *syslogEncoder) addKey(k string) {
+	buf := f.buf
+	buf.AppendByte(' ')
+	start := buf.Len()
+	buf.AppendBytes(f.prefix)
+	buf.AppendString(k)
+	if buf.Len() == start {
+		buf.AppendByte('_')
+	}
+	for i := start; i < buf.Len(); i++ {
+		if !isSDNameByte(buf.Data[i]) {
+			buf.Data[i] = '_'
+		}
+	}
+	if buf.Len()-start > syslogMaxSDName {
+		buf.Truncate(start + syslogMaxSDName)
+	}
+	buf.AppendString(`="`)
+}
+
+func (f *syslogEncoder) pushPrefix(k string) {
+	f.prefix = append(f.prefix, k...)
+	f.prefix = append(f.prefix, '.')
+}
+
+// flushJSON appends the JSON value in tmp as a parameter value and frees
+// tmp.
+func (f *syslogEncoder) flushJSON(tmp *Buffer) {
+	appendSDValue(f.buf, tmp.Data)
+	tmp.Free()
+	f.json.buf = nil
+}
+
+// appendSDValue appends s as the inside of an SD parameter value,
+// escaping '"', '\' and ']' with a backslash and replacing invalid UTF-8
+// with U+FFFD.
+func appendSDValue[S string | []byte](buf *Buffer, s S) {
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c == '"' || c == '\\' || c == ']' {
+				appendBuf(buf, s[start:i])
+				buf.AppendByte('\\')
+				buf.AppendByte(c)
+				start = i + 1
+			}
+			i++
+			continue
+		}
+		r, size := decodeRuneIn(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			appendBuf(buf, s[start:i])
+			buf.AppendString("�")
+			start = i + 1
+		}
+		i += size
+	}
+	appendBuf(buf, s[start:])
+}
+
+// appendSyslogText appends s replacing control characters with spaces, so
+// that a message cannot break the framing of legacy receivers.
+func appendSyslogText(buf *Buffer, s string) {
+	start := buf.Len()
+	buf.AppendString(s)
+	for i := start; i < buf.Len(); i++ {
+		if c := buf.Data[i]; c < ' ' || c == 0x7f {
+			buf.Data[i] = ' '
+		}
+	}
+}
+
+// syslogHeaderField returns s fit for an RFC 5424 header field: bytes
+// outside printable US-ASCII replaced with '_', cut to max bytes, and
+// "-" if empty.
+func syslogHeaderField(s string, max int) string {
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		return "-"
+	}
+	b := []byte(s)
+	for i, c := range b {
+		if c <= ' ' || c > '~' {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// syslogSDName returns s fit for an SD-ID.
+func syslogSDName(s string) string {
+	if len(s) > syslogMaxSDName {
+		s = s[:syslogMaxSDName]
+	}
+	b := []byte(s)
+	for i, c := range b {
+		if !isSDNameByte(c) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// isSDNameByte reports whether c is allowed in an SD-NAME: printable
+// US-ASCII except '=', ' ', ']' and '"'.
+func isSDNameByte(c byte) bool {
+	return c > ' ' && c <= '~' && c != '=' && c != ']' && c != '"'
+}
@@ -0,0 +1,223 @@
+package logf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogEncoder(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	head := `1 2024-05-01T10:00:00.123456Z api-1 billing 4242 - `
+	testCases := []encoderTestCase{
+		{
+			"Header",
+			Entry{Level: LevelInfo, Time: ts, LoggerName: "http", Text: "request served"},
+			`<14>` + head + `[logf@32473 logger="http"] request served` + "\n",
+		},
+		{
+			"NoFields",
+			Entry{Level: LevelError, Time: ts, Text: "boom"},
+			`<11>` + head + `- boom` + "\n",
+		},
+		{
+			"NoMessage",
+			Entry{Level: LevelDebug, Fields: []Field{Int("n", 1)}},
+			`<15>1 - api-1 billing 4242 - [logf@32473 n="1"]` + "\n",
+		},
+		{
+			"Fields",
+			Entry{Level: LevelWarn, Time: ts, Text: "m", Fields: []Field{
+				String("s", `a"b\c]d`), Bool("ok", true), Float64("f", 0.5), Uint64("u", 7),
+				Duration("d", time.Second), Time("t", ts.Truncate(time.Second)),
+				Bytes("b", []byte("hi")), NamedError("err", errors.New("boom")),
+				String("utf8", "привет\xff"),
+			}},
+			`<12>` + head + `[logf@32473 s="a\"b\\c\]d" ok="true" f="0.5" u="7" d="1s" t="2024-05-01T10:00:00Z"` +
+				` b="aGk=" err="boom" utf8="привет�"] m` + "\n",
+		},
+		{
+			"Flattening",
+			Entry{Level: LevelInfo, Time: ts, Text: "m", Fields: []Field{
+				Group("http", String("method", "GET"), Group("req", Int("size", 10))),
+				Group("", String("inline", "x")),
+				Group("empty"),
+				Object("user", testObjectEncoder{}),
+				Strings("tags", []string{"a", "b]"}),
+				Ints64("ids", []int64{1, 2}),
+				Array("arr", testArrayEncoder{}),
+				Any("map", map[string]int{"a": 1}),
+			}},
+			`<14>` + head + `[logf@32473 http.method="GET" http.req.size="10" inline="x" user.username="username"` +
+				` user.code="42" tags="[\"a\",\"b\]\"\]" ids="[1,2\]" arr="[42\]" map="{\"a\":1}"] m` + "\n",
+		},
+		{
+			"Names",
+			Entry{Level: LevelInfo, Time: ts, Text: "m", Fields: []Field{
+				String("a b", "1"), String(`a=]"`, "2"), String("", "3"),
+				String(strings.Repeat("k", 40), "4"),
+			}},
+			`<14>` + head + `[logf@32473 a_b="1" a___="2" _="3" ` + strings.Repeat("k", 32) + `="4"] m` + "\n",
+		},
+		{
+			"Bags",
+			Entry{
+				Level:     LevelInfo,
+				Time:      ts,
+				Text:      "m",
+				Bag:       NewBag(String("request_id", "r1")),
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http").With(String("method", "GET")),
+				Fields:    []Field{Int("status", 200)},
+			},
+			`<14>` + head + `[logf@32473 request_id="r1" service="api" http.method="GET" http.status="200"] m` + "\n",
+		},
+		{
+			"BagTrailingGroup",
+			Entry{Level: LevelInfo, Time: ts, Text: "m", LoggerBag: NewBag().WithGroup("http")},
+			`<14>` + head + `- m` + "\n",
+		},
+	}
+
+	enc := Syslog().Hostname("api-1").AppName("billing").ProcID("4242").StructuredDataID("logf@32473").Build()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden, b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestSyslogEncoderRFC3164(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	testCases := []encoderTestCase{
+		{
+			"Fields",
+			Entry{
+				Level: LevelInfo, Time: ts, LoggerName: "http", Text: "request served",
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http"),
+				Fields:    []Field{String("method", "GET")},
+			},
+			"<134>May  1 10:00:00 api-1 billing[4242]: request served logger=http service=api http.method=GET\n",
+		},
+		{
+			"NoFields",
+			Entry{Level: LevelWarn, Time: ts, Text: "line one\nline two"},
+			"<132>May  1 10:00:00 api-1 billing[4242]: line one line two\n",
+		},
+		{
+			"NoMessage",
+			Entry{Level: LevelError, Time: ts, Fields: []Field{Int("n", 1)}},
+			"<131>May  1 10:00:00 api-1 billing[4242]: n=1\n",
+		},
+	}
+
+	enc := Syslog().RFC3164().Facility(SyslogLocal0).Hostname("api-1").AppName("billing").ProcID("4242").Build()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden, b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestSyslogEncoderNoStructuredDataID(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	head := `1 2024-05-01T10:00:00.123456Z api-1 billing 4242 - - `
+	testCases := []encoderTestCase{
+		{
+			"Fields",
+			Entry{
+				Level: LevelInfo, Time: ts, LoggerName: "http", Text: "request served",
+				LoggerBag: NewBag(String("service", "api")).WithGroup("http"),
+				Fields:    []Field{String("method", "GET")},
+			},
+			`<14>` + head + `request served logger=http service=api http.method=GET` + "\n",
+		},
+		{
+			"NoFields",
+			Entry{Level: LevelError, Time: ts, Text: "boom"},
+			`<11>` + head + `boom` + "\n",
+		},
+		{
+			"NoMessage",
+			Entry{Level: LevelDebug, Time: ts, Fields: []Field{Int("n", 1)}},
+			`<15>` + head + `n=1` + "\n",
+		},
+	}
+
+	enc := Syslog().Hostname("api-1").AppName("billing").ProcID("4242").Build()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden, b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestSyslogEncoderBagCache(t *testing.T) {
+	enc := withCacheSlot(Syslog().Hostname("h").AppName("a").ProcID("1").StructuredDataID("logf@32473").Build())
+	logger := NewBag(String("a b", "x]"))
+
+	// The logger Bag is cached as SD-PARAMs, with the name sanitized and
+	// the value escaped; a context group has to prefix the name instead.
+	cases := []struct {
+		entry Entry
+		want  string
+	}{
+		{Entry{LoggerBag: logger}, `[logf@32473 a_b="x\]"]`},
+		{Entry{Bag: NewBag().WithGroup("req"), LoggerBag: logger, Fields: []Field{Int("n", 1)}}, `[logf@32473 req.a_b="x\]" req.n="1"]`},
+		{Entry{Bag: NewBag(String("request_id", "r1")), LoggerBag: logger}, `[logf@32473 request_id="r1" a_b="x\]"]`},
+	}
+	for _, c := range cases {
+		c.entry.Level, c.entry.Text = LevelInfo, "m"
+		b, err := enc.Encode(c.entry)
+		require.NoError(t, err)
+		assert.Equal(t, "<14>1 - h a 1 - "+c.want+" m\n", b.String())
+		b.Free()
+	}
+}
+
+func TestSyslogEncoderCaller(t *testing.T) {
+	enc := Syslog().Hostname("h").AppName("a").ProcID("1").StructuredDataID("logf@32473").Build()
+	b, err := enc.Encode(Entry{Level: LevelInfo, Text: "m", CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	assert.Regexp(t, `^<14>1 - h a 1 - \[logf@32473 caller="\S+syslogencoder_test.go:\d+"\] m\n$`, b.String())
+	b.Free()
+
+	enc = Syslog().Hostname("h").AppName("a").ProcID("1").DisableCaller().DisableName().Build()
+	b, err = enc.Encode(Entry{Level: LevelInfo, LoggerName: "n", Text: "m", CallerPC: CallerPC(0)})
+	require.NoError(t, err)
+	assert.Equal(t, "<14>1 - h a 1 - - m\n", b.String())
+	b.Free()
+}
+
+func TestNewSyslogEncoder(t *testing.T) {
+	enc := NewSyslogEncoder(SyslogEncoderConfig{
+		Facility:         SyslogDaemon,
+		Hostname:         "my host",
+		AppName:          "app",
+		ProcID:           "-",
+		MsgID:            "REQ",
+		StructuredDataID: "app@12345",
+		EncodeDuration:   NanoDurationEncoder,
+	})
+	b, err := enc.Encode(Entry{Level: LevelInfo, Text: "m", Fields: []Field{Duration("d", time.Second)}})
+	require.NoError(t, err)
+	assert.Equal(t, `<30>1 - my_host app - REQ [app@12345 d="1000000000"] m`+"\n", b.String())
+	b.Free()
+
+	cfg := NewSyslogEncoder(SyslogEncoderConfig{}).(*syslogEncoder).SyslogEncoderConfig
+	assert.Equal(t, SyslogUser, cfg.Facility)
+	assert.NotEmpty(t, cfg.AppName)
+	assert.NotEmpty(t, cfg.ProcID)
+}
@@ -0,0 +1,188 @@
+package logf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// syslogLocalPaths are the sockets of the local syslog daemon, tried in
+// order.
+var syslogLocalPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// NewSyslogWriter returns a Writer that sends each Write as one syslog
+// message. network is "unixgram", "udp" or "tcp"; an empty network or
+// an empty unixgram address means the local syslog daemon at /dev/log:
+//
+//	w, err := logf.NewSyslogWriter("", "")
+//	router, close, _ := logf.NewRouter().
+//	    Route(logf.Syslog().Build(), logf.OutputCloser(logf.LevelInfo, w)).
+//	    Build()
+//
+// Over unixgram and UDP every message is one datagram. Over TCP messages
+// are framed by octet counting (RFC 6587): the length in decimal and a
+// space precede each message. SyslogNewlineFraming switches to a
+// trailing newline for receivers that predate RFC 6587. A failed write
+// reconnects and retries once.
+//
+// Every Write must carry exactly one message, as Router outputs do. Do
+// not put a SlabWriter in front of it, since it batches messages; use an
+// AsyncHandler to move sending off the logging goroutine instead. A
+// trailing newline, as written by the syslog encoder, is stripped.
+// SyslogWriter is safe for concurrent use.
+func NewSyslogWriter(network, addr string, opts ...SyslogWriterOption) (*SyslogWriter, error) {
+	w := &SyslogWriter{
+		network:     network,
+		addr:        addr,
+		dialTimeout: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	switch network {
+	case "":
+		if addr != "" {
+			return nil, errors.New("logf: syslog address without a network")
+		}
+		w.network = "unixgram"
+	case "unixgram", "udp", "udp4", "udp6":
+	case "tcp", "tcp4", "tcp6":
+		w.stream = true
+	default:
+		return nil, fmt.Errorf("logf: unsupported syslog network %q", network)
+	}
+
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// SyslogWriterOption configures a SyslogWriter at creation time.
+type SyslogWriterOption func(*SyslogWriter)
+
+// SyslogNewlineFraming frames TCP messages with a trailing newline
+// (non-transparent framing) instead of octet counting. Newlines inside
+// messages then split them, so pair it with the RFC 3164 encoder mode,
+// which replaces them.
+func SyslogNewlineFraming() SyslogWriterOption {
+	return func(w *SyslogWriter) {
+		w.newline = true
+	}
+}
+
+// SyslogDialTimeout sets the timeout for connecting to the receiver
+// (default 5s).
+func SyslogDialTimeout(d time.Duration) SyslogWriterOption {
+	return func(w *SyslogWriter) {
+		w.dialTimeout = d
+	}
+}
+
+// SyslogWriter is the Writer built by NewSyslogWriter.
+type SyslogWriter struct {
+	network     string
+	addr        string
+	stream      bool
+	newline     bool
+	dialTimeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	frame  []byte
+	closed bool
+}
+
+// Write sends p as one syslog message.
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if n > 0 && p[n-1] == '\n' {
+		p = p[:n-1]
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, errors.New("logf: syslog writer is closed")
+	}
+
+	if w.stream {
+		if w.newline {
+			w.frame = append(append(w.frame[:0], p...), '\n')
+		} else {
+			w.frame = strconv.AppendInt(w.frame[:0], int64(len(p)), 10)
+			w.frame = append(w.frame, ' ')
+			w.frame = append(w.frame, p...)
+		}
+		p = w.frame
+	}
+
+	for attempt := 0; ; attempt++ {
+		if w.conn == nil {
+			if err := w.dial(); err != nil {
+				return 0, err
+			}
+		}
+		_, err := w.conn.Write(p)
+		if err == nil {
+			return n, nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+		if attempt > 0 {
+			return 0, err
+		}
+	}
+}
+
+// Flush is a no-op: every Write is sent immediately.
+func (w *SyslogWriter) Flush() error {
+	return nil
+}
+
+// Sync is a no-op: every Write is sent immediately.
+func (w *SyslogWriter) Sync() error {
+	return nil
+}
+
+// Close closes the connection. Writes after Close fail.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	return w.conn.Close()
+}
+
+func (w *SyslogWriter) dial() error {
+	if w.network != "unixgram" || w.addr != "" {
+		conn, err := net.DialTimeout(w.network, w.addr, w.dialTimeout)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+		return nil
+	}
+
+	// Local daemon: remember the first socket that works.
+	var err error
+	for _, path := range syslogLocalPaths {
+		var conn net.Conn
+		conn, err = net.DialTimeout("unixgram", path, w.dialTimeout)
+		if err == nil {
+			w.conn = conn
+			w.addr = path
+			return nil
+		}
+	}
+	return fmt.Errorf("logf: no local syslog daemon: %w", err)
+}
@@ -0,0 +1,169 @@
+package logf
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readSyslogDatagram(t *testing.T, pc net.PacketConn) string {
+	t.Helper()
+	require.NoError(t, pc.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, 65536)
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+// serveSyslogTCP accepts connections on ln and sends every received
+// frame, as returned by read, to the returned channel.
+func serveSyslogTCP(ln net.Listener, read func(*bufio.Reader) (string, error)) <-chan string {
+	received := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				r := bufio.NewReader(conn)
+				for {
+					msg, err := read(r)
+					if err != nil {
+						return
+					}
+					received <- msg
+				}
+			}()
+		}
+	}()
+	return received
+}
+
+// readOctetCounted reads one RFC 6587 octet-counted frame.
+func readOctetCounted(r *bufio.Reader) (string, error) {
+	prefix, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, n)
+	_, err = io.ReadFull(r, msg)
+	return string(msg), err
+}
+
+func TestSyslogWriterUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	w, err := NewSyslogWriter("udp", pc.LocalAddr().String())
+	require.NoError(t, err)
+
+	enc := Syslog().Hostname("h").AppName("a").ProcID("1").DisableCaller().Build()
+	router, closeRouter, err := NewRouter().Route(enc, OutputCloser(LevelInfo, w)).Build()
+	require.NoError(t, err)
+	New(router).Info(context.Background(), "hello", String("k", "v"))
+
+	msg := readSyslogDatagram(t, pc)
+	assert.Regexp(t, `^<14>1 \S+ h a 1 - - hello k=v$`, msg, "the trailing newline is stripped")
+
+	closeRouter()
+	_, err = w.Write([]byte("x"))
+	assert.Error(t, err, "closed by the router")
+}
+
+func TestSyslogWriterUnixgram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	pc, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer pc.Close()
+
+	w, err := NewSyslogWriter("unixgram", path)
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("<14>1 - h a 1 - - one\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "<14>1 - h a 1 - - one", readSyslogDatagram(t, pc))
+
+	// The local daemon is looked up in the well-known places.
+	saved := syslogLocalPaths
+	defer func() { syslogLocalPaths = saved }()
+	syslogLocalPaths = []string{filepath.Join(t.TempDir(), "missing"), path}
+	local, err := NewSyslogWriter("", "")
+	require.NoError(t, err)
+	defer local.Close()
+	assert.Equal(t, path, local.addr)
+	_, err = local.Write([]byte("two"))
+	require.NoError(t, err)
+	assert.Equal(t, "two", readSyslogDatagram(t, pc))
+
+	syslogLocalPaths = []string{filepath.Join(t.TempDir(), "missing")}
+	_, err = NewSyslogWriter("", "")
+	assert.Error(t, err)
+}
+
+func TestSyslogWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	received := serveSyslogTCP(ln, readOctetCounted)
+
+	w, err := NewSyslogWriter("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("<14>1 - h a 1 - - multi\nline\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("second"))
+	require.NoError(t, err)
+	assert.Equal(t, "<14>1 - h a 1 - - multi\nline", <-received)
+	assert.Equal(t, "second", <-received)
+
+	// A broken connection is replaced on the next write.
+	w.mu.Lock()
+	w.conn.Close()
+	w.mu.Unlock()
+	_, err = w.Write([]byte("third"))
+	require.NoError(t, err)
+	assert.Equal(t, "third", <-received)
+}
+
+func TestSyslogWriterTCPNewlineFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	received := serveSyslogTCP(ln, func(r *bufio.Reader) (string, error) {
+		return r.ReadString('\n')
+	})
+
+	w, err := NewSyslogWriter("tcp", ln.Addr().String(), SyslogNewlineFraming())
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("<14>May  1 10:00:00 h a[1]: one\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "<14>May  1 10:00:00 h a[1]: one\n", <-received)
+}
+
+func TestSyslogWriterOptions(t *testing.T) {
+	_, err := NewSyslogWriter("", "127.0.0.1:514")
+	assert.Error(t, err)
+	_, err = NewSyslogWriter("unix", "/dev/log")
+	assert.Error(t, err)
+	_, err = NewSyslogWriter("tcp", "127.0.0.1:1", SyslogDialTimeout(time.Second))
+	assert.Error(t, err, "nothing listens on port 1")
+}
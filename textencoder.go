@@ -5,6 +5,7 @@ import (
 	"math"
 	"sync"
 	"time"
+	"unicode/utf8"
 	"unsafe"
 )
 
@@ -20,6 +21,41 @@ type TextEncoderConfig struct {
 	DisableFieldMsg    bool
 	DisableFieldCaller bool
 
+	// DisableEscaping writes the message, logger name, keys and unquoted
+	// values as is. By default control characters, including newlines and
+	// ESC, C1 controls, line and paragraph separators and invalid UTF-8
+	// are escaped, so values from user input can neither forge log lines
+	// nor recolor the terminal.
+	DisableEscaping bool
+
+	// Multiline writes newlines in the message and in string values as
+	// line breaks. Continuation lines are indented with "  | " so that
+	// they cannot pass for entries. It has no effect with DisableEscaping,
+	// which writes newlines raw.
+	Multiline bool
+
+	// ReplaceField, if set, is called for every field before it is
+	// written, as JSONEncoderConfig.ReplaceField is. Built-in fields are
+	// passed with the DefaultFieldKey* keys; their keys are not shown.
+	ReplaceField func(groups []string, f Field) Field
+
+	// Pretty switches to the developer layout: the message column is
+	// aligned, entries that do not fit on a line get one field per line,
+	// nested objects and groups are indented and multi-line values such
+	// as stack traces are written line by line.
+	Pretty bool
+
+	// Width is the terminal width pretty mode wraps at. Zero means the
+	// COLUMNS environment variable, or 120 when it is not set.
+	Width int
+
+	// Limits bounds the message, values and the whole entry, as
+	// JSONEncoderConfig.Limits does; objects, arrays and Any values are
+	// limited as JSON. Groups are written as key prefixes and do not
+	// count towards MaxDepth. Pretty mode, meant for terminals, ignores
+	// the limits.
+	Limits SizeLimits
+
 	EncodeTime     TimeEncoder
 	EncodeDuration DurationEncoder
 	EncodeError    ErrorEncoder
@@ -46,6 +82,7 @@ func (c TextEncoderConfig) WithDefaults() TextEncoderConfig {
 	if c.EncodeCaller == nil {
 		c.EncodeCaller = ShortCallerEncoder
 	}
+	c.Limits = c.Limits.WithDefaults()
 	return c
 }
 
@@ -87,6 +124,59 @@ func (b *TextEncoderBuilder) NoColor() *TextEncoderBuilder {
 	return b
 }
 
+// DisableEscaping writes the message, logger name, keys and unquoted
+// values without escaping control characters. Only use it when no part of
+// an entry comes from user input: a newline or an ANSI escape sequence
+// can then forge log lines or recolor the terminal.
+func (b *TextEncoderBuilder) DisableEscaping() *TextEncoderBuilder {
+	b.cfg.DisableEscaping = true
+	return b
+}
+
+// Multiline writes newlines in the message and in string values, such as
+// stack traces, as line breaks with indented continuation lines:
+//
+//	Mar  2 09:10:34.000 [ERR] request failed
+//	  | retrying with backoff › attempt=3
+func (b *TextEncoderBuilder) Multiline() *TextEncoderBuilder {
+	b.cfg.Multiline = true
+	return b
+}
+
+// ReplaceField sets a hook that renames, converts or drops fields,
+// including Bag fields and the built-in ones, before they are written.
+// See JSONEncoderConfig.ReplaceField for details.
+func (b *TextEncoderBuilder) ReplaceField(fn func(groups []string, f Field) Field) *TextEncoderBuilder {
+	b.cfg.ReplaceField = fn
+	return b
+}
+
+// Pretty switches to the developer layout for local development:
+//
+//	10:00:00.123 [INF] http:  request served                 › status=200
+//	10:00:00.456 [ERR] http:  request failed → main.go:42
+//	    user:
+//	      name: alice
+//	    error: boom
+//	    error.verbose:
+//	      main.handler
+//	          /src/main.go:42
+//
+// Entries that fit the terminal stay on one line with their fields
+// aligned; larger ones get one field per line. Use Width to set the wrap
+// width.
+func (b *TextEncoderBuilder) Pretty() *TextEncoderBuilder {
+	b.cfg.Pretty = true
+	return b
+}
+
+// Width sets the terminal width the pretty layout wraps at (default
+// $COLUMNS, or 120).
+func (b *TextEncoderBuilder) Width(n int) *TextEncoderBuilder {
+	b.cfg.Width = n
+	return b
+}
+
 // DisableTime omits the timestamp from text output entirely.
 func (b *TextEncoderBuilder) DisableTime() *TextEncoderBuilder {
 	b.cfg.DisableFieldTime = true
@@ -147,6 +237,13 @@ func (b *TextEncoderBuilder) EncodeError(e ErrorEncoder) *TextEncoderBuilder {
 	return b
 }
 
+// Limits bounds the size of entries; see JSONEncoderBuilder.Limits.
+// Entries with anything truncated get a _truncated=true field.
+func (b *TextEncoderBuilder) Limits(l SizeLimits) *TextEncoderBuilder {
+	b.cfg.Limits = l
+	return b
+}
+
 // Build finalizes the configuration and returns a ready-to-use text Encoder.
 func (b *TextEncoderBuilder) Build() Encoder {
 	return buildTextEncoder(b.cfg)
@@ -155,11 +252,18 @@ func (b *TextEncoderBuilder) Build() Encoder {
 func buildTextEncoder(cfg TextEncoderConfig) Encoder {
 	cfg = cfg.WithDefaults()
 	// Shared JSON encoder for nested object/array/any rendering.
+	if cfg.Pretty {
+		cfg.Limits = SizeLimits{}
+	}
 	jsonTEF := buildJSONEncoder(JSONEncoderConfig{
 		EncodeTime:     cfg.EncodeTime,
 		EncodeDuration: cfg.EncodeDuration,
 		EncodeError:    cfg.EncodeError,
+		Limits:         cfg.Limits,
 	}).(TypeEncoderFactory)
+	if cfg.Pretty {
+		return buildPrettyEncoder(cfg, jsonTEF)
+	}
 	enc := &textEncoder{
 		TextEncoderConfig: cfg,
 		slot:              AllocEncoderSlot(),
@@ -167,12 +271,7 @@ func buildTextEncoder(cfg TextEncoderConfig) Encoder {
 		jsonTEF:           jsonTEF,
 	}
 	enc.pool = &sync.Pool{New: func() any {
-		return &textEncoder{
-			TextEncoderConfig: enc.TextEncoderConfig,
-			slot:              enc.slot,
-			eseq:              enc.eseq,
-			jsonTEF:           enc.jsonTEF,
-		}
+		return enc.Clone()
 	}}
 	return enc
 }
@@ -187,16 +286,32 @@ type textEncoder struct {
 	eseq         escSeq
 	jsonTEF      TypeEncoderFactory
 	fieldSepDone bool
+	fieldSepEnd  int
 	groupDepth   int
 	groupPrefix  string
+
+	// ReplaceField state: open group names, built-ins replaced with
+	// regular fields, and whether Bags must be encoded without their
+	// cache.
+	groups     []string
+	extra      []Field
+	noBagCache bool
+
+	// Limits state: the number of values truncated or dropped and
+	// whether the entry is full.
+	truncations int
+	full        bool
 }
 
 func (f *textEncoder) Clone() Encoder {
+	// Each clone gets its own JSON encoder for nested values, as it
+	// keeps the state of the value being written.
 	return &textEncoder{
 		TextEncoderConfig: f.TextEncoderConfig,
 		slot:              f.slot,
 		pool:              f.pool,
 		eseq:              f.eseq,
+		jsonTEF:           f.jsonTEF.(Encoder).Clone().(TypeEncoderFactory),
 	}
 }
 
@@ -210,6 +325,8 @@ func (f *textEncoder) Encode(e Entry) (*Buffer, error) {
 	clone.groupPrefix = ""
 	clone.groupDepth = 0
 	clone.fieldSepDone = false
+	clone.groups = clone.groups[:0]
+	clone.extra = clone.extra[:0]
 	f.pool.Put(clone)
 
 	if err != nil {
@@ -222,6 +339,12 @@ func (f *textEncoder) Encode(e Entry) (*Buffer, error) {
 func (f *textEncoder) encode(buf *Buffer, e Entry) error {
 	f.buf = buf
 	f.startBufLen = buf.Len()
+	f.truncations = 0
+	f.full = false
+
+	if f.ReplaceField != nil {
+		e = f.replaceBuiltins(e)
+	}
 
 	// Time.
 	if !f.DisableFieldTime && !e.Time.IsZero() {
@@ -240,7 +363,7 @@ func (f *textEncoder) encode(buf *Buffer, e Entry) error {
 	if !f.DisableFieldName && e.LoggerName != "" {
 		f.appendSeparator()
 		f.eseq.dimItalic(f.buf, func() {
-			f.buf.AppendString(e.LoggerName)
+			f.appendText(e.LoggerName, false)
 			f.buf.AppendByte(':')
 		})
 	}
@@ -251,11 +374,11 @@ func (f *textEncoder) encode(buf *Buffer, e Entry) error {
 		mc := msgColor(e.Level)
 		if mc == escDefault {
 			f.eseq.at(f.buf, escBold, func() {
-				f.buf.AppendString(e.Text)
+				f.appendMessage(e.Text)
 			})
 		} else {
 			f.eseq.at2(f.buf, escBold, mc, func() {
-				f.buf.AppendString(e.Text)
+				f.appendMessage(e.Text)
 			})
 		}
 	}
@@ -273,15 +396,29 @@ func (f *textEncoder) encode(buf *Buffer, e Entry) error {
 		}
 	}
 
+	// Built-ins that ReplaceField turned into regular fields.
+	for i := range f.extra {
+		f.extra[i].Accept(f)
+	}
+
 	// Context fields.
 	f.encodeBag(ctxBag)
 
-	// Logger's fields.
+	// Logger's fields. Their cached keys lack the prefix of groups left
+	// open by the context fields.
+	f.noBagCache = f.groupPrefix != ""
 	f.encodeBag(loggerBag)
+	f.noBagCache = false
 
 	// Entry's fields.
 	for i := range e.Fields {
-		e.Fields[i].Accept(f)
+		f.acceptField(e.Fields[i])
+	}
+
+	if f.truncations != 0 {
+		f.groupPrefix = ""
+		f.addKey(f.Limits.FieldKeyTruncated)
+		f.EncodeTypeBool(true)
 	}
 
 	// Caller — at the very end, after all fields.
@@ -303,31 +440,133 @@ func (f *textEncoder) encodeBag(bag *Bag) {
 	}
 	if bag.group != "" {
 		f.encodeBag(bag.parent)
-		// Push group prefix for nested fields.
-		f.groupPrefix += bag.group + "."
-		f.groupDepth++
+		f.pushGroup(bag.group)
 		return
 	}
 
-	// Field node: use cache.
-	if data := bag.LoadCache(f.slot); data != nil {
+	// Field node: use cache. The › separator is never cached, as the
+	// Bag may come first in one entry and after other fields in another.
+	if f.noBagCache {
+		f.encodeBag(bag.parent)
+		for _, field := range bag.fields {
+			f.acceptField(field)
+		}
+		return
+	}
+	if data := bag.LoadCache(f.slot); data != nil && f.fits(len(data)) {
+		f.pushBagGroups(bag.parent)
+		if len(data) != 0 {
+			f.appendFieldSep()
+		}
 		f.buf.AppendBytes(data)
 		return
 	}
 
 	start := f.buf.Len()
+	sepDone := f.fieldSepDone
+	truncations := f.truncations
 	f.encodeBag(bag.parent)
 	for _, field := range bag.fields {
-		field.Accept(f)
+		f.acceptField(field)
+	}
+	if !sepDone && f.fieldSepDone {
+		start = f.fieldSepEnd
 	}
 
-	if f.slot != 0 {
+	// Bags cut to the limits of this entry are not cached.
+	if f.slot != 0 && f.truncations == truncations {
 		encoded := make([]byte, f.buf.Len()-start)
 		copy(encoded, f.buf.Data[start:])
 		bag.StoreCache(f.slot, encoded)
 	}
 }
 
+// pushGroup pushes a group prefix for nested fields.
+func (f *textEncoder) pushGroup(name string) {
+	f.groupPrefix += name + "."
+	f.groupDepth++
+	if f.ReplaceField != nil {
+		f.groups = append(f.groups, name)
+	}
+}
+
+// pushBagGroups pushes the groups of a Bag chain, as encodeBag does, for
+// a Bag served from the cache.
+func (f *textEncoder) pushBagGroups(bag *Bag) {
+	if bag == nil {
+		return
+	}
+	f.pushBagGroups(bag.parent)
+	if bag.group != "" {
+		f.pushGroup(bag.group)
+	}
+}
+
+// acceptField encodes a field, passing it through ReplaceField first.
+func (f *textEncoder) acceptField(fd Field) {
+	if f.ReplaceField != nil && fd.Type != FieldTypeGroup {
+		fd = f.ReplaceField(f.groups, fd)
+	}
+	if f.Limits.MaxEntrySize > 0 {
+		if f.full {
+			return
+		}
+		start := f.buf.Len()
+		fd.Accept(f)
+		f.dropOverflow(start)
+		return
+	}
+	fd.Accept(f)
+}
+
+// replaceBuiltins passes the built-in fields of e through ReplaceField.
+// Dropped ones are cleared, and ones that are no longer built-ins are
+// kept in f.extra to be written as regular fields.
+func (f *textEncoder) replaceBuiltins(e Entry) Entry {
+	replace := func(fd Field) (Field, bool) {
+		r, ok := replaceBuiltin(f.ReplaceField, fd)
+		if !ok && r.Type != FieldTypeUnknown {
+			f.extra = append(f.extra, r)
+		}
+		return r, ok
+	}
+
+	if !f.DisableFieldTime && !e.Time.IsZero() {
+		r, ok := replace(Time(DefaultFieldKeyTime, e.Time))
+		e.Time = time.Time{}
+		if ok {
+			e.Time = fieldTime(r)
+		}
+	}
+	if !f.DisableFieldLevel {
+		if r, ok := replace(levelField(DefaultFieldKeyLevel, e.Level)); ok {
+			e.Level = r.Any.(Level)
+		}
+	}
+	if !f.DisableFieldName && e.LoggerName != "" {
+		r, ok := replace(String(DefaultFieldKeyName, e.LoggerName))
+		e.LoggerName = ""
+		if ok {
+			e.LoggerName = fieldString(r)
+		}
+	}
+	if !f.DisableFieldMsg && e.Text != "" {
+		r, ok := replace(String(DefaultFieldKeyMsg, e.Text))
+		e.Text = ""
+		if ok {
+			e.Text = fieldString(r)
+		}
+	}
+	if !f.DisableFieldCaller && e.CallerPC != 0 {
+		r, ok := replace(callerField(DefaultFieldKeyCaller, e.CallerPC))
+		e.CallerPC = 0
+		if ok {
+			e.CallerPC = r.Any.(uintptr)
+		}
+	}
+	return e
+}
+
 // --- TypeEncoder ---
 
 func (f *textEncoder) TypeEncoder(buf *Buffer) TypeEncoder {
@@ -337,7 +576,9 @@ func (f *textEncoder) TypeEncoder(buf *Buffer) TypeEncoder {
 }
 
 func (f *textEncoder) EncodeTypeAny(v interface{}) {
-	f.jsonTypeEncoder().EncodeTypeAny(v)
+	je := f.jsonTypeEncoder()
+	je.EncodeTypeAny(v)
+	f.jsonDone(je)
 }
 
 func (f *textEncoder) EncodeTypeBool(v bool) {
@@ -382,6 +623,12 @@ func (f *textEncoder) EncodeTypeTime(v time.Time) {
 }
 
 func (f *textEncoder) EncodeTypeString(v string) {
+	n, room := f.Limits.limit(f.Limits.MaxStringLength, f.buf.Len()-f.startBufLen)
+	cut := n >= 0 && len(v) > n
+	if cut {
+		v = truncate(v, n)
+	}
+
 	// Quote if contains spaces or special chars.
 	needsQuote := false
 	for i := 0; i < len(v); i++ {
@@ -390,77 +637,124 @@ func (f *textEncoder) EncodeTypeString(v string) {
 			break
 		}
 	}
-	if needsQuote {
+	switch {
+	case f.DisableEscaping && needsQuote:
 		f.buf.AppendByte('"')
 		_ = EscapeString(f.buf, v)
-		f.buf.AppendByte('"')
-	} else {
+	case f.DisableEscaping:
 		f.buf.AppendString(v)
+	case needsQuote:
+		f.buf.AppendByte('"')
+		appendTextEscaped(f.buf, v, true, f.Multiline)
+	default:
+		appendTextEscaped(f.buf, v, false, false)
+	}
+	if cut {
+		f.appendMarker(room)
+	}
+	if needsQuote {
+		f.buf.AppendByte('"')
 	}
 }
 
 func (f *textEncoder) EncodeTypeStrings(v []string) {
 	f.buf.AppendByte('[')
-	for i, s := range v {
+	for i, n := 0, f.arrayLen(len(v)); i < n && !f.full; i++ {
+		start := f.buf.Len()
 		if i > 0 {
 			f.buf.AppendByte(',')
 		}
-		f.EncodeTypeString(s)
+		f.EncodeTypeString(v[i])
+		f.dropOverflow(start)
 	}
 	f.buf.AppendByte(']')
 }
 
 func (f *textEncoder) EncodeTypeBytes(v []byte) {
+	n, room := f.Limits.limit(f.Limits.MaxStringLength, f.buf.Len()-f.startBufLen)
+	if room {
+		n = n / 4 * 3
+	}
+	cut := n >= 0 && len(v) > n
+	if cut {
+		v = v[:n]
+	}
 	f.buf.AppendByte('"')
 	base64.StdEncoding.Encode(f.buf.ExtendBytes(base64.StdEncoding.EncodedLen(len(v))), v)
+	if cut {
+		f.appendMarker(room)
+	}
 	f.buf.AppendByte('"')
 }
 
 func (f *textEncoder) EncodeTypeInts64(v []int64) {
 	f.buf.AppendByte('[')
-	for i, n := range v {
+	for i, n := 0, f.arrayLen(len(v)); i < n && !f.full; i++ {
+		start := f.buf.Len()
 		if i > 0 {
 			f.buf.AppendByte(',')
 		}
-		f.buf.AppendInt(n)
+		f.buf.AppendInt(v[i])
+		f.dropOverflow(start)
 	}
 	f.buf.AppendByte(']')
 }
 
 func (f *textEncoder) EncodeTypeFloats64(v []float64) {
 	f.buf.AppendByte('[')
-	for i, n := range v {
+	for i, n := 0, f.arrayLen(len(v)); i < n && !f.full; i++ {
+		start := f.buf.Len()
 		if i > 0 {
 			f.buf.AppendByte(',')
 		}
-		f.EncodeTypeFloat64(n)
+		f.EncodeTypeFloat64(v[i])
+		f.dropOverflow(start)
 	}
 	f.buf.AppendByte(']')
 }
 
 func (f *textEncoder) EncodeTypeDurations(v []time.Duration) {
 	f.buf.AppendByte('[')
-	for i, d := range v {
+	for i, n := 0, f.arrayLen(len(v)); i < n && !f.full; i++ {
+		start := f.buf.Len()
 		if i > 0 {
 			f.buf.AppendByte(',')
 		}
-		f.EncodeTypeDuration(d)
+		f.EncodeTypeDuration(v[i])
+		f.dropOverflow(start)
 	}
 	f.buf.AppendByte(']')
 }
 
 func (f *textEncoder) EncodeTypeArray(v ArrayEncoder) {
-	f.jsonTypeEncoder().EncodeTypeArray(v)
+	je := f.jsonTypeEncoder()
+	je.EncodeTypeArray(v)
+	f.jsonDone(je)
 }
 
 func (f *textEncoder) EncodeTypeObject(v ObjectEncoder) {
-	f.jsonTypeEncoder().EncodeTypeObject(v)
+	je := f.jsonTypeEncoder()
+	je.EncodeTypeObject(v)
+	f.jsonDone(je)
 }
 
 // jsonTypeEncoder returns a JSON TypeEncoder writing to f.buf.
 // Used for nested objects/arrays where JSON is more readable than key=value.
-func (f *textEncoder) jsonTypeEncoder() TypeEncoder {
-	return f.jsonTEF.TypeEncoder(f.buf)
+// With MaxEntrySize set, it is limited to the room left in the entry.
+func (f *textEncoder) jsonTypeEncoder() *jsonEncoder {
+	je := f.jsonTEF.TypeEncoder(f.buf).(*jsonEncoder)
+	if f.Limits.MaxEntrySize > 0 {
+		je.Limits.MaxEntrySize = max(f.Limits.MaxEntrySize-(f.buf.Len()-f.startBufLen), 1)
+	}
+	je.truncations = 0
+	je.full = false
+	return je
+}
+
+// jsonDone takes over what the JSON TypeEncoder truncated.
+func (f *textEncoder) jsonDone(je *jsonEncoder) {
+	f.truncations += je.truncations
+	f.full = f.full || je.full
 }
 
 func (f *textEncoder) EncodeTypeUnsafeBytes(v unsafe.Pointer) {
@@ -470,20 +764,13 @@ func (f *textEncoder) EncodeTypeUnsafeBytes(v unsafe.Pointer) {
 // --- FieldEncoder ---
 
 func (f *textEncoder) addKey(k string) {
-	if !f.fieldSepDone {
-		// First field — emit › separator.
-		f.fieldSepDone = true
-		f.appendSeparator()
-		f.eseq.dim(f.buf, func() {
-			f.buf.AppendString("›")
-		})
-	}
+	f.appendFieldSep()
 	f.appendSeparator()
 	f.eseq.at2(f.buf, escBrightBlue, escItalic, func() {
 		if f.groupPrefix != "" {
-			f.buf.AppendString(f.groupPrefix)
+			f.appendText(f.groupPrefix, false)
 		}
-		f.buf.AppendString(k)
+		f.appendText(k, false)
 	})
 	f.eseq.dim(f.buf, func() {
 		f.buf.AppendByte('=')
@@ -526,15 +813,21 @@ func (f *textEncoder) EncodeFieldObject(k string, v ObjectEncoder) {
 func (f *textEncoder) EncodeFieldGroup(k string, fs []Field) {
 	if k == "" {
 		for _, field := range fs {
-			field.Accept(f)
+			f.acceptField(field)
 		}
 		return
 	}
 	// Push group prefix, encode fields, pop.
 	saved := f.groupPrefix
 	f.groupPrefix += k + "."
+	if f.ReplaceField != nil {
+		f.groups = append(f.groups, k)
+	}
 	for _, field := range fs {
-		field.Accept(f)
+		f.acceptField(field)
+	}
+	if f.ReplaceField != nil {
+		f.groups = f.groups[:len(f.groups)-1]
 	}
 	f.groupPrefix = saved
 }
@@ -545,6 +838,143 @@ func (f *textEncoder) EncodeFieldError(k string, v error) {
 
 // --- helpers ---
 
+// appendText appends the message, the logger name or a key, escaped
+// unless escaping is disabled.
+func (f *textEncoder) appendText(s string, multiline bool) {
+	if f.DisableEscaping {
+		f.buf.AppendString(s)
+		return
+	}
+	appendTextEscaped(f.buf, s, false, multiline)
+}
+
+// textContinuation starts a continuation line in multiline mode.
+const textContinuation = "\n  | "
+
+// appendTextEscaped appends s with control characters, DEL, C1 controls
+// and line and paragraph separators escaped as \n, \r, \t or \uXXXX, and
+// invalid UTF-8 replaced with \ufffd, as the JSON encoder does. Quoted
+// strings also escape '"' and '\'. In multiline mode newlines start
+// continuation lines.
+func appendTextEscaped(buf *Buffer, s string, quoted, multiline bool) {
+	p := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != 0x7f && (!quoted || c != '"' && c != '\\') {
+				i++
+				continue
+			}
+			buf.AppendString(s[p:i])
+			switch c {
+			case '\n':
+				if multiline {
+					buf.AppendString(textContinuation)
+				} else {
+					buf.AppendString(`\n`)
+				}
+			case '\r':
+				buf.AppendString(`\r`)
+			case '\t':
+				buf.AppendString(`\t`)
+			case '"', '\\':
+				buf.AppendByte('\\')
+				buf.AppendByte(c)
+			default:
+				buf.AppendString(`\u00`)
+				buf.AppendByte(hex[c>>4])
+				buf.AppendByte(hex[c&0xf])
+			}
+			i++
+			p = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf.AppendString(s[p:i])
+			buf.AppendString(`\ufffd`)
+		case r <= 0x9f || r == '\u2028' || r == '\u2029':
+			buf.AppendString(s[p:i])
+			buf.AppendString(`\u`)
+			buf.AppendByte(hex[r>>12&0xf])
+			buf.AppendByte(hex[r>>8&0xf])
+			buf.AppendByte(hex[r>>4&0xf])
+			buf.AppendByte(hex[r&0xf])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		p = i
+	}
+	buf.AppendString(s[p:])
+}
+
+// appendMessage appends the message, cut to MaxMessageLength and to the
+// room left in the entry.
+func (f *textEncoder) appendMessage(s string) {
+	n, room := f.Limits.limit(f.Limits.MaxMessageLength, f.buf.Len()-f.startBufLen)
+	if n < 0 || len(s) <= n {
+		f.appendText(s, f.Multiline)
+		return
+	}
+	f.appendText(truncate(s, n), f.Multiline)
+	f.appendMarker(room)
+}
+
+// appendMarker ends a truncated value. Values cut to the room left fill
+// the entry.
+func (f *textEncoder) appendMarker(room bool) {
+	f.buf.AppendString(f.Limits.Marker)
+	f.truncations++
+	if room {
+		f.full = true
+	}
+}
+
+// fits reports whether n more bytes fit in the entry.
+func (f *textEncoder) fits(n int) bool {
+	return f.Limits.MaxEntrySize <= 0 || f.buf.Len()-f.startBufLen+n <= f.Limits.MaxEntrySize
+}
+
+// arrayLen returns how many of n array elements to write.
+func (f *textEncoder) arrayLen(n int) int {
+	if max := f.Limits.MaxArrayLength; max > 0 && n > max {
+		f.truncations++
+		return max
+	}
+	return n
+}
+
+// dropOverflow drops what was written from start if it took the entry
+// past MaxEntrySize, and marks the entry full.
+func (f *textEncoder) dropOverflow(start int) {
+	if f.Limits.MaxEntrySize <= 0 || f.full || f.buf.Len()-f.startBufLen <= f.Limits.MaxEntrySize {
+		return
+	}
+	f.buf.Truncate(start)
+	if f.fieldSepEnd > start {
+		f.fieldSepDone = false
+	}
+	f.full = true
+	f.truncations++
+}
+
+// appendFieldSep emits the › separator before the first field.
+func (f *textEncoder) appendFieldSep() {
+	if f.fieldSepDone {
+		return
+	}
+	f.fieldSepDone = true
+	f.appendSeparator()
+	f.eseq.dim(f.buf, func() {
+		f.buf.AppendString("›")
+	})
+	f.fieldSepEnd = f.buf.Len()
+}
+
 func (f *textEncoder) appendSeparator() {
 	if f.buf.Len() == f.startBufLen {
 		return
@@ -1,6 +1,7 @@
 package logf
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -452,3 +453,236 @@ func TestTextEncoderBuilderCustomEncoders(t *testing.T) {
 	assert.Contains(t, got, "CUSTOM_CALLER")
 	assert.Contains(t, got, "CUSTOM_ERR:oops")
 }
+
+func TestTextEncoderEscaping(t *testing.T) {
+	testCases := []encoderTestCase{
+		{
+			"ForgedLine",
+			Entry{Level: LevelInfo, Text: "ok\nMar  2 09:10:34.000 [ERR] forged"},
+			"[INF] ok\\nMar  2 09:10:34.000 [ERR] forged\n",
+		},
+		{
+			"ControlChars",
+			Entry{Level: LevelInfo, Text: "\x1b[31mred\r\t\x00\x7f"},
+			"[INF] \\u001b[31mred\\r\\t\\u0000\\u007f\n",
+		},
+		{
+			"UnicodeControls",
+			Entry{Level: LevelInfo, Text: "a\u009b31mb\u2028c\u2029d"},
+			"[INF] a\\u009b31mb\\u2028c\\u2029d\n",
+		},
+		{
+			"InvalidUTF8",
+			Entry{Level: LevelInfo, Text: "a\xffb\xc2"},
+			"[INF] a\\ufffdb\\ufffd\n",
+		},
+		{
+			"PrintableUnicode",
+			Entry{Level: LevelInfo, Text: `héllo ✓ "quoted" C:\dir`},
+			"[INF] héllo ✓ \"quoted\" C:\\dir\n",
+		},
+		{
+			"Name",
+			Entry{Level: LevelInfo, LoggerName: "api\n[ERR]", Text: "m"},
+			"[INF] api\\n[ERR]: m\n",
+		},
+		{
+			"Keys",
+			Entry{Level: LevelInfo, Fields: []Field{
+				String("k\x1b", "v"), Group("g\n", String("k", "v")),
+			}},
+			"[INF] › k\\u001b=v g\\n.k=v\n",
+		},
+		{
+			"UnquotedValues",
+			Entry{Level: LevelInfo, Fields: []Field{
+				String("a", "x\u009by"), String("b", "x\xffy"), Strings("c", []string{"\u2028"}),
+			}},
+			"[INF] › a=x\\u009by b=x\\ufffdy c=[\\u2028]\n",
+		},
+		{
+			"QuotedValues",
+			Entry{Level: LevelInfo, Fields: []Field{
+				String("a", "x y\n\x1b\u009b"), String("b", `"q" \`),
+			}},
+			"[INF] › a=\"x y\\n\\u001b\\u009b\" b=\"\\\"q\\\" \\\\\"\n",
+		},
+	}
+
+	enc := Text().NoColor().Build()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden, b.String())
+			b.Free()
+		})
+	}
+
+	t.Run("Colors", func(t *testing.T) {
+		b, err := Text().Build().Encode(Entry{Level: LevelWarn, Text: "\x1b[0mreset"})
+		require.NoError(t, err)
+		assert.Contains(t, b.String(), "\\u001b[0mreset")
+		assert.NotContains(t, b.String(), "\x1b[0mreset")
+		b.Free()
+	})
+}
+
+func TestTextEncoderMultiline(t *testing.T) {
+	enc := Text().NoColor().Multiline().Build()
+	b, err := enc.Encode(Entry{Level: LevelError, LoggerName: "a\nb", Text: "failed\nretrying\r", Fields: []Field{
+		String("stack", "main.go:1\nmain.go:2"), String("k\n", "v"),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "[ERR] a\\nb: failed\n  | retrying\\r › stack=\"main.go:1\n  | main.go:2\" k\\n=v\n", b.String())
+	b.Free()
+}
+
+func TestTextEncoderDisableEscaping(t *testing.T) {
+	enc := Text().NoColor().DisableEscaping().Multiline().Build()
+	b, err := enc.Encode(Entry{Level: LevelInfo, LoggerName: "n\x1b", Text: "a\nb", Fields: []Field{
+		String("k\n", "x\u009by"), String("q", "x y\n"),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "[INF] n\x1b: a\nb › k\n=x\u009by q=\"x y\\n\"\n", b.String())
+	b.Free()
+
+	enc = NewTextEncoder(TextEncoderConfig{NoColor: true, DisableEscaping: true})
+	b, err = enc.Encode(Entry{Level: LevelInfo, Text: "a\nb"})
+	require.NoError(t, err)
+	assert.Equal(t, "[INF] a\nb\n", b.String())
+	b.Free()
+}
+
+func TestTextEncoderBagCacheGroups(t *testing.T) {
+	// Cached Bags keep the prefix of their groups and never cache the ›
+	// separator.
+	enc := withCacheSlot(Text().NoColor().DisableTime().Build())
+	bag := NewBag(String("service", "api")).WithGroup("http").With(String("method", "GET"))
+	entries := []Entry{
+		{Level: LevelInfo, Text: "m", LoggerBag: bag, Fields: []Field{Int("status", 200)}},
+		{Level: LevelInfo, Text: "m", Bag: NewBag(Int("a", 1)), LoggerBag: bag, Fields: []Field{Int("status", 200)}},
+		{Level: LevelInfo, Text: "m", Bag: NewBag(Int("a", 1)).WithGroup("ctx"), LoggerBag: bag},
+	}
+	golden := []string{
+		"[INF] m › service=api http.method=GET http.status=200\n",
+		"[INF] m › a=1 service=api http.method=GET http.status=200\n",
+		"[INF] m › a=1 ctx.service=api ctx.http.method=GET\n",
+	}
+	for i := 0; i < 2; i++ {
+		for j, e := range entries {
+			b, err := enc.Encode(e)
+			require.NoError(t, err)
+			assert.Equal(t, golden[j], b.String())
+			b.Free()
+		}
+	}
+}
+
+func TestTextEncoderLimits(t *testing.T) {
+	testCases := []struct {
+		name   string
+		limits SizeLimits
+		entry  Entry
+		golden string
+	}{
+		{
+			"Message",
+			SizeLimits{MaxMessageLength: 4},
+			Entry{Level: LevelInfo, Text: "message", Fields: []Field{String("s", "value")}},
+			"[INF] mess… › s=value _truncated=true\n",
+		},
+		{
+			"Strings",
+			SizeLimits{MaxStringLength: 3},
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				String("s", "value"), String("q", "a b c"), Bytes("b", []byte("abcdef")), Strings("ss", []string{"abcdef"}),
+			}},
+			"[INF] m › s=val… q=\"a b…\" b=\"YWJj…\" ss=[abc…] _truncated=true\n",
+		},
+		{
+			"AnySummary",
+			SizeLimits{MaxStringLength: 32},
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Any("body", map[string]string{"b": strings.Repeat("x", 8<<20)}),
+			}},
+			"[INF] m › body=\"map[string]string len=1…\" _truncated=true\n",
+		},
+		{
+			"Arrays",
+			SizeLimits{MaxArrayLength: 2},
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Ints64("i", []int64{1, 2, 3}), Array("a", testCountArray(5)),
+			}},
+			"[INF] m › i=[1,2] a=[0,1] _truncated=true\n",
+		},
+		{
+			"Depth",
+			SizeLimits{MaxDepth: 1},
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Group("g", Object("o", testDeepObject(1))),
+			}},
+			"[INF] m › g.o={\"o\":\"…\"} _truncated=true\n",
+		},
+		{
+			"Entry",
+			SizeLimits{MaxEntrySize: 24},
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{
+				Int("a", 1), String("s", strings.Repeat("x", 100)), Int("b", 2),
+			}},
+			"[INF] m › a=1 s=xxxxxx… _truncated=true\n",
+		},
+		{
+			"EntryDropsFields",
+			SizeLimits{MaxEntrySize: 16},
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{Int("a", 1), Int("bbbbbbbbbb", 2)}},
+			"[INF] m › a=1 _truncated=true\n",
+		},
+		{
+			"EntryDropsFirstField",
+			SizeLimits{MaxEntrySize: 10},
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{Int("bbbbbbbbbb", 2)}},
+			"[INF] m › _truncated=true\n",
+		},
+		{
+			"EntryCutsObjects",
+			SizeLimits{MaxEntrySize: 24},
+			Entry{Level: LevelInfo, Text: "m", Fields: []Field{Array("a", testCountArray(100))}},
+			"[INF] m › a=[0,1,2,3,4] _truncated=true\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc := Text().NoColor().DisableTime().Limits(tc.limits).Build()
+			b, err := enc.Encode(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.golden, b.String())
+			b.Free()
+		})
+	}
+}
+
+func TestTextEncoderLimitsBag(t *testing.T) {
+	enc := withCacheSlot(Text().NoColor().DisableTime().Limits(SizeLimits{MaxStringLength: 4}).Build())
+	e := Entry{
+		Level:     LevelInfo,
+		Text:      "m",
+		LoggerBag: NewBag(String("service", "billing")).WithGroup("http"),
+		Fields:    []Field{Int("status", 200)},
+	}
+	// The second run must not take a truncated Bag from the cache.
+	for i := 0; i < 2; i++ {
+		b, err := enc.Encode(e)
+		require.NoError(t, err)
+		assert.Equal(t, "[INF] m › service=bill… http.status=200 _truncated=true\n", b.String())
+		b.Free()
+	}
+
+	// Pretty mode ignores the limits.
+	enc = Text().Pretty().Width(100).NoColor().DisableTime().Limits(SizeLimits{MaxStringLength: 4}).Build()
+	b, err := enc.Encode(e)
+	require.NoError(t, err)
+	assert.Equal(t, "[INF] m                                        › service=billing http.status=200\n", b.String())
+	b.Free()
+}